@@ -4,10 +4,11 @@ import (
 	"net/http"
 
 	"github.com/amrohan/expenso-go/internal/handlers"
+	"github.com/amrohan/expenso-go/internal/models"
 	"github.com/go-chi/chi/v5"
 )
 
-func LoadRoutes(r chi.Router) {
+func LoadRoutes(r chi.Router, h *handlers.Handler) {
 	r.Route("/", func(r chi.Router) {
 		r.Get("/", func(w http.ResponseWriter, r *http.Request) {
 			w.Write([]byte("Hello World!"))
@@ -15,54 +16,157 @@ func LoadRoutes(r chi.Router) {
 		r.Get("/health", func(w http.ResponseWriter, r *http.Request) {
 			w.Write([]byte("OK"))
 		})
+		r.Get("/.well-known/jwks.json", h.JWKS)
 
-		r.Post("/login", handlers.LoginUser)
-		r.Post("/register", handlers.RegisterUser)
-		r.Post("/logout", handlers.LogoutUser)
+		r.Post("/login", h.LoginUser)
+		r.Post("/login/mfa", h.LoginMFA)
+		r.Post("/register", h.RegisterUser)
+		r.Post("/refresh", h.RefreshToken)
+		r.Post("/logout", h.LogoutUser)
+		r.Post("/password/forgot", h.ForgotPassword)
+		r.Post("/password/reset", h.ResetPassword)
+		r.Get("/verify", h.VerifyEmail)
+		r.Post("/verify/resend", h.ResendVerification)
+
+		r.Get("/oidc", h.GetOIDCProviders)
+		r.Get("/oidc/{provider}/login", h.OIDCLogin)
+		r.Get("/oidc/{provider}/callback", h.OIDCCallback)
+	})
+
+	r.With(h.AuthMiddleware).Route("/api/transaction", func(r chi.Router) {
+		r.With(handlers.RequireScope(models.ScopeReportsRead), h.EnforceVerification).Get("/u/{month}-{year}-{userId}", h.GetTransactionByMonthAndYearByUserId)
+
+		r.Group(func(r chi.Router) {
+			r.Use(handlers.RequireAccess(models.ScopeTransactionsRead, models.ScopeTransactionsWrite), h.EnforceVerification)
+			r.Post("/", h.CreateTransaction)
+			r.Post("/transfer", h.CreateTransfer)
+			r.Get("/transfer/{id}", h.GetTransfer)
+			r.Put("/transfer/{id}", h.UpdateTransfer)
+			r.With(handlers.RequireRole(models.RoleAdmin)).Get("/", h.GetAllTransaction)
+			r.Get("/trash", h.GetTransactionTrash)
+			r.Post("/{id}/restore", h.RestoreTransaction)
+			r.Get("/{id}", h.GetTransactionById)
+			r.Get("/{id}/history", h.GetTransactionHistory)
+			r.Post("/{id}/revert/{version}", h.RevertTransaction)
+			r.Post("/{id}/status", h.SetTransactionStatus)
+			r.Post("/{id}/unlock", h.UnlockTransaction)
+			r.Get("/{month}-{year}", h.GetTransactionByMonthAndYear)
+			r.Get("/user/{id}", h.GetTransactionByUserId)
+			r.Get("/category/{id}", h.GetTransactionByCategoryId)
+			r.Get("/account/{id}", h.GetTransactionByAccountId)
+			r.Put("/", h.UpdateTransaction)
+			r.Delete("/{id}", h.DeleteTransaction)
+		})
+	})
+
+	r.With(h.AuthMiddleware).Route("/api/recurring", func(r chi.Router) {
+		r.Use(handlers.RequireAccess(models.ScopeTransactionsRead, models.ScopeTransactionsWrite), h.EnforceVerification)
+		r.Post("/", h.CreateRecurringRule)
+		r.Get("/", h.GetRecurringRules)
+		r.Get("/{id}", h.GetRecurringRule)
+		r.Put("/{id}", h.UpdateRecurringRule)
+		r.Delete("/{id}", h.DeleteRecurringRule)
+		r.Get("/{id}/occurrences", h.GetRecurringOccurrences)
+		r.Put("/{id}/occurrences/{date}", h.SetRecurringOccurrence)
+		r.Delete("/{id}/occurrences/{date}", h.ResetRecurringOccurrence)
+	})
+
+	r.With(h.AuthMiddleware).Route("/api/budget", func(r chi.Router) {
+		r.Use(handlers.RequireAccess(models.ScopeBudgetsRead, models.ScopeBudgetsWrite), h.EnforceVerification)
+		r.Post("/", h.CreateBudget)
+		r.Get("/", h.GetBudgets)
+		r.Get("/month/{month}", h.GetBudgetMonth)
+		r.Post("/month/{month}/copy", h.CopyBudgets)
+		r.Put("/{id}", h.UpdateBudget)
+		r.Delete("/{id}", h.DeleteBudget)
 	})
 
-	r.With(handlers.AuthMiddleware).Route("/api/transaction", func(r chi.Router) {
-		r.Post("/", handlers.CreateTransaction)
-		r.Get("/", handlers.GetAllTransaction)
-		r.Get("/{id}", handlers.GetTransactionById)
-		r.Get("/{month}-{year}", handlers.GetTransactionByMonthAndYear)
-		r.Get("/u/{month}-{year}-{userId}", handlers.GetTransactionByMonthAndYearByUserId)
-		r.Get("/user/{id}", handlers.GetTransactionByUserId)
-		r.Get("/category/{id}", handlers.GetTransactionByCategoryId)
-		r.Get("/account/{id}", handlers.GetTransactionByAccountId)
-		r.Put("/", handlers.UpdateTransaction)
-		r.Delete("/{id}", handlers.DeleteTransaction)
+	r.With(h.AuthMiddleware).Route("/api/envelope", func(r chi.Router) {
+		r.Use(handlers.RequireAccess(models.ScopeBudgetsRead, models.ScopeBudgetsWrite), h.EnforceVerification)
+		r.Get("/month/{month}", h.GetEnvelopeMonth)
+		r.Post("/month/{month}/assign", h.AssignEnvelope)
+		r.Post("/month/{month}/move", h.MoveEnvelope)
+		r.Get("/moves", h.GetEnvelopeMoves)
+		r.Delete("/moves/{id}", h.DeleteEnvelopeMove)
 	})
 
-	r.With(handlers.AuthMiddleware).Route("/api/category", func(r chi.Router) {
-		r.Post("/", handlers.CreateCategory)
-		r.Get("/", handlers.GetAllCategory)
-		r.Get("/{id}", handlers.GetCategoryById)
-		r.Get("/user/{id}", handlers.GetCategoryByUserId)
-		r.Put("/", handlers.UpdateCategory)
-		r.Delete("/{id}", handlers.DeleteCategory)
+	r.With(h.AuthMiddleware).Route("/api/category", func(r chi.Router) {
+		r.Use(handlers.RequireAccess(models.ScopeCategoriesRead, models.ScopeCategoriesWrite), h.EnforceVerification)
+		r.Post("/", h.CreateCategory)
+		r.Get("/", h.GetAllCategory)
+		r.Get("/trash", h.GetCategoryTrash)
+		r.Post("/{id}/restore", h.RestoreCategory)
+		r.Get("/{id}", h.GetCategoryById)
+		r.Get("/user/{id}", h.GetCategoryByUserId)
+		r.Put("/", h.UpdateCategory)
+		r.Delete("/{id}", h.DeleteCategory)
 	})
 
-	r.With(handlers.AuthMiddleware).Route("/api/account", func(r chi.Router) {
-		r.Post("/", handlers.CreateAccount)
-		r.Get("/", handlers.GetAllAccount)
-		r.Get("/{id}", handlers.GetAccountById)
-		r.Get("/user/{id}", handlers.GetAccountsByUserId)
-		r.Put("/", handlers.UpdateAccount)
-		r.Delete("/{id}", handlers.DeleteAccount)
+	r.With(h.AuthMiddleware).Route("/api/account", func(r chi.Router) {
+		r.Use(handlers.RequireAccess(models.ScopeAccountsRead, models.ScopeAccountsWrite), h.EnforceVerification)
+		r.Post("/", h.CreateAccount)
+		r.Get("/", h.GetAllAccount)
+		r.Get("/trash", h.GetAccountTrash)
+		r.Post("/{id}/restore", h.RestoreAccount)
+		r.Get("/{id}", h.GetAccountById)
+		r.Get("/{id}/balance", h.GetAccountBalance)
+		r.Get("/{id}/balance/history", h.GetAccountBalanceHistory)
+		r.Post("/{id}/reconciliations", h.StartReconciliation)
+		r.Get("/{id}/reconciliations", h.GetReconciliations)
+		r.Get("/{id}/reconciliations/{reconciliationId}", h.GetReconciliation)
+		r.Put("/{id}/reconciliations/{reconciliationId}", h.UpdateReconciliation)
+		r.Post("/{id}/reconciliations/{reconciliationId}/finish", h.FinishReconciliation)
+		r.Delete("/{id}/reconciliations/{reconciliationId}", h.CancelReconciliation)
+		r.Get("/user/{id}", h.GetAccountsByUserId)
+		r.Put("/", h.UpdateAccount)
+		r.Delete("/{id}", h.DeleteAccount)
+	})
+
+	r.With(h.AuthMiddleware).Route("/api/user", func(r chi.Router) {
+		r.Group(func(r chi.Router) {
+			r.Use(handlers.RequireSession)
+			r.Get("/sessions", h.GetSessions)
+			r.Delete("/sessions/{id}", h.RevokeSession)
+			r.Post("/apikeys", h.CreateAPIKey)
+			r.Get("/apikeys", h.GetAPIKeys)
+			r.Delete("/apikeys/{id}", h.RevokeAPIKey)
+			r.Post("/mfa/enroll", h.EnrollMFA)
+			r.Post("/mfa/confirm", h.ConfirmMFA)
+			r.Post("/mfa/recovery-codes", h.RegenerateRecoveryCodes)
+			r.Post("/mfa/disable", h.DisableMFA)
+			r.Get("/audit", h.GetMyAuditEvents)
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(handlers.RequireAccess(models.ScopeProfileRead, models.ScopeProfileWrite))
+			r.With(handlers.RequireRole(models.RoleAdmin)).Post("/", h.CreateUser)
+			r.With(handlers.RequireRole(models.RoleAdmin)).Get("/", h.GetAllUsers)
+			r.Get("/{id}", h.GetUserById)
+			r.Get("/email/{email}", h.GetUserByEmail)
+			r.Get("/username/{username}", h.GetUserByUsername)
+			r.Get("/u/{username}", h.CheckUsername)
+			r.With(handlers.RequireRole(models.RoleAdmin)).Get("/du/", h.GetAllDeletedUser)
+			r.With(handlers.RequireRole(models.RoleAdmin)).Post("/du/{id}", h.RestoreUser)
+			r.Put("/", h.UpdateUser)
+			r.Delete("/{id}", h.DeleteUser)
+		})
+	})
+
+	r.With(h.AuthMiddleware).Route("/api/rates", func(r chi.Router) {
+		r.With(handlers.RequireScope(models.ScopeReportsRead)).Get("/", h.GetExchangeRates)
+		r.With(handlers.RequireScope(models.ScopeReportsRead)).Get("/convert", h.ConvertAmount)
+
+		r.Group(func(r chi.Router) {
+			r.Use(handlers.RequireSession, handlers.RequireRole(models.RoleAdmin))
+			r.Post("/", h.CreateExchangeRate)
+			r.Post("/import", h.ImportExchangeRates)
+			r.Delete("/{id}", h.DeleteExchangeRate)
+		})
 	})
 
-	r.With(handlers.AuthMiddleware).Route("/api/user", func(r chi.Router) {
-		r.Post("/", handlers.CreateUser)
-		r.Get("/", handlers.GetAllUsers)
-		r.Get("/{id}", handlers.GetUserById)
-		r.Get("/email/{email}", handlers.GetUserByEmail)
-		r.Get("/username/{username}", handlers.GetUserByUsername)
-		r.Get("/u/{username}", handlers.CheckUsername)
-		r.Get("/du/", handlers.GetAllDeletedUser)
-		r.Post("/du/{id}", handlers.RestoreUser)
-		r.Put("/", handlers.UpdateUser)
-		r.Delete("/{id}", handlers.DeleteUser)
+	r.With(h.AuthMiddleware).Route("/api/audit", func(r chi.Router) {
+		r.Use(handlers.RequireSession, handlers.RequireRole(models.RoleAdmin))
+		r.Get("/", h.GetAuditEvents)
 	})
 
 }
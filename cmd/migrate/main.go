@@ -0,0 +1,193 @@
+// Command migrate upgrades documents written by older versions of the API.
+// Every step is idempotent, so it is safe to run again after a partial run.
+//
+//	go run ./cmd/migrate -currency INR -unit major
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/amrohan/expenso-go/internal/db"
+	"github.com/amrohan/expenso-go/internal/models"
+	"github.com/amrohan/expenso-go/internal/money"
+	"github.com/joho/godotenv"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const batchSize = 500
+
+type options struct {
+	currency string
+	// unit says what legacy integer amounts meant: "major" for whole units
+	// (1050 is ₹1050) or "minor" (1050 is ₹10.50).
+	unit   string
+	dryRun bool
+}
+
+type migration struct {
+	name string
+	run  func(ctx context.Context, database *mongo.Database, opts options) (int, error)
+}
+
+var migrations = []migration{
+	{"transaction amounts to money", migrateTransactionAmounts},
+	{"transaction history amounts to money", migrateHistoryAmounts},
+	{"account currencies", migrateAccountCurrencies},
+	{"transaction statuses", migrateTransactionStatuses},
+}
+
+func main() {
+	godotenv.Load()
+
+	var opts options
+	flag.StringVar(&opts.currency, "currency", "INR", "ISO 4217 currency of legacy amounts")
+	flag.StringVar(&opts.unit, "unit", "major", `what legacy integer amounts count: "major" or "minor" units`)
+	flag.BoolVar(&opts.dryRun, "dry-run", false, "report what would change without writing")
+	flag.Parse()
+
+	currency, err := money.NormalizeCurrency(opts.currency)
+	if err != nil {
+		log.Fatalf("-currency: %v", err)
+	}
+	opts.currency = currency
+	if opts.unit != "major" && opts.unit != "minor" {
+		log.Fatal(`-unit must be "major" or "minor"`)
+	}
+
+	client, err := db.GetMongoClient()
+	if err != nil {
+		log.Fatal(err)
+	}
+	ctx := context.Background()
+	defer client.Disconnect(ctx)
+	database := client.Database(db.Database)
+
+	for _, m := range migrations {
+		n, err := m.run(ctx, database, opts)
+		if err != nil {
+			log.Fatalf("%s: %v", m.name, err)
+		}
+		verb := "migrated"
+		if opts.dryRun {
+			verb = "would migrate"
+		}
+		fmt.Printf("%s: %s %d documents\n", m.name, verb, n)
+	}
+}
+
+func migrateTransactionAmounts(ctx context.Context, database *mongo.Database, opts options) (int, error) {
+	return convertAmounts(ctx, database.Collection(string(db.TransactionCollection)), bson.M{}, "amount", opts)
+}
+
+func migrateHistoryAmounts(ctx context.Context, database *mongo.Database, opts options) (int, error) {
+	return convertAmounts(ctx, database.Collection(string(db.RevisionCollection)), bson.M{"entityType": "transaction"}, "snapshot.amount", opts)
+}
+
+// migrateAccountCurrencies gives accounts created before accounts had a
+// currency the legacy one.
+func migrateAccountCurrencies(ctx context.Context, database *mongo.Database, opts options) (int, error) {
+	return setMissing(ctx, database.Collection(string(db.AccountCollection)), "currency", opts.currency, opts)
+}
+
+// migrateTransactionStatuses marks transactions created before statuses
+// existed as pending.
+func migrateTransactionStatuses(ctx context.Context, database *mongo.Database, opts options) (int, error) {
+	return setMissing(ctx, database.Collection(string(db.TransactionCollection)), "status", models.StatusPending, opts)
+}
+
+// setMissing sets field to value wherever it is absent or empty.
+func setMissing(ctx context.Context, collection *mongo.Collection, field, value string, opts options) (int, error) {
+	filter := bson.M{"$or": bson.A{bson.M{field: bson.M{"$exists": false}}, bson.M{field: ""}}}
+	if opts.dryRun {
+		n, err := collection.CountDocuments(ctx, filter)
+		return int(n), err
+	}
+	res, err := collection.UpdateMany(ctx, filter, bson.M{"$set": bson.M{field: value}})
+	if err != nil {
+		return 0, err
+	}
+	return int(res.ModifiedCount), nil
+}
+
+// convertAmounts rewrites the plain number at field into a money document on
+// every document matching filter.
+func convertAmounts(ctx context.Context, collection *mongo.Collection, filter bson.M, field string, opts options) (int, error) {
+	filter[field] = bson.M{"$type": "number"}
+	cur, err := collection.Find(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	defer cur.Close(ctx)
+
+	var writes []mongo.WriteModel
+	flush := func() error {
+		if len(writes) == 0 || opts.dryRun {
+			writes = writes[:0]
+			return nil
+		}
+		_, err := collection.BulkWrite(ctx, writes)
+		writes = writes[:0]
+		return err
+	}
+
+	count := 0
+	for cur.Next(ctx) {
+		value, err := cur.Current.LookupErr(splitPath(field)...)
+		if err != nil {
+			return count, err
+		}
+		amount, err := legacyAmount(value, opts)
+		if err != nil {
+			return count, fmt.Errorf("document %v: %w", cur.Current.Lookup("_id"), err)
+		}
+
+		// Match the old value too so a concurrent edit is not overwritten.
+		writes = append(writes, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": cur.Current.Lookup("_id"), field: value}).
+			SetUpdate(bson.M{"$set": bson.M{field: amount}}))
+		count++
+		if len(writes) == batchSize {
+			if err := flush(); err != nil {
+				return count, err
+			}
+		}
+	}
+	if err := cur.Err(); err != nil {
+		return count, err
+	}
+	return count, flush()
+}
+
+func legacyAmount(value bson.RawValue, opts options) (money.Money, error) {
+	var decimal string
+	switch value.Type {
+	case bson.TypeInt32, bson.TypeInt64:
+		if opts.unit == "minor" {
+			return money.New(value.AsInt64(), opts.currency), nil
+		}
+		decimal = strconv.FormatInt(value.AsInt64(), 10)
+	case bson.TypeDouble:
+		f := value.Double()
+		if opts.unit == "minor" {
+			if math.Abs(f) >= math.MaxInt64 {
+				return money.Money{}, money.ErrOverflow
+			}
+			return money.New(int64(math.Round(f)), opts.currency), nil
+		}
+		decimal = strconv.FormatFloat(f, 'f', -1, 64)
+	default:
+		return money.Money{}, fmt.Errorf("unexpected amount type %s", value.Type)
+	}
+	return money.Parse(decimal, opts.currency)
+}
+
+func splitPath(field string) []string {
+	return strings.Split(field, ".")
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/amrohan/expenso-go/internal/auth"
+	"github.com/amrohan/expenso-go/internal/handlers"
+	"github.com/amrohan/expenso-go/internal/mailer"
+	"github.com/amrohan/expenso-go/internal/money"
+	"github.com/amrohan/expenso-go/internal/oidc"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// loadConfig builds the handler configuration from the environment.
+func loadConfig() (handlers.Config, error) {
+	keys, err := loadKeySet()
+	if err != nil {
+		return handlers.Config{}, err
+	}
+
+	accessTTL, err := durationEnv("ACCESS_TOKEN_TTL")
+	if err != nil {
+		return handlers.Config{}, err
+	}
+	refreshTTL, err := durationEnv("REFRESH_TOKEN_TTL")
+	if err != nil {
+		return handlers.Config{}, err
+	}
+
+	retention, err := durationEnv("TRASH_RETENTION")
+	if err != nil {
+		return handlers.Config{}, err
+	}
+
+	throttle, err := loadLoginThrottle()
+	if err != nil {
+		return handlers.Config{}, err
+	}
+
+	providers, err := loadOIDCProviders()
+	if err != nil {
+		return handlers.Config{}, err
+	}
+
+	graceDays, err := intEnv("UNVERIFIED_LOGIN_GRACE_DAYS")
+	if err != nil {
+		return handlers.Config{}, err
+	}
+
+	currency := os.Getenv("DEFAULT_CURRENCY")
+	if currency != "" {
+		if currency, err = money.NormalizeCurrency(currency); err != nil {
+			return handlers.Config{}, fmt.Errorf("DEFAULT_CURRENCY: %w", err)
+		}
+	}
+
+	return handlers.Config{
+		AdminEmails:     splitList(os.Getenv("ADMIN_EMAILS")),
+		Keys:            keys,
+		AccessTokenTTL:  accessTTL,
+		RefreshTokenTTL: refreshTTL,
+		Mailer:          loadMailer(),
+		AppURL:          os.Getenv("APP_URL"),
+		PublicURL:       os.Getenv("PUBLIC_URL"),
+		Unverified: handlers.UnverifiedPolicy{
+			ReadOnly:       os.Getenv("UNVERIFIED_READ_ONLY") == "true",
+			LoginGraceDays: graceDays,
+		},
+		LoginThrottle:   throttle,
+		OIDCProviders:   providers,
+		TrashRetention:  retention,
+		DefaultCurrency: currency,
+	}, nil
+}
+
+// loadLoginThrottle reads LOGIN_MAX_ACCOUNT_FAILURES, LOGIN_MAX_IP_FAILURES,
+// LOGIN_FAILURE_WINDOW, LOGIN_LOCKOUT and LOGIN_MAX_LOCKOUT. Unset values
+// keep the handler defaults.
+func loadLoginThrottle() (handlers.LoginThrottle, error) {
+	var t handlers.LoginThrottle
+	var err error
+	if t.AccountLimit, err = intEnv("LOGIN_MAX_ACCOUNT_FAILURES"); err != nil {
+		return t, err
+	}
+	if t.IPLimit, err = intEnv("LOGIN_MAX_IP_FAILURES"); err != nil {
+		return t, err
+	}
+	if t.Window, err = durationEnv("LOGIN_FAILURE_WINDOW"); err != nil {
+		return t, err
+	}
+	if t.Lockout, err = durationEnv("LOGIN_LOCKOUT"); err != nil {
+		return t, err
+	}
+	if t.MaxLockout, err = durationEnv("LOGIN_MAX_LOCKOUT"); err != nil {
+		return t, err
+	}
+	return t, nil
+}
+
+// loadKeySet reads the JWT keys. JWT_KEYS (or the file named by
+// JWT_KEYS_FILE) holds a JSON array of key specs, for example
+//
+//	[{"kid":"2024-02","alg":"HS256","secret":"..."},
+//	 {"kid":"2024-01","alg":"HS256","secret":"..."}]
+//
+// JWT_ACTIVE_KID selects the signing key and defaults to the first entry; the
+// rest stay valid for verification so keys can be rotated without logging
+// everyone out. JWT_SECRET is a shorthand for a single HS256 key.
+//
+// Without any of these startup fails, unless JWT_ALLOW_RANDOM_KEY=true asks
+// for a random key for local development.
+func loadKeySet() (*auth.KeySet, error) {
+	spec := os.Getenv("JWT_KEYS")
+	if file := os.Getenv("JWT_KEYS_FILE"); spec == "" && file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		spec = string(data)
+	}
+	if spec != "" {
+		return auth.ParseKeySet([]byte(spec), os.Getenv("JWT_ACTIVE_KID"))
+	}
+
+	if secret := os.Getenv("JWT_SECRET"); secret != "" {
+		if len(secret) < auth.MinHMACSecret {
+			return nil, fmt.Errorf("JWT_SECRET must be at least %d bytes", auth.MinHMACSecret)
+		}
+		return auth.NewKeySet("default", auth.NewHMACKey("default", jwt.SigningMethodHS256, []byte(secret)))
+	}
+
+	if os.Getenv("JWT_ALLOW_RANDOM_KEY") != "true" {
+		return nil, errors.New("no JWT keys configured, set JWT_KEYS, JWT_KEYS_FILE or JWT_SECRET")
+	}
+	log.Println("No JWT keys configured, using a random key; tokens will not survive a restart")
+	return auth.NewRandomKeySet()
+}
+
+// loadOIDCProviders reads OIDC_PROVIDERS (or the file named by
+// OIDC_PROVIDERS_FILE), a JSON array such as
+//
+//	[{"name":"google","issuer":"https://accounts.google.com",
+//	  "clientId":"...","clientSecret":"..."}]
+//
+// Each provider gets /oidc/{name}/login and /oidc/{name}/callback.
+func loadOIDCProviders() ([]*oidc.Provider, error) {
+	spec := os.Getenv("OIDC_PROVIDERS")
+	if file := os.Getenv("OIDC_PROVIDERS_FILE"); spec == "" && file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		spec = string(data)
+	}
+	if spec == "" {
+		return nil, nil
+	}
+	return oidc.ParseProviders([]byte(spec), nil)
+}
+
+// loadMailer picks the mail transport. MAIL_DRIVER=smtp sends through
+// SMTP_HOST; anything else writes mail to MAIL_LOG_FILE, or the log when
+// that is unset.
+func loadMailer() mailer.Mailer {
+	if os.Getenv("MAIL_DRIVER") == "smtp" {
+		port := os.Getenv("SMTP_PORT")
+		if port == "" {
+			port = "587"
+		}
+		return &mailer.SMTPMailer{
+			Host:     os.Getenv("SMTP_HOST"),
+			Port:     port,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     os.Getenv("MAIL_FROM"),
+		}
+	}
+	return &mailer.LogMailer{Path: os.Getenv("MAIL_LOG_FILE")}
+}
+
+// splitList parses a comma separated environment value, dropping blanks.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// durationEnv parses a Go duration such as "15m"; unset means zero, which
+// leaves the handler default in place.
+func durationEnv(name string) (time.Duration, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", name, err)
+	}
+	return d, nil
+}
+
+// intEnv parses an integer; unset means zero.
+func intEnv(name string) (int, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", name, err)
+	}
+	return n, nil
+}
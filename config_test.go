@@ -0,0 +1,37 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLoadKeySet(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		wantErr bool
+	}{
+		{"nothing configured", nil, true},
+		{"random key allowed", map[string]string{"JWT_ALLOW_RANDOM_KEY": "true"}, false},
+		{"short secret", map[string]string{"JWT_SECRET": "short"}, true},
+		{"secret", map[string]string{"JWT_SECRET": strings.Repeat("s", 64)}, false},
+		{"key specs", map[string]string{"JWT_KEYS": `[{"kid":"k1","alg":"HS256","secret":"` + strings.Repeat("k", 64) + `"}]`}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, name := range []string{"JWT_KEYS", "JWT_KEYS_FILE", "JWT_ACTIVE_KID", "JWT_SECRET", "JWT_ALLOW_RANDOM_KEY"} {
+				t.Setenv(name, tt.env[name])
+			}
+			keys, err := loadKeySet()
+			if tt.wantErr {
+				if err == nil {
+					t.Error("loadKeySet succeeded, want an error")
+				}
+				return
+			}
+			if err != nil || keys == nil {
+				t.Errorf("loadKeySet = %v, %v, want a key set", keys, err)
+			}
+		})
+	}
+}
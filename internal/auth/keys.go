@@ -0,0 +1,276 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	Issuer   = "expenso-go"
+	Audience = "expenso-go"
+)
+
+var ErrUnknownKey = errors.New("unknown signing key")
+
+// Key is a single JWT signing or verification key identified by its kid.
+// A key without a private part can only verify tokens.
+type Key struct {
+	Id        string
+	Method    jwt.SigningMethod
+	signKey   interface{}
+	verifyKey interface{}
+}
+
+func (k *Key) canSign() bool {
+	return k.signKey != nil
+}
+
+// MinHMACSecret is the shortest HMAC secret accepted, in bytes.
+const MinHMACSecret = 16
+
+func NewHMACKey(id string, method jwt.SigningMethod, secret []byte) *Key {
+	return &Key{Id: id, Method: method, signKey: secret, verifyKey: secret}
+}
+
+// KeySet holds the active signing key and every key still accepted for
+// verification. Keys are rotated by adding a new active key and keeping the
+// previous one around as retired until the tokens it signed have expired.
+type KeySet struct {
+	active *Key
+	keys   map[string]*Key
+}
+
+func NewKeySet(activeId string, keys ...*Key) (*KeySet, error) {
+	set := &KeySet{keys: map[string]*Key{}}
+	for _, key := range keys {
+		if key.Id == "" {
+			return nil, errors.New("jwt key is missing a kid")
+		}
+		if _, ok := set.keys[key.Id]; ok {
+			return nil, fmt.Errorf("duplicate jwt kid %q", key.Id)
+		}
+		set.keys[key.Id] = key
+	}
+
+	active, ok := set.keys[activeId]
+	if !ok {
+		return nil, fmt.Errorf("active jwt kid %q is not configured", activeId)
+	}
+	if !active.canSign() {
+		return nil, fmt.Errorf("active jwt kid %q has no private key", activeId)
+	}
+	set.active = active
+	return set, nil
+}
+
+// NewRandomKeySet returns a set with a single freshly generated HMAC key.
+// Tokens signed with it do not survive a restart.
+func NewRandomKeySet() (*KeySet, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	return NewKeySet("ephemeral", NewHMACKey("ephemeral", jwt.SigningMethodHS256, secret))
+}
+
+// Sign signs claims with the active key and stamps its kid in the header.
+func (s *KeySet) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(s.active.Method, claims)
+	token.Header["kid"] = s.active.Id
+	return token.SignedString(s.active.signKey)
+}
+
+// Parse verifies tokenString against the key named by its kid header and
+// checks the standard expenso-go issuer and audience. Extra options, such as
+// jwt.WithTimeFunc, are applied after the defaults.
+func (s *KeySet) Parse(tokenString string, claims jwt.Claims, options ...jwt.ParserOption) (*jwt.Token, error) {
+	options = append([]jwt.ParserOption{
+		jwt.WithIssuer(Issuer),
+		jwt.WithAudience(Audience),
+		jwt.WithExpirationRequired(),
+	}, options...)
+	return jwt.ParseWithClaims(tokenString, claims, s.keyfunc, options...)
+}
+
+func (s *KeySet) keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := s.keys[kid]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	// Never let the token pick the algorithm.
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+	return key.verifyKey, nil
+}
+
+// JWK is the public form of a key as published at /.well-known/jwks.json.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWKS returns the public halves of the asymmetric keys in the set so other
+// services can verify tokens without holding a secret. HMAC keys are never
+// published.
+func (s *KeySet) JWKS() []JWK {
+	ids := make([]string, 0, len(s.keys))
+	for id := range s.keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	keys := []JWK{}
+	for _, id := range ids {
+		key := s.keys[id]
+		switch pub := key.verifyKey.(type) {
+		case *rsa.PublicKey:
+			keys = append(keys, JWK{
+				Kty: "RSA",
+				Kid: key.Id,
+				Alg: key.Method.Alg(),
+				Use: "sig",
+				N:   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			keys = append(keys, JWK{
+				Kty: "OKP",
+				Kid: key.Id,
+				Alg: key.Method.Alg(),
+				Use: "sig",
+				Crv: "Ed25519",
+				X:   base64.RawURLEncoding.EncodeToString(pub),
+			})
+		}
+	}
+	return keys
+}
+
+// KeySpec describes a key in configuration. HMAC keys take a secret;
+// RS256 and EdDSA keys take a PEM private key (or only a public key for
+// verify-only keys), either inline or from a file.
+type KeySpec struct {
+	Kid            string `json:"kid"`
+	Alg            string `json:"alg"`
+	Secret         string `json:"secret"`
+	PrivateKey     string `json:"privateKey"`
+	PrivateKeyFile string `json:"privateKeyFile"`
+	PublicKey      string `json:"publicKey"`
+	PublicKeyFile  string `json:"publicKeyFile"`
+}
+
+// ParseKeySet builds a KeySet from a JSON array of KeySpec. Every key other
+// than activeId is accepted for verification only.
+func ParseKeySet(data []byte, activeId string) (*KeySet, error) {
+	var specs []KeySpec
+	if err := json.Unmarshal(data, &specs); err != nil {
+		return nil, fmt.Errorf("parsing jwt keys: %w", err)
+	}
+	if len(specs) == 0 {
+		return nil, errors.New("no jwt keys configured")
+	}
+	if activeId == "" {
+		activeId = specs[0].Kid
+	}
+
+	keys := make([]*Key, 0, len(specs))
+	for _, spec := range specs {
+		key, err := spec.key()
+		if err != nil {
+			return nil, fmt.Errorf("jwt key %q: %w", spec.Kid, err)
+		}
+		keys = append(keys, key)
+	}
+	return NewKeySet(activeId, keys...)
+}
+
+func (spec KeySpec) key() (*Key, error) {
+	if spec.Alg == "" {
+		spec.Alg = jwt.SigningMethodHS256.Alg()
+	}
+	method := jwt.GetSigningMethod(spec.Alg)
+	if method == nil {
+		return nil, fmt.Errorf("unsupported alg %q", spec.Alg)
+	}
+
+	switch method.(type) {
+	case *jwt.SigningMethodHMAC:
+		if len(spec.Secret) < MinHMACSecret {
+			return nil, fmt.Errorf("hmac secret must be at least %d bytes", MinHMACSecret)
+		}
+		return NewHMACKey(spec.Kid, method, []byte(spec.Secret)), nil
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodEd25519:
+	default:
+		return nil, fmt.Errorf("unsupported alg %q", spec.Alg)
+	}
+
+	key := &Key{Id: spec.Kid, Method: method}
+	privatePEM, err := pemValue(spec.PrivateKey, spec.PrivateKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	publicPEM, err := pemValue(spec.PublicKey, spec.PublicKeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	switch method.(type) {
+	case *jwt.SigningMethodRSA:
+		if privatePEM != nil {
+			private, err := jwt.ParseRSAPrivateKeyFromPEM(privatePEM)
+			if err != nil {
+				return nil, err
+			}
+			key.signKey, key.verifyKey = private, &private.PublicKey
+		} else if publicPEM != nil {
+			if key.verifyKey, err = jwt.ParseRSAPublicKeyFromPEM(publicPEM); err != nil {
+				return nil, err
+			}
+		}
+	case *jwt.SigningMethodEd25519:
+		if privatePEM != nil {
+			private, err := jwt.ParseEdPrivateKeyFromPEM(privatePEM)
+			if err != nil {
+				return nil, err
+			}
+			key.signKey, key.verifyKey = private, private.(crypto.Signer).Public()
+		} else if publicPEM != nil {
+			if key.verifyKey, err = jwt.ParseEdPublicKeyFromPEM(publicPEM); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if key.verifyKey == nil {
+		return nil, errors.New("a private or public key is required")
+	}
+	return key, nil
+}
+
+func pemValue(inline, file string) ([]byte, error) {
+	if inline != "" {
+		return []byte(inline), nil
+	}
+	if file != "" {
+		return os.ReadFile(file)
+	}
+	return nil, nil
+}
@@ -20,10 +20,21 @@ var clientInstanceError error
 type Collection string
 
 const (
-	TransactionCollection Collection = "transactions"
-	CategoryCollection    Collection = "categories"
-	AccountCollection     Collection = "accounts"
-	UserCollection        Collection = "users"
+	TransactionCollection    Collection = "transactions"
+	CategoryCollection       Collection = "categories"
+	AccountCollection        Collection = "accounts"
+	UserCollection           Collection = "users"
+	SessionCollection        Collection = "sessions"
+	APIKeyCollection         Collection = "api_keys"
+	PasswordResetCollection  Collection = "password_resets"
+	LoginAttemptCollection   Collection = "login_attempts"
+	AuditEventCollection     Collection = "audit_events"
+	RevisionCollection       Collection = "history"
+	ExchangeRateCollection   Collection = "exchange_rates"
+	ReconciliationCollection Collection = "reconciliations"
+	RecurringRuleCollection  Collection = "recurring_rules"
+	BudgetCollection         Collection = "budgets"
+	EnvelopeMoveCollection   Collection = "envelope_moves"
 )
 
 const (
@@ -0,0 +1,132 @@
+// Package ecb reads exchange rate files in the formats published by the
+// European Central Bank: the eurofxref CSV files and the eurofxref XML feed.
+// Every rate is the price of one unit of the base currency (EUR for ECB data)
+// in Currency.
+package ecb
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// ErrFormat is returned for files that are neither ECB style CSV nor XML.
+var ErrFormat = errors.New("ecb: unrecognised rate file")
+
+// Rate is one published rate. Rate is kept as the decimal string from the
+// file so no precision is lost.
+type Rate struct {
+	Date     time.Time
+	Currency string
+	Rate     string
+}
+
+// dateLayouts covers the daily CSV ("17 October 2026") and the historical
+// CSV and XML files ("2026-10-17").
+var dateLayouts = []string{"2006-01-02", "2 January 2006", "02 January 2006"}
+
+// Parse reads CSV or XML, telling them apart by the first character.
+func Parse(data []byte) ([]Rate, error) {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 {
+		return nil, ErrFormat
+	}
+	if trimmed[0] == '<' {
+		return ParseXML(trimmed)
+	}
+	return ParseCSV(trimmed)
+}
+
+// ParseCSV reads a file with a Date column followed by one column per
+// currency. Blank and N/A cells are skipped.
+func ParseCSV(data []byte) ([]Rate, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, ErrFormat
+	}
+	if len(header) < 2 || !strings.EqualFold(strings.TrimSpace(header[0]), "Date") {
+		return nil, ErrFormat
+	}
+
+	var rates []Rate
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(record) == 0 || strings.TrimSpace(record[0]) == "" {
+			continue
+		}
+		date, err := parseDate(record[0])
+		if err != nil {
+			return nil, fmt.Errorf("ecb: line %d: %w", line, err)
+		}
+		for i := 1; i < len(record) && i < len(header); i++ {
+			currency := strings.TrimSpace(header[i])
+			value := strings.TrimSpace(record[i])
+			if currency == "" || value == "" || value == "N/A" {
+				continue
+			}
+			rates = append(rates, Rate{Date: date, Currency: currency, Rate: value})
+		}
+	}
+	return rates, nil
+}
+
+type xmlEnvelope struct {
+	Cube struct {
+		Days []struct {
+			Time  string `xml:"time,attr"`
+			Rates []struct {
+				Currency string `xml:"currency,attr"`
+				Rate     string `xml:"rate,attr"`
+			} `xml:"Cube"`
+		} `xml:"Cube"`
+	} `xml:"Cube"`
+}
+
+// ParseXML reads the gesmes envelope used by the eurofxref daily, 90 day and
+// historical feeds.
+func ParseXML(data []byte) ([]Rate, error) {
+	var envelope xmlEnvelope
+	if err := xml.Unmarshal(data, &envelope); err != nil {
+		return nil, ErrFormat
+	}
+	if len(envelope.Cube.Days) == 0 {
+		return nil, ErrFormat
+	}
+
+	var rates []Rate
+	for _, day := range envelope.Cube.Days {
+		date, err := parseDate(day.Time)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range day.Rates {
+			rates = append(rates, Rate{Date: date, Currency: strings.TrimSpace(r.Currency), Rate: strings.TrimSpace(r.Rate)})
+		}
+	}
+	return rates, nil
+}
+
+func parseDate(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("ecb: invalid date %q", value)
+}
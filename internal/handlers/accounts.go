@@ -3,120 +3,130 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"time"
 
-	"github.com/amrohan/expenso-go/internal/db"
 	"github.com/amrohan/expenso-go/internal/helpers"
 	"github.com/amrohan/expenso-go/internal/models"
+	"github.com/amrohan/expenso-go/internal/money"
+	"github.com/amrohan/expenso-go/internal/repository"
 	"github.com/go-chi/chi/v5"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-func CreateAccount(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) CreateAccount(w http.ResponseWriter, r *http.Request) {
 	account := models.Account{
 		Id:        primitive.NewObjectID(),
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
+		CreatedAt: h.now(),
+		UpdatedAt: h.now(),
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&account); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		helpers.SendResponse(w, http.StatusBadRequest, "Please send valid json", nil, err)
 		return
 	}
 	account.Id = primitive.NewObjectID()
-
-	client, err := db.GetMongoClient()
-	if err != nil {
-		helpers.SendResponse(w, http.StatusInternalServerError, "Couldnt connect to db", nil, err)
+	account.UserId = currentUserId(r)
+	account.IsDeleted = false
+	account.DeletedAt = time.Time{}
+	if account.Currency == "" {
+		account.Currency = h.baseCurrency(r.Context(), account.UserId)
+	}
+	if !normalizeAccount(w, &account) {
+		return
 	}
-	collection := client.Database(db.Database).Collection(string(db.AccountCollection))
 
-	data, err := collection.InsertOne(context.TODO(), account)
-	if err != nil {
+	if err := h.store.Accounts.Create(r.Context(), &account); err != nil {
 		helpers.SendResponse(w, http.StatusInternalServerError, "Couldnt create account", nil, err)
+		return
 	}
-
-	account.Id = data.InsertedID.(primitive.ObjectID)
-
+	h.recordRevision(r, "account", account.Id, account.UserId, models.RevisionCreate, nil, &account)
+	h.auditChange(r, models.AuditAccountCreate, "account", account.Id, nil, &account)
 	helpers.SendResponse(w, http.StatusOK, "Account created", account, nil)
 }
 
-func GetAllAccount(w http.ResponseWriter, r *http.Request) {
-	client, err := db.GetMongoClient()
+func (h *Handler) GetAllAccount(w http.ResponseWriter, r *http.Request) {
+	accounts, err := h.store.Accounts.Find(r.Context(), repository.AccountFilter{UserId: currentUserId(r)})
 	if err != nil {
-		helpers.SendResponse(w, http.StatusInternalServerError, "Error connecting to database", nil, err)
+		helpers.SendResponse(w, http.StatusInternalServerError, "Error finding accounts", nil, err)
+		return
+	}
+	h.sendAccounts(w, r, accounts)
+}
+
+func (h *Handler) GetAccountById(w http.ResponseWriter, r *http.Request) {
+	account, ok := h.ownedAccount(w, r, chi.URLParam(r, "id"))
+	if !ok {
 		return
 	}
-	collection := client.Database(db.Database).Collection(string(db.AccountCollection))
-	var accounts []*models.Account
-	cur, err := collection.Find(context.TODO(), bson.D{
-		primitive.E{},
-	})
+	view, err := h.accountView(r.Context(), account)
 	if err != nil {
-		helpers.SendResponse(w, http.StatusInternalServerError, "Error finding accounts", nil, err)
+		helpers.SendResponse(w, http.StatusInternalServerError, "Couldnt compute balance", nil, err)
 		return
 	}
-	for cur.Next(context.TODO()) {
-		var account models.Account
+	helpers.SendResponse(w, http.StatusOK, "Account found", view, nil)
+}
 
-		err := cur.Decode(&account)
-		if err != nil {
-			helpers.SendResponse(w, http.StatusInternalServerError, "Error decoding account", nil, err)
-			return
-		}
-		accounts = append(accounts, &account)
-	}
-	helpers.SendResponse(w, http.StatusOK, "Accounts found", accounts, nil)
+// ownedAccount loads a account belonging to the caller. When the id is invalid,
+// missing, in the trash or owned by someone else the response is written here and ok is
+// false.
+func (h *Handler) ownedAccount(w http.ResponseWriter, r *http.Request, idHex string) (*models.Account, bool) {
+	return h.findAccount(w, r, idHex, false)
+}
+
+// trashedAccount is ownedAccount for accounts in the trash.
+func (h *Handler) trashedAccount(w http.ResponseWriter, r *http.Request, idHex string) (*models.Account, bool) {
+	return h.findAccount(w, r, idHex, true)
 }
 
-func GetAccountById(w http.ResponseWriter, r *http.Request) {
-	var account models.Account
-	id, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+func (h *Handler) findAccount(w http.ResponseWriter, r *http.Request, idHex string, deleted bool) (*models.Account, bool) {
+	id, err := primitive.ObjectIDFromHex(idHex)
 	if err != nil {
 		helpers.SendResponse(w, http.StatusBadRequest, "Invalid id", nil, err)
+		return nil, false
 	}
 
-	client, err := db.GetMongoClient()
-	if err != nil {
-		helpers.SendResponse(w, http.StatusInternalServerError, "Couldnt connect to db", nil, err)
+	account, err := h.store.Accounts.FindById(r.Context(), id)
+	if errors.Is(err, repository.ErrNotFound) || (err == nil && (!owns(r, account.UserId) || account.IsDeleted != deleted)) {
+		helpers.SendResponse(w, http.StatusNotFound, "Account not found", nil, nil)
+		return nil, false
 	}
-	collection := client.Database(db.Database).Collection(string(db.TransactionCollection))
-
-	err = collection.FindOne(context.TODO(), bson.M{"_id": id}).Decode(&account)
 	if err != nil {
-		helpers.SendResponse(w, http.StatusInternalServerError, "Couldnt find transaction", nil, err)
+		helpers.SendResponse(w, http.StatusInternalServerError, "Couldnt find account", nil, err)
+		return nil, false
 	}
-	helpers.SendResponse(w, http.StatusOK, "Transaction found", account, nil)
+	return account, true
 }
 
-func GetAccountsByUserId(w http.ResponseWriter, r *http.Request) {
-	var accounts []*models.Account
+func (h *Handler) GetAccountsByUserId(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
-	client, err := db.GetMongoClient()
-	if err != nil {
-		helpers.SendResponse(w, http.StatusInternalServerError, "Couldnt connect to db", nil, err)
+	if !owns(r, id) {
+		helpers.SendResponse(w, http.StatusNotFound, "User not found", nil, nil)
+		return
 	}
-	collection := client.Database(db.Database).Collection(string(db.AccountCollection))
-	cur, err := collection.Find(context.TODO(), bson.M{"userId": id})
+
+	accounts, err := h.store.Accounts.Find(r.Context(), repository.AccountFilter{UserId: id})
 	if err != nil {
-		helpers.SendResponse(w, http.StatusInternalServerError, "Couldnt find transaction", nil, err)
+		helpers.SendResponse(w, http.StatusInternalServerError, "Couldnt find accounts", nil, err)
 		return
 	}
-	for cur.Next(context.TODO()) {
-		var account models.Account
-		if err := cur.Decode(&account); err != nil {
-			helpers.SendResponse(w, http.StatusInternalServerError, "Error decoding account", nil, err)
-		}
-		accounts = append(accounts, &account)
+	h.sendAccounts(w, r, accounts)
+}
+
+// sendAccounts responds with the accounts and their current balances.
+func (h *Handler) sendAccounts(w http.ResponseWriter, r *http.Request, accounts []models.Account) {
+	views, err := h.accountViews(r.Context(), accounts)
+	if err != nil {
+		helpers.SendResponse(w, http.StatusInternalServerError, "Couldnt compute balances", nil, err)
+		return
 	}
-	helpers.SendResponse(w, http.StatusOK, "Accounts found", accounts, nil)
+	helpers.SendResponse(w, http.StatusOK, "Accounts found", views, nil)
 }
 
-func UpdateAccount(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) UpdateAccount(w http.ResponseWriter, r *http.Request) {
 	account := models.Account{
-		UpdatedAt: time.Now(),
+		UpdatedAt: h.now(),
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&account); err != nil {
@@ -124,35 +134,133 @@ func UpdateAccount(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	client, err := db.GetMongoClient()
-	if err != nil {
-		helpers.SendResponse(w, http.StatusInternalServerError, "Couldnt connect to db", nil, err)
+	existing, ok := h.ownedAccount(w, r, account.Id.Hex())
+	if !ok {
+		return
+	}
+	account.UserId = currentUserId(r)
+	account.IsDeleted = false
+	account.DeletedAt = time.Time{}
+	if account.Currency == "" {
+		account.Currency = h.accountCurrency(existing)
+	}
+	if !normalizeAccount(w, &account) || !h.checkCurrencyChange(w, r, existing, account.Currency) {
+		return
 	}
-	collection := client.Database(db.Database).Collection(string(db.AccountCollection))
 
-	data, err := collection.UpdateOne(r.Context(), bson.M{"id": account.Id}, bson.M{"$set": account})
-	if err != nil {
+	if err := h.store.Accounts.Update(r.Context(), &account); err != nil {
 		helpers.SendResponse(w, http.StatusInternalServerError, "Error updating account", nil, err)
 		return
 	}
-	helpers.SendResponse(w, http.StatusOK, "Account updated successfully", data, nil)
+	h.recordRevision(r, "account", account.Id, account.UserId, models.RevisionUpdate, existing, &account)
+	h.auditChange(r, models.AuditAccountUpdate, "account", account.Id, existing, &account)
+	helpers.SendResponse(w, http.StatusOK, "Account updated successfully", account, nil)
+}
+
+func (h *Handler) DeleteAccount(w http.ResponseWriter, r *http.Request) {
+	account, ok := h.ownedAccount(w, r, chi.URLParam(r, "id"))
+	if !ok {
+		return
+	}
+
+	deleted := *account
+	deleted.IsDeleted = true
+	deleted.DeletedAt = h.now()
+	moved, ok := h.deleteWithDependents(w, r, "Account", account.Id.Hex(), deleted.DeletedAt,
+		repository.TransactionFilter{UserId: account.UserId, AccountId: account.Id.Hex()},
+		func(idHex string) error {
+			target, err := h.lookupAccount(r, idHex)
+			if err == nil && h.accountCurrency(target) != h.accountCurrency(account) {
+				err = money.ErrCurrencyMismatch
+			}
+			return err
+		},
+		func(t *models.Transaction, idHex string) { t.AccountId = idHex },
+		func(ctx context.Context) error {
+			return h.store.Accounts.Delete(ctx, account.Id, deleted.DeletedAt)
+		},
+	)
+	if !ok {
+		return
+	}
+	h.recordRevision(r, "account", account.Id, account.UserId, models.RevisionDelete, account, &deleted)
+	h.auditChange(r, models.AuditAccountDelete, "account", account.Id, account, &deleted)
+	helpers.SendResponse(w, http.StatusOK, "Account deleted successfully", map[string]interface{}{"transactions": moved}, nil)
 }
 
-func DeleteAccount(w http.ResponseWriter, r *http.Request) {
-	id, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+// normalizeAccount validates the account's currency and gives an opening
+// balance sent without a currency the account's one.
+func normalizeAccount(w http.ResponseWriter, account *models.Account) bool {
+	currency, err := money.NormalizeCurrency(account.Currency)
 	if err != nil {
-		helpers.SendResponse(w, http.StatusBadRequest, "Invalid id", nil, err)
+		helpers.SendResponse(w, http.StatusBadRequest, "Please send a valid currency", nil, err)
+		return false
 	}
-	client, err := db.GetMongoClient()
+	account.Currency = currency
+
+	opening, err := account.OpeningBalance.WithDefaultCurrency(currency)
 	if err != nil {
-		helpers.SendResponse(w, http.StatusInternalServerError, "Couldnt connect to db", nil, err)
+		helpers.SendResponse(w, http.StatusBadRequest, "Please send a valid opening balance", nil, err)
+		return false
+	}
+	if opening.Currency != currency {
+		helpers.SendResponse(w, http.StatusBadRequest, "Opening balance must be in the account currency, "+currency, nil, nil)
+		return false
 	}
-	collection := client.Database(db.Database).Collection(string(db.AccountCollection))
+	account.OpeningBalance = opening
+	return true
+}
+
+// checkCurrencyChange refuses to change the currency of an account that
+// still has transactions, including ones in the trash, since their amounts
+// are in the old currency.
+func (h *Handler) checkCurrencyChange(w http.ResponseWriter, r *http.Request, account *models.Account, currency string) bool {
+	if h.accountCurrency(account) == currency {
+		return true
+	}
+	for _, deleted := range []bool{false, true} {
+		transactions, err := h.store.Transactions.Find(r.Context(), repository.TransactionFilter{
+			UserId:      account.UserId,
+			AccountId:   account.Id.Hex(),
+			DeletedOnly: deleted,
+		})
+		if err != nil {
+			helpers.SendResponse(w, http.StatusInternalServerError, "Couldnt find transactions", nil, err)
+			return false
+		}
+		if len(transactions) > 0 {
+			helpers.SendResponse(w, http.StatusConflict, "Account has transactions, its currency cannot change", nil, nil)
+			return false
+		}
+	}
+	return true
+}
 
-	data, err := collection.DeleteOne(r.Context(), bson.M{"_id": id})
+// GetAccountTrash lists the caller's deleted accounts that have not been purged
+// yet.
+func (h *Handler) GetAccountTrash(w http.ResponseWriter, r *http.Request) {
+	accounts, err := h.store.Accounts.Find(r.Context(), repository.AccountFilter{UserId: currentUserId(r), DeletedOnly: true})
 	if err != nil {
-		helpers.SendResponse(w, http.StatusInternalServerError, "Error deleting account", nil, err)
+		helpers.SendResponse(w, http.StatusInternalServerError, "Couldnt find accounts", nil, err)
+		return
+	}
+	helpers.SendResponse(w, http.StatusOK, "Accounts found", accounts, nil)
+}
+
+func (h *Handler) RestoreAccount(w http.ResponseWriter, r *http.Request) {
+	account, ok := h.trashedAccount(w, r, chi.URLParam(r, "id"))
+	if !ok {
+		return
+	}
+
+	if err := h.store.Accounts.Restore(r.Context(), account.Id); err != nil {
+		helpers.SendResponse(w, http.StatusInternalServerError, "Error restoring account", nil, err)
 		return
 	}
-	helpers.SendResponse(w, http.StatusOK, "Account deleted successfully", data, nil)
+	restored := *account
+	restored.IsDeleted = false
+	restored.DeletedAt = time.Time{}
+	h.recordRevision(r, "account", account.Id, account.UserId, models.RevisionRestore, nil, &restored)
+	h.auditChange(r, models.AuditAccountRestore, "account", account.Id, account, &restored)
+	helpers.SendResponse(w, http.StatusOK, "Account restored successfully", restored, nil)
 }
@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/amrohan/expenso-go/internal/auth"
+	"github.com/amrohan/expenso-go/internal/helpers"
+	"github.com/amrohan/expenso-go/internal/models"
+	"github.com/amrohan/expenso-go/internal/repository"
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// apiKeyPrefix marks bearer credentials that are API keys rather than JWTs.
+const apiKeyPrefix = "exp_"
+
+func isAPIKey(token string) bool {
+	return strings.HasPrefix(token, apiKeyPrefix)
+}
+
+// authenticateAPIKey resolves an API key to the principal of its owner,
+// restricted to the key's scopes. API keys never carry roles.
+func (h *Handler) authenticateAPIKey(w http.ResponseWriter, r *http.Request, token string) (*Principal, bool) {
+	key, err := h.store.APIKeys.FindByHash(r.Context(), auth.HashToken(token))
+	if errors.Is(err, repository.ErrNotFound) {
+		sendUnauthorized(w, "invalid_token", "Invalid API key", nil)
+		return nil, false
+	}
+	if err != nil {
+		helpers.SendResponse(w, http.StatusInternalServerError, "Error finding API key", nil, err)
+		return nil, false
+	}
+
+	now := h.now()
+	if !key.IsActive(now) {
+		sendUnauthorized(w, "invalid_token", "API key has expired or been revoked", nil)
+		return nil, false
+	}
+
+	userId, err := primitive.ObjectIDFromHex(key.UserId)
+	if err != nil {
+		sendUnauthorized(w, "invalid_token", "Invalid API key", nil)
+		return nil, false
+	}
+	user, err := h.store.Users.FindById(r.Context(), userId)
+	if err != nil || user.IsDeleted {
+		sendUnauthorized(w, "invalid_token", "Invalid API key", nil)
+		return nil, false
+	}
+
+	if now.Sub(key.LastUsedAt) > lastSeenInterval {
+		h.store.APIKeys.Touch(r.Context(), key.Id, now)
+	}
+
+	return &Principal{
+		UserId:     key.UserId,
+		Username:   user.Username,
+		IsVerified: user.IsVerified,
+		APIKeyId:   key.Id.Hex(),
+		Scopes:     key.Scopes,
+	}, true
+}
+
+func (h *Handler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Name      string    `json:"name"`
+		Scopes    []string  `json:"scopes"`
+		ExpiresAt time.Time `json:"expiresAt"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		helpers.SendResponse(w, http.StatusBadRequest, "Please send valid body", nil, err)
+		return
+	}
+	if body.Name == "" || len(body.Scopes) == 0 {
+		helpers.SendResponse(w, http.StatusBadRequest, "Name and scopes are required", nil, nil)
+		return
+	}
+	for _, scope := range body.Scopes {
+		if !models.IsValidScope(scope) {
+			helpers.SendResponse(w, http.StatusBadRequest, fmt.Sprintf("Unknown scope %q", scope), models.Scopes, nil)
+			return
+		}
+	}
+	now := h.now()
+	if !body.ExpiresAt.IsZero() && !body.ExpiresAt.After(now) {
+		helpers.SendResponse(w, http.StatusBadRequest, "Expiry must be in the future", nil, nil)
+		return
+	}
+
+	secret, err := auth.NewOpaqueToken()
+	if err != nil {
+		helpers.SendResponse(w, http.StatusInternalServerError, "Error creating API key", nil, err)
+		return
+	}
+	token := apiKeyPrefix + secret
+
+	key := models.APIKey{
+		Id:        primitive.NewObjectID(),
+		UserId:    currentUserId(r),
+		Name:      body.Name,
+		Prefix:    token[:len(apiKeyPrefix)+6],
+		KeyHash:   auth.HashToken(token),
+		Scopes:    body.Scopes,
+		ExpiresAt: body.ExpiresAt,
+		CreatedAt: now,
+	}
+	if err := h.store.APIKeys.Create(r.Context(), &key); err != nil {
+		helpers.SendResponse(w, http.StatusInternalServerError, "Error creating API key", nil, err)
+		return
+	}
+
+	// The plain key is only ever returned here.
+	helpers.SendResponse(w, http.StatusCreated, "API key created", map[string]interface{}{
+		"apiKey": key,
+		"key":    token,
+	}, nil)
+}
+
+func (h *Handler) GetAPIKeys(w http.ResponseWriter, r *http.Request) {
+	keys, err := h.store.APIKeys.Find(r.Context(), repository.APIKeyFilter{UserId: currentUserId(r)})
+	if err != nil {
+		helpers.SendResponse(w, http.StatusInternalServerError, "Error getting API keys", nil, err)
+		return
+	}
+	helpers.SendResponse(w, http.StatusOK, "API keys fetched successfully", keys, nil)
+}
+
+func (h *Handler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	id, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.SendResponse(w, http.StatusBadRequest, "Invalid API key id", nil, err)
+		return
+	}
+
+	key, err := h.store.APIKeys.FindById(r.Context(), id)
+	if errors.Is(err, repository.ErrNotFound) || (err == nil && !owns(r, key.UserId)) {
+		helpers.SendResponse(w, http.StatusNotFound, "API key not found", nil, nil)
+		return
+	}
+	if err != nil {
+		helpers.SendResponse(w, http.StatusInternalServerError, "Error finding API key", nil, err)
+		return
+	}
+
+	if err := h.store.APIKeys.Revoke(r.Context(), key.Id, h.now()); err != nil {
+		helpers.SendResponse(w, http.StatusInternalServerError, "Error revoking API key", nil, err)
+		return
+	}
+	helpers.SendResponse(w, http.StatusOK, "API key revoked", nil, nil)
+}
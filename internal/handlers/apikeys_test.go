@@ -0,0 +1,53 @@
+package handlers_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/amrohan/expenso-go/internal/handlers"
+	"github.com/amrohan/expenso-go/internal/models"
+)
+
+func TestAPIKeys(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t, handlers.Config{})
+	hana := s.register("hana")
+	categoryId := s.createdId(hana.Token, "/api/category/", map[string]string{"title": "Rent"})
+
+	s.expect(http.StatusBadRequest, hana.Token, http.MethodPost, "/api/user/apikeys",
+		map[string]interface{}{"name": "bad", "scopes": []string{"everything"}})
+
+	var created struct {
+		Key    string `json:"key"`
+		APIKey struct {
+			Id string `json:"id"`
+		} `json:"apiKey"`
+	}
+	s.decode(s.expect(http.StatusCreated, hana.Token, http.MethodPost, "/api/user/apikeys", map[string]interface{}{
+		"name":   "export",
+		"scopes": []string{models.ScopeCategoriesRead},
+	}), &created)
+
+	// The key reads within its scopes and nothing more.
+	s.expect(http.StatusOK, created.Key, http.MethodGet, "/api/category/"+categoryId, nil)
+	s.expect(http.StatusForbidden, created.Key, http.MethodPost, "/api/category/", map[string]string{"title": "Nope"})
+	s.expect(http.StatusForbidden, created.Key, http.MethodGet, "/api/account/", nil)
+	s.expect(http.StatusForbidden, created.Key, http.MethodGet, "/api/user/apikeys", nil)
+
+	var keys []struct {
+		Id        string `json:"id"`
+		IsRevoked bool   `json:"isRevoked"`
+	}
+	s.decode(s.expect(http.StatusOK, hana.Token, http.MethodGet, "/api/user/apikeys", nil), &keys)
+	if len(keys) != 1 || keys[0].Id != created.APIKey.Id {
+		t.Fatalf("keys = %+v, want the one created", keys)
+	}
+
+	other := s.register("ivan")
+	s.expect(http.StatusNotFound, other.Token, http.MethodDelete, "/api/user/apikeys/"+created.APIKey.Id, nil)
+	s.expect(http.StatusOK, created.Key, http.MethodGet, "/api/category/"+categoryId, nil)
+
+	s.expect(http.StatusOK, hana.Token, http.MethodDelete, "/api/user/apikeys/"+created.APIKey.Id, nil)
+	s.expect(http.StatusUnauthorized, created.Key, http.MethodGet, "/api/category/"+categoryId, nil)
+	s.expect(http.StatusUnauthorized, "exp_made-up", http.MethodGet, "/api/category/"+categoryId, nil)
+}
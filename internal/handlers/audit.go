@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/amrohan/expenso-go/internal/helpers"
+	"github.com/amrohan/expenso-go/internal/models"
+	"github.com/amrohan/expenso-go/internal/repository"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	defaultAuditPageSize = 50
+	maxAuditPageSize     = 200
+)
+
+// redactedFields never have their values copied into the audit trail; a
+// change to one is recorded without the values.
+var redactedFields = map[string]bool{
+	"password":           true,
+	"totpSecret":         true,
+	"totpLastStep":       true,
+	"recoveryCodeHashes": true,
+}
+
+// audit records event, filling in the request details. A failure to write
+// the trail is logged rather than failing the request that caused it.
+func (h *Handler) audit(r *http.Request, event models.AuditEvent) {
+	event.Id = primitive.NewObjectID()
+	event.Ip = helpers.ClientIP(r)
+	event.UserAgent = r.UserAgent()
+	event.CreatedAt = h.now()
+	if event.ActorId == "" {
+		if p, ok := PrincipalFromContext(r.Context()); ok {
+			event.ActorId = p.UserId
+		}
+	}
+	if err := h.store.AuditEvents.Create(r.Context(), &event); err != nil {
+		log.Printf("audit: unable to record %s: %v", event.Action, err)
+	}
+}
+
+// auditChange records a change to a stored entity. before is nil for a
+// create and after is nil for a delete.
+func (h *Handler) auditChange(r *http.Request, action, targetType string, targetId primitive.ObjectID, before, after interface{}) {
+	h.audit(r, models.AuditEvent{
+		Action:     action,
+		TargetType: targetType,
+		TargetId:   targetId.Hex(),
+		Changes:    auditDiff(before, after),
+	})
+}
+
+// auditDiff compares the stored form of two entities field by field.
+func auditDiff(before, after interface{}) map[string]models.AuditChange {
+	return diffFields(storedFields(before), storedFields(after))
+}
+
+// diffFields lists the fields that differ between two stored documents.
+func diffFields(b, a bson.M) map[string]models.AuditChange {
+	changes := map[string]models.AuditChange{}
+	keys := map[string]bool{}
+	for key := range b {
+		keys[key] = true
+	}
+	for key := range a {
+		keys[key] = true
+	}
+	for key := range keys {
+		value := a[key]
+		if key == "_id" || reflect.DeepEqual(b[key], value) {
+			continue
+		}
+		if redactedFields[key] {
+			changes[key] = models.AuditChange{}
+			continue
+		}
+		changes[key] = models.AuditChange{Before: b[key], After: value}
+	}
+	return changes
+}
+
+func storedFields(v interface{}) bson.M {
+	fields := bson.M{}
+	if v == nil {
+		return fields
+	}
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Pointer && rv.IsNil() {
+		return fields
+	}
+	data, err := bson.Marshal(v)
+	if err == nil {
+		err = bson.Unmarshal(data, &fields)
+	}
+	if err != nil {
+		log.Printf("audit: unable to encode %T: %v", v, err)
+	}
+	return fields
+}
+
+// auditLoginFailure records a failed password or second factor check.
+// user is nil when the login matched no account.
+func (h *Handler) auditLoginFailure(r *http.Request, user *models.User, login, reason string) {
+	event := models.AuditEvent{
+		Action:     models.AuditLoginFailure,
+		TargetType: "user",
+		Details:    map[string]interface{}{"reason": reason},
+	}
+	if user != nil {
+		event.ActorId = user.Id.Hex()
+		event.TargetId = user.Id.Hex()
+	} else {
+		event.Details["login"] = login
+	}
+	h.audit(r, event)
+}
+
+// GetMyAuditEvents pages through the events the caller performed.
+func (h *Handler) GetMyAuditEvents(w http.ResponseWriter, r *http.Request) {
+	filter, page, ok := auditQuery(w, r)
+	if !ok {
+		return
+	}
+	filter.ActorId = currentUserId(r)
+	h.findAuditEvents(w, r, filter, page)
+}
+
+// GetAuditEvents lets admins search every user's events.
+func (h *Handler) GetAuditEvents(w http.ResponseWriter, r *http.Request) {
+	filter, page, ok := auditQuery(w, r)
+	if !ok {
+		return
+	}
+	filter.ActorId = r.URL.Query().Get("actorId")
+	filter.TargetType = r.URL.Query().Get("targetType")
+	filter.TargetId = r.URL.Query().Get("targetId")
+	h.findAuditEvents(w, r, filter, page)
+}
+
+func (h *Handler) findAuditEvents(w http.ResponseWriter, r *http.Request, filter repository.AuditEventFilter, page repository.Page) {
+	events, total, err := h.store.AuditEvents.Find(r.Context(), filter, page)
+	if err != nil {
+		helpers.SendResponse(w, http.StatusInternalServerError, "Couldnt find audit events", nil, err)
+		return
+	}
+	helpers.SendResponse(w, http.StatusOK, "Audit events found", map[string]interface{}{
+		"events":   events,
+		"page":     page.Number,
+		"pageSize": page.Size,
+		"total":    total,
+	}, nil)
+}
+
+// auditQuery reads the query parameters shared by the audit endpoints:
+// action, from and to (RFC 3339), page and pageSize.
+func auditQuery(w http.ResponseWriter, r *http.Request) (repository.AuditEventFilter, repository.Page, bool) {
+	query := r.URL.Query()
+	filter := repository.AuditEventFilter{Action: query.Get("action")}
+
+	for name, t := range map[string]*time.Time{"from": &filter.From, "to": &filter.To} {
+		if value := query.Get(name); value != "" {
+			parsed, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				helpers.SendResponse(w, http.StatusBadRequest, "Please send a valid "+name+" time", nil, err)
+				return filter, repository.Page{}, false
+			}
+			*t = parsed
+		}
+	}
+	page, ok := pageQuery(w, r, defaultAuditPageSize, maxAuditPageSize)
+	return filter, page, ok
+}
+
+// pageQuery reads the page and pageSize query parameters, capping the size
+// at maxSize.
+func pageQuery(w http.ResponseWriter, r *http.Request, defaultSize, maxSize int) (repository.Page, bool) {
+	page := repository.Page{Number: 1, Size: defaultSize}
+	for name, n := range map[string]*int{"page": &page.Number, "pageSize": &page.Size} {
+		if value := r.URL.Query().Get(name); value != "" {
+			parsed, err := strconv.Atoi(value)
+			if err != nil || parsed < 1 {
+				helpers.SendResponse(w, http.StatusBadRequest, "Please send a valid "+name, nil, err)
+				return page, false
+			}
+			*n = parsed
+		}
+	}
+	if page.Size > maxSize {
+		page.Size = maxSize
+	}
+	return page, true
+}
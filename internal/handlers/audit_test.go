@@ -0,0 +1,92 @@
+package handlers_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/amrohan/expenso-go/internal/handlers"
+)
+
+type auditPage struct {
+	Events []struct {
+		Action    string                 `json:"action"`
+		ActorId   string                 `json:"actorId"`
+		TargetId  string                 `json:"targetId"`
+		Ip        string                 `json:"ip"`
+		UserAgent string                 `json:"userAgent"`
+		Details   map[string]interface{} `json:"details"`
+		Changes   map[string]struct {
+			Before interface{} `json:"before"`
+			After  interface{} `json:"after"`
+		} `json:"changes"`
+	} `json:"events"`
+	Total int `json:"total"`
+}
+
+func TestAuditLog(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t, handlers.Config{AdminEmails: []string{"zed@example.com"}})
+	xena := s.register("xena")
+	yara := s.register("yara")
+	s.register("zed")
+	s.expect(http.StatusOK, "", http.MethodGet, s.link("zed@example.com", "/verify?token="), nil)
+	var zed tokens
+	s.decode(s.login("zed"), &zed)
+	xenaId := s.subject(xena.Token)
+
+	s.expect(http.StatusUnauthorized, "", http.MethodPost, "/login", map[string]string{"username": "xena", "password": "wrong"})
+	category := s.createdId(xena.Token, "/api/category/", map[string]string{"title": "Food"})
+	s.expect(http.StatusOK, xena.Token, http.MethodPut, "/api/category/", map[string]string{"id": category, "title": "Groceries"})
+	s.expect(http.StatusOK, xena.Token, http.MethodDelete, "/api/category/"+category, nil)
+	s.expect(http.StatusOK, xena.Token, http.MethodPost, "/logout", nil)
+	s.decode(s.login("xena"), &xena)
+
+	var mine auditPage
+	s.decode(s.expect(http.StatusOK, xena.Token, http.MethodGet, "/api/user/audit", nil), &mine)
+	seen := map[string]int{}
+	for _, event := range mine.Events {
+		seen[event.Action]++
+		if event.ActorId != xenaId || event.Ip == "" || event.UserAgent == "" {
+			t.Errorf("event %+v is missing its actor or request details", event)
+		}
+		switch event.Action {
+		case "category.update":
+			if change := event.Changes["title"]; change.Before != "Food" || change.After != "Groceries" {
+				t.Errorf("category.update title change = %+v, want Food to Groceries", change)
+			}
+		case "login.failure":
+			if event.Details["reason"] != "bad_password" {
+				t.Errorf("login.failure details = %v, want reason bad_password", event.Details)
+			}
+		}
+	}
+	for _, action := range []string{"login.success", "login.failure", "category.create", "category.update", "category.delete", "logout"} {
+		if seen[action] == 0 {
+			t.Errorf("no %s event among %v", action, seen)
+		}
+	}
+	if len(mine.Events) == 0 || mine.Events[0].Action != "login.success" {
+		t.Errorf("events are not newest first: %v", mine.Events)
+	}
+
+	var page auditPage
+	s.decode(s.expect(http.StatusOK, xena.Token, http.MethodGet, "/api/user/audit?pageSize=2&page=2", nil), &page)
+	if len(page.Events) != 2 || page.Total != mine.Total {
+		t.Errorf("second page has %d events of %d, want 2 of %d", len(page.Events), page.Total, mine.Total)
+	}
+
+	// Users only see their own events; admins search everyone's.
+	var theirs auditPage
+	s.decode(s.expect(http.StatusOK, yara.Token, http.MethodGet, "/api/user/audit", nil), &theirs)
+	for _, event := range theirs.Events {
+		if event.ActorId != s.subject(yara.Token) {
+			t.Errorf("yara sees %s by %s", event.Action, event.ActorId)
+		}
+	}
+	s.expect(http.StatusForbidden, xena.Token, http.MethodGet, "/api/audit", nil)
+	var found auditPage
+	s.decode(s.expect(http.StatusOK, zed.Token, http.MethodGet, "/api/audit?actorId="+xenaId+"&action=category.update", nil), &found)
+	if found.Total != 1 || len(found.Events) != 1 || found.Events[0].TargetId != category {
+		t.Errorf("admin search = %+v, want the one category update", found)
+	}
+}
@@ -1,19 +1,18 @@
 package handlers
 
 import (
-	"context"
 	"encoding/json"
-	"fmt"
+	"errors"
 	"net/http"
+	"strings"
 	"time"
 
-	"github.com/amrohan/expenso-go/internal/db"
+	"github.com/amrohan/expenso-go/internal/auth"
 	"github.com/amrohan/expenso-go/internal/helpers"
 	"github.com/amrohan/expenso-go/internal/models"
+	"github.com/amrohan/expenso-go/internal/repository"
 	"github.com/golang-jwt/jwt/v5"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -22,8 +21,6 @@ type Claims struct {
 	Claims   jwt.RegisteredClaims
 }
 
-var jwtKey = []byte("n8&DfaT4cCJ424EEuwd")
-
 func HashPassword(password string) (string, error) {
 	bytes, err := bcrypt.GenerateFromPassword([]byte(password), 14)
 	return string(bytes), err
@@ -38,17 +35,33 @@ func Validate(u models.User) bool {
 	return u.Username != "" && u.Email != "" && u.Password != ""
 }
 
-func RegisterUser(w http.ResponseWriter, r *http.Request) {
-	user := models.User{
-		Id:        primitive.NewObjectID(),
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
-	}
+// registerRequest is what a registrant may set; everything else on the
+// user is decided by the server.
+type registerRequest struct {
+	Username string `json:"username"`
+	Name     string `json:"name"`
+	Email    string `json:"email"`
+	Password string `json:"password"`
+	ImageUrl string `json:"imageUrl"`
+}
 
-	if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
+func (h *Handler) RegisterUser(w http.ResponseWriter, r *http.Request) {
+	var req registerRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		helpers.SendResponse(w, http.StatusBadRequest, "Please send valid body", nil, err)
 		return
 	}
+	user := models.User{
+		Id:        primitive.NewObjectID(),
+		Username:  req.Username,
+		Name:      req.Name,
+		Email:     req.Email,
+		Password:  req.Password,
+		ImageUrl:  req.ImageUrl,
+		CreatedAt: h.now(),
+		UpdatedAt: h.now(),
+		IsActive:  true,
+	}
 	if !Validate(user) {
 		helpers.SendResponse(w, http.StatusBadRequest, "Please send valid body", nil, nil)
 		return
@@ -61,28 +74,20 @@ func RegisterUser(w http.ResponseWriter, r *http.Request) {
 	}
 	user.Password = HashPassword
 
-	client, err := db.GetMongoClient()
-	if err != nil {
-		helpers.SendResponse(w, http.StatusInternalServerError, "Cannot connect to database", nil, err)
-		return
-	}
-	collection := client.Database(db.Database).Collection(string(db.UserCollection))
-
 	// Check if the username or email already exists
-	filter := bson.D{
-		primitive.E{Key: "$or", Value: []bson.D{
-			{{Key: "username", Value: user.Username}},
-			{{Key: "email", Value: user.Email}},
-		}},
-	}
-	var existingUser models.User
-	if err := collection.FindOne(context.TODO(), filter).Decode(&existingUser); err != nil {
-		if err == mongo.ErrNoDocuments {
+	existingUser, err := h.store.Users.FindByLogin(r.Context(), user.Username, user.Email)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
 			// No existing user with the same username or email, proceed with insertion
-			if _, err := collection.InsertOne(context.TODO(), user); err != nil {
+			if err := h.store.Users.Create(r.Context(), &user); err != nil {
+				if errors.Is(err, repository.ErrDuplicate) {
+					helpers.SendResponse(w, http.StatusBadRequest, "Username or email already exists", nil, err)
+					return
+				}
 				helpers.SendResponse(w, http.StatusInternalServerError, "Unable to create user", nil, err)
 				return
 			}
+			h.sendVerificationEmail(r, &user)
 			helpers.SendResponse(w, http.StatusOK, "User registered successfully", nil, nil)
 		} else {
 			helpers.SendResponse(w, http.StatusInternalServerError, "Unable to check for existing user", nil, err)
@@ -100,111 +105,312 @@ func RegisterUser(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func LoginUser(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) LoginUser(w http.ResponseWriter, r *http.Request) {
 	var user models.User
 	if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
 		helpers.SendResponse(w, http.StatusBadRequest, "Please send valid body", nil, err)
 		return
 	}
 
-	client, err := db.GetMongoClient()
-	if err != nil {
-		helpers.SendResponse(w, http.StatusInternalServerError, "Cannot connect to database", nil, err)
+	ipKey := h.ipThrottleKey(r)
+	if until, err := h.loginLockedUntil(r, ipKey); err != nil {
+		helpers.SendResponse(w, http.StatusInternalServerError, "Unable to check login attempts", nil, err)
+		return
+	} else if !until.IsZero() {
+		h.sendLoginLocked(w, until)
 		return
 	}
-	collection := client.Database(db.Database).Collection(string(db.UserCollection))
 
-	// Check if the username or email exists
-	filter := bson.D{
-		primitive.E{Key: "$or", Value: []bson.D{
-			{{Key: "username", Value: user.Username}},
-			{{Key: "email", Value: user.Email}},
-		}},
+	// Check if the username or email exists. Unknown logins and wrong
+	// passwords get the same answer so accounts cannot be enumerated.
+	existingUser, err := h.store.Users.FindByLogin(r.Context(), user.Username, user.Email)
+	if err == nil && existingUser.IsDeleted {
+		err = repository.ErrNotFound
 	}
-	var existingUser models.User
-	if err := collection.FindOne(context.TODO(), filter).Decode(&existingUser); err != nil {
-		if err == mongo.ErrNoDocuments {
-			helpers.SendResponse(w, http.StatusNotFound, "User not found", nil, nil)
+	if errors.Is(err, repository.ErrNotFound) {
+		login := user.Email
+		if login == "" {
+			login = user.Username
+		}
+		loginKey := h.unknownLoginThrottleKey(login)
+		if until, err := h.loginLockedUntil(r, loginKey); err == nil && !until.IsZero() {
+			h.sendLoginLocked(w, until)
 			return
 		}
-
+		burnPasswordCheck(user.Password)
+		h.auditLoginFailure(r, nil, login, "unknown_user")
+		h.recordLoginFailure(r, "", loginKey, ipKey)
+		helpers.SendResponse(w, http.StatusUnauthorized, "Invalid username or password", nil, nil)
+		return
+	}
+	if err != nil {
 		helpers.SendResponse(w, http.StatusInternalServerError, "Unable to check for existing user", nil, err)
+		return
+	}
 
+	accountKey := h.accountThrottleKey(existingUser)
+	if until, err := h.loginLockedUntil(r, accountKey); err != nil {
+		helpers.SendResponse(w, http.StatusInternalServerError, "Unable to check login attempts", nil, err)
+		return
+	} else if !until.IsZero() {
+		h.sendLoginLocked(w, until)
 		return
 	}
 	if !CheckPasswordHash(user.Password, existingUser.Password) {
-		helpers.SendResponse(w, http.StatusUnauthorized, "Incorrect password", nil, nil)
+		h.auditLoginFailure(r, existingUser, "", "bad_password")
+		h.recordLoginFailure(r, existingUser.Id.Hex(), accountKey, ipKey)
+		helpers.SendResponse(w, http.StatusUnauthorized, "Invalid username or password", nil, nil)
+		return
+	}
+	if h.verificationOverdue(existingUser) {
+		helpers.SendResponse(w, http.StatusForbidden, "Please verify your email before logging in", nil, nil)
 		return
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"username":   existingUser.Username,
-		"isVerified": existingUser.IsVerified,
-		"exp":        time.Now().AddDate(0, 0, 7).Unix(),
-		"iat":        time.Now().Unix(),
-		"nbf":        time.Now().Unix(),
-		"sub":        existingUser.Id,
-		"aud":        "expenso-go",
-		"iss":        "expenso-go",
-	})
-	tokenString, err := token.SignedString([]byte(jwtKey))
-	if err != nil {
-		helpers.SendResponse(w, http.StatusInternalServerError, "Error signing token", nil, err)
+	if existingUser.TOTPEnabled {
+		h.sendMFAChallenge(w, existingUser)
 		return
 	}
-	http.SetCookie(w, &http.Cookie{
-		Name:     "token",
-		Value:    tokenString,
-		SameSite: http.SameSiteNoneMode,
-		Secure:   true,
-	})
-	helpers.SendResponse(w, http.StatusOK, "Login successful", map[string]string{"token": tokenString}, nil)
+	h.resetLoginFailures(r, existingUser)
+	h.startSession(w, r, existingUser)
+}
 
+// signPurposeToken signs a short-lived token that is only good for one
+// purpose, such as an email verification link or an MFA challenge.
+func (h *Handler) signPurposeToken(purpose, subject string, ttl time.Duration, extra jwt.MapClaims) (string, error) {
+	now := h.now()
+	claims := jwt.MapClaims{
+		"purpose": purpose,
+		"sub":     subject,
+		"exp":     now.Add(ttl).Unix(),
+		"iat":     now.Unix(),
+		"aud":     auth.Audience,
+		"iss":     auth.Issuer,
+	}
+	for k, v := range extra {
+		claims[k] = v
+	}
+	return h.config.Keys.Sign(claims)
+}
+
+// parsePurposeToken verifies a token from signPurposeToken and returns its
+// subject as a user id.
+func (h *Handler) parsePurposeToken(token, purpose string) (primitive.ObjectID, jwt.MapClaims, error) {
+	claims, err := h.parsePurposeClaims(token, purpose)
+	if err != nil {
+		return primitive.NilObjectID, nil, err
+	}
+	subject, _ := claims.GetSubject()
+	id, err := primitive.ObjectIDFromHex(subject)
+	return id, claims, err
 }
 
-func LogoutUser(w http.ResponseWriter, r *http.Request) {
-	http.SetCookie(w, &http.Cookie{
-		Name:     "token",
-		Value:    "",
-		MaxAge:   -1,
-		SameSite: http.SameSiteNoneMode,
-		Secure:   true,
+// parsePurposeClaims verifies a token from signPurposeToken whose subject is
+// not a user.
+func (h *Handler) parsePurposeClaims(token, purpose string) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	if _, err := h.config.Keys.Parse(token, claims, jwt.WithTimeFunc(h.now)); err != nil {
+		return nil, err
+	}
+	if p, _ := claims["purpose"].(string); p != purpose {
+		return nil, errors.New("token has the wrong purpose")
+	}
+	return claims, nil
+}
+
+// issueAccessToken signs a short-lived access token bound to session.
+func (h *Handler) issueAccessToken(user *models.User, session *models.Session) (string, error) {
+	now := h.now()
+	return h.config.Keys.Sign(jwt.MapClaims{
+		"username":   user.Username,
+		"isVerified": user.IsVerified,
+		"roles":      user.Roles,
+		"exp":        now.Add(h.config.AccessTokenTTL).Unix(),
+		"iat":        now.Unix(),
+		"nbf":        now.Unix(),
+		"sub":        user.Id.Hex(),
+		"sid":        session.Id.Hex(),
+		"aud":        auth.Audience,
+		"iss":        auth.Issuer,
 	})
-	helpers.SendResponse(w, http.StatusOK, "Logout successful", nil, nil)
 }
 
-func AuthMiddleware(next http.Handler) http.Handler {
+func (h *Handler) AuthMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		cookie, err := r.Cookie("token")
+		tokenStr, err := accessTokenFromRequest(r)
 		if err != nil {
-			if err == http.ErrNoCookie {
-				helpers.SendResponse(w, http.StatusUnauthorized, "Unauthorized", nil, nil)
+			w.Header().Set("WWW-Authenticate", authenticateChallenge("invalid_request", err.Error()))
+			helpers.SendResponse(w, http.StatusBadRequest, "Bad Request", nil, err)
+			return
+		}
+		if tokenStr == "" {
+			sendUnauthorized(w, "", "Unauthorized", nil)
+			return
+		}
+		if isAPIKey(tokenStr) {
+			principal, ok := h.authenticateAPIKey(w, r, tokenStr)
+			if !ok {
 				return
 			}
-			helpers.SendResponse(w, http.StatusBadRequest, "Bad Request", nil, err)
+			next.ServeHTTP(w, r.WithContext(withPrincipal(r.Context(), principal)))
 			return
 		}
-		tokenStr := cookie.Value
 
-		token, err := jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
-			// Validate the signing method
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-			}
-			// Return the secret key
-			return []byte("n8&DfaT4cCJ424EEuwd"), nil
-		})
+		token, err := h.config.Keys.Parse(tokenStr, jwt.MapClaims{}, jwt.WithTimeFunc(h.now))
 
 		if err != nil || !token.Valid {
-			helpers.SendResponse(w, http.StatusUnauthorized, "Token has expired buddy", nil, err)
+			sendUnauthorized(w, "invalid_token", "Token has expired buddy", err)
+			return
+		}
+
+		claims, ok := token.Claims.(jwt.MapClaims)
+		if !ok {
+			sendUnauthorized(w, "invalid_token", "Unauthorized", nil)
+			return
+		}
+		subject, err := claims.GetSubject()
+		if err != nil || subject == "" {
+			sendUnauthorized(w, "invalid_token", "Unauthorized", err)
+			return
+		}
+		// Purpose tokens such as email verification links are not access tokens.
+		if purpose, _ := claims["purpose"].(string); purpose != "" {
+			sendUnauthorized(w, "invalid_token", "Unauthorized", nil)
 			return
 		}
+		sessionId, _ := claims["sid"].(string)
+		if !h.checkSession(w, r, subject, sessionId) {
+			return
+		}
+		username, _ := claims["username"].(string)
+		isVerified, _ := claims["isVerified"].(bool)
+		var roles []string
+		if values, ok := claims["roles"].([]interface{}); ok {
+			for _, value := range values {
+				if role, ok := value.(string); ok {
+					roles = append(roles, role)
+				}
+			}
+		}
+
+		ctx := withPrincipal(r.Context(), &Principal{
+			UserId:     subject,
+			Username:   username,
+			IsVerified: isVerified,
+			Roles:      roles,
+			SessionId:  sessionId,
+		})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// accessTokenFromRequest returns the access token of a request. An
+// Authorization header takes precedence over the token cookie; when the
+// header is present but not a Bearer credential the request is rejected
+// rather than silently falling back to the cookie.
+func accessTokenFromRequest(r *http.Request) (string, error) {
+	if header := r.Header.Get("Authorization"); header != "" {
+		scheme, token, ok := strings.Cut(header, " ")
+		token = strings.TrimSpace(token)
+		if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+			return "", errors.New("authorization header must use the Bearer scheme")
+		}
+		return token, nil
+	}
+	if cookie, err := r.Cookie(accessTokenCookie); err == nil {
+		return cookie.Value, nil
+	}
+	return "", nil
+}
+
+// sendUnauthorized writes a 401 with an RFC 6750 WWW-Authenticate challenge.
+// code is the OAuth error code and is left out when the request carried no
+// credentials at all.
+func sendUnauthorized(w http.ResponseWriter, code, message string, err error) {
+	w.Header().Set("WWW-Authenticate", authenticateChallenge(code, message))
+	helpers.SendResponse(w, http.StatusUnauthorized, message, nil, err)
+}
+
+func authenticateChallenge(code, description string) string {
+	challenge := `Bearer realm="` + auth.Issuer + `"`
+	if code != "" {
+		challenge += `, error="` + code + `"`
+		if description != "" {
+			challenge += `, error_description="` + strings.ReplaceAll(description, `"`, "'") + `"`
+		}
+	}
+	return challenge
+}
+
+// RequireScope rejects API keys that were not granted scope.
+func RequireScope(scope string) func(http.Handler) http.Handler {
+	return RequireAccess(scope, scope)
+}
 
-		_, ok := token.Claims.(jwt.MapClaims)
+// RequireAccess rejects API keys lacking readScope for safe methods or
+// writeScope for everything else. It is applied per route group.
+func RequireAccess(readScope, writeScope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal, ok := PrincipalFromContext(r.Context())
+			if !ok {
+				sendUnauthorized(w, "", "Unauthorized", nil)
+				return
+			}
+			scope := writeScope
+			if r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions {
+				scope = readScope
+			}
+			if !principal.HasScope(scope) {
+				w.Header().Set("WWW-Authenticate", authenticateChallenge("insufficient_scope", "Requires "+scope))
+				helpers.SendResponse(w, http.StatusForbidden, "API key is missing scope "+scope, nil, nil)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireSession rejects API keys. It guards credential management so a
+// leaked key cannot be used to mint more keys or end the owner's sessions.
+func RequireSession(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := PrincipalFromContext(r.Context())
 		if !ok {
-			helpers.SendResponse(w, http.StatusUnauthorized, "Unauthorized", nil, nil)
+			sendUnauthorized(w, "", "Unauthorized", nil)
+			return
+		}
+		if principal.IsAPIKey() {
+			helpers.SendResponse(w, http.StatusForbidden, "API keys cannot access this route", nil, nil)
 			return
 		}
 		next.ServeHTTP(w, r)
 	})
 }
+
+// RequireRole only lets callers holding role through. It must run after
+// AuthMiddleware.
+func RequireRole(role string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal, ok := PrincipalFromContext(r.Context())
+			if !ok {
+				sendUnauthorized(w, "", "Unauthorized", nil)
+				return
+			}
+			if !principal.HasRole(role) {
+				w.Header().Set("WWW-Authenticate", authenticateChallenge("insufficient_scope", ""))
+				helpers.SendResponse(w, http.StatusForbidden, "Forbidden", nil, nil)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// JWKS publishes the public keys of our asymmetric signing keys so other
+// services can verify the tokens we issue.
+func (h *Handler) JWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"keys": h.config.Keys.JWKS()})
+}
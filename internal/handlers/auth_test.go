@@ -0,0 +1,68 @@
+package handlers_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/amrohan/expenso-go/internal/handlers"
+)
+
+func TestAccessTokenSources(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t, handlers.Config{})
+	quinn := s.register("quinn")
+
+	get := func(authorization, cookie string) *http.Response {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodGet, s.url+"/api/category/", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		if cookie != "" {
+			req.AddCookie(&http.Cookie{Name: "token", Value: cookie})
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	tests := []struct {
+		name          string
+		authorization string
+		cookie        string
+		status        int
+		challenge     string
+	}{
+		{"bearer", "Bearer " + quinn.Token, "", http.StatusOK, ""},
+		{"lowercase scheme", "bearer " + quinn.Token, "", http.StatusOK, ""},
+		{"cookie", "", quinn.Token, http.StatusOK, ""},
+		// The header wins over the cookie, either way round.
+		{"valid header, stale cookie", "Bearer " + quinn.Token, "stale", http.StatusOK, ""},
+		{"stale header, valid cookie", "Bearer stale", quinn.Token, http.StatusUnauthorized, `error="invalid_token"`},
+		{"no credentials", "", "", http.StatusUnauthorized, `Bearer realm=`},
+		{"basic scheme", "Basic cXVpbm46cGFzcw==", "", http.StatusBadRequest, `error="invalid_request"`},
+	}
+	for _, tt := range tests {
+		resp := get(tt.authorization, tt.cookie)
+		if resp.StatusCode != tt.status {
+			t.Errorf("%s: status %d, want %d", tt.name, resp.StatusCode, tt.status)
+		}
+		challenge := resp.Header.Get("WWW-Authenticate")
+		if tt.challenge == "" && challenge != "" {
+			t.Errorf("%s: unexpected challenge %q", tt.name, challenge)
+		}
+		if tt.challenge != "" && !strings.Contains(challenge, tt.challenge) {
+			t.Errorf("%s: challenge %q, want it to contain %s", tt.name, challenge, tt.challenge)
+		}
+	}
+	if challenge := get("", "").Header.Get("WWW-Authenticate"); strings.Contains(challenge, "error=") {
+		t.Errorf("challenge without credentials = %q, want no error code", challenge)
+	}
+}
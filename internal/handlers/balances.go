@@ -0,0 +1,264 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/amrohan/expenso-go/internal/helpers"
+	"github.com/amrohan/expenso-go/internal/models"
+	"github.com/amrohan/expenso-go/internal/money"
+	"github.com/amrohan/expenso-go/internal/repository"
+	"github.com/go-chi/chi/v5"
+)
+
+// maxBalancePoints bounds a balance history series.
+const maxBalancePoints = 1000
+
+// Balances are computed from the transactions every time rather than
+// stored, so edits, deletes and backdated entries are always reflected.
+
+// balanceEffect is how a transaction changes the balance of its account.
+// Types other than income, expense and transfers leave it alone.
+func balanceEffect(t models.Transaction, amount money.Money) (money.Money, bool) {
+	switch {
+	case t.IsTransfer() && t.TransferDirection == models.TransferIn, !t.IsTransfer() && t.Type == models.TransactionIncome:
+		return amount, true
+	case t.IsTransfer() && t.TransferDirection == models.TransferOut, !t.IsTransfer() && t.Type == models.TransactionExpense:
+		return amount.Neg(), true
+	}
+	return money.Money{}, false
+}
+
+// ledger is an account's opening balance and the transactions that move
+// it, oldest first.
+type ledger struct {
+	account      *models.Account
+	currency     string
+	opening      money.Money
+	transactions []models.Transaction
+}
+
+// loadLedger reads the account's transactions dated from its opening date
+// up to, but excluding, until.
+func (h *Handler) loadLedger(ctx context.Context, account *models.Account, until time.Time) (*ledger, error) {
+	currency := h.accountCurrency(account)
+	opening, err := account.OpeningBalance.WithDefaultCurrency(currency)
+	if err != nil {
+		return nil, err
+	}
+	transactions, err := h.store.Transactions.Find(ctx, repository.TransactionFilter{
+		UserId:    account.UserId,
+		AccountId: account.Id.Hex(),
+		From:      account.OpeningDate,
+		To:        until,
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(transactions, func(i, j int) bool {
+		return transactions[i].Date.Before(transactions[j].Date)
+	})
+	return &ledger{account: account, currency: currency, opening: opening, transactions: transactions}, nil
+}
+
+// walk calls fn with the balance before each cutoff, which must be in
+// ascending order.
+func (l *ledger) walk(cutoffs []time.Time, fn func(cutoff time.Time, balance money.Money)) error {
+	balance := l.opening
+	next := 0
+	for _, cutoff := range cutoffs {
+		for ; next < len(l.transactions) && l.transactions[next].Date.Before(cutoff); next++ {
+			t := l.transactions[next]
+			amount, err := t.Amount.WithDefaultCurrency(l.currency)
+			if err != nil {
+				return err
+			}
+			effect, ok := balanceEffect(t, amount)
+			if !ok {
+				continue
+			}
+			if balance, err = balance.Add(effect); err != nil {
+				return err
+			}
+		}
+		fn(cutoff, balance)
+	}
+	return nil
+}
+
+// balanceAt returns the balance at the end of the UTC day of date.
+func (h *Handler) balanceAt(ctx context.Context, account *models.Account, date time.Time) (money.Money, error) {
+	until := rateDay(date).AddDate(0, 0, 1)
+	ledger, err := h.loadLedger(ctx, account, until)
+	if err != nil {
+		return money.Money{}, err
+	}
+	var balance money.Money
+	err = ledger.walk([]time.Time{until}, func(_ time.Time, b money.Money) { balance = b })
+	return balance, err
+}
+
+// accountView is an account with its balance as of today.
+type accountView struct {
+	models.Account
+	Balance money.Money `json:"balance"`
+}
+
+func (h *Handler) accountViews(ctx context.Context, accounts []models.Account) ([]accountView, error) {
+	views := make([]accountView, 0, len(accounts))
+	for i := range accounts {
+		view, err := h.accountView(ctx, &accounts[i])
+		if err != nil {
+			return nil, err
+		}
+		views = append(views, view)
+	}
+	return views, nil
+}
+
+func (h *Handler) accountView(ctx context.Context, account *models.Account) (accountView, error) {
+	balance, err := h.balanceAt(ctx, account, h.now())
+	if err != nil {
+		return accountView{}, err
+	}
+	view := accountView{Account: *account, Balance: balance}
+	view.Currency = h.accountCurrency(account)
+	view.OpeningBalance, err = account.OpeningBalance.WithDefaultCurrency(view.Currency)
+	return view, err
+}
+
+// GetAccountBalance returns the balance at the end of the date query
+// parameter, a day such as "2026-10-17" that defaults to today.
+func (h *Handler) GetAccountBalance(w http.ResponseWriter, r *http.Request) {
+	account, ok := h.ownedAccount(w, r, chi.URLParam(r, "id"))
+	if !ok {
+		return
+	}
+	date := h.now()
+	if value := r.URL.Query().Get("date"); value != "" {
+		var err error
+		if date, err = time.Parse(rateDateLayout, value); err != nil {
+			helpers.SendResponse(w, http.StatusBadRequest, "Please send a valid date", nil, err)
+			return
+		}
+	}
+	if !account.OpeningDate.IsZero() && rateDay(date).AddDate(0, 0, 1).Before(account.OpeningDate) {
+		helpers.SendResponse(w, http.StatusBadRequest, "Date is before the account was opened", nil, nil)
+		return
+	}
+
+	balance, err := h.balanceAt(r.Context(), account, date)
+	if err != nil {
+		helpers.SendResponse(w, http.StatusInternalServerError, "Couldnt compute balance", nil, err)
+		return
+	}
+	helpers.SendResponse(w, http.StatusOK, "Balance found", map[string]interface{}{
+		"accountId": account.Id.Hex(),
+		"date":      rateDay(date).Format(rateDateLayout),
+		"balance":   balance,
+	}, nil)
+}
+
+// balancePoint is the balance at the end of Date.
+type balancePoint struct {
+	Date    string      `json:"date"`
+	Balance money.Money `json:"balance"`
+}
+
+// GetAccountBalanceHistory returns the closing balance of every day, week or
+// month (the interval query parameter, day by default) between from and to.
+// from defaults to the opening date, or the first transaction, and to to
+// today.
+func (h *Handler) GetAccountBalanceHistory(w http.ResponseWriter, r *http.Request) {
+	account, ok := h.ownedAccount(w, r, chi.URLParam(r, "id"))
+	if !ok {
+		return
+	}
+	query := r.URL.Query()
+	var from, to time.Time
+	for name, t := range map[string]*time.Time{"from": &from, "to": &to} {
+		if value := query.Get(name); value != "" {
+			parsed, err := time.Parse(rateDateLayout, value)
+			if err != nil {
+				helpers.SendResponse(w, http.StatusBadRequest, "Please send a valid "+name+" date", nil, err)
+				return
+			}
+			*t = parsed
+		}
+	}
+	step := map[string]func(time.Time) time.Time{
+		"day":   func(t time.Time) time.Time { return t.AddDate(0, 0, 1) },
+		"week":  func(t time.Time) time.Time { return t.AddDate(0, 0, 7) },
+		"month": func(t time.Time) time.Time { return t.AddDate(0, 1, 0) },
+	}
+	interval := query.Get("interval")
+	if interval == "" {
+		interval = "day"
+	}
+	next, ok := step[interval]
+	if !ok {
+		helpers.SendResponse(w, http.StatusBadRequest, "Interval must be day, week or month", nil, nil)
+		return
+	}
+
+	if to.IsZero() {
+		to = rateDay(h.now())
+	}
+	ledger, err := h.loadLedger(r.Context(), account, to.AddDate(0, 0, 1))
+	if err != nil {
+		helpers.SendResponse(w, http.StatusInternalServerError, "Couldnt compute balance", nil, err)
+		return
+	}
+	if from.IsZero() {
+		switch {
+		case !account.OpeningDate.IsZero():
+			from = account.OpeningDate
+		case len(ledger.transactions) > 0:
+			from = ledger.transactions[0].Date
+		default:
+			from = account.CreatedAt
+		}
+	}
+	if from.Before(account.OpeningDate) {
+		from = account.OpeningDate
+	}
+	from = rateDay(from)
+	if interval == "month" {
+		// Month steps from the 31st would skip short months.
+		from = from.AddDate(0, 0, 1-from.Day())
+	}
+	if to.Before(from) {
+		helpers.SendResponse(w, http.StatusBadRequest, "Please send a from date before the to date", nil, nil)
+		return
+	}
+
+	// Each point closes its period; the last one always closes on to.
+	var cutoffs []time.Time
+	for day := from; !day.After(to); day = next(day) {
+		if len(cutoffs) == maxBalancePoints {
+			helpers.SendResponse(w, http.StatusBadRequest, "Too many points, please use a shorter range or a longer interval", nil, nil)
+			return
+		}
+		end := next(day)
+		if end.After(to) {
+			end = to.AddDate(0, 0, 1)
+		}
+		cutoffs = append(cutoffs, end)
+	}
+
+	points := make([]balancePoint, 0, len(cutoffs))
+	err = ledger.walk(cutoffs, func(cutoff time.Time, balance money.Money) {
+		points = append(points, balancePoint{Date: cutoff.AddDate(0, 0, -1).Format(rateDateLayout), Balance: balance})
+	})
+	if err != nil {
+		helpers.SendResponse(w, http.StatusInternalServerError, "Couldnt compute balance", nil, err)
+		return
+	}
+	helpers.SendResponse(w, http.StatusOK, "Balance history found", map[string]interface{}{
+		"accountId": account.Id.Hex(),
+		"interval":  interval,
+		"points":    points,
+	}, nil)
+}
@@ -0,0 +1,86 @@
+package handlers_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/amrohan/expenso-go/internal/handlers"
+)
+
+func TestAccountBalances(t *testing.T) {
+	t.Parallel()
+	now := &clock{now: time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)}
+	s := newTestServer(t, handlers.Config{Now: now.Now})
+	kim := s.register("kim")
+	accountId := s.createdId(kim.Token, "/api/account/", map[string]interface{}{
+		"title":          "Bank",
+		"openingBalance": map[string]string{"amount": "1000", "currency": "INR"},
+		"openingDate":    "2026-10-01T00:00:00Z",
+	})
+	spend := func(kind string, amount int, date string) string {
+		return s.createdId(kim.Token, "/api/transaction/", map[string]interface{}{
+			"title": kind, "amount": amount, "date": date, "type": kind, "accountId": accountId,
+		})
+	}
+	rent := spend("Expense", 100, "2026-10-05T10:00:00Z")
+	salary := spend("Income", 50, "2026-10-10T10:00:00Z")
+	// Before the opening date, so already part of the opening balance.
+	spend("Expense", 30, "2026-09-28T10:00:00Z")
+
+	balance := func(query string) string {
+		var got struct {
+			Balance amount `json:"balance"`
+		}
+		s.decode(s.expect(http.StatusOK, kim.Token, http.MethodGet, "/api/account/"+accountId+"/balance"+query, nil), &got)
+		return got.Balance.Amount
+	}
+	if got := balance("?date=2026-10-05"); got != "900.00" {
+		t.Errorf("balance on the 5th = %s, want 900.00", got)
+	}
+	if got := balance(""); got != "950.00" {
+		t.Errorf("balance today = %s, want 950.00", got)
+	}
+	s.expect(http.StatusBadRequest, kim.Token, http.MethodGet, "/api/account/"+accountId+"/balance?date=2026-09-20", nil)
+	s.expect(http.StatusBadRequest, kim.Token, http.MethodGet, "/api/account/"+accountId+"/balance?date=yesterday", nil)
+
+	// Edits, deletes and backdated entries show up straight away.
+	s.expect(http.StatusOK, kim.Token, http.MethodPut, "/api/transaction/", map[string]interface{}{
+		"id": salary, "title": "Salary", "amount": 80, "date": "2026-10-10T10:00:00Z", "type": "Income", "accountId": accountId,
+	})
+	s.expect(http.StatusOK, kim.Token, http.MethodDelete, "/api/transaction/"+rent, nil)
+	spend("Expense", 20, "2026-10-02T10:00:00Z")
+	if got := balance(""); got != "1060.00" {
+		t.Errorf("balance after changes = %s, want 1060.00", got)
+	}
+	var account struct {
+		Balance amount `json:"balance"`
+	}
+	s.decode(s.expect(http.StatusOK, kim.Token, http.MethodGet, "/api/account/"+accountId, nil), &account)
+	if account.Balance.Amount != "1060.00" {
+		t.Errorf("account balance = %s, want 1060.00", account.Balance.Amount)
+	}
+
+	var history struct {
+		Points []struct {
+			Date    string `json:"date"`
+			Balance amount `json:"balance"`
+		} `json:"points"`
+	}
+	s.decode(s.expect(http.StatusOK, kim.Token, http.MethodGet,
+		"/api/account/"+accountId+"/balance/history?from=2026-09-25&to=2026-10-12&interval=week", nil), &history)
+	// The range starts at the opening date and the last week closes on to.
+	want := [][2]string{{"2026-10-07", "980.00"}, {"2026-10-12", "1060.00"}}
+	if len(history.Points) != len(want) {
+		t.Fatalf("history = %+v, want %v", history.Points, want)
+	}
+	for i, point := range history.Points {
+		if point.Date != want[i][0] || point.Balance.Amount != want[i][1] {
+			t.Errorf("point %d = %s %s, want %s %s", i, point.Date, point.Balance.Amount, want[i][0], want[i][1])
+		}
+	}
+	s.expect(http.StatusBadRequest, kim.Token, http.MethodGet, "/api/account/"+accountId+"/balance/history?interval=year", nil)
+
+	other := s.register("lee")
+	s.expect(http.StatusNotFound, other.Token, http.MethodGet, "/api/account/"+accountId+"/balance", nil)
+}
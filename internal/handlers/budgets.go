@@ -0,0 +1,555 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/amrohan/expenso-go/internal/helpers"
+	"github.com/amrohan/expenso-go/internal/models"
+	"github.com/amrohan/expenso-go/internal/money"
+	"github.com/amrohan/expenso-go/internal/repository"
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const budgetMonthLayout = "2006-01"
+
+// maxRolloverMonths bounds how far back a rollover chain is followed.
+const maxRolloverMonths = 120
+
+type budgetRequest struct {
+	CategoryId string      `json:"categoryId"`
+	Month      string      `json:"month"`
+	Amount     money.Money `json:"amount"`
+	Rollover   bool        `json:"rollover"`
+}
+
+func (h *Handler) CreateBudget(w http.ResponseWriter, r *http.Request) {
+	var req budgetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		helpers.SendResponse(w, http.StatusBadRequest, "Couldnt decode request", nil, err)
+		return
+	}
+	if _, err := time.Parse(budgetMonthLayout, req.Month); err != nil {
+		helpers.SendResponse(w, http.StatusBadRequest, "Please send a valid month such as 2026-10", nil, err)
+		return
+	}
+	if _, err := h.lookupCategory(r, req.CategoryId); err != nil {
+		sendReferenceError(w, "Category", err)
+		return
+	}
+	amount, ok := h.budgetAmount(w, r, req.Amount)
+	if !ok {
+		return
+	}
+
+	now := h.now()
+	budget := models.Budget{
+		Id:         primitive.NewObjectID(),
+		UserId:     currentUserId(r),
+		CategoryId: req.CategoryId,
+		Month:      req.Month,
+		Amount:     amount,
+		Rollover:   req.Rollover,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	err := h.store.Budgets.Create(r.Context(), &budget)
+	if errors.Is(err, repository.ErrDuplicate) {
+		helpers.SendResponse(w, http.StatusConflict, "Category already has a budget for "+req.Month, nil, nil)
+		return
+	}
+	if err != nil {
+		helpers.SendResponse(w, http.StatusInternalServerError, "Couldnt create budget", nil, err)
+		return
+	}
+	helpers.SendResponse(w, http.StatusOK, "Budget created", budget, nil)
+}
+
+// GetBudgets lists the caller's budgets, optionally for one ?month= or
+// ?categoryId=.
+func (h *Handler) GetBudgets(w http.ResponseWriter, r *http.Request) {
+	budgets, err := h.store.Budgets.Find(r.Context(), repository.BudgetFilter{
+		UserId:     currentUserId(r),
+		CategoryId: r.URL.Query().Get("categoryId"),
+		Month:      r.URL.Query().Get("month"),
+	})
+	if err != nil {
+		helpers.SendResponse(w, http.StatusInternalServerError, "Couldnt find budgets", nil, err)
+		return
+	}
+	helpers.SendResponse(w, http.StatusOK, "Budgets found", budgets, nil)
+}
+
+// UpdateBudget changes the amount and rollover of a budget. Its category
+// and month stay.
+func (h *Handler) UpdateBudget(w http.ResponseWriter, r *http.Request) {
+	budget, ok := h.ownedBudget(w, r)
+	if !ok {
+		return
+	}
+	var req budgetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		helpers.SendResponse(w, http.StatusBadRequest, "Couldnt decode request", nil, err)
+		return
+	}
+	amount, ok := h.budgetAmount(w, r, req.Amount)
+	if !ok {
+		return
+	}
+	budget.Amount = amount
+	budget.Rollover = req.Rollover
+	budget.UpdatedAt = h.now()
+	if err := h.store.Budgets.Update(r.Context(), budget); err != nil {
+		helpers.SendResponse(w, http.StatusInternalServerError, "Couldnt update budget", nil, err)
+		return
+	}
+	helpers.SendResponse(w, http.StatusOK, "Budget updated", budget, nil)
+}
+
+func (h *Handler) DeleteBudget(w http.ResponseWriter, r *http.Request) {
+	budget, ok := h.ownedBudget(w, r)
+	if !ok {
+		return
+	}
+	if err := h.store.Budgets.Delete(r.Context(), budget.Id); err != nil {
+		helpers.SendResponse(w, http.StatusInternalServerError, "Couldnt delete budget", nil, err)
+		return
+	}
+	helpers.SendResponse(w, http.StatusOK, "Budget deleted", nil, nil)
+}
+
+// CopyBudgets copies the budgets of the month before {month}, or of ?from=,
+// into {month}. Categories already budgeted in {month}, and categories
+// since deleted, are left out.
+func (h *Handler) CopyBudgets(w http.ResponseWriter, r *http.Request) {
+	month, ok := budgetMonth(w, chi.URLParam(r, "month"))
+	if !ok {
+		return
+	}
+	from := month.AddDate(0, -1, 0)
+	if value := r.URL.Query().Get("from"); value != "" {
+		if from, ok = budgetMonth(w, value); !ok {
+			return
+		}
+	}
+	if from.Equal(month) {
+		helpers.SendResponse(w, http.StatusBadRequest, "Cannot copy a month onto itself", nil, nil)
+		return
+	}
+
+	userId := currentUserId(r)
+	source, err := h.store.Budgets.Find(r.Context(), repository.BudgetFilter{UserId: userId, Month: from.Format(budgetMonthLayout)})
+	if err != nil {
+		helpers.SendResponse(w, http.StatusInternalServerError, "Couldnt find budgets", nil, err)
+		return
+	}
+	created := []models.Budget{}
+	skipped := 0
+	now := h.now()
+	for _, b := range source {
+		if _, err := h.lookupCategory(r, b.CategoryId); err != nil {
+			skipped++
+			continue
+		}
+		budget := models.Budget{
+			Id:         primitive.NewObjectID(),
+			UserId:     userId,
+			CategoryId: b.CategoryId,
+			Month:      month.Format(budgetMonthLayout),
+			Amount:     b.Amount,
+			Rollover:   b.Rollover,
+			CreatedAt:  now,
+			UpdatedAt:  now,
+		}
+		err := h.store.Budgets.Create(r.Context(), &budget)
+		if errors.Is(err, repository.ErrDuplicate) {
+			skipped++
+			continue
+		}
+		if err != nil {
+			helpers.SendResponse(w, http.StatusInternalServerError, "Couldnt copy budgets", nil, err)
+			return
+		}
+		created = append(created, budget)
+	}
+	helpers.SendResponse(w, http.StatusOK, "Budgets copied", map[string]interface{}{"created": created, "skipped": skipped}, nil)
+}
+
+// budgetAmounts is the state of a budget in a month. Available is the
+// budget plus what rolled over from the month before; Remaining is what is
+// left of it after spending and is negative when overspent.
+type budgetAmounts struct {
+	Budgeted  money.Money `json:"budgeted"`
+	Carried   money.Money `json:"carried"`
+	Available money.Money `json:"available"`
+	Spent     money.Money `json:"spent"`
+	Remaining money.Money `json:"remaining"`
+}
+
+// budgetLine is one category's budget in a month.
+type budgetLine struct {
+	CategoryId string `json:"categoryId"`
+	BudgetId   string `json:"budgetId,omitempty"`
+	budgetAmounts
+}
+
+// GetBudgetMonth reports budgeted, spent and remaining amounts per category
+// for {month}, such as 2026-10. Spending is the expenses among the
+// transactions GetTransactionByMonthAndYearByUserId returns for the month,
+// split transactions counted by their splits and converted to the base
+// currency. Categories with spending but no budget are listed too.
+func (h *Handler) GetBudgetMonth(w http.ResponseWriter, r *http.Request) {
+	month, ok := budgetMonth(w, chi.URLParam(r, "month"))
+	if !ok {
+		return
+	}
+	userId := currentUserId(r)
+	report, err := h.newBudgetReport(r.Context(), userId).month(month)
+	if err != nil {
+		helpers.SendResponse(w, http.StatusInternalServerError, "Couldnt compute budgets", nil, err)
+		return
+	}
+	helpers.SendResponse(w, http.StatusOK, "Budgets found", report, nil)
+}
+
+// budgetReport computes budget lines, caching each month's spending so
+// rollover chains do not reload it.
+type budgetReport struct {
+	h         *Handler
+	ctx       context.Context
+	userId    string
+	base      string
+	converter *rateConverter
+	spending  map[string]map[string]money.Money
+	missing   []missingRate
+}
+
+func (h *Handler) newBudgetReport(ctx context.Context, userId string) *budgetReport {
+	return &budgetReport{
+		h:         h,
+		ctx:       ctx,
+		userId:    userId,
+		base:      h.baseCurrency(ctx, userId),
+		converter: h.newRateConverter(ctx),
+		spending:  map[string]map[string]money.Money{},
+		missing:   []missingRate{},
+	}
+}
+
+func (b *budgetReport) month(month time.Time) (map[string]interface{}, error) {
+	key := month.Format(budgetMonthLayout)
+	budgets, err := b.h.store.Budgets.Find(b.ctx, repository.BudgetFilter{UserId: b.userId, Month: key})
+	if err != nil {
+		return nil, err
+	}
+	spent, err := b.spent(month)
+	if err != nil {
+		return nil, err
+	}
+
+	zero := money.New(0, b.base)
+	lines := []budgetLine{}
+	budgeted := map[string]bool{}
+	for _, budget := range budgets {
+		carried, err := b.carried(budget, month)
+		if err != nil {
+			return nil, err
+		}
+		amount, err := b.budgeted(budget)
+		if err != nil {
+			return nil, err
+		}
+		line, err := newBudgetLine(budget.CategoryId, amount, carried, spentIn(spent, budget.CategoryId, zero))
+		if err != nil {
+			return nil, err
+		}
+		line.BudgetId = budget.Id.Hex()
+		lines = append(lines, line)
+		budgeted[budget.CategoryId] = true
+	}
+	unbudgeted := []string{}
+	for categoryId := range spent {
+		if !budgeted[categoryId] {
+			unbudgeted = append(unbudgeted, categoryId)
+		}
+	}
+	sort.Strings(unbudgeted)
+	for _, categoryId := range unbudgeted {
+		line, err := newBudgetLine(categoryId, zero, zero, spent[categoryId])
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, line)
+	}
+
+	total := budgetAmounts{Budgeted: zero, Carried: zero, Available: zero, Spent: zero, Remaining: zero}
+	for _, line := range lines {
+		if err := total.add(line.budgetAmounts); err != nil {
+			return nil, err
+		}
+	}
+
+	return map[string]interface{}{
+		"month":        key,
+		"currency":     b.base,
+		"categories":   lines,
+		"totals":       total,
+		"missingRates": b.missing,
+	}, nil
+}
+
+func newBudgetLine(categoryId string, budgeted, carried, spent money.Money) (budgetLine, error) {
+	available, err := budgeted.Add(carried)
+	if err != nil {
+		return budgetLine{}, err
+	}
+	remaining, err := available.Sub(spent)
+	if err != nil {
+		return budgetLine{}, err
+	}
+	return budgetLine{
+		CategoryId: categoryId,
+		budgetAmounts: budgetAmounts{
+			Budgeted:  budgeted,
+			Carried:   carried,
+			Available: available,
+			Spent:     spent,
+			Remaining: remaining,
+		},
+	}, nil
+}
+
+func (l *budgetAmounts) add(o budgetAmounts) error {
+	var err error
+	for _, sum := range []struct {
+		total  *money.Money
+		amount money.Money
+	}{
+		{&l.Budgeted, o.Budgeted},
+		{&l.Carried, o.Carried},
+		{&l.Available, o.Available},
+		{&l.Spent, o.Spent},
+		{&l.Remaining, o.Remaining},
+	} {
+		if *sum.total, err = sum.total.Add(sum.amount); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func spentIn(spent map[string]money.Money, categoryId string, zero money.Money) money.Money {
+	if amount, ok := spent[categoryId]; ok {
+		return amount
+	}
+	return zero
+}
+
+// carried is what rolls into budget from the month before: nothing unless
+// budget has Rollover and the category was budgeted that month, otherwise
+// that month's remaining amount, itself including its own rollover.
+func (b *budgetReport) carried(budget models.Budget, month time.Time) (money.Money, error) {
+	zero := money.New(0, b.base)
+	if !budget.Rollover {
+		return zero, nil
+	}
+	earlier, err := b.h.store.Budgets.Find(b.ctx, repository.BudgetFilter{
+		UserId:     b.userId,
+		CategoryId: budget.CategoryId,
+		Before:     month.Format(budgetMonthLayout),
+	})
+	if err != nil {
+		return zero, err
+	}
+
+	// Walk back over consecutive budgeted months linked by rollover, then
+	// add them up oldest first.
+	var chain []models.Budget
+	expected := month.AddDate(0, -1, 0)
+	for i := len(earlier) - 1; i >= 0 && len(chain) < maxRolloverMonths; i-- {
+		if earlier[i].Month != expected.Format(budgetMonthLayout) {
+			break
+		}
+		chain = append(chain, earlier[i])
+		if !earlier[i].Rollover {
+			break
+		}
+		expected = expected.AddDate(0, -1, 0)
+	}
+
+	carry := zero
+	for i := len(chain) - 1; i >= 0; i-- {
+		previous := chain[i]
+		start, _ := time.Parse(budgetMonthLayout, previous.Month)
+		spent, err := b.spent(start)
+		if err != nil {
+			return zero, err
+		}
+		if !previous.Rollover {
+			carry = zero
+		}
+		amount, err := b.budgeted(previous)
+		if err != nil {
+			return zero, err
+		}
+		line, err := newBudgetLine(previous.CategoryId, amount, carry, spentIn(spent, previous.CategoryId, zero))
+		if err != nil {
+			return zero, err
+		}
+		carry = line.Remaining
+	}
+	return carry, nil
+}
+
+// budgeted is the amount of budget in the base currency. Budgets are stored
+// in the base currency of the day; convert them if it has changed since.
+func (b *budgetReport) budgeted(budget models.Budget) (money.Money, error) {
+	month, err := time.Parse(budgetMonthLayout, budget.Month)
+	if err != nil {
+		return budget.Amount, err
+	}
+	return b.converter.convert(budget.Amount, b.base, month)
+}
+
+// spent totals the month's expenses per category in the base currency.
+func (b *budgetReport) spent(month time.Time) (map[string]money.Money, error) {
+	key := month.Format(budgetMonthLayout)
+	if spent, ok := b.spending[key]; ok {
+		return spent, nil
+	}
+	activity, err := b.activity(month, month.AddDate(0, 1, 0))
+	if err != nil {
+		return nil, err
+	}
+	b.spending[key] = activity.spent
+	return activity.spent, nil
+}
+
+// budgetActivity is what came in and went out between two dates, in the
+// base currency. Spent is per category, split transactions counted by
+// their splits.
+type budgetActivity struct {
+	spent  map[string]money.Money
+	income money.Money
+}
+
+// activity totals expenses and income dated from up to, but not including,
+// to. Amounts without an exchange rate are left out and listed in missing.
+func (b *budgetReport) activity(from, to time.Time) (*budgetActivity, error) {
+	transactions, err := b.h.store.Transactions.Find(b.ctx, repository.TransactionFilter{
+		UserId: b.userId,
+		From:   from,
+		To:     to,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	activity := &budgetActivity{spent: map[string]money.Money{}, income: money.New(0, b.base)}
+	for _, transaction := range transactions {
+		switch transaction.Type {
+		case models.TransactionIncome:
+			amount, ok, err := b.convert(&transaction, transaction.Amount)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				continue
+			}
+			if activity.income, err = activity.income.Add(amount); err != nil {
+				return nil, err
+			}
+		case models.TransactionExpense:
+			for _, split := range transaction.CategoryAmounts() {
+				amount, ok, err := b.convert(&transaction, split.Amount)
+				if err != nil {
+					return nil, err
+				}
+				if !ok {
+					continue
+				}
+				total, ok := activity.spent[split.CategoryId]
+				if !ok {
+					total = money.New(0, b.base)
+				}
+				if activity.spent[split.CategoryId], err = total.Add(amount); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return activity, nil
+}
+
+// convert converts an amount of transaction to the base currency at the
+// rate of its date. Without a rate it reports false and lists the
+// transaction in missing, once.
+func (b *budgetReport) convert(transaction *models.Transaction, amount money.Money) (money.Money, bool, error) {
+	amount, err := amount.WithDefaultCurrency(b.h.config.DefaultCurrency)
+	if err != nil {
+		return amount, false, err
+	}
+	converted, err := b.converter.convert(amount, b.base, transaction.Date)
+	if errors.Is(err, errNoRate) {
+		if n := len(b.missing); n == 0 || b.missing[n-1].TransactionId != transaction.Id.Hex() {
+			b.missing = append(b.missing, missingRate{
+				TransactionId: transaction.Id.Hex(),
+				From:          amount.Currency,
+				To:            b.base,
+				Date:          rateDay(transaction.Date).Format(rateDateLayout),
+			})
+		}
+		return converted, false, nil
+	}
+	return converted, err == nil, err
+}
+
+// budgetAmount checks a budget amount: not negative and in the caller's
+// base currency, which it defaults to.
+func (h *Handler) budgetAmount(w http.ResponseWriter, r *http.Request, amount money.Money) (money.Money, bool) {
+	base := h.baseCurrency(r.Context(), currentUserId(r))
+	amount, err := amount.WithDefaultCurrency(base)
+	if err != nil || amount.Currency != base {
+		helpers.SendResponse(w, http.StatusBadRequest, "Budget amounts must be in your base currency, "+base, nil, err)
+		return amount, false
+	}
+	if amount.Amount < 0 {
+		helpers.SendResponse(w, http.StatusBadRequest, "Budget amounts cannot be negative", nil, nil)
+		return amount, false
+	}
+	return amount, true
+}
+
+func budgetMonth(w http.ResponseWriter, value string) (time.Time, bool) {
+	month, err := time.Parse(budgetMonthLayout, value)
+	if err != nil {
+		helpers.SendResponse(w, http.StatusBadRequest, "Please send a valid month such as 2026-10", nil, err)
+		return time.Time{}, false
+	}
+	return month, true
+}
+
+// ownedBudget loads the budget named in the URL when it belongs to the
+// caller.
+func (h *Handler) ownedBudget(w http.ResponseWriter, r *http.Request) (*models.Budget, bool) {
+	id, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.SendResponse(w, http.StatusBadRequest, "Invalid id", nil, err)
+		return nil, false
+	}
+	budget, err := h.store.Budgets.FindById(r.Context(), id)
+	if errors.Is(err, repository.ErrNotFound) || (err == nil && !owns(r, budget.UserId)) {
+		helpers.SendResponse(w, http.StatusNotFound, "Budget not found", nil, nil)
+		return nil, false
+	}
+	if err != nil {
+		helpers.SendResponse(w, http.StatusInternalServerError, "Couldnt find budget", nil, err)
+		return nil, false
+	}
+	return budget, true
+}
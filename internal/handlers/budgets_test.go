@@ -0,0 +1,98 @@
+package handlers_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/amrohan/expenso-go/internal/handlers"
+)
+
+type budgetMonthReport struct {
+	Categories []struct {
+		CategoryId string `json:"categoryId"`
+		Budgeted   amount `json:"budgeted"`
+		Carried    amount `json:"carried"`
+		Available  amount `json:"available"`
+		Spent      amount `json:"spent"`
+		Remaining  amount `json:"remaining"`
+	} `json:"categories"`
+}
+
+type amount struct {
+	Amount   string `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+func TestBudgetRollover(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t, handlers.Config{})
+	gina := s.register("gina")
+	categoryId := s.createdId(gina.Token, "/api/category/", map[string]string{"title": "Groceries"})
+
+	for _, budget := range []struct {
+		month    string
+		amount   int
+		rollover bool
+	}{
+		{"2026-07", 500, false},
+		{"2026-09", 100, true},
+		{"2026-10", 50, true},
+		{"2026-11", 40, false},
+		{"2026-12", 30, true},
+	} {
+		s.createdId(gina.Token, "/api/budget/", map[string]interface{}{
+			"categoryId": categoryId,
+			"month":      budget.month,
+			"amount":     budget.amount,
+			"rollover":   budget.rollover,
+		})
+	}
+	for _, expense := range []struct {
+		date   string
+		amount string
+	}{
+		{"2026-07-20T10:00:00Z", "100"},
+		{"2026-09-03T10:00:00Z", "70.25"},
+		{"2026-09-30T23:59:59Z", "49.75"},
+		{"2026-10-12T10:00:00Z", "10"},
+	} {
+		s.createdId(gina.Token, "/api/transaction/", map[string]interface{}{
+			"title":      "Shopping",
+			"amount":     map[string]string{"amount": expense.amount, "currency": "INR"},
+			"date":       expense.date,
+			"type":       "Expense",
+			"categoryId": categoryId,
+		})
+	}
+
+	tests := []struct {
+		month                                          string
+		budgeted, carried, available, spent, remaining string
+	}{
+		// August has no budget, so nothing rolls over from July.
+		{"2026-09", "100.00", "0.00", "100.00", "120.00", "-20.00"},
+		{"2026-10", "50.00", "-20.00", "30.00", "10.00", "20.00"},
+		{"2026-11", "40.00", "0.00", "40.00", "0.00", "40.00"},
+		// November's remaining rolls into December, but not what November
+		// would have carried itself.
+		{"2026-12", "30.00", "40.00", "70.00", "0.00", "70.00"},
+	}
+	for _, tt := range tests {
+		var report budgetMonthReport
+		s.decode(s.expect(http.StatusOK, gina.Token, http.MethodGet, "/api/budget/month/"+tt.month, nil), &report)
+		if len(report.Categories) != 1 {
+			t.Fatalf("%s: %d categories, want 1", tt.month, len(report.Categories))
+		}
+		line := report.Categories[0]
+		got := []string{line.Budgeted.Amount, line.Carried.Amount, line.Available.Amount, line.Spent.Amount, line.Remaining.Amount}
+		want := []string{tt.budgeted, tt.carried, tt.available, tt.spent, tt.remaining}
+		for i, name := range []string{"budgeted", "carried", "available", "spent", "remaining"} {
+			if got[i] != want[i] {
+				t.Errorf("%s %s = %s, want %s", tt.month, name, got[i], want[i])
+			}
+		}
+		if line.Remaining.Currency != "INR" {
+			t.Errorf("%s currency = %s, want INR", tt.month, line.Remaining.Currency)
+		}
+	}
+}
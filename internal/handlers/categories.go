@@ -3,22 +3,22 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"time"
 
-	"github.com/amrohan/expenso-go/internal/db"
 	"github.com/amrohan/expenso-go/internal/helpers"
 	"github.com/amrohan/expenso-go/internal/models"
+	"github.com/amrohan/expenso-go/internal/repository"
 	"github.com/go-chi/chi/v5"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-func CreateCategory(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) CreateCategory(w http.ResponseWriter, r *http.Request) {
 	category := models.Category{
 		Id:        primitive.NewObjectID(),
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
+		CreatedAt: h.now(),
+		UpdatedAt: h.now(),
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&category); err != nil {
@@ -26,114 +26,89 @@ func CreateCategory(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	category.Id = primitive.NewObjectID()
+	category.UserId = currentUserId(r)
+	category.IsDeleted = false
+	category.DeletedAt = time.Time{}
 
-	client, err := db.GetMongoClient()
-	if err != nil {
-		helpers.SendResponse(w, http.StatusInternalServerError, "Error connecting to database", nil, err)
-		return
-	}
-
-	collection := client.Database(db.Database).Collection(string(db.CategoryCollection))
-
-	data, err := collection.InsertOne(r.Context(), category)
-	if err != nil {
+	if err := h.store.Categories.Create(r.Context(), &category); err != nil {
 		helpers.SendResponse(w, http.StatusInternalServerError, "Error creating category", nil, err)
 		return
 	}
-	category.Id = data.InsertedID.(primitive.ObjectID)
+	h.recordRevision(r, "category", category.Id, category.UserId, models.RevisionCreate, nil, &category)
+	h.auditChange(r, models.AuditCategoryCreate, "category", category.Id, nil, &category)
 	helpers.SendResponse(w, http.StatusCreated, "Category created successfully", category, nil)
 }
 
-func GetAllCategory(w http.ResponseWriter, r *http.Request) {
-	client, err := db.GetMongoClient()
+func (h *Handler) GetAllCategory(w http.ResponseWriter, r *http.Request) {
+	categories, err := h.store.Categories.Find(r.Context(), repository.CategoryFilter{UserId: currentUserId(r)})
 	if err != nil {
-		helpers.SendResponse(w, http.StatusInternalServerError, "Error connecting to database", nil, err)
+		helpers.SendResponse(w, http.StatusInternalServerError, "Error fetching categories", nil, err)
 		return
 	}
+	helpers.SendResponse(w, http.StatusOK, "Categories fetched successfully", categories, nil)
+}
 
-	collection := client.Database(db.Database).Collection(string(db.CategoryCollection))
-
-	var categories []models.Category
-
-	cur, err := collection.Find(context.TODO(), bson.D{
-		primitive.E{},
-	})
-
-	if err != nil {
-		helpers.SendResponse(w, http.StatusInternalServerError, "Error fetching categories", nil, err)
+func (h *Handler) GetCategoryById(w http.ResponseWriter, r *http.Request) {
+	category, ok := h.ownedCategory(w, r, chi.URLParam(r, "id"))
+	if !ok {
 		return
 	}
-	for cur.Next(r.Context()) {
-		var category *models.Category
-		if err := cur.Decode(&category); err != nil {
-			helpers.SendResponse(w, http.StatusInternalServerError, "Error decoding category", nil, err)
-			return
-		}
-		categories = append(categories, *category)
-	}
-	helpers.SendResponse(w, http.StatusOK, "Categories fetched successfully", categories, nil)
+	helpers.SendResponse(w, http.StatusOK, "Category fetched successfully", category, nil)
+}
+
+// ownedCategory loads a category belonging to the caller. When the id is invalid,
+// missing, in the trash or owned by someone else the response is written here and ok is
+// false.
+func (h *Handler) ownedCategory(w http.ResponseWriter, r *http.Request, idHex string) (*models.Category, bool) {
+	return h.findCategory(w, r, idHex, false)
+}
+
+// trashedCategory is ownedCategory for categories in the trash.
+func (h *Handler) trashedCategory(w http.ResponseWriter, r *http.Request, idHex string) (*models.Category, bool) {
+	return h.findCategory(w, r, idHex, true)
 }
 
-func GetCategoryById(w http.ResponseWriter, r *http.Request) {
-	id, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+func (h *Handler) findCategory(w http.ResponseWriter, r *http.Request, idHex string, deleted bool) (*models.Category, bool) {
+	id, err := primitive.ObjectIDFromHex(idHex)
 	if err != nil {
 		helpers.SendResponse(w, http.StatusBadRequest, "Invalid id", nil, err)
+		return nil, false
 	}
 
-	client, err := db.GetMongoClient()
-	if err != nil {
-		helpers.SendResponse(w, http.StatusInternalServerError, "Error connecting to database", nil, err)
-		return
+	category, err := h.store.Categories.FindById(r.Context(), id)
+	if errors.Is(err, repository.ErrNotFound) || (err == nil && (!owns(r, category.UserId) || category.IsDeleted != deleted)) {
+		helpers.SendResponse(w, http.StatusNotFound, "Category not found", nil, nil)
+		return nil, false
 	}
-
-	collection := client.Database(db.Database).Collection(string(db.CategoryCollection))
-
-	var category *models.Category
-
-	if err := collection.FindOne(r.Context(), bson.M{"_id": id}).Decode(&category); err != nil {
+	if err != nil {
 		helpers.SendResponse(w, http.StatusInternalServerError, "Error fetching category", nil, err)
-		return
+		return nil, false
 	}
-	helpers.SendResponse(w, http.StatusOK, "Category fetched successfully", category, nil)
+	return category, true
 }
 
-func GetCategoryByUserId(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) GetCategoryByUserId(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 	if id == "" {
 		helpers.SendResponse(w, http.StatusBadRequest, "Please provide a valid id", nil, nil)
 		return
 	}
-
-	client, err := db.GetMongoClient()
-	if err != nil {
-		helpers.SendResponse(w, http.StatusInternalServerError, "Error connecting to database", nil, err)
+	if !owns(r, id) {
+		helpers.SendResponse(w, http.StatusNotFound, "User not found", nil, nil)
 		return
 	}
 
-	collection := client.Database(db.Database).Collection(string(db.CategoryCollection))
-
-	var categories []*models.Category
-
-	cur, err := collection.Find(context.TODO(), bson.M{"userId": id})
-
+	categories, err := h.store.Categories.Find(r.Context(), repository.CategoryFilter{UserId: id})
 	if err != nil {
 		helpers.SendResponse(w, http.StatusInternalServerError, "Error fetching categories", nil, err)
 		return
 	}
-	for cur.Next(r.Context()) {
-		var category models.Category
-		if err := cur.Decode(&category); err != nil {
-			helpers.SendResponse(w, http.StatusInternalServerError, "Error decoding category", nil, err)
-			return
-		}
-		categories = append(categories, &category)
-	}
 	helpers.SendResponse(w, http.StatusOK, "Categories fetched successfully", categories, nil)
 }
 
-func UpdateCategory(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) UpdateCategory(w http.ResponseWriter, r *http.Request) {
 	category := models.Category{
-		UpdatedAt: time.Now(),
+		UpdatedAt: h.now(),
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&category); err != nil {
@@ -141,38 +116,95 @@ func UpdateCategory(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	client, err := db.GetMongoClient()
-	if err != nil {
-		helpers.SendResponse(w, http.StatusInternalServerError, "Error connecting to database", nil, err)
+	existing, ok := h.ownedCategory(w, r, category.Id.Hex())
+	if !ok {
 		return
 	}
-	collection := client.Database(db.Database).Collection(string(db.CategoryCollection))
+	category.UserId = currentUserId(r)
+	category.IsDeleted = false
+	category.DeletedAt = time.Time{}
 
-	data, err := collection.UpdateOne(r.Context(), bson.M{"_id": category.Id}, bson.M{"$set": category})
-	if err != nil {
+	if err := h.store.Categories.Update(r.Context(), &category); err != nil {
 		helpers.SendResponse(w, http.StatusInternalServerError, "Error updating category", nil, err)
 		return
 	}
-	helpers.SendResponse(w, http.StatusOK, "Category updated successfully", data, nil)
+	h.recordRevision(r, "category", category.Id, category.UserId, models.RevisionUpdate, existing, &category)
+	h.auditChange(r, models.AuditCategoryUpdate, "category", category.Id, existing, &category)
+	helpers.SendResponse(w, http.StatusOK, "Category updated successfully", category, nil)
 }
 
-func DeleteCategory(w http.ResponseWriter, r *http.Request) {
-	id, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
-	if err != nil {
-		helpers.SendResponse(w, http.StatusBadRequest, "Invalid id", nil, err)
+func (h *Handler) DeleteCategory(w http.ResponseWriter, r *http.Request) {
+	category, ok := h.ownedCategory(w, r, chi.URLParam(r, "id"))
+	if !ok {
+		return
 	}
 
-	client, err := db.GetMongoClient()
+	deleted := *category
+	deleted.IsDeleted = true
+	deleted.DeletedAt = h.now()
+	moved, ok := h.deleteWithDependents(w, r, "Category", category.Id.Hex(), deleted.DeletedAt,
+		repository.TransactionFilter{UserId: category.UserId, CategoryId: category.Id.Hex()},
+		func(idHex string) error {
+			_, err := h.lookupCategory(r, idHex)
+			return err
+		},
+		func(t *models.Transaction, idHex string) { reassignCategory(t, category.Id.Hex(), idHex) },
+		func(ctx context.Context) error {
+			return h.store.Categories.Delete(ctx, category.Id, deleted.DeletedAt)
+		},
+	)
+	if !ok {
+		return
+	}
+	h.recordRevision(r, "category", category.Id, category.UserId, models.RevisionDelete, category, &deleted)
+	h.auditChange(r, models.AuditCategoryDelete, "category", category.Id, category, &deleted)
+	helpers.SendResponse(w, http.StatusOK, "Category deleted successfully", map[string]interface{}{"transactions": moved}, nil)
+}
+
+// reassignCategory moves t, or the splits of t, in category from to
+// category to.
+func reassignCategory(t *models.Transaction, from, to string) {
+	if t.CategoryId == from {
+		t.CategoryId = to
+	}
+	if len(t.Splits) == 0 {
+		return
+	}
+	splits := make([]models.Split, len(t.Splits))
+	for i, split := range t.Splits {
+		if split.CategoryId == from {
+			split.CategoryId = to
+		}
+		splits[i] = split
+	}
+	t.Splits = splits
+}
+
+// GetCategoryTrash lists the caller's deleted categories that have not been purged
+// yet.
+func (h *Handler) GetCategoryTrash(w http.ResponseWriter, r *http.Request) {
+	categories, err := h.store.Categories.Find(r.Context(), repository.CategoryFilter{UserId: currentUserId(r), DeletedOnly: true})
 	if err != nil {
-		helpers.SendResponse(w, http.StatusInternalServerError, "Error connecting to database", nil, err)
+		helpers.SendResponse(w, http.StatusInternalServerError, "Couldnt find categories", nil, err)
 		return
 	}
+	helpers.SendResponse(w, http.StatusOK, "Categories found", categories, nil)
+}
 
-	collection := client.Database(db.Database).Collection(string(db.CategoryCollection))
+func (h *Handler) RestoreCategory(w http.ResponseWriter, r *http.Request) {
+	category, ok := h.trashedCategory(w, r, chi.URLParam(r, "id"))
+	if !ok {
+		return
+	}
 
-	if _, err := collection.DeleteOne(r.Context(), bson.M{"_id": id}); err != nil {
-		helpers.SendResponse(w, http.StatusInternalServerError, "Error deleting category", nil, err)
+	if err := h.store.Categories.Restore(r.Context(), category.Id); err != nil {
+		helpers.SendResponse(w, http.StatusInternalServerError, "Error restoring category", nil, err)
 		return
 	}
-	helpers.SendResponse(w, http.StatusOK, "Category deleted successfully", nil, nil)
+	restored := *category
+	restored.IsDeleted = false
+	restored.DeletedAt = time.Time{}
+	h.recordRevision(r, "category", category.Id, category.UserId, models.RevisionRestore, nil, &restored)
+	h.auditChange(r, models.AuditCategoryRestore, "category", category.Id, category, &restored)
+	helpers.SendResponse(w, http.StatusOK, "Category restored successfully", restored, nil)
 }
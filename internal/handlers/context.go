@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+)
+
+type contextKey string
+
+const principalKey contextKey = "principal"
+
+// Principal is the authenticated caller of a request, as established by
+// AuthMiddleware.
+type Principal struct {
+	UserId     string
+	Username   string
+	IsVerified bool
+	Roles      []string
+	SessionId  string
+	// APIKeyId and Scopes are set when the request authenticated with a
+	// personal API key instead of a session.
+	APIKeyId string
+	Scopes   []string
+}
+
+func (p *Principal) HasRole(role string) bool {
+	for _, r := range p.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Principal) IsAPIKey() bool {
+	return p.APIKeyId != ""
+}
+
+// HasScope reports whether the caller may use scope. Session logins are not
+// restricted by scopes.
+func (p *Principal) HasScope(scope string) bool {
+	if !p.IsAPIKey() {
+		return true
+	}
+	for _, s := range p.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func withPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalKey, principal)
+}
+
+// PrincipalFromContext returns the caller stored by AuthMiddleware.
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	principal, ok := ctx.Value(principalKey).(*Principal)
+	return principal, ok && principal != nil
+}
+
+// currentUserId returns the id of the authenticated caller. Handlers behind
+// AuthMiddleware can rely on it being non-empty.
+func currentUserId(r *http.Request) string {
+	if principal, ok := PrincipalFromContext(r.Context()); ok {
+		return principal.UserId
+	}
+	return ""
+}
+
+// owns reports whether a document belonging to userId may be accessed by the
+// caller.
+func owns(r *http.Request, userId string) bool {
+	current := currentUserId(r)
+	return current != "" && current == userId
+}
@@ -0,0 +1,298 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/amrohan/expenso-go/internal/helpers"
+	"github.com/amrohan/expenso-go/internal/models"
+	"github.com/amrohan/expenso-go/internal/money"
+	"github.com/amrohan/expenso-go/internal/repository"
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type envelopeAssignRequest struct {
+	CategoryId string      `json:"categoryId"`
+	Amount     money.Money `json:"amount"`
+	Note       string      `json:"note"`
+}
+
+// AssignEnvelope assigns money from the ready to assign pool to the
+// category envelope in the body, in {month}. Assigning more than the pool
+// holds is allowed and reported as overassigned.
+func (h *Handler) AssignEnvelope(w http.ResponseWriter, r *http.Request) {
+	var req envelopeAssignRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		helpers.SendResponse(w, http.StatusBadRequest, "Couldnt decode request", nil, err)
+		return
+	}
+	if req.CategoryId == "" {
+		helpers.SendResponse(w, http.StatusBadRequest, "Please send the categoryId to assign to", nil, nil)
+		return
+	}
+	h.saveEnvelopeMove(w, r, models.EnvelopeMove{ToCategoryId: req.CategoryId, Amount: req.Amount, Note: req.Note})
+}
+
+// MoveEnvelope moves money between envelopes in {month}. An empty
+// fromCategoryId or toCategoryId is the ready to assign pool, so money can
+// also be taken back out of an envelope.
+func (h *Handler) MoveEnvelope(w http.ResponseWriter, r *http.Request) {
+	var move models.EnvelopeMove
+	if err := json.NewDecoder(r.Body).Decode(&move); err != nil {
+		helpers.SendResponse(w, http.StatusBadRequest, "Couldnt decode request", nil, err)
+		return
+	}
+	if move.FromCategoryId == move.ToCategoryId {
+		helpers.SendResponse(w, http.StatusBadRequest, "Please send two different envelopes", nil, nil)
+		return
+	}
+	h.saveEnvelopeMove(w, r, move)
+}
+
+func (h *Handler) saveEnvelopeMove(w http.ResponseWriter, r *http.Request, move models.EnvelopeMove) {
+	month, ok := budgetMonth(w, chi.URLParam(r, "month"))
+	if !ok {
+		return
+	}
+	for _, categoryId := range []string{move.FromCategoryId, move.ToCategoryId} {
+		if categoryId == "" {
+			continue
+		}
+		if _, err := h.lookupCategory(r, categoryId); err != nil {
+			sendReferenceError(w, "Category", err)
+			return
+		}
+	}
+	amount, ok := h.budgetAmount(w, r, move.Amount)
+	if !ok {
+		return
+	}
+	if amount.IsZero() {
+		helpers.SendResponse(w, http.StatusBadRequest, "Please send an amount to move", nil, nil)
+		return
+	}
+
+	move.Id = primitive.NewObjectID()
+	move.UserId = currentUserId(r)
+	move.Month = month.Format(budgetMonthLayout)
+	move.Amount = amount
+	move.CreatedAt = h.now()
+	if err := h.store.EnvelopeMoves.Create(r.Context(), &move); err != nil {
+		helpers.SendResponse(w, http.StatusInternalServerError, "Couldnt move money", nil, err)
+		return
+	}
+	helpers.SendResponse(w, http.StatusOK, "Money moved", move, nil)
+}
+
+// GetEnvelopeMoves lists the caller's assignments and moves, optionally for
+// one ?month=.
+func (h *Handler) GetEnvelopeMoves(w http.ResponseWriter, r *http.Request) {
+	moves, err := h.store.EnvelopeMoves.Find(r.Context(), repository.EnvelopeMoveFilter{
+		UserId: currentUserId(r),
+		Month:  r.URL.Query().Get("month"),
+	})
+	if err != nil {
+		helpers.SendResponse(w, http.StatusInternalServerError, "Couldnt find moves", nil, err)
+		return
+	}
+	helpers.SendResponse(w, http.StatusOK, "Moves found", moves, nil)
+}
+
+// DeleteEnvelopeMove undoes an assignment or move.
+func (h *Handler) DeleteEnvelopeMove(w http.ResponseWriter, r *http.Request) {
+	id, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.SendResponse(w, http.StatusBadRequest, "Invalid id", nil, err)
+		return
+	}
+	move, err := h.store.EnvelopeMoves.FindById(r.Context(), id)
+	if errors.Is(err, repository.ErrNotFound) || (err == nil && !owns(r, move.UserId)) {
+		helpers.SendResponse(w, http.StatusNotFound, "Move not found", nil, nil)
+		return
+	}
+	if err == nil {
+		err = h.store.EnvelopeMoves.Delete(r.Context(), id)
+	}
+	if err != nil {
+		helpers.SendResponse(w, http.StatusInternalServerError, "Couldnt delete move", nil, err)
+		return
+	}
+	helpers.SendResponse(w, http.StatusOK, "Move deleted", nil, nil)
+}
+
+// envelopeLine is one category envelope in a month. Carried is what was
+// left in it at the end of the month before, negative when that month was
+// overspent and not covered since. Overspent is how far Available is below
+// zero.
+type envelopeLine struct {
+	CategoryId string      `json:"categoryId"`
+	Carried    money.Money `json:"carried"`
+	Assigned   money.Money `json:"assigned"`
+	Spent      money.Money `json:"spent"`
+	Available  money.Money `json:"available"`
+	Overspent  money.Money `json:"overspent"`
+}
+
+// readyToAssign is the pool income goes into before it is assigned.
+// Spent is uncategorized spending, which has no envelope to come out of.
+// Overassigned is how far Available is below zero.
+type readyToAssign struct {
+	Carried      money.Money `json:"carried"`
+	Income       money.Money `json:"income"`
+	Assigned     money.Money `json:"assigned"`
+	Spent        money.Money `json:"spent"`
+	Available    money.Money `json:"available"`
+	Overassigned money.Money `json:"overassigned"`
+}
+
+// GetEnvelopeMonth reports the ready to assign pool and every envelope for
+// {month}, such as 2026-10. Envelope budgeting starts in the month of the
+// first assignment: income and spending from then on are counted, and
+// envelope balances carry from month to month.
+func (h *Handler) GetEnvelopeMonth(w http.ResponseWriter, r *http.Request) {
+	month, ok := budgetMonth(w, chi.URLParam(r, "month"))
+	if !ok {
+		return
+	}
+	report, err := h.newBudgetReport(r.Context(), currentUserId(r)).envelopes(month)
+	if err != nil {
+		helpers.SendResponse(w, http.StatusInternalServerError, "Couldnt compute envelopes", nil, err)
+		return
+	}
+	helpers.SendResponse(w, http.StatusOK, "Envelopes found", report, nil)
+}
+
+func (b *budgetReport) envelopes(month time.Time) (map[string]interface{}, error) {
+	key := month.Format(budgetMonthLayout)
+	moves, err := b.h.store.EnvelopeMoves.Find(b.ctx, repository.EnvelopeMoveFilter{UserId: b.userId, Through: key})
+	if err != nil {
+		return nil, err
+	}
+	start := month
+	if len(moves) > 0 {
+		if first, err := time.Parse(budgetMonthLayout, moves[0].Month); err == nil && first.Before(start) {
+			start = first
+		}
+	}
+
+	zero := money.New(0, b.base)
+	pool := readyToAssign{Carried: zero, Income: zero, Assigned: zero, Spent: zero, Available: zero, Overassigned: zero}
+	lines := map[string]*envelopeLine{}
+	line := func(categoryId string) *envelopeLine {
+		if l, ok := lines[categoryId]; ok {
+			return l
+		}
+		l := &envelopeLine{CategoryId: categoryId, Carried: zero, Assigned: zero, Spent: zero, Available: zero, Overspent: zero}
+		lines[categoryId] = l
+		return l
+	}
+
+	// add credits an envelope, or the pool for an empty category, with
+	// amount, before this month or in it.
+	add := func(categoryId string, amount money.Money, current bool) error {
+		var err error
+		switch {
+		case categoryId == "" && current:
+			pool.Assigned, err = pool.Assigned.Sub(amount)
+		case categoryId == "":
+			pool.Carried, err = pool.Carried.Add(amount)
+		case current:
+			l := line(categoryId)
+			l.Assigned, err = l.Assigned.Add(amount)
+		default:
+			l := line(categoryId)
+			l.Carried, err = l.Carried.Add(amount)
+		}
+		return err
+	}
+
+	for _, move := range moves {
+		moved, _ := time.Parse(budgetMonthLayout, move.Month)
+		// Moves are stored in the base currency of the day, like budgets.
+		amount, err := b.converter.convert(move.Amount, b.base, moved)
+		if err != nil {
+			return nil, err
+		}
+		current := move.Month == key
+		if err := add(move.FromCategoryId, amount.Neg(), current); err != nil {
+			return nil, err
+		}
+		if err := add(move.ToCategoryId, amount, current); err != nil {
+			return nil, err
+		}
+	}
+
+	if start.Before(month) {
+		before, err := b.activity(start, month)
+		if err != nil {
+			return nil, err
+		}
+		if err := add("", before.income, false); err != nil {
+			return nil, err
+		}
+		for categoryId, spent := range before.spent {
+			if err := add(categoryId, spent.Neg(), false); err != nil {
+				return nil, err
+			}
+		}
+	}
+	during, err := b.activity(month, month.AddDate(0, 1, 0))
+	if err != nil {
+		return nil, err
+	}
+	pool.Income = during.income
+	for categoryId, spent := range during.spent {
+		if categoryId == "" {
+			pool.Spent = spent
+			continue
+		}
+		line(categoryId).Spent = spent
+	}
+
+	if pool.Available, err = sumMoney(pool.Carried, pool.Income, pool.Assigned.Neg(), pool.Spent.Neg()); err != nil {
+		return nil, err
+	}
+	if pool.Available.Amount < 0 {
+		pool.Overassigned = pool.Available.Neg()
+	}
+
+	envelopes := []envelopeLine{}
+	overspent := []envelopeLine{}
+	for _, l := range lines {
+		if l.Available, err = sumMoney(l.Carried, l.Assigned, l.Spent.Neg()); err != nil {
+			return nil, err
+		}
+		if l.Available.Amount < 0 {
+			l.Overspent = l.Available.Neg()
+			overspent = append(overspent, *l)
+		}
+		envelopes = append(envelopes, *l)
+	}
+	for _, list := range [][]envelopeLine{envelopes, overspent} {
+		sort.Slice(list, func(i, j int) bool { return list[i].CategoryId < list[j].CategoryId })
+	}
+
+	return map[string]interface{}{
+		"month":         key,
+		"currency":      b.base,
+		"readyToAssign": pool,
+		"envelopes":     envelopes,
+		"overspent":     overspent,
+		"missingRates":  b.missing,
+	}, nil
+}
+
+// sumMoney adds up amounts of one currency.
+func sumMoney(first money.Money, rest ...money.Money) (money.Money, error) {
+	var err error
+	for _, amount := range rest {
+		if first, err = first.Add(amount); err != nil {
+			return first, err
+		}
+	}
+	return first, nil
+}
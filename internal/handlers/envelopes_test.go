@@ -0,0 +1,130 @@
+package handlers_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/amrohan/expenso-go/internal/handlers"
+)
+
+type envelopeMonth struct {
+	ReadyToAssign struct {
+		Carried      amount `json:"carried"`
+		Income       amount `json:"income"`
+		Assigned     amount `json:"assigned"`
+		Spent        amount `json:"spent"`
+		Available    amount `json:"available"`
+		Overassigned amount `json:"overassigned"`
+	} `json:"readyToAssign"`
+	Envelopes []struct {
+		CategoryId string `json:"categoryId"`
+		Carried    amount `json:"carried"`
+		Assigned   amount `json:"assigned"`
+		Spent      amount `json:"spent"`
+		Available  amount `json:"available"`
+		Overspent  amount `json:"overspent"`
+	} `json:"envelopes"`
+	Overspent []struct {
+		CategoryId string `json:"categoryId"`
+	} `json:"overspent"`
+}
+
+func TestEnvelopes(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t, handlers.Config{})
+	nia := s.register("nia")
+	food := s.createdId(nia.Token, "/api/category/", map[string]string{"title": "Food"})
+	home := s.createdId(nia.Token, "/api/category/", map[string]string{"title": "Home"})
+
+	record := func(kind, categoryId string, amount int, date string) {
+		s.createdId(nia.Token, "/api/transaction/", map[string]interface{}{
+			"title": kind, "amount": amount, "date": date, "type": kind, "categoryId": categoryId,
+		})
+	}
+	move := func(month, from, to string, amount int) string {
+		return s.createdId(nia.Token, "/api/envelope/month/"+month+"/move", map[string]interface{}{
+			"fromCategoryId": from, "toCategoryId": to, "amount": amount,
+		})
+	}
+	record("Income", "", 1000, "2026-09-02T10:00:00Z")
+	s.createdId(nia.Token, "/api/envelope/month/2026-09/assign", map[string]interface{}{"categoryId": food, "amount": 300})
+	s.createdId(nia.Token, "/api/envelope/month/2026-09/assign", map[string]interface{}{"categoryId": home, "amount": 200})
+	record("Expense", food, 350, "2026-09-10T10:00:00Z")
+	record("Expense", home, 120, "2026-09-12T10:00:00Z")
+
+	s.createdId(nia.Token, "/api/envelope/month/2026-10/assign", map[string]interface{}{"categoryId": food, "amount": 100})
+	move("2026-10", home, food, 30)
+	backToPool := move("2026-10", food, "", 20)
+	record("Expense", "", 10, "2026-10-03T10:00:00Z")
+
+	s.expect(http.StatusBadRequest, nia.Token, http.MethodPost, "/api/envelope/month/2026-10/move",
+		map[string]interface{}{"fromCategoryId": food, "toCategoryId": food, "amount": 5})
+	s.expect(http.StatusBadRequest, nia.Token, http.MethodPost, "/api/envelope/month/2026-10/assign",
+		map[string]interface{}{"categoryId": food, "amount": 0})
+	s.expect(http.StatusBadRequest, nia.Token, http.MethodPost, "/api/envelope/month/2026-10/assign",
+		map[string]interface{}{"amount": 5})
+
+	report := func(month string) envelopeMonth {
+		var got envelopeMonth
+		s.decode(s.expect(http.StatusOK, nia.Token, http.MethodGet, "/api/envelope/month/"+month, nil), &got)
+		return got
+	}
+	check := func(month, name string, got []amount, want ...string) {
+		t.Helper()
+		for i, a := range got {
+			if a.Amount != want[i] {
+				t.Errorf("%s %s field %d = %s, want %s", month, name, i, a.Amount, want[i])
+			}
+		}
+	}
+	checkMonth := func(month string, pool []string, envelopes map[string][]string, overspent []string) {
+		t.Helper()
+		got := report(month)
+		p := got.ReadyToAssign
+		check(month, "ready to assign", []amount{p.Carried, p.Income, p.Assigned, p.Spent, p.Available, p.Overassigned}, pool...)
+		if len(got.Envelopes) != len(envelopes) {
+			t.Errorf("%s has %d envelopes, want %d", month, len(got.Envelopes), len(envelopes))
+		}
+		for _, e := range got.Envelopes {
+			check(month, e.CategoryId, []amount{e.Carried, e.Assigned, e.Spent, e.Available, e.Overspent}, envelopes[e.CategoryId]...)
+		}
+		var gotOverspent []string
+		for _, e := range got.Overspent {
+			gotOverspent = append(gotOverspent, e.CategoryId)
+		}
+		if len(gotOverspent) != len(overspent) || (len(overspent) == 1 && gotOverspent[0] != overspent[0]) {
+			t.Errorf("%s overspent = %v, want %v", month, gotOverspent, overspent)
+		}
+	}
+
+	// Fields: carried, income, assigned, spent, available, overassigned for
+	// the pool; carried, assigned, spent, available, overspent per envelope.
+	checkMonth("2026-09",
+		[]string{"0.00", "1000.00", "500.00", "0.00", "500.00", "0.00"},
+		map[string][]string{
+			food: {"0.00", "300.00", "350.00", "-50.00", "50.00"},
+			home: {"0.00", "200.00", "120.00", "80.00", "0.00"},
+		},
+		[]string{food})
+	// Food's overspending carries over and is covered by October's money.
+	checkMonth("2026-10",
+		[]string{"500.00", "0.00", "80.00", "10.00", "410.00", "0.00"},
+		map[string][]string{
+			food: {"-50.00", "110.00", "0.00", "60.00", "0.00"},
+			home: {"80.00", "-30.00", "0.00", "50.00", "0.00"},
+		},
+		nil)
+
+	other := s.register("omar")
+	s.expect(http.StatusNotFound, other.Token, http.MethodDelete, "/api/envelope/moves/"+backToPool, nil)
+	s.expect(http.StatusOK, nia.Token, http.MethodDelete, "/api/envelope/moves/"+backToPool, nil)
+
+	s.createdId(nia.Token, "/api/envelope/month/2026-11/assign", map[string]interface{}{"categoryId": home, "amount": 1000})
+	checkMonth("2026-11",
+		[]string{"390.00", "0.00", "1000.00", "0.00", "-610.00", "610.00"},
+		map[string][]string{
+			food: {"80.00", "0.00", "0.00", "80.00", "0.00"},
+			home: {"50.00", "1000.00", "0.00", "1050.00", "0.00"},
+		},
+		nil)
+}
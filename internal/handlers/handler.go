@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"strings"
+	"time"
+
+	"github.com/amrohan/expenso-go/internal/auth"
+	"github.com/amrohan/expenso-go/internal/mailer"
+	"github.com/amrohan/expenso-go/internal/oidc"
+	"github.com/amrohan/expenso-go/internal/repository"
+)
+
+// Config holds the runtime settings the handlers need beyond storage.
+type Config struct {
+	// AdminEmails are granted the admin role when they register or when
+	// BootstrapAdmins runs at startup.
+	AdminEmails []string
+	// Keys signs the tokens we issue and verifies the ones we receive.
+	Keys *auth.KeySet
+	// AccessTokenTTL and RefreshTokenTTL bound the lifetime of the access
+	// JWT and of the server-side session behind it.
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+	// Mailer delivers password reset and other account emails.
+	Mailer mailer.Mailer
+	// AppURL is the base URL of the web app, used to build links in emails.
+	AppURL string
+	// PasswordResetTTL bounds how long a reset link stays valid.
+	PasswordResetTTL time.Duration
+	// PublicURL is the externally reachable base URL of this API, used for
+	// email verification links. It defaults to the request's host.
+	PublicURL string
+	// VerifyEmailTTL bounds how long an email verification link stays valid.
+	VerifyEmailTTL time.Duration
+	Unverified     UnverifiedPolicy
+	LoginThrottle  LoginThrottle
+	// OIDCProviders are the external identity providers users can sign in
+	// with.
+	OIDCProviders []*oidc.Provider
+	// TrashRetention is how long deleted items stay restorable before
+	// PurgeTrash removes them for good.
+	TrashRetention time.Duration
+	// DefaultCurrency is the ISO 4217 code given to amounts sent without
+	// one. It defaults to INR.
+	DefaultCurrency string
+	// Now is the clock used for time based checks such as TOTP codes.
+	// It defaults to time.Now and is swapped out in tests.
+	Now func() time.Time
+}
+
+// UnverifiedPolicy restricts users who have not verified their email.
+type UnverifiedPolicy struct {
+	// ReadOnly rejects writes to transactions, categories and accounts.
+	ReadOnly bool
+	// LoginGraceDays blocks login once an account has been unverified for
+	// this many days. Zero disables the check.
+	LoginGraceDays int
+}
+
+// LoginThrottle slows down password guessing. Failures are counted per
+// account and per client IP; once a counter reaches its limit the key is
+// locked for Lockout, doubling with every further failure up to MaxLockout.
+type LoginThrottle struct {
+	AccountLimit int
+	IPLimit      int
+	// Window is how long a failure is remembered after the last one.
+	Window     time.Duration
+	Lockout    time.Duration
+	MaxLockout time.Duration
+}
+
+// Handler serves the HTTP API on top of a repository.Store.
+type Handler struct {
+	store  *repository.Store
+	config Config
+}
+
+func New(store *repository.Store, config Config) *Handler {
+	if config.AccessTokenTTL <= 0 {
+		config.AccessTokenTTL = 15 * time.Minute
+	}
+	if config.RefreshTokenTTL <= 0 {
+		config.RefreshTokenTTL = 30 * 24 * time.Hour
+	}
+	if config.Mailer == nil {
+		config.Mailer = &mailer.LogMailer{}
+	}
+	if config.PasswordResetTTL <= 0 {
+		config.PasswordResetTTL = time.Hour
+	}
+	if config.VerifyEmailTTL <= 0 {
+		config.VerifyEmailTTL = 48 * time.Hour
+	}
+	if config.LoginThrottle.AccountLimit <= 0 {
+		config.LoginThrottle.AccountLimit = 5
+	}
+	if config.LoginThrottle.IPLimit <= 0 {
+		config.LoginThrottle.IPLimit = 50
+	}
+	if config.LoginThrottle.Window <= 0 {
+		config.LoginThrottle.Window = 15 * time.Minute
+	}
+	if config.LoginThrottle.Lockout <= 0 {
+		config.LoginThrottle.Lockout = time.Minute
+	}
+	if config.LoginThrottle.MaxLockout <= 0 {
+		config.LoginThrottle.MaxLockout = time.Hour
+	}
+	if config.TrashRetention <= 0 {
+		config.TrashRetention = 30 * 24 * time.Hour
+	}
+	if config.DefaultCurrency == "" {
+		config.DefaultCurrency = "INR"
+	}
+	if config.Now == nil {
+		config.Now = time.Now
+	}
+	config.AppURL = strings.TrimRight(config.AppURL, "/")
+	config.PublicURL = strings.TrimRight(config.PublicURL, "/")
+	return &Handler{store: store, config: config}
+}
+
+func (h *Handler) now() time.Time {
+	return h.config.Now()
+}
+
+func (h *Handler) isAdminEmail(email string) bool {
+	for _, admin := range h.config.AdminEmails {
+		if email != "" && strings.EqualFold(admin, email) {
+			return true
+		}
+	}
+	return false
+}
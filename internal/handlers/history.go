@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/amrohan/expenso-go/internal/helpers"
+	"github.com/amrohan/expenso-go/internal/models"
+	"github.com/amrohan/expenso-go/internal/repository"
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// recordRevision stores the state of an entity after operation. When the
+// entity predates history, before is stored first as its initial version so
+// the change can still be undone. For deletes, state is the deleted document.
+func (h *Handler) recordRevision(r *http.Request, entityType string, id primitive.ObjectID, userId, operation string, before, state interface{}) {
+	ctx := r.Context()
+	if before != nil && operation != models.RevisionCreate {
+		existing, err := h.store.Revisions.Find(ctx, entityType, id.Hex())
+		if err == nil && len(existing) == 0 {
+			h.appendRevision(r, entityType, id, userId, models.RevisionInitial, before)
+		}
+	}
+	h.appendRevision(r, entityType, id, userId, operation, state)
+}
+
+func (h *Handler) appendRevision(r *http.Request, entityType string, id primitive.ObjectID, userId, operation string, state interface{}) {
+	revision := models.Revision{
+		Id:         primitive.NewObjectID(),
+		EntityType: entityType,
+		EntityId:   id.Hex(),
+		UserId:     userId,
+		Operation:  operation,
+		Snapshot:   storedFields(state),
+		ActorId:    currentUserId(r),
+		CreatedAt:  h.now(),
+	}
+	if err := h.store.Revisions.Append(r.Context(), &revision); err != nil {
+		log.Printf("history: unable to record %s %s: %v", entityType, id.Hex(), err)
+	}
+}
+
+// ownedRevisions loads an entity's history when it belongs to the caller.
+// History outlives deletes, so ownership comes from the revisions
+// themselves rather than the live document.
+func (h *Handler) ownedRevisions(w http.ResponseWriter, r *http.Request, entityType string) ([]models.Revision, bool) {
+	id, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.SendResponse(w, http.StatusBadRequest, "Invalid id", nil, err)
+		return nil, false
+	}
+	revisions, err := h.store.Revisions.Find(r.Context(), entityType, id.Hex())
+	if err != nil {
+		helpers.SendResponse(w, http.StatusInternalServerError, "Couldnt find history", nil, err)
+		return nil, false
+	}
+	if len(revisions) == 0 || !owns(r, revisions[len(revisions)-1].UserId) {
+		helpers.SendResponse(w, http.StatusNotFound, "History not found", nil, nil)
+		return nil, false
+	}
+	return revisions, true
+}
+
+// revisionView is a version together with what changed since the one
+// before it.
+type revisionView struct {
+	models.Revision
+	Changes map[string]models.AuditChange `json:"changes"`
+}
+
+func (h *Handler) sendHistory(w http.ResponseWriter, r *http.Request, entityType string) {
+	revisions, ok := h.ownedRevisions(w, r, entityType)
+	if !ok {
+		return
+	}
+
+	views := make([]revisionView, 0, len(revisions))
+	var previous bson.M
+	for _, revision := range revisions {
+		views = append(views, revisionView{Revision: revision, Changes: diffFields(previous, revision.Snapshot)})
+		previous = revision.Snapshot
+	}
+	helpers.SendResponse(w, http.StatusOK, "History found", views, nil)
+}
+
+// GetTransactionHistory lists every stored version of a transaction.
+func (h *Handler) GetTransactionHistory(w http.ResponseWriter, r *http.Request) {
+	h.sendHistory(w, r, "transaction")
+}
+
+// RevertTransaction restores a transaction to an earlier version. Reverting a
+// transaction in the trash, or one already purged, brings it back.
+func (h *Handler) RevertTransaction(w http.ResponseWriter, r *http.Request) {
+	revisions, ok := h.ownedRevisions(w, r, "transaction")
+	if !ok {
+		return
+	}
+	version, err := strconv.Atoi(chi.URLParam(r, "version"))
+	if err != nil || version < 1 || version > len(revisions) {
+		helpers.SendResponse(w, http.StatusNotFound, "Version not found", nil, err)
+		return
+	}
+	target := revisions[version-1]
+	if target.Operation == models.RevisionDelete {
+		helpers.SendResponse(w, http.StatusBadRequest, "Cannot revert to a deleted version", nil, nil)
+		return
+	}
+
+	var transaction models.Transaction
+	data, err := bson.Marshal(target.Snapshot)
+	if err == nil {
+		err = bson.Unmarshal(data, &transaction)
+	}
+	if err != nil {
+		helpers.SendResponse(w, http.StatusInternalServerError, "Couldnt read version", nil, err)
+		return
+	}
+	if transaction.IsTransfer() {
+		helpers.SendResponse(w, http.StatusBadRequest, "Transfers cannot be reverted one side at a time, edit the transfer instead", nil, nil)
+		return
+	}
+	transaction.UserId = currentUserId(r)
+	transaction.UpdatedAt = h.now()
+	// A reverted transaction is no longer the one that was reconciled.
+	if transaction.IsLocked() {
+		transaction.Status = models.StatusCleared
+		transaction.ReconciliationId = ""
+	}
+	// The version may name an account or category deleted since.
+	if !h.checkTransactionReferences(w, r, &transaction) {
+		return
+	}
+	// Versions stored before amounts carried a currency decode without one.
+	if !h.normalizeAmount(w, r, &transaction) {
+		return
+	}
+
+	existing, err := h.store.Transactions.FindById(r.Context(), transaction.Id)
+	if err == nil && existing.IsLocked() {
+		sendLocked(w)
+		return
+	}
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		existing = nil
+		err = h.store.Transactions.Create(r.Context(), &transaction)
+	case err == nil:
+		err = h.store.Transactions.Update(r.Context(), &transaction)
+	}
+	if err != nil {
+		helpers.SendResponse(w, http.StatusInternalServerError, "Couldnt revert transaction", nil, err)
+		return
+	}
+
+	h.recordRevision(r, "transaction", transaction.Id, transaction.UserId, models.RevisionRevert, nil, &transaction)
+	h.audit(r, models.AuditEvent{
+		Action:     models.AuditTransactionRevert,
+		TargetType: "transaction",
+		TargetId:   transaction.Id.Hex(),
+		Details:    map[string]interface{}{"version": version},
+		Changes:    auditDiff(existing, &transaction),
+	})
+	helpers.SendResponse(w, http.StatusOK, "Transaction reverted", transaction, nil)
+}
@@ -0,0 +1,53 @@
+package handlers_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/amrohan/expenso-go/internal/handlers"
+)
+
+// Every timestamp the API stores comes from Config.Now, so history reads
+// the same whatever the wall clock says.
+func TestHistoryFollowsClock(t *testing.T) {
+	t.Parallel()
+	start := time.Date(2030, 1, 1, 9, 0, 0, 0, time.UTC)
+	now := &clock{now: start}
+	s := newTestServer(t, handlers.Config{Now: now.Now})
+	pia := s.register("pia")
+
+	id := s.createdId(pia.Token, "/api/transaction/", map[string]interface{}{
+		"title": "Lunch", "amount": 12, "date": "2029-12-31T12:00:00Z", "type": "Expense",
+	})
+	now.Add(5 * time.Minute)
+	s.expect(http.StatusOK, pia.Token, http.MethodPut, "/api/transaction/", map[string]interface{}{
+		"id": id, "title": "Brunch", "amount": 15, "date": "2029-12-31T12:00:00Z", "type": "Expense",
+	})
+	now.Add(5 * time.Minute)
+	s.expect(http.StatusOK, pia.Token, http.MethodPost, "/api/transaction/"+id+"/revert/1", nil)
+
+	var transaction struct {
+		Title     string    `json:"title"`
+		CreatedAt time.Time `json:"createdAt"`
+		UpdatedAt time.Time `json:"updatedAt"`
+	}
+	s.decode(s.expect(http.StatusOK, pia.Token, http.MethodGet, "/api/transaction/"+id, nil), &transaction)
+	if transaction.Title != "Lunch" || !transaction.CreatedAt.Equal(start) || !transaction.UpdatedAt.Equal(start.Add(10*time.Minute)) {
+		t.Errorf("reverted transaction = %+v, want Lunch created at %v and updated at %v", transaction, start, start.Add(10*time.Minute))
+	}
+
+	var history []struct {
+		Operation string    `json:"operation"`
+		CreatedAt time.Time `json:"createdAt"`
+	}
+	s.decode(s.expect(http.StatusOK, pia.Token, http.MethodGet, "/api/transaction/"+id+"/history", nil), &history)
+	if len(history) != 3 {
+		t.Fatalf("%d versions, want 3", len(history))
+	}
+	for i, version := range history {
+		if want := start.Add(time.Duration(i) * 5 * time.Minute); !version.CreatedAt.Equal(want) {
+			t.Errorf("version %d (%s) at %v, want %v", i+1, version.Operation, version.CreatedAt, want)
+		}
+	}
+}
@@ -0,0 +1,262 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/amrohan/expenso-go/internal/helpers"
+	"github.com/amrohan/expenso-go/internal/models"
+	"github.com/amrohan/expenso-go/internal/money"
+	"github.com/amrohan/expenso-go/internal/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Delete strategies for categories and accounts that transactions still
+// reference, chosen with the strategy query parameter.
+const (
+	// strategyRestrict refuses the delete with 409. It is the default.
+	strategyRestrict = "restrict"
+	// strategyReassign moves the transactions to the category or account
+	// given by the to query parameter.
+	strategyReassign = "reassign"
+	// strategyCascade deletes the transactions along with their parent.
+	strategyCascade = "cascade"
+)
+
+// lookupCategory returns the caller's live category with the given id, or
+// repository.ErrNotFound when there is none.
+func (h *Handler) lookupCategory(r *http.Request, idHex string) (*models.Category, error) {
+	id, err := primitive.ObjectIDFromHex(idHex)
+	if err != nil {
+		return nil, repository.ErrNotFound
+	}
+	category, err := h.store.Categories.FindById(r.Context(), id)
+	if err == nil && (!owns(r, category.UserId) || category.IsDeleted) {
+		err = repository.ErrNotFound
+	}
+	return category, err
+}
+
+// lookupAccount returns the caller's live account with the given id, or
+// repository.ErrNotFound when there is none.
+func (h *Handler) lookupAccount(r *http.Request, idHex string) (*models.Account, error) {
+	id, err := primitive.ObjectIDFromHex(idHex)
+	if err != nil {
+		return nil, repository.ErrNotFound
+	}
+	account, err := h.store.Accounts.FindById(r.Context(), id)
+	if err == nil && (!owns(r, account.UserId) || account.IsDeleted) {
+		err = repository.ErrNotFound
+	}
+	return account, err
+}
+
+// checkTransactionReferences makes sure the category and account a
+// transaction points at exist and belong to the caller. Either may be left
+// empty, but every split needs a category.
+func (h *Handler) checkTransactionReferences(w http.ResponseWriter, r *http.Request, transaction *models.Transaction) bool {
+	if transaction.CategoryId != "" {
+		if _, err := h.lookupCategory(r, transaction.CategoryId); err != nil {
+			sendReferenceError(w, "Category", err)
+			return false
+		}
+	}
+	for _, split := range transaction.Splits {
+		if split.CategoryId == "" {
+			helpers.SendResponse(w, http.StatusBadRequest, "Every split needs a categoryId", nil, nil)
+			return false
+		}
+		if _, err := h.lookupCategory(r, split.CategoryId); err != nil {
+			sendReferenceError(w, "Split category", err)
+			return false
+		}
+	}
+	if transaction.AccountId != "" {
+		if _, err := h.lookupAccount(r, transaction.AccountId); err != nil {
+			sendReferenceError(w, "Account", err)
+			return false
+		}
+	}
+	return true
+}
+
+func sendReferenceError(w http.ResponseWriter, kind string, err error) {
+	if errors.Is(err, repository.ErrNotFound) {
+		helpers.SendResponse(w, http.StatusBadRequest, kind+" does not exist", nil, nil)
+		return
+	}
+	if errors.Is(err, money.ErrCurrencyMismatch) {
+		helpers.SendResponse(w, http.StatusBadRequest, kind+" uses a different currency", nil, nil)
+		return
+	}
+	helpers.SendResponse(w, http.StatusInternalServerError, "Couldnt check "+kind, nil, err)
+}
+
+// deleteWithDependents applies the requested delete strategy to the live
+// transactions matching filter and deletes their parent, parentId, with
+// deleteParent. The transactions and the parent are written in one store
+// transaction, so a failure leaves all of them as they were. validTarget
+// checks the reassign target and reassign points a transaction at it. When
+// the parent must not or could not be deleted the response is written here
+// and ok is false; otherwise it returns the number of transactions touched.
+func (h *Handler) deleteWithDependents(w http.ResponseWriter, r *http.Request, kind, parentId string, at time.Time, filter repository.TransactionFilter,
+	validTarget func(idHex string) error, reassign func(t *models.Transaction, idHex string), deleteParent func(ctx context.Context) error) (int, bool) {
+	strategy := r.URL.Query().Get("strategy")
+	if strategy == "" {
+		strategy = strategyRestrict
+	}
+	if strategy != strategyRestrict && strategy != strategyReassign && strategy != strategyCascade {
+		helpers.SendResponse(w, http.StatusBadRequest, "Strategy must be restrict, reassign or cascade", nil, nil)
+		return 0, false
+	}
+
+	target := r.URL.Query().Get("to")
+	if strategy == strategyReassign {
+		if target == parentId {
+			helpers.SendResponse(w, http.StatusBadRequest, "Cannot reassign transactions to the "+strings.ToLower(kind)+" being deleted", nil, nil)
+			return 0, false
+		}
+		if err := validTarget(target); err != nil {
+			sendReferenceError(w, "Target "+strings.ToLower(kind), err)
+			return 0, false
+		}
+	}
+
+	transactions, err := h.store.Transactions.Find(r.Context(), filter)
+	if err != nil {
+		helpers.SendResponse(w, http.StatusInternalServerError, "Couldnt find transactions", nil, err)
+		return 0, false
+	}
+	if len(transactions) > 0 && strategy == strategyRestrict {
+		helpers.SendResponse(w, http.StatusConflict,
+			fmt.Sprintf("%s is still used by %d transactions, reassign or cascade them first", kind, len(transactions)),
+			map[string]interface{}{"transactions": len(transactions)}, nil)
+		return 0, false
+	}
+	// Reconciled transactions can be recategorized but not deleted or
+	// moved to another account.
+	locked := 0
+	for _, t := range transactions {
+		moved := t
+		if strategy == strategyReassign {
+			reassign(&moved, target)
+		}
+		if t.IsLocked() && (strategy == strategyCascade || moved.AccountId != t.AccountId) {
+			locked++
+		}
+	}
+	if locked > 0 {
+		helpers.SendResponse(w, http.StatusConflict,
+			fmt.Sprintf("%d transactions are reconciled, unlock them first", locked),
+			map[string]interface{}{"transactions": locked}, nil)
+		return 0, false
+	}
+
+	// Every write is conditional on the transaction being unchanged since
+	// it was read here. The revisions and audit events are only recorded
+	// once the writes went through.
+	var updates []repository.TransactionUpdate
+	var record []func()
+	planned := map[primitive.ObjectID]bool{}
+	for i := range transactions {
+		existing := transactions[i]
+		if planned[existing.Id] {
+			continue
+		}
+		planned[existing.Id] = true
+
+		if strategy == strategyCascade {
+			// Deleting a transfer leg takes the other leg with it, which
+			// may be later in the list.
+			legs := []*models.Transaction{&existing}
+			if existing.IsTransfer() {
+				partner, err := h.transferPartner(r, &existing)
+				if err != nil {
+					helpers.SendResponse(w, http.StatusInternalServerError, "Couldnt delete transactions", nil, err)
+					return 0, false
+				}
+				if partner.IsLocked() {
+					helpers.SendResponse(w, http.StatusConflict, "The other side of a transfer is reconciled, unlock it first", nil, errLocked)
+					return 0, false
+				}
+				planned[partner.Id] = true
+				legs = append(legs, partner)
+			}
+			for _, leg := range legs {
+				leg := leg
+				deleted := *leg
+				deleted.IsDeleted = true
+				deleted.DeletedAt = at
+				updates = append(updates, repository.TransactionUpdate{Transaction: &deleted, Loaded: leg})
+				record = append(record, func() {
+					h.recordRevision(r, "transaction", leg.Id, leg.UserId, models.RevisionDelete, leg, &deleted)
+					h.auditChange(r, models.AuditTransactionDelete, "transaction", leg.Id, leg, &deleted)
+				})
+			}
+			continue
+		}
+
+		updated := existing
+		reassign(&updated, target)
+		updated.UpdatedAt = at
+		updates = append(updates, repository.TransactionUpdate{Transaction: &updated, Loaded: &existing})
+		if !updated.IsTransfer() || updated.AccountId == existing.AccountId {
+			record = append(record, func() {
+				h.recordRevision(r, "transaction", updated.Id, updated.UserId, models.RevisionUpdate, &existing, &updated)
+				h.auditChange(r, models.AuditTransactionUpdate, "transaction", updated.Id, &existing, &updated)
+			})
+			continue
+		}
+		// A transfer leg moving to another account repoints the other leg.
+		partner, err := h.transferPartner(r, &existing)
+		if err != nil {
+			helpers.SendResponse(w, http.StatusInternalServerError, "Couldnt reassign transactions", nil, err)
+			return 0, false
+		}
+		if partner.AccountId == updated.AccountId {
+			helpers.SendResponse(w, http.StatusConflict, "A transfer would move money within the target "+strings.ToLower(kind), nil, errSelfTransfer)
+			return 0, false
+		}
+		if partner.IsLocked() {
+			helpers.SendResponse(w, http.StatusConflict, "The other side of a transfer is reconciled, unlock it first", nil, errLocked)
+			return 0, false
+		}
+		planned[partner.Id] = true
+		movedPartner := *partner
+		movedPartner.TransferAccountId = updated.AccountId
+		movedPartner.UpdatedAt = at
+		updates = append(updates, repository.TransactionUpdate{Transaction: &movedPartner, Loaded: partner})
+		record = append(record, func() {
+			if existing.TransferDirection == models.TransferOut {
+				h.recordTransferUpdate(r, &existing, partner, &updated, &movedPartner)
+			} else {
+				h.recordTransferUpdate(r, partner, &existing, &movedPartner, &updated)
+			}
+		})
+	}
+
+	err = h.store.Atomically(r.Context(), func(ctx context.Context) error {
+		if len(updates) > 0 {
+			if err := h.store.Transactions.UpdateAll(ctx, updates); err != nil {
+				return err
+			}
+		}
+		return deleteParent(ctx)
+	})
+	if errors.Is(err, repository.ErrConflict) {
+		helpers.SendResponse(w, http.StatusConflict, "A transaction changed while deleting, please try again", nil, err)
+		return 0, false
+	}
+	if err != nil {
+		helpers.SendResponse(w, http.StatusInternalServerError, "Error deleting "+strings.ToLower(kind), nil, err)
+		return 0, false
+	}
+	for _, fn := range record {
+		fn()
+	}
+	return len(transactions), true
+}
@@ -0,0 +1,86 @@
+package handlers_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/amrohan/expenso-go/internal/handlers"
+)
+
+type transferLegs struct {
+	From struct {
+		Id                string `json:"id"`
+		AccountId         string `json:"accountId"`
+		TransferAccountId string `json:"transferAccountId"`
+	} `json:"from"`
+	To struct {
+		Id        string `json:"id"`
+		AccountId string `json:"accountId"`
+	} `json:"to"`
+}
+
+func TestDeleteStrategies(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t, handlers.Config{})
+	nina := s.register("nina")
+	food := s.createdId(nina.Token, "/api/category/", map[string]string{"title": "Food"})
+	misc := s.createdId(nina.Token, "/api/category/", map[string]string{"title": "Misc"})
+	bank := s.createdId(nina.Token, "/api/account/", map[string]interface{}{"title": "Bank"})
+	wallet := s.createdId(nina.Token, "/api/account/", map[string]interface{}{"title": "Wallet"})
+	card := s.createdId(nina.Token, "/api/account/", map[string]interface{}{"title": "Card"})
+	for _, title := range []string{"Lunch", "Dinner"} {
+		s.createdId(nina.Token, "/api/transaction/", map[string]interface{}{
+			"title": title, "amount": 20, "date": "2026-10-05T10:00:00Z", "type": "Expense",
+			"categoryId": food, "accountId": bank,
+		})
+	}
+	var transfer transferLegs
+	s.decode(s.expect(http.StatusOK, nina.Token, http.MethodPost, "/api/transaction/transfer", map[string]interface{}{
+		"title": "Top up", "date": "2026-10-06T10:00:00Z", "amount": 50,
+		"fromAccountId": bank, "toAccountId": wallet,
+	}), &transfer)
+
+	count := func(path string) int {
+		var transactions []struct{ Id string }
+		s.decode(s.expect(http.StatusOK, nina.Token, http.MethodGet, path, nil), &transactions)
+		return len(transactions)
+	}
+
+	// Categories in use are kept unless told what to do with the
+	// transactions.
+	s.expect(http.StatusConflict, nina.Token, http.MethodDelete, "/api/category/"+food, nil)
+	s.expect(http.StatusBadRequest, nina.Token, http.MethodDelete, "/api/category/"+food+"?strategy=ignore", nil)
+	s.expect(http.StatusBadRequest, nina.Token, http.MethodDelete, "/api/category/"+food+"?strategy=reassign&to="+food, nil)
+	s.expect(http.StatusOK, nina.Token, http.MethodDelete, "/api/category/"+food+"?strategy=reassign&to="+misc, nil)
+	if n := count("/api/transaction/category/" + misc); n != 2 {
+		t.Errorf("%d transactions in misc after reassigning, want 2", n)
+	}
+	s.expect(http.StatusNotFound, nina.Token, http.MethodGet, "/api/category/"+food, nil)
+
+	// Moving the in leg of a transfer to the account it comes from is
+	// refused, and nothing is written.
+	s.expect(http.StatusConflict, nina.Token, http.MethodDelete, "/api/account/"+wallet+"?strategy=reassign&to="+bank, nil)
+	s.expect(http.StatusOK, nina.Token, http.MethodGet, "/api/account/"+wallet, nil)
+	if n := count("/api/transaction/account/" + wallet); n != 1 {
+		t.Errorf("%d transactions in wallet after a refused reassign, want 1", n)
+	}
+
+	// Moving it to another account repoints the out leg too.
+	s.expect(http.StatusOK, nina.Token, http.MethodDelete, "/api/account/"+wallet+"?strategy=reassign&to="+card, nil)
+	var moved transferLegs
+	s.decode(s.expect(http.StatusOK, nina.Token, http.MethodGet, "/api/transaction/transfer/"+transfer.From.Id, nil), &moved)
+	if moved.To.AccountId != card || moved.From.TransferAccountId != card {
+		t.Errorf("transfer after reassign = %+v, want both legs pointing at card", moved)
+	}
+
+	// Cascading takes both legs of a transfer to the trash.
+	s.expect(http.StatusOK, nina.Token, http.MethodDelete, "/api/account/"+card+"?strategy=cascade", nil)
+	s.expect(http.StatusNotFound, nina.Token, http.MethodGet, "/api/transaction/"+transfer.From.Id, nil)
+	s.expect(http.StatusNotFound, nina.Token, http.MethodGet, "/api/transaction/"+transfer.To.Id, nil)
+	if n := count("/api/transaction/trash"); n != 2 {
+		t.Errorf("%d transactions in the trash after cascading, want 2", n)
+	}
+	if n := count("/api/transaction/account/" + bank); n != 2 {
+		t.Errorf("%d transactions left in bank, want 2", n)
+	}
+}
@@ -0,0 +1,349 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/amrohan/expenso-go/internal/auth"
+	"github.com/amrohan/expenso-go/internal/helpers"
+	"github.com/amrohan/expenso-go/internal/models"
+	"github.com/amrohan/expenso-go/internal/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	purposeMFA = "mfa"
+
+	mfaChallengeTTL   = 5 * time.Minute
+	recoveryCodeCount = 10
+)
+
+type mfaCodeBody struct {
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recoveryCode"`
+}
+
+// sendMFAChallenge answers a correct password for a user with TOTP enabled.
+// Instead of a session the client gets a challenge token to present with a
+// code at POST /login/mfa.
+func (h *Handler) sendMFAChallenge(w http.ResponseWriter, user *models.User) {
+	token, err := h.signPurposeToken(purposeMFA, user.Id.Hex(), mfaChallengeTTL, nil)
+	if err != nil {
+		helpers.SendResponse(w, http.StatusInternalServerError, "Error signing token", nil, err)
+		return
+	}
+	helpers.SendResponse(w, http.StatusOK, "mfa_required", map[string]interface{}{
+		"mfaRequired":    true,
+		"challengeToken": token,
+		"expiresIn":      int(mfaChallengeTTL.Seconds()),
+	}, nil)
+}
+
+// LoginMFA completes a login that was answered with an MFA challenge.
+func (h *Handler) LoginMFA(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		ChallengeToken string `json:"challengeToken"`
+		mfaCodeBody
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		helpers.SendResponse(w, http.StatusBadRequest, "Please send valid body", nil, err)
+		return
+	}
+
+	id, _, err := h.parsePurposeToken(body.ChallengeToken, purposeMFA)
+	if err != nil {
+		helpers.SendResponse(w, http.StatusUnauthorized, "Challenge is invalid or has expired", nil, err)
+		return
+	}
+	user, err := h.store.Users.FindById(r.Context(), id)
+	if err != nil || user.IsDeleted || !user.TOTPEnabled {
+		helpers.SendResponse(w, http.StatusUnauthorized, "Challenge is invalid or has expired", nil, nil)
+		return
+	}
+
+	keys, ok := h.codeThrottle(w, r, user)
+	if !ok {
+		return
+	}
+
+	valid, err := h.checkSecondFactor(r, user, body.mfaCodeBody)
+	if err != nil {
+		helpers.SendResponse(w, http.StatusInternalServerError, "Error checking code", nil, err)
+		return
+	}
+	if !valid {
+		h.auditLoginFailure(r, user, "", "bad_second_factor")
+		h.recordLoginFailure(r, user.Id.Hex(), keys...)
+		helpers.SendResponse(w, http.StatusUnauthorized, "Invalid code", nil, nil)
+		return
+	}
+	h.resetLoginFailures(r, user)
+	h.startSession(w, r, user)
+}
+
+// codeThrottle returns the throttle keys a code guess for user counts
+// against, writing the response itself when they are locked. Codes are only
+// six digits, so guesses count against the same limits as passwords, and
+// the same ones wherever a code is asked for.
+func (h *Handler) codeThrottle(w http.ResponseWriter, r *http.Request, user *models.User) ([]throttleKey, bool) {
+	keys := []throttleKey{h.accountThrottleKey(user), h.ipThrottleKey(r)}
+	until, err := h.loginLockedUntil(r, keys...)
+	if err != nil {
+		helpers.SendResponse(w, http.StatusInternalServerError, "Unable to check login attempts", nil, err)
+		return nil, false
+	}
+	if !until.IsZero() {
+		h.sendLoginLocked(w, until)
+		return nil, false
+	}
+	return keys, true
+}
+
+// checkSecondFactor accepts either a TOTP code or an unused recovery code.
+// Whatever it accepts is consumed in the store first, so of concurrent
+// requests with the same code only one gets through.
+func (h *Handler) checkSecondFactor(r *http.Request, user *models.User, body mfaCodeBody) (bool, error) {
+	if body.Code != "" {
+		step, ok := auth.ValidateTOTP(user.TOTPSecret, body.Code, h.now(), user.TOTPLastStep)
+		if !ok {
+			return false, nil
+		}
+		if err := h.store.Users.UseTOTPStep(r.Context(), user.Id, step); err != nil {
+			return false, ignoreNotFound(err)
+		}
+		user.TOTPLastStep = step
+		return true, nil
+	}
+
+	if code := normalizeRecoveryCode(body.RecoveryCode); code != "" {
+		for i, hash := range user.RecoveryCodeHashes {
+			if bcrypt.CompareHashAndPassword([]byte(hash), []byte(code)) == nil {
+				if err := h.store.Users.UseRecoveryCode(r.Context(), user.Id, hash); err != nil {
+					return false, ignoreNotFound(err)
+				}
+				user.RecoveryCodeHashes = append(user.RecoveryCodeHashes[:i:i], user.RecoveryCodeHashes[i+1:]...)
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// ignoreNotFound turns repository.ErrNotFound into nil.
+func ignoreNotFound(err error) error {
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+// EnrollMFA starts TOTP enrollment by generating a secret. It only takes
+// effect once confirmed with a code from the authenticator app.
+func (h *Handler) EnrollMFA(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
+	if user.TOTPEnabled {
+		helpers.SendResponse(w, http.StatusConflict, "Two-factor authentication is already enabled", nil, nil)
+		return
+	}
+
+	secret, err := auth.NewTOTPSecret()
+	if err != nil {
+		helpers.SendResponse(w, http.StatusInternalServerError, "Error creating secret", nil, err)
+		return
+	}
+	user.TOTPSecret = secret
+	user.TOTPLastStep = 0
+	user.UpdatedAt = h.now()
+	if err := h.store.Users.Update(r.Context(), user); err != nil {
+		helpers.SendResponse(w, http.StatusInternalServerError, "Error updating user", nil, err)
+		return
+	}
+
+	account := user.Email
+	if account == "" {
+		account = user.Username
+	}
+	helpers.SendResponse(w, http.StatusOK, "Scan the code and confirm it", map[string]string{
+		"secret": secret,
+		"uri":    auth.TOTPURI(account, secret),
+	}, nil)
+}
+
+// ConfirmMFA turns on TOTP after checking a code against the pending secret
+// and returns a fresh set of recovery codes.
+func (h *Handler) ConfirmMFA(w http.ResponseWriter, r *http.Request) {
+	var body mfaCodeBody
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Code == "" {
+		helpers.SendResponse(w, http.StatusBadRequest, "Please send valid body", nil, err)
+		return
+	}
+
+	user, ok := h.currentUser(w, r)
+	if !ok {
+		return
+	}
+	if user.TOTPEnabled {
+		helpers.SendResponse(w, http.StatusConflict, "Two-factor authentication is already enabled", nil, nil)
+		return
+	}
+	if user.TOTPSecret == "" {
+		helpers.SendResponse(w, http.StatusBadRequest, "Start enrollment first", nil, nil)
+		return
+	}
+
+	keys, ok := h.codeThrottle(w, r, user)
+	if !ok {
+		return
+	}
+	step, valid := auth.ValidateTOTP(user.TOTPSecret, body.Code, h.now(), user.TOTPLastStep)
+	if !valid {
+		h.recordLoginFailure(r, user.Id.Hex(), keys...)
+		helpers.SendResponse(w, http.StatusBadRequest, "Invalid code", nil, nil)
+		return
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		helpers.SendResponse(w, http.StatusInternalServerError, "Error creating recovery codes", nil, err)
+		return
+	}
+	user.TOTPEnabled = true
+	user.TOTPLastStep = step
+	user.RecoveryCodeHashes = hashes
+	user.UpdatedAt = h.now()
+	if err := h.store.Users.Update(r.Context(), user); err != nil {
+		helpers.SendResponse(w, http.StatusInternalServerError, "Error updating user", nil, err)
+		return
+	}
+	helpers.SendResponse(w, http.StatusOK, "Two-factor authentication enabled", map[string]interface{}{
+		"recoveryCodes": codes,
+	}, nil)
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes. It needs a current
+// code so a hijacked session alone cannot read them.
+func (h *Handler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.userWithSecondFactor(w, r)
+	if !ok {
+		return
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		helpers.SendResponse(w, http.StatusInternalServerError, "Error creating recovery codes", nil, err)
+		return
+	}
+	user.RecoveryCodeHashes = hashes
+	user.UpdatedAt = h.now()
+	if err := h.store.Users.Update(r.Context(), user); err != nil {
+		helpers.SendResponse(w, http.StatusInternalServerError, "Error updating user", nil, err)
+		return
+	}
+	helpers.SendResponse(w, http.StatusOK, "Recovery codes regenerated", map[string]interface{}{
+		"recoveryCodes": codes,
+	}, nil)
+}
+
+// DisableMFA turns TOTP off after checking a code or recovery code.
+func (h *Handler) DisableMFA(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.userWithSecondFactor(w, r)
+	if !ok {
+		return
+	}
+
+	user.TOTPEnabled = false
+	user.TOTPSecret = ""
+	user.TOTPLastStep = 0
+	user.RecoveryCodeHashes = nil
+	user.UpdatedAt = h.now()
+	if err := h.store.Users.Update(r.Context(), user); err != nil {
+		helpers.SendResponse(w, http.StatusInternalServerError, "Error updating user", nil, err)
+		return
+	}
+	helpers.SendResponse(w, http.StatusOK, "Two-factor authentication disabled", nil, nil)
+}
+
+// userWithSecondFactor loads the caller, who must have TOTP enabled, and
+// checks the code in the request body.
+func (h *Handler) userWithSecondFactor(w http.ResponseWriter, r *http.Request) (*models.User, bool) {
+	var body mfaCodeBody
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		helpers.SendResponse(w, http.StatusBadRequest, "Please send valid body", nil, err)
+		return nil, false
+	}
+
+	user, ok := h.currentUser(w, r)
+	if !ok {
+		return nil, false
+	}
+	if !user.TOTPEnabled {
+		helpers.SendResponse(w, http.StatusBadRequest, "Two-factor authentication is not enabled", nil, nil)
+		return nil, false
+	}
+
+	keys, ok := h.codeThrottle(w, r, user)
+	if !ok {
+		return nil, false
+	}
+	valid, err := h.checkSecondFactor(r, user, body)
+	if err != nil {
+		helpers.SendResponse(w, http.StatusInternalServerError, "Error checking code", nil, err)
+		return nil, false
+	}
+	if !valid {
+		h.recordLoginFailure(r, user.Id.Hex(), keys...)
+		helpers.SendResponse(w, http.StatusUnauthorized, "Invalid code", nil, nil)
+		return nil, false
+	}
+	return user, true
+}
+
+// currentUser loads the authenticated caller's user record.
+func (h *Handler) currentUser(w http.ResponseWriter, r *http.Request) (*models.User, bool) {
+	id, err := primitive.ObjectIDFromHex(currentUserId(r))
+	if err != nil {
+		helpers.SendResponse(w, http.StatusUnauthorized, "Unauthorized", nil, err)
+		return nil, false
+	}
+	return h.ownedUser(w, r, id)
+}
+
+// normalizeRecoveryCode accepts codes typed in upper case or without the dash.
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), " ", ""))
+	if len(code) == 10 && !strings.Contains(code, "-") {
+		code = code[:5] + "-" + code[5:]
+	}
+	return code
+}
+
+// newRecoveryCodes returns codes to show the user once and the bcrypt hashes
+// to store in their place.
+func newRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		b := make([]byte, 5)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		code := hex.EncodeToString(b)
+		code = code[:5] + "-" + code[5:]
+		hash, err := bcrypt.GenerateFromPassword([]byte(code), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, nil, err
+		}
+		codes = append(codes, code)
+		hashes = append(hashes, string(hash))
+	}
+	return codes, hashes, nil
+}
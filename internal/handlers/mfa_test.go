@@ -0,0 +1,156 @@
+package handlers_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/amrohan/expenso-go/internal/auth"
+	"github.com/amrohan/expenso-go/internal/handlers"
+)
+
+func TestMFA(t *testing.T) {
+	t.Parallel()
+	now := &clock{now: time.Now()}
+	s := newTestServer(t, handlers.Config{Now: now.Now})
+	carol := s.register("carol")
+
+	var enrollment struct {
+		Secret string `json:"secret"`
+	}
+	s.decode(s.expect(http.StatusOK, carol.Token, http.MethodPost, "/api/user/mfa/enroll", nil), &enrollment)
+	code := func(at time.Time) string {
+		code, err := auth.TOTPCode(enrollment.Secret, auth.TOTPStep(at))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return code
+	}
+
+	// Codes outside the skew window around the handler clock are refused.
+	s.expect(http.StatusBadRequest, carol.Token, http.MethodPost, "/api/user/mfa/confirm",
+		map[string]string{"code": code(now.Now().Add(5 * auth.TOTPPeriod))})
+
+	var confirmed struct {
+		RecoveryCodes []string `json:"recoveryCodes"`
+	}
+	first := code(now.Now())
+	s.decode(s.expect(http.StatusOK, carol.Token, http.MethodPost, "/api/user/mfa/confirm",
+		map[string]string{"code": first}), &confirmed)
+	if len(confirmed.RecoveryCodes) == 0 {
+		t.Fatal("confirm returned no recovery codes")
+	}
+
+	// A code is good once.
+	s.expect(http.StatusUnauthorized, carol.Token, http.MethodPost, "/api/user/mfa/recovery-codes",
+		map[string]string{"code": first})
+
+	// Login now stops at a challenge.
+	var challenge struct {
+		MFARequired    bool   `json:"mfaRequired"`
+		ChallengeToken string `json:"challengeToken"`
+	}
+	s.decode(s.login("carol"), &challenge)
+	if !challenge.MFARequired || challenge.ChallengeToken == "" {
+		t.Fatalf("login = %+v, want an MFA challenge", challenge)
+	}
+	s.expect(http.StatusUnauthorized, "", http.MethodPost, "/login/mfa",
+		map[string]string{"challengeToken": challenge.ChallengeToken, "code": first})
+
+	now.Add(auth.TOTPPeriod)
+	var session tokens
+	s.decode(s.expect(http.StatusOK, "", http.MethodPost, "/login/mfa",
+		map[string]string{"challengeToken": challenge.ChallengeToken, "code": code(now.Now())}), &session)
+	if session.Token == "" {
+		t.Fatal("MFA login returned no token")
+	}
+
+	// Recovery codes are single use too.
+	s.expect(http.StatusOK, session.Token, http.MethodPost, "/api/user/mfa/recovery-codes",
+		map[string]string{"recoveryCode": confirmed.RecoveryCodes[0]})
+	s.expect(http.StatusUnauthorized, session.Token, http.MethodPost, "/api/user/mfa/disable",
+		map[string]string{"recoveryCode": confirmed.RecoveryCodes[0]})
+
+	now.Add(auth.TOTPPeriod)
+	s.expect(http.StatusOK, session.Token, http.MethodPost, "/api/user/mfa/disable",
+		map[string]string{"code": code(now.Now())})
+	s.decode(s.login("carol"), &session)
+	if session.Token == "" {
+		t.Error("login after disabling MFA returned no token")
+	}
+}
+
+func TestMFACodeGuessesAreThrottled(t *testing.T) {
+	t.Parallel()
+	now := &clock{now: time.Now()}
+	s := newTestServer(t, handlers.Config{
+		Now:           now.Now,
+		LoginThrottle: handlers.LoginThrottle{AccountLimit: 3},
+	})
+	dave := s.register("dave")
+
+	var enrollment struct {
+		Secret string `json:"secret"`
+	}
+	s.decode(s.expect(http.StatusOK, dave.Token, http.MethodPost, "/api/user/mfa/enroll", nil), &enrollment)
+	wrong, err := auth.TOTPCode(enrollment.Secret, auth.TOTPStep(now.Now())+10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		s.expect(http.StatusBadRequest, dave.Token, http.MethodPost, "/api/user/mfa/confirm", map[string]string{"code": wrong})
+	}
+	right, err := auth.TOTPCode(enrollment.Secret, auth.TOTPStep(now.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.expect(http.StatusTooManyRequests, dave.Token, http.MethodPost, "/api/user/mfa/confirm", map[string]string{"code": right})
+}
+
+// A code, TOTP or recovery, is used once even when sent several times at
+// once.
+func TestConcurrentSecondFactor(t *testing.T) {
+	t.Parallel()
+	now := &clock{now: time.Now()}
+	s := newTestServer(t, handlers.Config{
+		Now:           now.Now,
+		LoginThrottle: handlers.LoginThrottle{AccountLimit: 100, IPLimit: 100},
+	})
+	jane := s.register("jane")
+
+	var enrollment struct {
+		Secret string `json:"secret"`
+	}
+	s.decode(s.expect(http.StatusOK, jane.Token, http.MethodPost, "/api/user/mfa/enroll", nil), &enrollment)
+	code := func() string {
+		code, err := auth.TOTPCode(enrollment.Secret, auth.TOTPStep(now.Now()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return code
+	}
+	var confirmed struct {
+		RecoveryCodes []string `json:"recoveryCodes"`
+	}
+	s.decode(s.expect(http.StatusOK, jane.Token, http.MethodPost, "/api/user/mfa/confirm",
+		map[string]string{"code": code()}), &confirmed)
+
+	var challenge struct {
+		ChallengeToken string `json:"challengeToken"`
+	}
+	s.decode(s.login("jane"), &challenge)
+
+	now.Add(auth.TOTPPeriod)
+	counts := s.concurrently(8, "/login/mfa", map[string]string{"challengeToken": challenge.ChallengeToken, "code": code()})
+	if counts[http.StatusOK] != 1 || counts[http.StatusUnauthorized] != 7 {
+		t.Errorf("TOTP login statuses = %v, want one 200 and seven 401", counts)
+	}
+
+	counts = s.concurrently(8, "/login/mfa", map[string]string{
+		"challengeToken": challenge.ChallengeToken,
+		"recoveryCode":   confirmed.RecoveryCodes[0],
+	})
+	if counts[http.StatusOK] != 1 || counts[http.StatusUnauthorized] != 7 {
+		t.Errorf("recovery code login statuses = %v, want one 200 and seven 401", counts)
+	}
+}
@@ -0,0 +1,265 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/amrohan/expenso-go/internal/helpers"
+	"github.com/amrohan/expenso-go/internal/models"
+	"github.com/amrohan/expenso-go/internal/oidc"
+	"github.com/amrohan/expenso-go/internal/repository"
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	purposeOIDC = "oidc_login"
+
+	// oidcStateCookie carries the signed state, nonce and PKCE verifier
+	// between the redirect to the provider and the callback.
+	oidcStateCookie = "oidc_state"
+	oidcStateTTL    = 10 * time.Minute
+)
+
+var usernameInvalidChars = regexp.MustCompile(`[^a-z0-9._-]+`)
+
+func (h *Handler) oidcProvider(w http.ResponseWriter, r *http.Request) (*oidc.Provider, bool) {
+	name := chi.URLParam(r, "provider")
+	for _, p := range h.config.OIDCProviders {
+		if p.Name() == name {
+			return p, true
+		}
+	}
+	helpers.SendResponse(w, http.StatusNotFound, "Unknown login provider", nil, nil)
+	return nil, false
+}
+
+func (h *Handler) oidcRedirectURL(r *http.Request, p *oidc.Provider) string {
+	if p.RedirectURL() != "" {
+		return p.RedirectURL()
+	}
+	return h.publicURL(r) + "/oidc/" + p.Name() + "/callback"
+}
+
+// GetOIDCProviders lists the configured login providers.
+func (h *Handler) GetOIDCProviders(w http.ResponseWriter, r *http.Request) {
+	names := []string{}
+	for _, p := range h.config.OIDCProviders {
+		names = append(names, p.Name())
+	}
+	helpers.SendResponse(w, http.StatusOK, "Providers", names, nil)
+}
+
+// OIDCLogin redirects the browser to the provider's authorization endpoint.
+func (h *Handler) OIDCLogin(w http.ResponseWriter, r *http.Request) {
+	provider, ok := h.oidcProvider(w, r)
+	if !ok {
+		return
+	}
+
+	state, err := oidc.RandomString()
+	if err != nil {
+		helpers.SendResponse(w, http.StatusInternalServerError, "Error starting login", nil, err)
+		return
+	}
+	nonce, err := oidc.RandomString()
+	if err != nil {
+		helpers.SendResponse(w, http.StatusInternalServerError, "Error starting login", nil, err)
+		return
+	}
+	verifier, challenge, err := oidc.NewPKCE()
+	if err != nil {
+		helpers.SendResponse(w, http.StatusInternalServerError, "Error starting login", nil, err)
+		return
+	}
+
+	redirectURL := h.oidcRedirectURL(r, provider)
+	authURL, err := provider.AuthCodeURL(r.Context(), redirectURL, state, nonce, challenge)
+	if err != nil {
+		helpers.SendResponse(w, http.StatusBadGateway, "Login provider is unavailable", nil, err)
+		return
+	}
+	cookie, err := h.signPurposeToken(purposeOIDC, state, oidcStateTTL, map[string]interface{}{
+		"provider": provider.Name(),
+		"nonce":    nonce,
+		"verifier": verifier,
+	})
+	if err != nil {
+		helpers.SendResponse(w, http.StatusInternalServerError, "Error starting login", nil, err)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    cookie,
+		Path:     "/oidc",
+		MaxAge:   int(oidcStateTTL.Seconds()),
+		HttpOnly: true,
+		// Lax so the cookie comes back on the provider's top level
+		// redirect to the callback.
+		SameSite: http.SameSiteLaxMode,
+		Secure:   true,
+	})
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// OIDCCallback finishes the authorization code flow, links or creates the
+// user and starts a session the same way LoginUser does.
+func (h *Handler) OIDCCallback(w http.ResponseWriter, r *http.Request) {
+	provider, ok := h.oidcProvider(w, r)
+	if !ok {
+		return
+	}
+
+	// The state cookie is single use.
+	http.SetCookie(w, &http.Cookie{Name: oidcStateCookie, Path: "/oidc", MaxAge: -1, HttpOnly: true, Secure: true})
+
+	query := r.URL.Query()
+	if e := query.Get("error"); e != "" {
+		helpers.SendResponse(w, http.StatusUnauthorized, "Login was not completed: "+e, nil, nil)
+		return
+	}
+
+	stateCookie, err := r.Cookie(oidcStateCookie)
+	if err != nil {
+		helpers.SendResponse(w, http.StatusBadRequest, "Login has expired, please try again", nil, err)
+		return
+	}
+	claims, err := h.parsePurposeClaims(stateCookie.Value, purposeOIDC)
+	if err != nil {
+		helpers.SendResponse(w, http.StatusBadRequest, "Login has expired, please try again", nil, err)
+		return
+	}
+	state, _ := claims.GetSubject()
+	nonce, _ := claims["nonce"].(string)
+	verifier, _ := claims["verifier"].(string)
+	if name, _ := claims["provider"].(string); name != provider.Name() || state == "" || query.Get("state") != state {
+		helpers.SendResponse(w, http.StatusBadRequest, "Login state does not match", nil, nil)
+		return
+	}
+
+	idToken, err := provider.Exchange(r.Context(), h.oidcRedirectURL(r, provider), query.Get("code"), verifier)
+	if err != nil {
+		helpers.SendResponse(w, http.StatusBadGateway, "Unable to complete login with provider", nil, err)
+		return
+	}
+	identity, err := provider.VerifyIDToken(r.Context(), idToken, nonce, h.now)
+	if err != nil {
+		helpers.SendResponse(w, http.StatusUnauthorized, "Provider returned an invalid identity", nil, err)
+		return
+	}
+
+	user, status, err := h.userForIdentity(r, provider.Name(), identity)
+	if err != nil {
+		helpers.SendResponse(w, status, err.Error(), nil, nil)
+		return
+	}
+	if user.IsDeleted {
+		helpers.SendResponse(w, http.StatusForbidden, "This account has been deleted", nil, nil)
+		return
+	}
+	if h.verificationOverdue(user) {
+		helpers.SendResponse(w, http.StatusForbidden, "Please verify your email before logging in", nil, nil)
+		return
+	}
+	if user.TOTPEnabled {
+		h.sendMFAChallenge(w, user)
+		return
+	}
+	h.startSession(w, r, user)
+}
+
+// userForIdentity returns the user linked to identity. An unlinked identity
+// is linked to the user with the same email when both the provider and the
+// user have verified that email, and otherwise gets a new user. The status
+// goes with the error.
+func (h *Handler) userForIdentity(r *http.Request, provider string, identity *oidc.IDTokenClaims) (*models.User, int, error) {
+	ctx := r.Context()
+	user, err := h.store.Users.FindByIdentity(ctx, provider, identity.Subject)
+	if err == nil {
+		return user, 0, nil
+	}
+	if !errors.Is(err, repository.ErrNotFound) {
+		return nil, http.StatusInternalServerError, errors.New("Unable to check for existing user")
+	}
+
+	link := models.UserIdentity{
+		Provider: provider,
+		Subject:  identity.Subject,
+		Email:    identity.Email,
+		LinkedAt: h.now(),
+	}
+
+	if identity.Email != "" {
+		user, err := h.store.Users.FindByEmail(ctx, identity.Email)
+		switch {
+		case err == nil && identity.EmailVerified && user.IsVerified:
+			user.Identities = append(user.Identities, link)
+			user.UpdatedAt = h.now()
+			if err := h.store.Users.Update(ctx, user); err != nil {
+				return nil, http.StatusInternalServerError, errors.New("Error linking account")
+			}
+			return user, 0, nil
+		case err == nil:
+			// Without a verified email on both sides anyone could claim
+			// the account, or keep a password on one they registered
+			// ahead of its owner.
+			return nil, http.StatusConflict, errors.New("An account with this email already exists, please log in with your password")
+		case !errors.Is(err, repository.ErrNotFound):
+			return nil, http.StatusInternalServerError, errors.New("Unable to check for existing user")
+		}
+	}
+
+	username, err := h.availableUsername(r, identity)
+	if err != nil {
+		return nil, http.StatusInternalServerError, errors.New("Unable to check for existing user")
+	}
+	user = &models.User{
+		Id:         primitive.NewObjectID(),
+		Username:   username,
+		Name:       identity.Name,
+		Email:      identity.Email,
+		ImageUrl:   identity.Picture,
+		CreatedAt:  h.now(),
+		UpdatedAt:  h.now(),
+		IsActive:   true,
+		IsVerified: identity.EmailVerified,
+		Identities: []models.UserIdentity{link},
+	}
+	if identity.EmailVerified && h.isAdminEmail(user.Email) {
+		user.Roles = []string{models.RoleAdmin}
+	}
+	if err := h.store.Users.Create(ctx, user); err != nil {
+		return nil, http.StatusInternalServerError, errors.New("Unable to create user")
+	}
+	return user, 0, nil
+}
+
+// availableUsername derives a free username from the identity's preferred
+// username or email, adding a number when it is taken.
+func (h *Handler) availableUsername(r *http.Request, identity *oidc.IDTokenClaims) (string, error) {
+	base := identity.PreferredUsername
+	if base == "" {
+		base, _, _ = strings.Cut(identity.Email, "@")
+	}
+	base = strings.Trim(usernameInvalidChars.ReplaceAllString(strings.ToLower(base), ""), ".-_")
+	if base == "" {
+		base = "user"
+	}
+
+	candidate := base
+	for i := 2; ; i++ {
+		_, err := h.store.Users.FindByUsername(r.Context(), candidate)
+		if errors.Is(err, repository.ErrNotFound) {
+			return candidate, nil
+		}
+		if err != nil {
+			return "", err
+		}
+		candidate = base + strconv.Itoa(i)
+	}
+}
@@ -0,0 +1,207 @@
+package handlers_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/amrohan/expenso-go/internal/handlers"
+	"github.com/amrohan/expenso-go/internal/oidc"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// mockProvider is an OpenID provider that issues ID tokens for whatever
+// identity the test hands it with each authorization code.
+type mockProvider struct {
+	t   *testing.T
+	url string
+	key *rsa.PrivateKey
+
+	mu     sync.Mutex
+	grants map[string]mockGrant
+}
+
+type mockGrant struct {
+	nonce, challenge string
+	claims           jwt.MapClaims
+}
+
+func newMockProvider(t *testing.T) *mockProvider {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &mockProvider{t: t, key: key, grants: map[string]mockGrant{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 p.url,
+			"authorization_endpoint": p.url + "/authorize",
+			"token_endpoint":         p.url + "/token",
+			"jwks_uri":               p.url + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "mock",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", p.token)
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	p.url = server.URL
+	return p
+}
+
+func (p *mockProvider) token(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	grant, ok := p.grants[r.FormValue("code")]
+	delete(p.grants, r.FormValue("code"))
+	p.mu.Unlock()
+
+	sum := sha256.Sum256([]byte(r.FormValue("code_verifier")))
+	if !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != grant.challenge {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":   p.url,
+		"aud":   "expenso",
+		"nonce": grant.nonce,
+		"iat":   now.Unix(),
+		"exp":   now.Add(5 * time.Minute).Unix(),
+	}
+	for k, v := range grant.claims {
+		claims[k] = v
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = "mock"
+	idToken, err := token.SignedString(p.key)
+	if err != nil {
+		p.t.Error(err)
+	}
+	json.NewEncoder(w).Encode(map[string]string{"id_token": idToken})
+}
+
+// oidcLogin runs the login flow against provider, which signs in as the
+// identity in claims. tamper may change the callback query before it is
+// sent.
+func (s *testServer) oidcLogin(provider *mockProvider, claims jwt.MapClaims, tamper func(url.Values)) apiResponse {
+	s.t.Helper()
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(s.url + "/oidc/mock/login")
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		s.t.Fatalf("login redirect = %d, want 302", resp.StatusCode)
+	}
+	authURL, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	auth := authURL.Query()
+	if auth.Get("code_challenge_method") != "S256" || auth.Get("client_id") != "expenso" {
+		s.t.Fatalf("authorization request %s lacks PKCE or the client id", authURL)
+	}
+
+	code := auth.Get("state")
+	provider.mu.Lock()
+	provider.grants[code] = mockGrant{nonce: auth.Get("nonce"), challenge: auth.Get("code_challenge"), claims: claims}
+	provider.mu.Unlock()
+
+	query := url.Values{"code": {code}, "state": {auth.Get("state")}}
+	if tamper != nil {
+		tamper(query)
+	}
+	req, err := http.NewRequest(http.MethodGet, s.url+"/oidc/mock/callback?"+query.Encode(), nil)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	for _, cookie := range resp.Cookies() {
+		req.AddCookie(&http.Cookie{Name: cookie.Name, Value: cookie.Value})
+	}
+	callback, err := http.DefaultClient.Do(req)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	defer callback.Body.Close()
+	var out apiResponse
+	if err := json.NewDecoder(callback.Body).Decode(&out); err != nil {
+		s.t.Fatal(err)
+	}
+	return out
+}
+
+func TestOIDCLogin(t *testing.T) {
+	t.Parallel()
+	provider := newMockProvider(t)
+	s := newTestServer(t, handlers.Config{
+		OIDCProviders: []*oidc.Provider{oidc.NewProvider(oidc.ProviderConfig{
+			Name:     "mock",
+			Issuer:   provider.url,
+			ClientID: "expenso",
+		}, nil)},
+	})
+	login := func(claims jwt.MapClaims, tamper func(url.Values)) (apiResponse, string) {
+		resp := s.oidcLogin(provider, claims, tamper)
+		if resp.Status != http.StatusOK {
+			return resp, ""
+		}
+		var session tokens
+		s.decode(resp, &session)
+		s.expect(http.StatusOK, session.Token, http.MethodGet, "/api/category/", nil)
+		return resp, s.subject(session.Token)
+	}
+	uma := jwt.MapClaims{"sub": "mock-uma", "email": "uma@example.com", "email_verified": true, "preferred_username": "Uma"}
+
+	// A new identity gets a user, and the same one each time.
+	resp, first := login(uma, nil)
+	if resp.Status != http.StatusOK {
+		t.Fatalf("first login = %d %q, want 200", resp.Status, resp.Message)
+	}
+	if _, again := login(uma, nil); again != first {
+		t.Errorf("second login signed in as %s, want %s", again, first)
+	}
+
+	// The flow refuses a forged state or a code redeemed without the
+	// matching PKCE verifier.
+	if resp, _ := login(uma, func(q url.Values) { q.Set("state", "forged") }); resp.Status != http.StatusBadRequest {
+		t.Errorf("forged state = %d %q, want 400", resp.Status, resp.Message)
+	}
+	if resp, _ := login(uma, func(q url.Values) { q.Set("code", "unknown") }); resp.Status != http.StatusBadGateway {
+		t.Errorf("unknown code = %d %q, want 502", resp.Status, resp.Message)
+	}
+	if resp, _ := login(jwt.MapClaims{"sub": "mock-uma", "nonce": "replayed"}, nil); resp.Status != http.StatusUnauthorized {
+		t.Errorf("wrong nonce = %d %q, want 401", resp.Status, resp.Message)
+	}
+
+	// A password account is only linked once both sides verified the email.
+	vic := s.register("vic")
+	claims := jwt.MapClaims{"sub": "mock-vic", "email": "vic@example.com", "email_verified": true}
+	if resp, _ := login(claims, nil); resp.Status != http.StatusConflict {
+		t.Errorf("login to an unverified account = %d %q, want 409", resp.Status, resp.Message)
+	}
+	s.expect(http.StatusOK, "", http.MethodGet, s.link("vic@example.com", "/verify?token="), nil)
+	if _, linked := login(claims, nil); linked != s.subject(vic.Token) {
+		t.Errorf("login after verifying signed in as %s, want vic %s", linked, s.subject(vic.Token))
+	}
+}
@@ -0,0 +1,72 @@
+package handlers_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/amrohan/expenso-go/internal/handlers"
+)
+
+// Another user's records must look exactly like records that do not exist.
+func TestOtherUsersRecordsAreNotFound(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t, handlers.Config{})
+	alice := s.register("alice")
+	bob := s.register("bob")
+
+	categoryId := s.createdId(alice.Token, "/api/category/", map[string]string{"title": "Food"})
+	accountId := s.createdId(alice.Token, "/api/account/", map[string]string{"title": "Wallet"})
+	transactionId := s.createdId(alice.Token, "/api/transaction/", map[string]interface{}{
+		"title":      "Lunch",
+		"amount":     250,
+		"date":       "2026-10-05T12:00:00Z",
+		"type":       "Expense",
+		"categoryId": categoryId,
+		"accountId":  accountId,
+	})
+	budgetId := s.createdId(alice.Token, "/api/budget/", map[string]interface{}{
+		"categoryId": categoryId,
+		"month":      "2026-10",
+		"amount":     1000,
+	})
+
+	requests := []struct {
+		method, path string
+		body         interface{}
+	}{
+		{http.MethodGet, "/api/category/" + categoryId, nil},
+		{http.MethodGet, "/api/account/" + accountId, nil},
+		{http.MethodGet, "/api/transaction/" + transactionId, nil},
+		{http.MethodPut, "/api/transaction/", map[string]interface{}{"id": transactionId, "title": "Mine now", "amount": 1, "type": "Expense"}},
+		{http.MethodDelete, "/api/transaction/" + transactionId, nil},
+		{http.MethodPut, "/api/budget/" + budgetId, map[string]interface{}{"amount": 1}},
+		{http.MethodDelete, "/api/budget/" + budgetId, nil},
+	}
+	for _, req := range requests {
+		s.expect(http.StatusNotFound, bob.Token, req.method, req.path, req.body)
+	}
+
+	// Bob's attempts left everything in place.
+	for _, path := range []string{
+		"/api/category/" + categoryId,
+		"/api/account/" + accountId,
+		"/api/transaction/" + transactionId,
+	} {
+		s.expect(http.StatusOK, alice.Token, http.MethodGet, path, nil)
+	}
+	var transaction struct {
+		Title string `json:"title"`
+	}
+	s.decode(s.expect(http.StatusOK, alice.Token, http.MethodGet, "/api/transaction/"+transactionId, nil), &transaction)
+	if transaction.Title != "Lunch" {
+		t.Errorf("title = %q after bob's update, want Lunch", transaction.Title)
+	}
+
+	// Nor can bob point his own records at alice's.
+	s.expect(http.StatusBadRequest, bob.Token, http.MethodPost, "/api/transaction/", map[string]interface{}{
+		"title":      "Sneaky",
+		"amount":     1,
+		"type":       "Expense",
+		"categoryId": categoryId,
+	})
+}
@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+
+	"github.com/amrohan/expenso-go/internal/auth"
+	"github.com/amrohan/expenso-go/internal/helpers"
+	"github.com/amrohan/expenso-go/internal/mailer"
+	"github.com/amrohan/expenso-go/internal/models"
+	"github.com/amrohan/expenso-go/internal/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ForgotPassword emails a single-use reset link. It answers the same way
+// whether or not the email is registered so it cannot be used to probe for
+// accounts; the mail goes out in the background so response times do not
+// give it away either.
+func (h *Handler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Email string `json:"email"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Email == "" {
+		helpers.SendResponse(w, http.StatusBadRequest, "Please send valid body", nil, err)
+		return
+	}
+
+	const message = "If the email is registered, a reset link has been sent"
+
+	user, err := h.store.Users.FindByEmail(r.Context(), body.Email)
+	if errors.Is(err, repository.ErrNotFound) || (err == nil && user.IsDeleted) {
+		helpers.SendResponse(w, http.StatusOK, message, nil, nil)
+		return
+	}
+	if err != nil {
+		helpers.SendResponse(w, http.StatusInternalServerError, "Unable to check for existing user", nil, err)
+		return
+	}
+
+	token, err := auth.NewOpaqueToken()
+	if err != nil {
+		helpers.SendResponse(w, http.StatusInternalServerError, "Error creating reset token", nil, err)
+		return
+	}
+	now := h.now()
+	reset := models.PasswordReset{
+		Id:        primitive.NewObjectID(),
+		UserId:    user.Id.Hex(),
+		TokenHash: auth.HashToken(token),
+		CreatedAt: now,
+		ExpiresAt: now.Add(h.config.PasswordResetTTL),
+	}
+	if err := h.store.PasswordResets.Create(r.Context(), &reset); err != nil {
+		helpers.SendResponse(w, http.StatusInternalServerError, "Error creating reset token", nil, err)
+		return
+	}
+
+	link := h.config.AppURL + "/reset-password?token=" + url.QueryEscape(token)
+	mail := mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your Expenso password",
+		Body: fmt.Sprintf("Hi %s,\n\nUse the link below to choose a new password. It expires in %s and can only be used once.\n\n%s\n\nIf you did not ask for this you can ignore this email.\n",
+			user.Username, h.config.PasswordResetTTL, link),
+	}
+	ctx := context.WithoutCancel(r.Context())
+	go func() {
+		if err := h.config.Mailer.Send(ctx, mail); err != nil {
+			log.Printf("Error sending password reset email: %v", err)
+		}
+	}()
+	helpers.SendResponse(w, http.StatusOK, message, nil, nil)
+}
+
+// ResetPassword sets a new password from a reset token and signs the user
+// out everywhere.
+func (h *Handler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Token == "" || body.Password == "" {
+		helpers.SendResponse(w, http.StatusBadRequest, "Please send valid body", nil, err)
+		return
+	}
+
+	// Burn the token before doing anything else so it cannot be replayed.
+	now := h.now()
+	reset, err := h.store.PasswordResets.Consume(r.Context(), auth.HashToken(body.Token), now)
+	if errors.Is(err, repository.ErrNotFound) {
+		helpers.SendResponse(w, http.StatusBadRequest, "Reset link is invalid or has expired", nil, nil)
+		return
+	}
+	if err != nil {
+		helpers.SendResponse(w, http.StatusInternalServerError, "Error resetting password", nil, err)
+		return
+	}
+
+	userId, err := primitive.ObjectIDFromHex(reset.UserId)
+	if err != nil {
+		helpers.SendResponse(w, http.StatusBadRequest, "Reset link is invalid or has expired", nil, err)
+		return
+	}
+	user, err := h.store.Users.FindById(r.Context(), userId)
+	if err != nil {
+		sendUserLookupError(w, err)
+		return
+	}
+
+	hash, err := HashPassword(body.Password)
+	if err != nil {
+		helpers.SendResponse(w, http.StatusBadRequest, "Please send valid body", nil, err)
+		return
+	}
+	user.Password = hash
+	user.UpdatedAt = now
+	if err := h.store.Users.Update(r.Context(), user); err != nil {
+		helpers.SendResponse(w, http.StatusInternalServerError, "Error resetting password", nil, err)
+		return
+	}
+	h.audit(r, models.AuditEvent{
+		Action:     models.AuditPasswordChange,
+		ActorId:    reset.UserId,
+		TargetType: "user",
+		TargetId:   reset.UserId,
+		Details:    map[string]interface{}{"method": "reset"},
+	})
+
+	if err := h.store.PasswordResets.InvalidateAll(r.Context(), reset.UserId, now); err != nil {
+		log.Printf("Error invalidating password resets: %v", err)
+	}
+	if err := h.store.Sessions.RevokeAll(r.Context(), reset.UserId, now); err != nil {
+		helpers.SendResponse(w, http.StatusInternalServerError, "Password changed but sessions could not be revoked", nil, err)
+		return
+	}
+	helpers.SendResponse(w, http.StatusOK, "Password has been reset", nil, nil)
+}
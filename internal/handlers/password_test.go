@@ -0,0 +1,71 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/amrohan/expenso-go/internal/handlers"
+)
+
+// resetToken waits for the nth reset link mailed to email, which is sent in
+// the background, and returns its token.
+func (s *testServer) resetToken(email string, n int) string {
+	s.t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		data, _ := os.ReadFile(s.mail)
+		if strings.Count(string(data), "/reset-password?token=") >= n {
+			break
+		}
+	}
+	u, err := url.Parse(s.link(email, "/reset-password?token="))
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	return u.Query().Get("token")
+}
+
+func TestPasswordReset(t *testing.T) {
+	t.Parallel()
+	now := &clock{now: time.Now()}
+	s := newTestServer(t, handlers.Config{Now: now.Now})
+	rosa := s.register("rosa")
+
+	// Unknown emails get the same answer.
+	unknown := s.expect(http.StatusOK, "", http.MethodPost, "/password/forgot", map[string]string{"email": "nobody@example.com"})
+	known := s.expect(http.StatusOK, "", http.MethodPost, "/password/forgot", map[string]string{"email": "rosa@example.com"})
+	if unknown.Message != known.Message {
+		t.Errorf("forgot messages differ: %q and %q", unknown.Message, known.Message)
+	}
+	token := s.resetToken("rosa@example.com", 1)
+
+	reset := func(token string) apiResponse {
+		return s.call("", http.MethodPost, "/password/reset", map[string]string{"token": token, "password": "battery staple rosa"})
+	}
+	if resp := reset("made-up"); resp.Status != http.StatusBadRequest {
+		t.Errorf("reset with a made-up token = %d %q, want 400", resp.Status, resp.Message)
+	}
+	if resp := reset(token); resp.Status != http.StatusOK {
+		t.Fatalf("reset = %d %q, want 200", resp.Status, resp.Message)
+	}
+	if resp := reset(token); resp.Status != http.StatusBadRequest {
+		t.Errorf("second reset with the same token = %d %q, want 400", resp.Status, resp.Message)
+	}
+
+	// Every existing session is signed out, and only the new password works.
+	s.expect(http.StatusUnauthorized, rosa.Token, http.MethodGet, "/api/category/", nil)
+	s.expect(http.StatusUnauthorized, "", http.MethodPost, "/refresh", map[string]string{"refreshToken": rosa.RefreshToken})
+	s.expect(http.StatusUnauthorized, "", http.MethodPost, "/login", map[string]string{"username": "rosa", "password": "correct horse rosa"})
+	s.expect(http.StatusOK, "", http.MethodPost, "/login", map[string]string{"username": "rosa", "password": "battery staple rosa"})
+
+	// Links expire.
+	s.expect(http.StatusOK, "", http.MethodPost, "/password/forgot", map[string]string{"email": "rosa@example.com"})
+	expired := s.resetToken("rosa@example.com", 2)
+	now.Add(2 * time.Hour)
+	if resp := reset(expired); resp.Status != http.StatusBadRequest {
+		t.Errorf("reset with an expired token = %d %q, want 400", resp.Status, resp.Message)
+	}
+}
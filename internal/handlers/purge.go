@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// PurgeTrash permanently deletes transactions, accounts and categories that
+// have been in the trash for longer than Config.TrashRetention. Deleted users
+// are left for an admin to restore or remove.
+func (h *Handler) PurgeTrash(ctx context.Context) error {
+	cutoff := h.now().Add(-h.config.TrashRetention)
+	purges := []struct {
+		name  string
+		purge func(context.Context, time.Time) (int64, error)
+	}{
+		{"transactions", h.store.Transactions.Purge},
+		{"accounts", h.store.Accounts.Purge},
+		{"categories", h.store.Categories.Purge},
+	}
+	for _, p := range purges {
+		n, err := p.purge(ctx, cutoff)
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			log.Printf("Purged %d %s from the trash", n, p.name)
+		}
+	}
+	return nil
+}
+
+// RunTrashPurge calls PurgeTrash every interval until ctx is done.
+func (h *Handler) RunTrashPurge(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := h.PurgeTrash(ctx); err != nil {
+			log.Printf("Error purging trash: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
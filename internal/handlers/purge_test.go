@@ -0,0 +1,99 @@
+package handlers_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/amrohan/expenso-go/internal/handlers"
+)
+
+func TestTrash(t *testing.T) {
+	t.Parallel()
+	now := &clock{now: time.Now()}
+	s := newTestServer(t, handlers.Config{
+		Now:            now.Now,
+		TrashRetention: 24 * time.Hour,
+		// Tokens outlive the retention so the clock can skip past it.
+		AccessTokenTTL: 7 * 24 * time.Hour,
+	})
+	ada := s.register("ada")
+	ids := map[string]string{
+		"category": s.createdId(ada.Token, "/api/category/", map[string]string{"title": "Books"}),
+		"account":  s.createdId(ada.Token, "/api/account/", map[string]interface{}{"title": "Cash"}),
+		"transaction": s.createdId(ada.Token, "/api/transaction/", map[string]interface{}{
+			"title": "Novel", "amount": 9, "date": "2026-10-01T10:00:00Z", "type": "Expense",
+		}),
+	}
+	count := func(path string) int {
+		var items []struct{ Id string }
+		s.decode(s.expect(http.StatusOK, ada.Token, http.MethodGet, path, nil), &items)
+		return len(items)
+	}
+
+	// Deleted items leave the listings for the trash and can come back.
+	for kind, id := range ids {
+		base := "/api/" + kind + "/"
+		s.expect(http.StatusOK, ada.Token, http.MethodDelete, base+id, nil)
+		s.expect(http.StatusNotFound, ada.Token, http.MethodGet, base+id, nil)
+		if n := count("/api/" + kind + "/user/" + s.subject(ada.Token)); n != 0 {
+			t.Errorf("%d %s listed after deleting, want 0", n, kind)
+		}
+		if n := count(base + "trash"); n != 1 {
+			t.Errorf("%d %s in the trash, want 1", n, kind)
+		}
+		s.expect(http.StatusOK, ada.Token, http.MethodPost, base+id+"/restore", nil)
+		s.expect(http.StatusOK, ada.Token, http.MethodGet, base+id, nil)
+		s.expect(http.StatusNotFound, ada.Token, http.MethodPost, base+id+"/restore", nil)
+		s.expect(http.StatusOK, ada.Token, http.MethodDelete, base+id, nil)
+	}
+
+	// The purge only removes what has been in the trash past retention.
+	now.Add(23 * time.Hour)
+	if err := s.handler.PurgeTrash(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	for kind := range ids {
+		if n := count("/api/" + kind + "/trash"); n != 1 {
+			t.Errorf("%d %s in the trash before retention, want 1", n, kind)
+		}
+	}
+	now.Add(2 * time.Hour)
+	if err := s.handler.PurgeTrash(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	for kind, id := range ids {
+		if n := count("/api/" + kind + "/trash"); n != 0 {
+			t.Errorf("%d %s in the trash after retention, want 0", n, kind)
+		}
+		s.expect(http.StatusNotFound, ada.Token, http.MethodPost, "/api/"+kind+"/"+id+"/restore", nil)
+	}
+}
+
+func TestUserTrash(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t, handlers.Config{AdminEmails: []string{"bea@example.com"}})
+	s.register("bea")
+	s.expect(http.StatusOK, "", http.MethodGet, s.link("bea@example.com", "/verify?token="), nil)
+	var bea tokens
+	s.decode(s.login("bea"), &bea)
+	cy := s.register("cy")
+	cyId := s.subject(cy.Token)
+
+	// Deleting an account signs it out and keeps it for an admin to
+	// restore.
+	s.expect(http.StatusOK, cy.Token, http.MethodDelete, "/api/user/"+cyId, nil)
+	s.expect(http.StatusUnauthorized, cy.Token, http.MethodGet, "/api/category/", nil)
+	s.expect(http.StatusUnauthorized, "", http.MethodPost, "/login", map[string]string{"username": "cy", "password": "correct horse cy"})
+
+	var deleted []struct {
+		Id string `json:"id"`
+	}
+	s.decode(s.expect(http.StatusOK, bea.Token, http.MethodGet, "/api/user/du/", nil), &deleted)
+	if len(deleted) != 1 || deleted[0].Id != cyId {
+		t.Fatalf("deleted users = %+v, want cy", deleted)
+	}
+	s.expect(http.StatusOK, bea.Token, http.MethodPost, "/api/user/du/"+cyId, nil)
+	s.login("cy")
+}
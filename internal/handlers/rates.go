@@ -0,0 +1,371 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"time"
+
+	"github.com/amrohan/expenso-go/internal/ecb"
+	"github.com/amrohan/expenso-go/internal/helpers"
+	"github.com/amrohan/expenso-go/internal/models"
+	"github.com/amrohan/expenso-go/internal/money"
+	"github.com/amrohan/expenso-go/internal/repository"
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	defaultRatePageSize = 100
+	maxRatePageSize     = 1000
+	// maxRateFileSize bounds imports; the full ECB history is about 2MB.
+	maxRateFileSize = 16 << 20
+	rateDateLayout  = "2006-01-02"
+)
+
+// ratePivots are tried, in order, when two currencies have no direct or
+// inverse rate between them. ECB files quote everything against EUR.
+var ratePivots = []string{"EUR", "USD"}
+
+// errNoRate is returned when no rate on or before the requested day can be
+// found or derived.
+var errNoRate = errors.New("no exchange rate")
+
+// rateDay truncates t to the UTC day rates are stored under.
+func rateDay(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// rateConverter converts amounts at the rate in effect on a given day. It
+// caches lookups, so use one per request.
+type rateConverter struct {
+	ctx   context.Context
+	rates repository.ExchangeRateRepository
+	cache map[rateKey]*big.Rat
+}
+
+type rateKey struct {
+	from, to string
+	day      time.Time
+}
+
+func (h *Handler) newRateConverter(ctx context.Context) *rateConverter {
+	return &rateConverter{ctx: ctx, rates: h.store.ExchangeRates, cache: map[rateKey]*big.Rat{}}
+}
+
+// convert returns amount in currency at the rate of the day of on.
+func (c *rateConverter) convert(amount money.Money, currency string, on time.Time) (money.Money, error) {
+	if amount.Currency == currency {
+		return amount, nil
+	}
+	rate, err := c.rate(amount.Currency, currency, rateDay(on))
+	if err != nil {
+		return money.Money{}, err
+	}
+	return amount.Convert(currency, rate)
+}
+
+// rate finds the price of one from in to: directly, as the inverse of the
+// opposite pair, or across one of the ratePivots.
+func (c *rateConverter) rate(from, to string, day time.Time) (*big.Rat, error) {
+	key := rateKey{from, to, day}
+	if rate, ok := c.cache[key]; ok {
+		return rate, nil
+	}
+
+	rate, err := c.pairRate(from, to, day)
+	for _, pivot := range ratePivots {
+		if !errors.Is(err, errNoRate) {
+			break
+		}
+		if pivot == from || pivot == to {
+			continue
+		}
+		var viaFrom, viaTo *big.Rat
+		if viaFrom, err = c.pairRate(pivot, from, day); err == nil {
+			if viaTo, err = c.pairRate(pivot, to, day); err == nil {
+				rate = new(big.Rat).Quo(viaTo, viaFrom)
+			}
+		}
+	}
+	if err != nil {
+		if errors.Is(err, errNoRate) {
+			return nil, fmt.Errorf("%w from %s to %s on %s", errNoRate, from, to, day.Format(rateDateLayout))
+		}
+		return nil, err
+	}
+	c.cache[key] = rate
+	return rate, nil
+}
+
+func (c *rateConverter) pairRate(from, to string, day time.Time) (*big.Rat, error) {
+	stored, err := c.rates.Latest(c.ctx, from, to, day)
+	if err == nil {
+		return money.ParseRate(stored.Rate)
+	}
+	if !errors.Is(err, repository.ErrNotFound) {
+		return nil, err
+	}
+	stored, err = c.rates.Latest(c.ctx, to, from, day)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, errNoRate
+	}
+	if err != nil {
+		return nil, err
+	}
+	rate, err := money.ParseRate(stored.Rate)
+	if err != nil {
+		return nil, err
+	}
+	return rate.Inv(rate), nil
+}
+
+// baseCurrency is the currency a user's summaries are reported in.
+func (h *Handler) baseCurrency(ctx context.Context, userId string) string {
+	if id, err := primitive.ObjectIDFromHex(userId); err == nil {
+		if user, err := h.store.Users.FindById(ctx, id); err == nil && user.BaseCurrency != "" {
+			return user.BaseCurrency
+		}
+	}
+	return h.config.DefaultCurrency
+}
+
+// accountCurrency is the currency of an account's transactions. Accounts
+// created before accounts had a currency use the default one.
+func (h *Handler) accountCurrency(account *models.Account) string {
+	if account.Currency != "" {
+		return account.Currency
+	}
+	return h.config.DefaultCurrency
+}
+
+// exchangeRateRequest is the body of CreateExchangeRate. Date is a day such
+// as "2026-10-17".
+type exchangeRateRequest struct {
+	Base  string `json:"base"`
+	Quote string `json:"quote"`
+	Rate  string `json:"rate"`
+	Date  string `json:"date"`
+}
+
+// CreateExchangeRate stores a manually entered rate, replacing any rate for
+// the same pair and day.
+func (h *Handler) CreateExchangeRate(w http.ResponseWriter, r *http.Request) {
+	var req exchangeRateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		helpers.SendResponse(w, http.StatusBadRequest, "Please send valid json", nil, err)
+		return
+	}
+
+	base, err := money.NormalizeCurrency(req.Base)
+	if err != nil {
+		helpers.SendResponse(w, http.StatusBadRequest, "Please send a valid base currency", nil, err)
+		return
+	}
+	quote, err := money.NormalizeCurrency(req.Quote)
+	if err != nil || quote == base {
+		helpers.SendResponse(w, http.StatusBadRequest, "Please send a valid quote currency", nil, err)
+		return
+	}
+	if _, err := money.ParseRate(req.Rate); err != nil {
+		helpers.SendResponse(w, http.StatusBadRequest, "Please send a valid rate", nil, err)
+		return
+	}
+	date, err := time.Parse(rateDateLayout, req.Date)
+	if err != nil {
+		helpers.SendResponse(w, http.StatusBadRequest, "Please send a valid date", nil, err)
+		return
+	}
+
+	rate := models.ExchangeRate{
+		Id:        primitive.NewObjectID(),
+		Base:      base,
+		Quote:     quote,
+		Rate:      req.Rate,
+		Date:      date,
+		Source:    models.RateSourceManual,
+		UpdatedAt: h.now(),
+	}
+	if err := h.store.ExchangeRates.Put(r.Context(), []models.ExchangeRate{rate}); err != nil {
+		helpers.SendResponse(w, http.StatusInternalServerError, "Couldnt save exchange rate", nil, err)
+		return
+	}
+	stored, err := h.store.ExchangeRates.Latest(r.Context(), base, quote, date)
+	if err != nil {
+		helpers.SendResponse(w, http.StatusInternalServerError, "Couldnt save exchange rate", nil, err)
+		return
+	}
+	helpers.SendResponse(w, http.StatusOK, "Exchange rate saved", stored, nil)
+}
+
+// ImportExchangeRates loads an ECB style CSV or XML file from the request
+// body. The file's rates are quoted against the base query parameter, EUR by
+// default.
+func (h *Handler) ImportExchangeRates(w http.ResponseWriter, r *http.Request) {
+	base := "EUR"
+	if value := r.URL.Query().Get("base"); value != "" {
+		var err error
+		if base, err = money.NormalizeCurrency(value); err != nil {
+			helpers.SendResponse(w, http.StatusBadRequest, "Please send a valid base currency", nil, err)
+			return
+		}
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRateFileSize))
+	if err != nil {
+		helpers.SendResponse(w, http.StatusRequestEntityTooLarge, "Rate file is too large", nil, err)
+		return
+	}
+	parsed, err := ecb.Parse(data)
+	if err != nil {
+		helpers.SendResponse(w, http.StatusBadRequest, "Please send an ECB style CSV or XML file", nil, err)
+		return
+	}
+
+	now := h.now()
+	rates := make([]models.ExchangeRate, 0, len(parsed))
+	var from, to time.Time
+	for _, p := range parsed {
+		quote, err := money.NormalizeCurrency(p.Currency)
+		if err == nil {
+			_, err = money.ParseRate(p.Rate)
+		}
+		if err != nil {
+			helpers.SendResponse(w, http.StatusBadRequest, fmt.Sprintf("Invalid %s rate on %s", p.Currency, p.Date.Format(rateDateLayout)), nil, err)
+			return
+		}
+		if quote == base {
+			continue
+		}
+		rates = append(rates, models.ExchangeRate{
+			Id:        primitive.NewObjectID(),
+			Base:      base,
+			Quote:     quote,
+			Rate:      p.Rate,
+			Date:      p.Date,
+			Source:    models.RateSourceImport,
+			UpdatedAt: now,
+		})
+		if from.IsZero() || p.Date.Before(from) {
+			from = p.Date
+		}
+		if p.Date.After(to) {
+			to = p.Date
+		}
+	}
+
+	if err := h.store.ExchangeRates.Put(r.Context(), rates); err != nil {
+		helpers.SendResponse(w, http.StatusInternalServerError, "Couldnt save exchange rates", nil, err)
+		return
+	}
+	summary := map[string]interface{}{"imported": len(rates), "base": base}
+	if len(rates) > 0 {
+		summary["from"] = from.Format(rateDateLayout)
+		summary["to"] = to.Format(rateDateLayout)
+	}
+	helpers.SendResponse(w, http.StatusOK, "Exchange rates imported", summary, nil)
+}
+
+// GetExchangeRates pages through stored rates, newest first. It accepts
+// base, quote, from and to (days, to exclusive), page and pageSize.
+func (h *Handler) GetExchangeRates(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := repository.ExchangeRateFilter{}
+	for name, code := range map[string]*string{"base": &filter.Base, "quote": &filter.Quote} {
+		if value := query.Get(name); value != "" {
+			normalized, err := money.NormalizeCurrency(value)
+			if err != nil {
+				helpers.SendResponse(w, http.StatusBadRequest, "Please send a valid "+name+" currency", nil, err)
+				return
+			}
+			*code = normalized
+		}
+	}
+	for name, t := range map[string]*time.Time{"from": &filter.From, "to": &filter.To} {
+		if value := query.Get(name); value != "" {
+			parsed, err := time.Parse(rateDateLayout, value)
+			if err != nil {
+				helpers.SendResponse(w, http.StatusBadRequest, "Please send a valid "+name+" date", nil, err)
+				return
+			}
+			*t = parsed
+		}
+	}
+	page, ok := pageQuery(w, r, defaultRatePageSize, maxRatePageSize)
+	if !ok {
+		return
+	}
+
+	rates, total, err := h.store.ExchangeRates.Find(r.Context(), filter, page)
+	if err != nil {
+		helpers.SendResponse(w, http.StatusInternalServerError, "Couldnt find exchange rates", nil, err)
+		return
+	}
+	helpers.SendResponse(w, http.StatusOK, "Exchange rates found", map[string]interface{}{
+		"rates":    rates,
+		"page":     page.Number,
+		"pageSize": page.Size,
+		"total":    total,
+	}, nil)
+}
+
+// ConvertAmount converts amount from one currency to another at the rate
+// of date, today by default.
+func (h *Handler) ConvertAmount(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	to, err := money.NormalizeCurrency(query.Get("to"))
+	if err != nil {
+		helpers.SendResponse(w, http.StatusBadRequest, "Please send a valid to currency", nil, err)
+		return
+	}
+	amount, err := money.Parse(query.Get("amount"), query.Get("from"))
+	if err != nil {
+		helpers.SendResponse(w, http.StatusBadRequest, "Please send a valid amount and from currency", nil, err)
+		return
+	}
+	on := h.now()
+	if value := query.Get("date"); value != "" {
+		if on, err = time.Parse(rateDateLayout, value); err != nil {
+			helpers.SendResponse(w, http.StatusBadRequest, "Please send a valid date", nil, err)
+			return
+		}
+	}
+
+	converted, err := h.newRateConverter(r.Context()).convert(amount, to, on)
+	if errors.Is(err, errNoRate) {
+		helpers.SendResponse(w, http.StatusNotFound, "No exchange rate found", nil, err)
+		return
+	}
+	if err != nil {
+		helpers.SendResponse(w, http.StatusInternalServerError, "Couldnt convert amount", nil, err)
+		return
+	}
+	helpers.SendResponse(w, http.StatusOK, "Amount converted", map[string]interface{}{
+		"amount":    amount,
+		"converted": converted,
+		"date":      rateDay(on).Format(rateDateLayout),
+	}, nil)
+}
+
+func (h *Handler) DeleteExchangeRate(w http.ResponseWriter, r *http.Request) {
+	id, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.SendResponse(w, http.StatusBadRequest, "Invalid id", nil, err)
+		return
+	}
+	err = h.store.ExchangeRates.Delete(r.Context(), id)
+	if errors.Is(err, repository.ErrNotFound) {
+		helpers.SendResponse(w, http.StatusNotFound, "Exchange rate not found", nil, nil)
+		return
+	}
+	if err != nil {
+		helpers.SendResponse(w, http.StatusInternalServerError, "Couldnt delete exchange rate", nil, err)
+		return
+	}
+	helpers.SendResponse(w, http.StatusOK, "Exchange rate deleted", nil, nil)
+}
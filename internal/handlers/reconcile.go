@@ -0,0 +1,451 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/amrohan/expenso-go/internal/helpers"
+	"github.com/amrohan/expenso-go/internal/models"
+	"github.com/amrohan/expenso-go/internal/money"
+	"github.com/amrohan/expenso-go/internal/repository"
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// errLocked is returned when a change would touch a reconciled transaction.
+var errLocked = errors.New("transaction is reconciled")
+
+func sendLocked(w http.ResponseWriter) {
+	helpers.SendResponse(w, http.StatusConflict, "Transaction is reconciled, unlock it before changing it", nil, nil)
+}
+
+// checkStatus validates the status sent with a transaction. Empty keeps
+// current, which is pending for new transactions; reconciled can only be
+// set by finishing a reconciliation.
+func checkStatus(w http.ResponseWriter, transaction *models.Transaction, current string) bool {
+	if transaction.Status == "" {
+		transaction.Status = current
+	}
+	if transaction.Status != models.StatusPending && transaction.Status != models.StatusCleared {
+		helpers.SendResponse(w, http.StatusBadRequest, "Status must be pending or cleared", nil, nil)
+		return false
+	}
+	transaction.ReconciliationId = ""
+	return true
+}
+
+// SetTransactionStatus marks any unlocked transaction, transfer legs
+// included, pending or cleared.
+func (h *Handler) SetTransactionStatus(w http.ResponseWriter, r *http.Request) {
+	id, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.SendResponse(w, http.StatusBadRequest, "Invalid id", nil, err)
+		return
+	}
+	var req struct {
+		Status string `json:"status"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		helpers.SendResponse(w, http.StatusBadRequest, "Couldnt decode request", nil, err)
+		return
+	}
+
+	existing, ok := h.ownedTransaction(w, r, id)
+	if !ok {
+		return
+	}
+	if existing.IsLocked() {
+		sendLocked(w)
+		return
+	}
+	updated := *existing
+	updated.Status = req.Status
+	if !checkStatus(w, &updated, "") {
+		return
+	}
+	h.saveStatus(w, r, existing, &updated, models.AuditTransactionUpdate)
+}
+
+// UnlockTransaction takes a transaction out of its reconciliation so it can
+// be edited again. It goes back to cleared.
+func (h *Handler) UnlockTransaction(w http.ResponseWriter, r *http.Request) {
+	id, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.SendResponse(w, http.StatusBadRequest, "Invalid id", nil, err)
+		return
+	}
+	existing, ok := h.ownedTransaction(w, r, id)
+	if !ok {
+		return
+	}
+	if !existing.IsLocked() {
+		helpers.SendResponse(w, http.StatusBadRequest, "Transaction is not reconciled", nil, nil)
+		return
+	}
+	updated := *existing
+	updated.Status = models.StatusCleared
+	updated.ReconciliationId = ""
+	h.saveStatus(w, r, existing, &updated, models.AuditTransactionUnlock)
+}
+
+func (h *Handler) saveStatus(w http.ResponseWriter, r *http.Request, existing, updated *models.Transaction, action string) {
+	updated.UpdatedAt = h.now()
+	if err := h.store.Transactions.Update(r.Context(), updated); err != nil {
+		helpers.SendResponse(w, http.StatusInternalServerError, "Couldnt update transaction", nil, err)
+		return
+	}
+	h.recordRevision(r, "transaction", updated.Id, updated.UserId, models.RevisionUpdate, existing, updated)
+	h.auditChange(r, action, "transaction", updated.Id, existing, updated)
+	helpers.SendResponse(w, http.StatusOK, "Transaction updated", updated, nil)
+}
+
+// reconciliationView is a reconciliation with the difference still to
+// explain. Transactions are the ones it can tick while open and the ones it
+// reconciled once finished.
+type reconciliationView struct {
+	models.Reconciliation
+	Difference   money.Money          `json:"difference"`
+	Transactions []models.Transaction `json:"transactions"`
+}
+
+// reconcileState walks the account's transactions for rec. Candidates are
+// the unreconciled transactions up to the statement date plus any this
+// reconciliation already locked; cleared is the opening balance, everything
+// reconciled before and the ticked candidates.
+func (h *Handler) reconcileState(ctx context.Context, account *models.Account, rec *models.Reconciliation) ([]models.Transaction, money.Money, error) {
+	ledger, err := h.loadLedger(ctx, account, time.Time{})
+	if err != nil {
+		return nil, money.Money{}, err
+	}
+	ticked := map[string]bool{}
+	for _, id := range rec.TransactionIds {
+		ticked[id] = true
+	}
+	until := rateDay(rec.StatementDate).AddDate(0, 0, 1)
+
+	candidates := []models.Transaction{}
+	cleared := ledger.opening
+	for _, t := range ledger.transactions {
+		amount, err := t.Amount.WithDefaultCurrency(ledger.currency)
+		if err != nil {
+			return nil, money.Money{}, err
+		}
+		effect, ok := balanceEffect(t, amount)
+		if !ok {
+			continue
+		}
+		mine := t.ReconciliationId == rec.Id.Hex()
+		switch {
+		case t.IsLocked() && !mine:
+			cleared, err = cleared.Add(effect)
+		case mine || t.Date.Before(until):
+			candidates = append(candidates, t)
+			if ticked[t.Id.Hex()] {
+				cleared, err = cleared.Add(effect)
+			}
+		}
+		if err != nil {
+			return nil, money.Money{}, err
+		}
+	}
+	return candidates, cleared, nil
+}
+
+func (h *Handler) reconciliationView(ctx context.Context, account *models.Account, rec *models.Reconciliation) (*reconciliationView, error) {
+	view := &reconciliationView{Reconciliation: *rec, Transactions: []models.Transaction{}}
+	if rec.Status == models.ReconciliationFinished {
+		transactions, err := h.store.Transactions.Find(ctx, repository.TransactionFilter{UserId: account.UserId, AccountId: account.Id.Hex()})
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range transactions {
+			if t.ReconciliationId == rec.Id.Hex() {
+				view.Transactions = append(view.Transactions, t)
+			}
+		}
+		view.Difference, err = rec.StatementBalance.Sub(rec.ClearedBalance)
+		return view, err
+	}
+
+	candidates, cleared, err := h.reconcileState(ctx, account, rec)
+	if err != nil {
+		return nil, err
+	}
+	view.Transactions = candidates
+	view.ClearedBalance = cleared
+	view.Difference, err = rec.StatementBalance.Sub(cleared)
+	return view, err
+}
+
+func (h *Handler) sendReconciliation(w http.ResponseWriter, r *http.Request, status int, message string, account *models.Account, rec *models.Reconciliation) {
+	view, err := h.reconciliationView(r.Context(), account, rec)
+	if err != nil {
+		helpers.SendResponse(w, http.StatusInternalServerError, "Couldnt compute reconciliation", nil, err)
+		return
+	}
+	helpers.SendResponse(w, status, message, view, nil)
+}
+
+// StartReconciliation opens a reconciliation of an account against a
+// statement ending on statementDate (a day such as "2026-10-31") with
+// statementBalance. Transactions already marked cleared start ticked.
+func (h *Handler) StartReconciliation(w http.ResponseWriter, r *http.Request) {
+	account, ok := h.ownedAccount(w, r, chi.URLParam(r, "id"))
+	if !ok {
+		return
+	}
+	var req struct {
+		StatementDate    string      `json:"statementDate"`
+		StatementBalance money.Money `json:"statementBalance"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		helpers.SendResponse(w, http.StatusBadRequest, "Couldnt decode request", nil, err)
+		return
+	}
+	date, err := time.Parse(rateDateLayout, req.StatementDate)
+	if err != nil {
+		helpers.SendResponse(w, http.StatusBadRequest, "Please send a valid statementDate", nil, err)
+		return
+	}
+	currency := h.accountCurrency(account)
+	balance, err := req.StatementBalance.WithDefaultCurrency(currency)
+	if err != nil || balance.Currency != currency {
+		helpers.SendResponse(w, http.StatusBadRequest, "Please send a statementBalance in the account currency, "+currency, nil, err)
+		return
+	}
+
+	existing, err := h.store.Reconciliations.FindByAccount(r.Context(), account.Id.Hex())
+	if err != nil {
+		helpers.SendResponse(w, http.StatusInternalServerError, "Couldnt find reconciliations", nil, err)
+		return
+	}
+	for _, rec := range existing {
+		if rec.Status == models.ReconciliationOpen {
+			helpers.SendResponse(w, http.StatusConflict, "Account already has an open reconciliation", map[string]interface{}{"id": rec.Id}, nil)
+			return
+		}
+	}
+
+	rec := models.Reconciliation{
+		Id:               primitive.NewObjectID(),
+		AccountId:        account.Id.Hex(),
+		UserId:           account.UserId,
+		StatementDate:    date,
+		StatementBalance: balance,
+		TransactionIds:   []string{},
+		Status:           models.ReconciliationOpen,
+		CreatedAt:        h.now(),
+	}
+	candidates, _, err := h.reconcileState(r.Context(), account, &rec)
+	if err != nil {
+		helpers.SendResponse(w, http.StatusInternalServerError, "Couldnt compute reconciliation", nil, err)
+		return
+	}
+	for _, t := range candidates {
+		if t.Status == models.StatusCleared {
+			rec.TransactionIds = append(rec.TransactionIds, t.Id.Hex())
+		}
+	}
+
+	if err := h.store.Reconciliations.Create(r.Context(), &rec); err != nil {
+		helpers.SendResponse(w, http.StatusInternalServerError, "Couldnt start reconciliation", nil, err)
+		return
+	}
+	h.audit(r, models.AuditEvent{
+		Action:     models.AuditReconciliationStart,
+		TargetType: "reconciliation",
+		TargetId:   rec.Id.Hex(),
+		Details:    map[string]interface{}{"accountId": rec.AccountId},
+	})
+	h.sendReconciliation(w, r, http.StatusOK, "Reconciliation started", account, &rec)
+}
+
+func (h *Handler) GetReconciliations(w http.ResponseWriter, r *http.Request) {
+	account, ok := h.ownedAccount(w, r, chi.URLParam(r, "id"))
+	if !ok {
+		return
+	}
+	reconciliations, err := h.store.Reconciliations.FindByAccount(r.Context(), account.Id.Hex())
+	if err != nil {
+		helpers.SendResponse(w, http.StatusInternalServerError, "Couldnt find reconciliations", nil, err)
+		return
+	}
+	helpers.SendResponse(w, http.StatusOK, "Reconciliations found", reconciliations, nil)
+}
+
+func (h *Handler) GetReconciliation(w http.ResponseWriter, r *http.Request) {
+	account, rec, ok := h.ownedReconciliation(w, r)
+	if !ok {
+		return
+	}
+	h.sendReconciliation(w, r, http.StatusOK, "Reconciliation found", account, rec)
+}
+
+// UpdateReconciliation replaces the ticked transactions of an open
+// reconciliation.
+func (h *Handler) UpdateReconciliation(w http.ResponseWriter, r *http.Request) {
+	account, rec, ok := h.openReconciliation(w, r)
+	if !ok {
+		return
+	}
+	var req struct {
+		TransactionIds []string `json:"transactionIds"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		helpers.SendResponse(w, http.StatusBadRequest, "Couldnt decode request", nil, err)
+		return
+	}
+
+	candidates, _, err := h.reconcileState(r.Context(), account, rec)
+	if err != nil {
+		helpers.SendResponse(w, http.StatusInternalServerError, "Couldnt compute reconciliation", nil, err)
+		return
+	}
+	allowed := map[string]bool{}
+	for _, t := range candidates {
+		allowed[t.Id.Hex()] = true
+	}
+	ticked := []string{}
+	seen := map[string]bool{}
+	for _, id := range req.TransactionIds {
+		if !allowed[id] {
+			helpers.SendResponse(w, http.StatusBadRequest, fmt.Sprintf("Transaction %s cannot be ticked in this reconciliation", id), nil, nil)
+			return
+		}
+		if !seen[id] {
+			seen[id] = true
+			ticked = append(ticked, id)
+		}
+	}
+
+	rec.TransactionIds = ticked
+	if err := h.store.Reconciliations.Update(r.Context(), rec); err != nil {
+		helpers.SendResponse(w, http.StatusInternalServerError, "Couldnt update reconciliation", nil, err)
+		return
+	}
+	h.sendReconciliation(w, r, http.StatusOK, "Reconciliation updated", account, rec)
+}
+
+// FinishReconciliation locks the ticked transactions once they account for
+// the statement balance exactly. The transactions are locked together or not
+// at all; should closing the reconciliation then fail, finishing again
+// picks up from there.
+func (h *Handler) FinishReconciliation(w http.ResponseWriter, r *http.Request) {
+	account, rec, ok := h.openReconciliation(w, r)
+	if !ok {
+		return
+	}
+	view, err := h.reconciliationView(r.Context(), account, rec)
+	if err != nil {
+		helpers.SendResponse(w, http.StatusInternalServerError, "Couldnt compute reconciliation", nil, err)
+		return
+	}
+	if !view.Difference.IsZero() {
+		helpers.SendResponse(w, http.StatusConflict, "Cleared balance differs from the statement by "+view.Difference.String(), view, nil)
+		return
+	}
+
+	ticked := map[string]bool{}
+	for _, id := range rec.TransactionIds {
+		ticked[id] = true
+	}
+	// Each transaction is only locked as it was when the balance above was
+	// worked out; one changed since then fails the whole finish.
+	var updates []repository.TransactionUpdate
+	for i := range view.Transactions {
+		existing := view.Transactions[i]
+		if !ticked[existing.Id.Hex()] || existing.IsLocked() {
+			continue
+		}
+		updated := existing
+		updated.Status = models.StatusReconciled
+		updated.ReconciliationId = rec.Id.Hex()
+		updated.UpdatedAt = h.now()
+		updates = append(updates, repository.TransactionUpdate{Transaction: &updated, Loaded: &existing})
+	}
+	err = h.store.Transactions.UpdateAll(r.Context(), updates)
+	if errors.Is(err, repository.ErrConflict) || errors.Is(err, repository.ErrNotFound) {
+		helpers.SendResponse(w, http.StatusConflict, "A transaction changed while reconciling, please review and finish again", nil, nil)
+		return
+	}
+	if err != nil {
+		helpers.SendResponse(w, http.StatusInternalServerError, "Couldnt reconcile transactions", nil, err)
+		return
+	}
+	for _, update := range updates {
+		h.recordRevision(r, "transaction", update.Transaction.Id, update.Transaction.UserId, models.RevisionUpdate, update.Loaded, update.Transaction)
+		h.auditChange(r, models.AuditTransactionUpdate, "transaction", update.Transaction.Id, update.Loaded, update.Transaction)
+	}
+
+	rec.Status = models.ReconciliationFinished
+	rec.ClearedBalance = view.ClearedBalance
+	rec.FinishedAt = h.now()
+	if err := h.store.Reconciliations.Update(r.Context(), rec); err != nil {
+		helpers.SendResponse(w, http.StatusInternalServerError, "Couldnt finish reconciliation", nil, err)
+		return
+	}
+	h.audit(r, models.AuditEvent{
+		Action:     models.AuditReconciliationFinish,
+		TargetType: "reconciliation",
+		TargetId:   rec.Id.Hex(),
+		Details:    map[string]interface{}{"accountId": rec.AccountId, "transactions": len(rec.TransactionIds)},
+	})
+	h.sendReconciliation(w, r, http.StatusOK, "Reconciliation finished", account, rec)
+}
+
+// CancelReconciliation discards an open reconciliation. Finished ones stay
+// in the history; unlock single transactions instead.
+func (h *Handler) CancelReconciliation(w http.ResponseWriter, r *http.Request) {
+	_, rec, ok := h.openReconciliation(w, r)
+	if !ok {
+		return
+	}
+	if err := h.store.Reconciliations.Delete(r.Context(), rec.Id); err != nil {
+		helpers.SendResponse(w, http.StatusInternalServerError, "Couldnt cancel reconciliation", nil, err)
+		return
+	}
+	h.audit(r, models.AuditEvent{
+		Action:     models.AuditReconciliationCancel,
+		TargetType: "reconciliation",
+		TargetId:   rec.Id.Hex(),
+		Details:    map[string]interface{}{"accountId": rec.AccountId},
+	})
+	helpers.SendResponse(w, http.StatusOK, "Reconciliation cancelled", nil, nil)
+}
+
+// ownedReconciliation loads the reconciliation named in the URL when it
+// belongs to the caller's account, also named in the URL.
+func (h *Handler) ownedReconciliation(w http.ResponseWriter, r *http.Request) (*models.Account, *models.Reconciliation, bool) {
+	account, ok := h.ownedAccount(w, r, chi.URLParam(r, "id"))
+	if !ok {
+		return nil, nil, false
+	}
+	id, err := primitive.ObjectIDFromHex(chi.URLParam(r, "reconciliationId"))
+	if err != nil {
+		helpers.SendResponse(w, http.StatusBadRequest, "Invalid reconciliation id", nil, err)
+		return nil, nil, false
+	}
+	rec, err := h.store.Reconciliations.FindById(r.Context(), id)
+	if errors.Is(err, repository.ErrNotFound) || (err == nil && rec.AccountId != account.Id.Hex()) {
+		helpers.SendResponse(w, http.StatusNotFound, "Reconciliation not found", nil, nil)
+		return nil, nil, false
+	}
+	if err != nil {
+		helpers.SendResponse(w, http.StatusInternalServerError, "Couldnt find reconciliation", nil, err)
+		return nil, nil, false
+	}
+	return account, rec, true
+}
+
+func (h *Handler) openReconciliation(w http.ResponseWriter, r *http.Request) (*models.Account, *models.Reconciliation, bool) {
+	account, rec, ok := h.ownedReconciliation(w, r)
+	if ok && rec.Status != models.ReconciliationOpen {
+		helpers.SendResponse(w, http.StatusConflict, "Reconciliation is already finished", nil, nil)
+		return nil, nil, false
+	}
+	return account, rec, ok
+}
@@ -0,0 +1,80 @@
+package handlers_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/amrohan/expenso-go/internal/handlers"
+)
+
+func TestReconciliation(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t, handlers.Config{})
+	mia := s.register("mia")
+	accountId := s.createdId(mia.Token, "/api/account/", map[string]interface{}{"title": "Bank", "openingBalance": 1000})
+	transaction := func(kind string, amount int, date, status string) string {
+		return s.createdId(mia.Token, "/api/transaction/", map[string]interface{}{
+			"title":     kind,
+			"amount":    amount,
+			"date":      date,
+			"type":      kind,
+			"accountId": accountId,
+			"status":    status,
+		})
+	}
+	rent := transaction("Expense", 200, "2026-10-05T10:00:00Z", "cleared")
+	salary := transaction("Income", 50, "2026-10-10T10:00:00Z", "pending")
+	later := transaction("Expense", 30, "2026-11-02T10:00:00Z", "cleared")
+
+	base := "/api/account/" + accountId + "/reconciliations"
+	var rec struct {
+		Id             string   `json:"id"`
+		TransactionIds []string `json:"transactionIds"`
+		Difference     amount   `json:"difference"`
+	}
+	s.decode(s.expect(http.StatusOK, mia.Token, http.MethodPost, base, map[string]interface{}{
+		"statementDate":    "2026-10-31",
+		"statementBalance": 850,
+	}), &rec)
+	if len(rec.TransactionIds) != 1 || rec.TransactionIds[0] != rent || rec.Difference.Amount != "50.00" {
+		t.Fatalf("started reconciliation = %+v, want rent ticked and 50.00 to explain", rec)
+	}
+	s.expect(http.StatusConflict, mia.Token, http.MethodPost, base, map[string]interface{}{
+		"statementDate":    "2026-10-31",
+		"statementBalance": 850,
+	})
+	s.expect(http.StatusConflict, mia.Token, http.MethodPost, base+"/"+rec.Id+"/finish", nil)
+
+	s.expect(http.StatusBadRequest, mia.Token, http.MethodPut, base+"/"+rec.Id,
+		map[string]interface{}{"transactionIds": []string{rent, salary, later}})
+	s.decode(s.expect(http.StatusOK, mia.Token, http.MethodPut, base+"/"+rec.Id,
+		map[string]interface{}{"transactionIds": []string{rent, salary}}), &rec)
+	if rec.Difference.Amount != "0.00" {
+		t.Fatalf("difference = %s after ticking, want 0.00", rec.Difference.Amount)
+	}
+	s.expect(http.StatusOK, mia.Token, http.MethodPost, base+"/"+rec.Id+"/finish", nil)
+
+	// Reconciled transactions are locked until unlocked.
+	var locked struct {
+		Status           string `json:"status"`
+		ReconciliationId string `json:"reconciliationId"`
+	}
+	s.decode(s.expect(http.StatusOK, mia.Token, http.MethodGet, "/api/transaction/"+salary, nil), &locked)
+	if locked.Status != "reconciled" || locked.ReconciliationId != rec.Id {
+		t.Fatalf("salary = %+v, want reconciled by %s", locked, rec.Id)
+	}
+	s.expect(http.StatusConflict, mia.Token, http.MethodPut, "/api/transaction/", map[string]interface{}{
+		"id": salary, "title": "Salary", "amount": 60, "type": "Income", "accountId": accountId,
+	})
+	s.expect(http.StatusConflict, mia.Token, http.MethodDelete, "/api/transaction/"+salary, nil)
+	s.expect(http.StatusOK, mia.Token, http.MethodPost, "/api/transaction/"+salary+"/unlock", nil)
+	var unlocked struct {
+		Status           string `json:"status"`
+		ReconciliationId string `json:"reconciliationId"`
+	}
+	s.decode(s.expect(http.StatusOK, mia.Token, http.MethodGet, "/api/transaction/"+salary, nil), &unlocked)
+	if unlocked.Status != "cleared" || unlocked.ReconciliationId != "" {
+		t.Errorf("unlocked salary = %+v, want cleared", unlocked)
+	}
+	s.expect(http.StatusOK, mia.Token, http.MethodGet, "/api/transaction/"+later, nil)
+}
@@ -0,0 +1,557 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/amrohan/expenso-go/internal/helpers"
+	"github.com/amrohan/expenso-go/internal/models"
+	"github.com/amrohan/expenso-go/internal/repository"
+	"github.com/amrohan/expenso-go/internal/schedule"
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// maxOccurrences bounds the occurrences listed in one response.
+const maxOccurrences = 1000
+
+// maxPostsPerRun bounds the occurrences one scheduler run posts for a
+// rule; a rule further behind catches up over the following runs.
+const maxPostsPerRun = 500
+
+// recurringRequest creates or replaces a recurring rule. StartDate is a day
+// such as "2026-11-01"; a start in the past, at most a year back, posts the
+// missed occurrences on the next scheduler runs.
+type recurringRequest struct {
+	Name      string                   `json:"name"`
+	Schedule  string                   `json:"schedule"`
+	StartDate string                   `json:"startDate"`
+	Template  models.RecurringTemplate `json:"template"`
+	IsActive  *bool                    `json:"isActive"`
+}
+
+func (h *Handler) CreateRecurringRule(w http.ResponseWriter, r *http.Request) {
+	var req recurringRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		helpers.SendResponse(w, http.StatusBadRequest, "Couldnt decode request", nil, err)
+		return
+	}
+	now := h.now()
+	rule := models.RecurringRule{
+		Id:         primitive.NewObjectID(),
+		UserId:     currentUserId(r),
+		Exceptions: []models.RecurringException{},
+		IsActive:   true,
+		CreatedAt:  now,
+	}
+	if !h.applyRecurringRequest(w, r, &rule, req) {
+		return
+	}
+	rule.PostedThrough = rule.StartDate.AddDate(0, 0, -1)
+
+	if err := h.store.RecurringRules.Create(r.Context(), &rule); err != nil {
+		helpers.SendResponse(w, http.StatusInternalServerError, "Couldnt create recurring rule", nil, err)
+		return
+	}
+	helpers.SendResponse(w, http.StatusOK, "Recurring rule created", rule, nil)
+}
+
+func (h *Handler) GetRecurringRules(w http.ResponseWriter, r *http.Request) {
+	rules, err := h.store.RecurringRules.FindByUser(r.Context(), currentUserId(r))
+	if err != nil {
+		helpers.SendResponse(w, http.StatusInternalServerError, "Couldnt find recurring rules", nil, err)
+		return
+	}
+	helpers.SendResponse(w, http.StatusOK, "Recurring rules found", rules, nil)
+}
+
+func (h *Handler) GetRecurringRule(w http.ResponseWriter, r *http.Request) {
+	rule, ok := h.ownedRecurringRule(w, r)
+	if !ok {
+		return
+	}
+	helpers.SendResponse(w, http.StatusOK, "Recurring rule found", rule, nil)
+}
+
+// UpdateRecurringRule replaces a rule's name, schedule, start date and
+// template. Occurrences already posted are left alone. A paused rule that is
+// resumed picks up from today rather than posting the days it was paused.
+func (h *Handler) UpdateRecurringRule(w http.ResponseWriter, r *http.Request) {
+	existing, ok := h.ownedRecurringRule(w, r)
+	if !ok {
+		return
+	}
+	var req recurringRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		helpers.SendResponse(w, http.StatusBadRequest, "Couldnt decode request", nil, err)
+		return
+	}
+	if req.StartDate == "" {
+		req.StartDate = existing.StartDate.Format(rateDateLayout)
+	}
+	rule := *existing
+	if !h.applyRecurringRequest(w, r, &rule, req) {
+		return
+	}
+	if rule.IsActive && !existing.IsActive {
+		rule.PostedThrough = laterDay(rule.PostedThrough, schedule.Day(h.now()).AddDate(0, 0, -1))
+	}
+	rule.LastError = ""
+
+	if err := h.store.RecurringRules.Update(r.Context(), &rule); err != nil {
+		helpers.SendResponse(w, http.StatusInternalServerError, "Couldnt update recurring rule", nil, err)
+		return
+	}
+	helpers.SendResponse(w, http.StatusOK, "Recurring rule updated", rule, nil)
+}
+
+// DeleteRecurringRule stops a rule. Transactions it already posted stay.
+func (h *Handler) DeleteRecurringRule(w http.ResponseWriter, r *http.Request) {
+	rule, ok := h.ownedRecurringRule(w, r)
+	if !ok {
+		return
+	}
+	if err := h.store.RecurringRules.Delete(r.Context(), rule.Id); err != nil {
+		helpers.SendResponse(w, http.StatusInternalServerError, "Couldnt delete recurring rule", nil, err)
+		return
+	}
+	helpers.SendResponse(w, http.StatusOK, "Recurring rule deleted", nil, nil)
+}
+
+// occurrence is one day a rule fires on. Status is posted, skipped,
+// modified or scheduled.
+type occurrence struct {
+	Date          string                   `json:"date"`
+	Status        string                   `json:"status"`
+	TransactionId string                   `json:"transactionId,omitempty"`
+	Template      models.RecurringTemplate `json:"template"`
+}
+
+// GetRecurringOccurrences lists the days a rule fires on between from
+// (default today) and to (default three months later), both "2006-01-02"
+// and inclusive.
+func (h *Handler) GetRecurringOccurrences(w http.ResponseWriter, r *http.Request) {
+	rule, ok := h.ownedRecurringRule(w, r)
+	if !ok {
+		return
+	}
+	parsed, err := schedule.Parse(rule.Schedule)
+	if err != nil {
+		helpers.SendResponse(w, http.StatusInternalServerError, "Couldnt read schedule", nil, err)
+		return
+	}
+
+	from := schedule.Day(h.now())
+	if value := r.URL.Query().Get("from"); value != "" {
+		if from, err = time.Parse(rateDateLayout, value); err != nil {
+			helpers.SendResponse(w, http.StatusBadRequest, "Please send a valid from date", nil, err)
+			return
+		}
+	}
+	to := from.AddDate(0, 3, 0)
+	if value := r.URL.Query().Get("to"); value != "" {
+		if to, err = time.Parse(rateDateLayout, value); err != nil {
+			helpers.SendResponse(w, http.StatusBadRequest, "Please send a valid to date", nil, err)
+			return
+		}
+	}
+	if to.Before(from) {
+		helpers.SendResponse(w, http.StatusBadRequest, "from must not be after to", nil, nil)
+		return
+	}
+
+	days := parsed.Between(rule.StartDate, from, to.AddDate(0, 0, 1))
+	if len(days) > maxOccurrences {
+		days = days[:maxOccurrences]
+	}
+	occurrences := make([]occurrence, 0, len(days))
+	for _, day := range days {
+		o := occurrence{Date: day.Format(rateDateLayout), Status: "scheduled", Template: rule.Template}
+		exception := rule.Exception(day)
+		switch {
+		case exception != nil && exception.Skip:
+			o.Status = "skipped"
+		case !day.After(rule.PostedThrough):
+			o.Status = "posted"
+			o.TransactionId = occurrenceId(rule.Id, day).Hex()
+		case exception != nil:
+			o.Status = "modified"
+		}
+		if exception != nil && exception.Template != nil {
+			o.Template = *exception.Template
+		}
+		occurrences = append(occurrences, o)
+	}
+	helpers.SendResponse(w, http.StatusOK, "Occurrences found", occurrences, nil)
+}
+
+// SetRecurringOccurrence skips or changes the occurrence on {date}. Send
+// {"skip": true} to skip it or {"template": {...}} to post something else
+// that day. Occurrences already posted are edited as transactions instead.
+func (h *Handler) SetRecurringOccurrence(w http.ResponseWriter, r *http.Request) {
+	rule, day, ok := h.ownedOccurrence(w, r)
+	if !ok {
+		return
+	}
+	var req struct {
+		Skip     bool                      `json:"skip"`
+		Template *models.RecurringTemplate `json:"template"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		helpers.SendResponse(w, http.StatusBadRequest, "Couldnt decode request", nil, err)
+		return
+	}
+	if req.Skip == (req.Template != nil) {
+		helpers.SendResponse(w, http.StatusBadRequest, "Please send either skip or template", nil, nil)
+		return
+	}
+	if req.Template != nil && !h.checkRecurringTemplate(w, r, req.Template) {
+		return
+	}
+
+	exception := models.RecurringException{Date: day, Skip: req.Skip, Template: req.Template}
+	exceptions := make([]models.RecurringException, 0, len(rule.Exceptions)+1)
+	for _, e := range rule.Exceptions {
+		if !e.Date.Equal(day) {
+			exceptions = append(exceptions, e)
+		}
+	}
+	rule.Exceptions = append(exceptions, exception)
+	h.saveRecurringRule(w, r, rule, "Occurrence updated")
+}
+
+// ResetRecurringOccurrence undoes SetRecurringOccurrence for {date}.
+func (h *Handler) ResetRecurringOccurrence(w http.ResponseWriter, r *http.Request) {
+	rule, day, ok := h.ownedOccurrence(w, r)
+	if !ok {
+		return
+	}
+	if rule.Exception(day) == nil {
+		helpers.SendResponse(w, http.StatusNotFound, "Occurrence has no changes", nil, nil)
+		return
+	}
+	exceptions := make([]models.RecurringException, 0, len(rule.Exceptions))
+	for _, e := range rule.Exceptions {
+		if !e.Date.Equal(day) {
+			exceptions = append(exceptions, e)
+		}
+	}
+	rule.Exceptions = exceptions
+	h.saveRecurringRule(w, r, rule, "Occurrence reset")
+}
+
+func (h *Handler) saveRecurringRule(w http.ResponseWriter, r *http.Request, rule *models.RecurringRule, message string) {
+	rule.UpdatedAt = h.now()
+	if err := h.store.RecurringRules.Update(r.Context(), rule); err != nil {
+		helpers.SendResponse(w, http.StatusInternalServerError, "Couldnt update recurring rule", nil, err)
+		return
+	}
+	helpers.SendResponse(w, http.StatusOK, message, rule, nil)
+}
+
+// applyRecurringRequest validates req onto rule. On failure the response is
+// written here and ok is false.
+func (h *Handler) applyRecurringRequest(w http.ResponseWriter, r *http.Request, rule *models.RecurringRule, req recurringRequest) bool {
+	if _, err := schedule.Parse(req.Schedule); err != nil {
+		helpers.SendResponse(w, http.StatusBadRequest, "Please send a valid schedule", nil, err)
+		return false
+	}
+	start, err := time.Parse(rateDateLayout, req.StartDate)
+	if err != nil {
+		helpers.SendResponse(w, http.StatusBadRequest, "Please send a valid startDate", nil, err)
+		return false
+	}
+	if !start.Equal(rule.StartDate) && start.Before(schedule.Day(h.now()).AddDate(-1, 0, 0)) {
+		helpers.SendResponse(w, http.StatusBadRequest, "startDate cannot be more than a year in the past", nil, nil)
+		return false
+	}
+	if !h.checkRecurringTemplate(w, r, &req.Template) {
+		return false
+	}
+
+	rule.Name = req.Name
+	if rule.Name == "" {
+		rule.Name = req.Template.Title
+	}
+	rule.Schedule = req.Schedule
+	rule.StartDate = start
+	rule.Template = req.Template
+	if req.IsActive != nil {
+		rule.IsActive = *req.IsActive
+	}
+	rule.UpdatedAt = h.now()
+	return true
+}
+
+// checkRecurringTemplate runs a template through the checks a transaction
+// gets when it is created and keeps the normalized amounts.
+func (h *Handler) checkRecurringTemplate(w http.ResponseWriter, r *http.Request, template *models.RecurringTemplate) bool {
+	transaction := recurringTransaction(currentUserId(r), *template)
+	if !rejectTransferType(w, &transaction) {
+		return false
+	}
+	if !h.checkTransactionReferences(w, r, &transaction) || !h.normalizeAmount(w, r, &transaction) || !normalizeSplits(w, &transaction) {
+		return false
+	}
+	template.Amount = transaction.Amount
+	template.CategoryId = transaction.CategoryId
+	template.Splits = transaction.Splits
+	return true
+}
+
+func recurringTransaction(userId string, template models.RecurringTemplate) models.Transaction {
+	return models.Transaction{
+		Title:      template.Title,
+		Amount:     template.Amount,
+		CategoryId: template.CategoryId,
+		Type:       template.Type,
+		ImageUrl:   template.ImageUrl,
+		AccountId:  template.AccountId,
+		UserId:     userId,
+		Splits:     append([]models.Split(nil), template.Splits...),
+	}
+}
+
+// ownedRecurringRule loads the rule named in the URL when it belongs to the
+// caller.
+func (h *Handler) ownedRecurringRule(w http.ResponseWriter, r *http.Request) (*models.RecurringRule, bool) {
+	id, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.SendResponse(w, http.StatusBadRequest, "Invalid id", nil, err)
+		return nil, false
+	}
+	rule, err := h.store.RecurringRules.FindById(r.Context(), id)
+	if errors.Is(err, repository.ErrNotFound) || (err == nil && !owns(r, rule.UserId)) {
+		helpers.SendResponse(w, http.StatusNotFound, "Recurring rule not found", nil, nil)
+		return nil, false
+	}
+	if err != nil {
+		helpers.SendResponse(w, http.StatusInternalServerError, "Couldnt find recurring rule", nil, err)
+		return nil, false
+	}
+	return rule, true
+}
+
+// ownedOccurrence loads the rule named in the URL and checks that it fires
+// on {date} and has not posted that day yet.
+func (h *Handler) ownedOccurrence(w http.ResponseWriter, r *http.Request) (*models.RecurringRule, time.Time, bool) {
+	rule, ok := h.ownedRecurringRule(w, r)
+	if !ok {
+		return nil, time.Time{}, false
+	}
+	day, err := time.Parse(rateDateLayout, chi.URLParam(r, "date"))
+	if err != nil {
+		helpers.SendResponse(w, http.StatusBadRequest, "Please send a valid date", nil, err)
+		return nil, time.Time{}, false
+	}
+	parsed, err := schedule.Parse(rule.Schedule)
+	if err != nil {
+		helpers.SendResponse(w, http.StatusInternalServerError, "Couldnt read schedule", nil, err)
+		return nil, time.Time{}, false
+	}
+	if len(parsed.Between(rule.StartDate, day, day.AddDate(0, 0, 1))) == 0 {
+		helpers.SendResponse(w, http.StatusBadRequest, "The rule does not fire on "+day.Format(rateDateLayout), nil, nil)
+		return nil, time.Time{}, false
+	}
+	if !day.After(rule.PostedThrough) {
+		helpers.SendResponse(w, http.StatusConflict, "Occurrence is already posted, edit or delete the transaction instead",
+			map[string]interface{}{"transactionId": occurrenceId(rule.Id, day)}, nil)
+		return nil, time.Time{}, false
+	}
+	return rule, day, true
+}
+
+// occurrenceId is the id of the transaction posted for a rule on day. It is
+// derived from both so posting the same occurrence twice is a duplicate
+// insert rather than a second transaction. Like any ObjectID it starts with
+// a timestamp, here the occurrence day.
+func occurrenceId(ruleId primitive.ObjectID, day time.Time) primitive.ObjectID {
+	var id primitive.ObjectID
+	binary.BigEndian.PutUint32(id[:4], uint32(day.Unix()))
+	sum := sha256.Sum256([]byte(ruleId.Hex() + day.Format(rateDateLayout)))
+	copy(id[4:], sum[:8])
+	return id
+}
+
+func laterDay(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+// PostRecurring posts every occurrence of every active rule due up to and
+// including today, catching up on days missed while the server was down.
+// Posting is idempotent, so several instances may run it at once.
+func (h *Handler) PostRecurring(ctx context.Context) error {
+	today := schedule.Day(h.now())
+	rules, err := h.store.RecurringRules.FindDue(ctx, today)
+	if err != nil {
+		return err
+	}
+	for _, rule := range rules {
+		if err := h.postRule(ctx, rule, today); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// postRule posts rule's occurrences after its PostedThrough up to today,
+// at most maxPostsPerRun of them. An occurrence that cannot be posted, say because its account was
+// deleted, stops the rule there and is kept as its LastError until the rule
+// is fixed.
+func (h *Handler) postRule(ctx context.Context, rule models.RecurringRule, today time.Time) error {
+	through := today
+	var failure error
+	parsed, err := schedule.Parse(rule.Schedule)
+	if err != nil {
+		through, failure = rule.PostedThrough, err
+	} else {
+		days := parsed.Between(rule.StartDate, rule.PostedThrough.AddDate(0, 0, 1), today.AddDate(0, 0, 1))
+		if len(days) > maxPostsPerRun {
+			days = days[:maxPostsPerRun]
+			through = days[len(days)-1]
+		}
+		for _, day := range days {
+			if failure = h.postOccurrence(ctx, rule, day); failure != nil {
+				through = day.AddDate(0, 0, -1)
+				break
+			}
+		}
+	}
+
+	lastError := ""
+	if failure != nil {
+		lastError = failure.Error()
+		log.Printf("recurring: rule %s: %v", rule.Id.Hex(), failure)
+	}
+	err = h.store.RecurringRules.MarkPosted(ctx, rule.Id, rule.PostedThrough, through, lastError)
+	if errors.Is(err, repository.ErrNotFound) {
+		// Edited, deleted or posted by another run meanwhile; the
+		// transactions written are kept and the next run carries on.
+		return nil
+	}
+	return err
+}
+
+func (h *Handler) postOccurrence(ctx context.Context, rule models.RecurringRule, day time.Time) error {
+	template := rule.Template
+	if exception := rule.Exception(day); exception != nil {
+		if exception.Skip {
+			return nil
+		}
+		if exception.Template != nil {
+			template = *exception.Template
+		}
+	}
+	if err := h.checkRecurringReferences(ctx, rule.UserId, template); err != nil {
+		return fmt.Errorf("%s: %w", day.Format(rateDateLayout), err)
+	}
+
+	now := h.now()
+	transaction := recurringTransaction(rule.UserId, template)
+	transaction.Id = occurrenceId(rule.Id, day)
+	transaction.Date = day
+	transaction.Status = models.StatusPending
+	transaction.RecurringRuleId = rule.Id.Hex()
+	transaction.IsActive = true
+	transaction.CreatedAt = now
+	transaction.UpdatedAt = now
+	err := h.store.Transactions.Create(ctx, &transaction)
+	if errors.Is(err, repository.ErrDuplicate) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	revision := models.Revision{
+		Id:         primitive.NewObjectID(),
+		EntityType: "transaction",
+		EntityId:   transaction.Id.Hex(),
+		UserId:     transaction.UserId,
+		Operation:  models.RevisionCreate,
+		Snapshot:   storedFields(&transaction),
+		CreatedAt:  now,
+	}
+	if err := h.store.Revisions.Append(ctx, &revision); err != nil {
+		log.Printf("history: unable to record transaction %s: %v", transaction.Id.Hex(), err)
+	}
+	event := models.AuditEvent{
+		Id:         primitive.NewObjectID(),
+		Action:     models.AuditTransactionCreate,
+		TargetType: "transaction",
+		TargetId:   transaction.Id.Hex(),
+		Details:    map[string]interface{}{"recurringRuleId": rule.Id.Hex()},
+		Changes:    auditDiff(nil, &transaction),
+		CreatedAt:  now,
+	}
+	if err := h.store.AuditEvents.Create(ctx, &event); err != nil {
+		log.Printf("audit: unable to record %s: %v", event.Action, err)
+	}
+	return nil
+}
+
+// checkRecurringReferences is checkTransactionReferences for the scheduler,
+// which has no request: the account and categories must still be live and
+// belong to userId.
+func (h *Handler) checkRecurringReferences(ctx context.Context, userId string, template models.RecurringTemplate) error {
+	categories := []string{template.CategoryId}
+	for _, split := range template.Splits {
+		categories = append(categories, split.CategoryId)
+	}
+	for _, idHex := range categories {
+		if idHex == "" {
+			continue
+		}
+		id, err := primitive.ObjectIDFromHex(idHex)
+		if err != nil {
+			return fmt.Errorf("category %s does not exist", idHex)
+		}
+		category, err := h.store.Categories.FindById(ctx, id)
+		if errors.Is(err, repository.ErrNotFound) || (err == nil && (category.UserId != userId || category.IsDeleted)) {
+			return fmt.Errorf("category %s does not exist", idHex)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if template.AccountId != "" {
+		id, err := primitive.ObjectIDFromHex(template.AccountId)
+		if err != nil {
+			return fmt.Errorf("account %s does not exist", template.AccountId)
+		}
+		account, err := h.store.Accounts.FindById(ctx, id)
+		if errors.Is(err, repository.ErrNotFound) || (err == nil && (account.UserId != userId || account.IsDeleted)) {
+			return fmt.Errorf("account %s does not exist", template.AccountId)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RunRecurring calls PostRecurring every interval until ctx is done.
+func (h *Handler) RunRecurring(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := h.PostRecurring(ctx); err != nil {
+			log.Printf("Error posting recurring transactions: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
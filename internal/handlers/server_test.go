@@ -0,0 +1,250 @@
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/amrohan/expenso-go/api/routes"
+	"github.com/amrohan/expenso-go/internal/auth"
+	"github.com/amrohan/expenso-go/internal/handlers"
+	"github.com/amrohan/expenso-go/internal/mailer"
+	"github.com/amrohan/expenso-go/internal/repository"
+	"github.com/go-chi/chi/v5"
+)
+
+// testServer serves the API on an in-memory store.
+type testServer struct {
+	t       *testing.T
+	url     string
+	mail    string
+	handler *handlers.Handler
+}
+
+func newTestServer(t *testing.T, config handlers.Config) *testServer {
+	t.Helper()
+	keys, err := auth.NewRandomKeySet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.Keys = keys
+	mail := filepath.Join(t.TempDir(), "mail.log")
+	config.Mailer = &mailer.LogMailer{Path: mail}
+
+	h := handlers.New(repository.NewMemoryStore(), config)
+	router := chi.NewRouter()
+	routes.LoadRoutes(router, h)
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	return &testServer{t: t, url: server.URL, mail: mail, handler: h}
+}
+
+type apiResponse struct {
+	Status  int             `json:"status"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// call sends body as JSON with token as the bearer token, if any, and
+// decodes the response.
+func (s *testServer) call(token, method, path string, body interface{}) apiResponse {
+	s.t.Helper()
+	var reader io.Reader = http.NoBody
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			s.t.Fatal(err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, s.url+path, reader)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var out apiResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		s.t.Fatalf("%s %s: decoding response: %v", method, path, err)
+	}
+	if out.Status != resp.StatusCode {
+		s.t.Fatalf("%s %s: body status %d, HTTP status %d", method, path, out.Status, resp.StatusCode)
+	}
+	return out
+}
+
+// expect is call that fails the test unless the response has status.
+func (s *testServer) expect(status int, token, method, path string, body interface{}) apiResponse {
+	s.t.Helper()
+	resp := s.call(token, method, path, body)
+	if resp.Status != status {
+		s.t.Fatalf("%s %s = %d %q, want %d", method, path, resp.Status, resp.Message, status)
+	}
+	return resp
+}
+
+// decode unmarshals the data of resp into v.
+func (s *testServer) decode(resp apiResponse, v interface{}) {
+	s.t.Helper()
+	if err := json.Unmarshal(resp.Data, v); err != nil {
+		s.t.Fatalf("decoding %s: %v", resp.Data, err)
+	}
+}
+
+type tokens struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refreshToken"`
+}
+
+// register signs up name and logs in, returning the session tokens.
+func (s *testServer) register(name string) tokens {
+	s.t.Helper()
+	s.expect(http.StatusOK, "", http.MethodPost, "/register", map[string]string{
+		"username": name,
+		"name":     name,
+		"email":    name + "@example.com",
+		"password": "correct horse " + name,
+	})
+	var out tokens
+	s.decode(s.login(name), &out)
+	return out
+}
+
+func (s *testServer) login(name string) apiResponse {
+	s.t.Helper()
+	return s.expect(http.StatusOK, "", http.MethodPost, "/login", map[string]string{
+		"username": name,
+		"password": "correct horse " + name,
+	})
+}
+
+// link returns the path and query of the last link containing marker that
+// was mailed to email.
+func (s *testServer) link(email, marker string) string {
+	s.t.Helper()
+	data, err := os.ReadFile(s.mail)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	entries := strings.Split(string(data), "\n----- ")
+	for i := len(entries) - 1; i >= 0; i-- {
+		if !strings.Contains(entries[i], "To: "+email+"\n") {
+			continue
+		}
+		for _, field := range strings.Fields(entries[i]) {
+			if strings.Contains(field, marker) {
+				u, err := url.Parse(field)
+				if err != nil {
+					s.t.Fatal(err)
+				}
+				return u.RequestURI()
+			}
+		}
+	}
+	s.t.Fatalf("no %s link mailed to %s", marker, email)
+	return ""
+}
+
+// subject is the user id an access token was issued to.
+func (s *testServer) subject(token string) string {
+	s.t.Helper()
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		s.t.Fatalf("%q is not a JWT", token)
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	var claims struct {
+		Sub string `json:"sub"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		s.t.Fatal(err)
+	}
+	return claims.Sub
+}
+
+// createdId posts body to path and returns the id of what was created.
+func (s *testServer) createdId(token, path string, body interface{}) string {
+	s.t.Helper()
+	resp := s.call(token, http.MethodPost, path, body)
+	if resp.Status != http.StatusOK && resp.Status != http.StatusCreated {
+		s.t.Fatalf("POST %s = %d %q", path, resp.Status, resp.Message)
+	}
+	var created struct {
+		Id string `json:"id"`
+	}
+	s.decode(resp, &created)
+	return created.Id
+}
+
+// concurrently posts body to path n times at once and counts the
+// responses by status.
+func (s *testServer) concurrently(n int, path string, body interface{}) map[int]int {
+	s.t.Helper()
+	data, err := json.Marshal(body)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+
+	statuses := make(chan int, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := http.Post(s.url+path, "application/json", bytes.NewReader(data))
+			if err != nil {
+				s.t.Error(err)
+				return
+			}
+			resp.Body.Close()
+			statuses <- resp.StatusCode
+		}()
+	}
+	wg.Wait()
+	close(statuses)
+
+	counts := map[int]int{}
+	for status := range statuses {
+		counts[status]++
+	}
+	return counts
+}
+
+// clock is a settable Config.Now.
+type clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *clock) Add(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
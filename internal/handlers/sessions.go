@@ -0,0 +1,330 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/amrohan/expenso-go/internal/auth"
+	"github.com/amrohan/expenso-go/internal/helpers"
+	"github.com/amrohan/expenso-go/internal/models"
+	"github.com/amrohan/expenso-go/internal/repository"
+	"github.com/go-chi/chi/v5"
+	"github.com/golang-jwt/jwt/v5"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	accessTokenCookie  = "token"
+	refreshTokenCookie = "refresh_token"
+
+	// lastSeenInterval limits how often a request rewrites the session's
+	// LastSeenAt.
+	lastSeenInterval = time.Minute
+)
+
+// startSession creates a server-side session for user and sends the first
+// access and refresh token pair.
+func (h *Handler) startSession(w http.ResponseWriter, r *http.Request, user *models.User) {
+	refreshToken, err := auth.NewOpaqueToken()
+	if err != nil {
+		helpers.SendResponse(w, http.StatusInternalServerError, "Error creating session", nil, err)
+		return
+	}
+
+	now := h.now()
+	session := models.Session{
+		Id:               primitive.NewObjectID(),
+		UserId:           user.Id.Hex(),
+		RefreshTokenHash: auth.HashToken(refreshToken),
+		UserAgent:        r.UserAgent(),
+		Ip:               helpers.ClientIP(r),
+		CreatedAt:        now,
+		LastSeenAt:       now,
+		ExpiresAt:        now.Add(h.config.RefreshTokenTTL),
+	}
+	if err := h.store.Sessions.Create(r.Context(), &session); err != nil {
+		helpers.SendResponse(w, http.StatusInternalServerError, "Error creating session", nil, err)
+		return
+	}
+	h.audit(r, models.AuditEvent{
+		Action:     models.AuditLoginSuccess,
+		ActorId:    user.Id.Hex(),
+		TargetType: "session",
+		TargetId:   session.Id.Hex(),
+	})
+	h.sendTokens(w, "Login successful", user, &session, refreshToken)
+}
+
+func (h *Handler) sendTokens(w http.ResponseWriter, message string, user *models.User, session *models.Session, refreshToken string) {
+	accessToken, err := h.issueAccessToken(user, session)
+	if err != nil {
+		helpers.SendResponse(w, http.StatusInternalServerError, "Error signing token", nil, err)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     accessTokenCookie,
+		Value:    accessToken,
+		Path:     "/",
+		MaxAge:   int(h.config.AccessTokenTTL.Seconds()),
+		SameSite: http.SameSiteNoneMode,
+		Secure:   true,
+	})
+	http.SetCookie(w, &http.Cookie{
+		Name:     refreshTokenCookie,
+		Value:    refreshToken,
+		Path:     "/",
+		Expires:  session.ExpiresAt,
+		HttpOnly: true,
+		SameSite: http.SameSiteNoneMode,
+		Secure:   true,
+	})
+	helpers.SendResponse(w, http.StatusOK, message, map[string]interface{}{
+		"token":        accessToken,
+		"refreshToken": refreshToken,
+		"expiresIn":    int(h.config.AccessTokenTTL.Seconds()),
+	}, nil)
+}
+
+// refreshTokenFromRequest reads the refresh token from the JSON body or,
+// failing that, from the refresh cookie.
+func refreshTokenFromRequest(r *http.Request) string {
+	var body struct {
+		RefreshToken string `json:"refreshToken"`
+	}
+	if json.NewDecoder(r.Body).Decode(&body) == nil && body.RefreshToken != "" {
+		return body.RefreshToken
+	}
+	if cookie, err := r.Cookie(refreshTokenCookie); err == nil {
+		return cookie.Value
+	}
+	return ""
+}
+
+// RefreshToken exchanges a refresh token for a new access and refresh token
+// pair. Refresh tokens are single use: presenting one that was already
+// rotated revokes the whole session, since it means the token leaked.
+func (h *Handler) RefreshToken(w http.ResponseWriter, r *http.Request) {
+	token := refreshTokenFromRequest(r)
+	if token == "" {
+		helpers.SendResponse(w, http.StatusUnauthorized, "Unauthorized", nil, nil)
+		return
+	}
+	hash := auth.HashToken(token)
+
+	session, err := h.store.Sessions.FindByTokenHash(r.Context(), hash)
+	if errors.Is(err, repository.ErrNotFound) {
+		helpers.SendResponse(w, http.StatusUnauthorized, "Invalid refresh token", nil, nil)
+		return
+	}
+	if err != nil {
+		helpers.SendResponse(w, http.StatusInternalServerError, "Error finding session", nil, err)
+		return
+	}
+
+	now := h.now()
+	if session.PreviousTokenHash == hash {
+		h.revokeSession(r, session, now)
+		helpers.SendResponse(w, http.StatusUnauthorized, "Refresh token reuse detected, session revoked", nil, nil)
+		return
+	}
+	if !session.IsActive(now) {
+		helpers.SendResponse(w, http.StatusUnauthorized, "Session has expired", nil, nil)
+		return
+	}
+
+	userId, err := primitive.ObjectIDFromHex(session.UserId)
+	if err != nil {
+		helpers.SendResponse(w, http.StatusUnauthorized, "Invalid refresh token", nil, err)
+		return
+	}
+	user, err := h.store.Users.FindById(r.Context(), userId)
+	if err != nil || user.IsDeleted {
+		helpers.SendResponse(w, http.StatusUnauthorized, "Invalid refresh token", nil, nil)
+		return
+	}
+	if h.verificationOverdue(user) {
+		helpers.SendResponse(w, http.StatusForbidden, "Please verify your email before logging in", nil, nil)
+		return
+	}
+
+	newToken, err := auth.NewOpaqueToken()
+	if err != nil {
+		helpers.SendResponse(w, http.StatusInternalServerError, "Error refreshing session", nil, err)
+		return
+	}
+	session.PreviousTokenHash = session.RefreshTokenHash
+	session.RefreshTokenHash = auth.HashToken(newToken)
+	session.LastSeenAt = now
+	session.UserAgent = r.UserAgent()
+	session.Ip = helpers.ClientIP(r)
+	// A concurrent refresh that rotated the token first makes this one a
+	// reuse.
+	err = h.store.Sessions.Rotate(r.Context(), session)
+	if errors.Is(err, repository.ErrNotFound) {
+		if current, err := h.store.Sessions.FindById(r.Context(), session.Id); err == nil {
+			h.revokeSession(r, current, now)
+		}
+		helpers.SendResponse(w, http.StatusUnauthorized, "Refresh token reuse detected, session revoked", nil, nil)
+		return
+	}
+	if err != nil {
+		helpers.SendResponse(w, http.StatusInternalServerError, "Error refreshing session", nil, err)
+		return
+	}
+	h.sendTokens(w, "Token refreshed", user, session, newToken)
+}
+
+func (h *Handler) LogoutUser(w http.ResponseWriter, r *http.Request) {
+	if session := h.sessionFromRequest(r); session != nil {
+		h.revokeSession(r, session, h.now())
+		h.audit(r, models.AuditEvent{
+			Action:     models.AuditLogout,
+			ActorId:    session.UserId,
+			TargetType: "session",
+			TargetId:   session.Id.Hex(),
+		})
+	}
+
+	for _, name := range []string{accessTokenCookie, refreshTokenCookie} {
+		http.SetCookie(w, &http.Cookie{
+			Name:     name,
+			Value:    "",
+			Path:     "/",
+			MaxAge:   -1,
+			SameSite: http.SameSiteNoneMode,
+			Secure:   true,
+		})
+	}
+	helpers.SendResponse(w, http.StatusOK, "Logout successful", nil, nil)
+}
+
+// sessionFromRequest finds the session a logout refers to, preferring the
+// refresh token and falling back to the sid of a still valid access token.
+func (h *Handler) sessionFromRequest(r *http.Request) *models.Session {
+	if token := refreshTokenFromRequest(r); token != "" {
+		if session, err := h.store.Sessions.FindByTokenHash(r.Context(), auth.HashToken(token)); err == nil {
+			return session
+		}
+	}
+
+	accessToken, err := accessTokenFromRequest(r)
+	if err != nil || accessToken == "" {
+		return nil
+	}
+	claims := jwt.MapClaims{}
+	if _, err := h.config.Keys.Parse(accessToken, claims, jwt.WithTimeFunc(h.now)); err != nil {
+		return nil
+	}
+	sessionId, _ := claims["sid"].(string)
+	id, err := primitive.ObjectIDFromHex(sessionId)
+	if err != nil {
+		return nil
+	}
+	session, err := h.store.Sessions.FindById(r.Context(), id)
+	if err != nil {
+		return nil
+	}
+	return session
+}
+
+func (h *Handler) revokeSession(r *http.Request, session *models.Session, at time.Time) error {
+	if session.IsRevoked {
+		return nil
+	}
+	if err := h.store.Sessions.Revoke(r.Context(), session.Id, at); err != nil {
+		return err
+	}
+	session.IsRevoked = true
+	session.RevokedAt = at
+	return nil
+}
+
+// checkSession makes sure the session an access token was issued for is
+// still live, so revoking a session takes effect immediately rather than
+// when the access token expires.
+func (h *Handler) checkSession(w http.ResponseWriter, r *http.Request, userId, sessionId string) bool {
+	id, err := primitive.ObjectIDFromHex(sessionId)
+	if err != nil {
+		sendUnauthorized(w, "invalid_token", "Unauthorized", nil)
+		return false
+	}
+
+	session, err := h.store.Sessions.FindById(r.Context(), id)
+	if errors.Is(err, repository.ErrNotFound) {
+		sendUnauthorized(w, "invalid_token", "Session has been revoked", nil)
+		return false
+	}
+	if err != nil {
+		helpers.SendResponse(w, http.StatusInternalServerError, "Error finding session", nil, err)
+		return false
+	}
+
+	now := h.now()
+	if session.UserId != userId || !session.IsActive(now) {
+		sendUnauthorized(w, "invalid_token", "Session has been revoked", nil)
+		return false
+	}
+
+	if now.Sub(session.LastSeenAt) > lastSeenInterval {
+		h.store.Sessions.Touch(r.Context(), session.Id, now, helpers.ClientIP(r))
+	}
+	return true
+}
+
+func (h *Handler) GetSessions(w http.ResponseWriter, r *http.Request) {
+	sessions, err := h.store.Sessions.Find(r.Context(), repository.SessionFilter{
+		UserId:   currentUserId(r),
+		ActiveAt: h.now(),
+	})
+	if err != nil {
+		helpers.SendResponse(w, http.StatusInternalServerError, "Error getting sessions", nil, err)
+		return
+	}
+
+	current := ""
+	if principal, ok := PrincipalFromContext(r.Context()); ok {
+		current = principal.SessionId
+	}
+	type sessionView struct {
+		models.Session
+		Current bool `json:"current"`
+	}
+	views := make([]sessionView, 0, len(sessions))
+	for _, session := range sessions {
+		views = append(views, sessionView{Session: session, Current: session.Id.Hex() == current})
+	}
+	helpers.SendResponse(w, http.StatusOK, "Sessions fetched successfully", views, nil)
+}
+
+func (h *Handler) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	id, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.SendResponse(w, http.StatusBadRequest, "Invalid session id", nil, err)
+		return
+	}
+
+	session, err := h.store.Sessions.FindById(r.Context(), id)
+	if errors.Is(err, repository.ErrNotFound) || (err == nil && !owns(r, session.UserId)) {
+		helpers.SendResponse(w, http.StatusNotFound, "Session not found", nil, nil)
+		return
+	}
+	if err != nil {
+		helpers.SendResponse(w, http.StatusInternalServerError, "Error finding session", nil, err)
+		return
+	}
+
+	if err := h.revokeSession(r, session, h.now()); err != nil {
+		helpers.SendResponse(w, http.StatusInternalServerError, "Error revoking session", nil, err)
+		return
+	}
+	h.audit(r, models.AuditEvent{
+		Action:     models.AuditLogout,
+		TargetType: "session",
+		TargetId:   session.Id.Hex(),
+		Details:    map[string]interface{}{"remote": true},
+	})
+	helpers.SendResponse(w, http.StatusOK, "Session revoked", nil, nil)
+}
@@ -0,0 +1,53 @@
+package handlers_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/amrohan/expenso-go/internal/handlers"
+)
+
+func TestRefreshRotation(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t, handlers.Config{})
+	erin := s.register("erin")
+
+	refresh := func(token string) apiResponse {
+		return s.call("", http.MethodPost, "/refresh", map[string]string{"refreshToken": token})
+	}
+
+	var rotated tokens
+	resp := refresh(erin.RefreshToken)
+	if resp.Status != http.StatusOK {
+		t.Fatalf("refresh = %d %q, want 200", resp.Status, resp.Message)
+	}
+	s.decode(resp, &rotated)
+	if rotated.RefreshToken == "" || rotated.RefreshToken == erin.RefreshToken {
+		t.Fatalf("refresh token was not rotated: %q", rotated.RefreshToken)
+	}
+	s.expect(http.StatusOK, rotated.Token, http.MethodGet, "/api/user/sessions", nil)
+
+	// Replaying the old token means it leaked: the session is revoked, and
+	// the token the legitimate client holds stops working too.
+	if resp := refresh(erin.RefreshToken); resp.Status != http.StatusUnauthorized {
+		t.Fatalf("reused refresh = %d %q, want 401", resp.Status, resp.Message)
+	}
+	if resp := refresh(rotated.RefreshToken); resp.Status != http.StatusUnauthorized {
+		t.Fatalf("refresh after reuse = %d %q, want 401", resp.Status, resp.Message)
+	}
+	s.expect(http.StatusUnauthorized, rotated.Token, http.MethodGet, "/api/user/sessions", nil)
+
+	s.expect(http.StatusUnauthorized, "", http.MethodPost, "/refresh", map[string]string{"refreshToken": "made-up"})
+}
+
+// Of several refreshes racing with one token, only one may get new tokens.
+func TestConcurrentRefresh(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t, handlers.Config{})
+	frank := s.register("frank")
+
+	counts := s.concurrently(8, "/refresh", map[string]string{"refreshToken": frank.RefreshToken})
+	if counts[http.StatusOK] != 1 || counts[http.StatusUnauthorized] != 7 {
+		t.Errorf("refresh statuses = %v, want one 200 and seven 401", counts)
+	}
+}
@@ -0,0 +1,77 @@
+package handlers_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/amrohan/expenso-go/internal/handlers"
+)
+
+func TestSplitTransactions(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t, handlers.Config{})
+	mia := s.register("mia")
+	food := s.createdId(mia.Token, "/api/category/", map[string]string{"title": "Food"})
+	home := s.createdId(mia.Token, "/api/category/", map[string]string{"title": "Home"})
+
+	shop := func(splits ...interface{}) map[string]interface{} {
+		return map[string]interface{}{
+			"title": "Supermarket", "amount": 100, "date": "2026-10-06T10:00:00Z", "type": "Expense", "splits": splits,
+		}
+	}
+	split := func(categoryId, amount string) map[string]interface{} {
+		return map[string]interface{}{"categoryId": categoryId, "amount": map[string]string{"amount": amount}}
+	}
+	for _, body := range []map[string]interface{}{
+		shop(split(food, "60"), split(home, "30")),
+		shop(split(food, "100"), split(home, "0")),
+		shop(split(food, "60"), split("", "40")),
+		shop(split(food, "60"), map[string]interface{}{"categoryId": home, "amount": map[string]string{"amount": "40", "currency": "USD"}}),
+	} {
+		s.expect(http.StatusBadRequest, mia.Token, http.MethodPost, "/api/transaction/", body)
+	}
+	id := s.createdId(mia.Token, "/api/transaction/", shop(split(food, "60"), split(home, "40")))
+	s.createdId(mia.Token, "/api/transaction/", map[string]interface{}{
+		"title": "Lunch", "amount": 15, "date": "2026-10-07T10:00:00Z", "type": "Expense", "categoryId": food,
+	})
+
+	var inFood []struct {
+		Id             string `json:"id"`
+		CategoryAmount amount `json:"categoryAmount"`
+	}
+	s.decode(s.expect(http.StatusOK, mia.Token, http.MethodGet, "/api/transaction/category/"+food, nil), &inFood)
+	got := map[string]string{}
+	for _, t := range inFood {
+		got[t.Id] = t.CategoryAmount.Amount
+	}
+	if len(got) != 2 || got[id] != "60.00" {
+		t.Errorf("food transactions = %v, want the split counted at 60.00 and the lunch", got)
+	}
+
+	var month struct {
+		Summary struct {
+			TotalExpense amount `json:"totalExpense"`
+			ByCategory   []struct {
+				CategoryId   string `json:"categoryId"`
+				TotalExpense amount `json:"totalExpense"`
+			} `json:"byCategory"`
+		} `json:"summary"`
+	}
+	s.decode(s.expect(http.StatusOK, mia.Token, http.MethodGet, "/api/transaction/u/10-2026-"+s.subject(mia.Token), nil), &month)
+	if month.Summary.TotalExpense.Amount != "115.00" {
+		t.Errorf("month expense = %s, want 115.00", month.Summary.TotalExpense.Amount)
+	}
+	byCategory := map[string]string{}
+	for _, c := range month.Summary.ByCategory {
+		byCategory[c.CategoryId] = c.TotalExpense.Amount
+	}
+	if byCategory[food] != "75.00" || byCategory[home] != "40.00" || len(byCategory) != 2 {
+		t.Errorf("expense by category = %v, want food 75.00 and home 40.00", byCategory)
+	}
+
+	// Changing the amount without the splits leaves them out of step.
+	s.expect(http.StatusBadRequest, mia.Token, http.MethodPut, "/api/transaction/", map[string]interface{}{
+		"id": id, "title": "Supermarket", "amount": 120, "date": "2026-10-06T10:00:00Z", "type": "Expense",
+		"splits": []interface{}{split(food, "60"), split(home, "40")},
+	})
+}
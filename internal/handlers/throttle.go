@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/amrohan/expenso-go/internal/helpers"
+	"github.com/amrohan/expenso-go/internal/models"
+	"github.com/amrohan/expenso-go/internal/repository"
+)
+
+// throttleKey is one login counter together with the limit that locks it.
+type throttleKey struct {
+	key   string
+	limit int
+}
+
+func (h *Handler) ipThrottleKey(r *http.Request) throttleKey {
+	return throttleKey{key: "ip:" + helpers.ClientIP(r), limit: h.config.LoginThrottle.IPLimit}
+}
+
+// accountThrottleKey counts failures against an existing user.
+func (h *Handler) accountThrottleKey(user *models.User) throttleKey {
+	return throttleKey{key: "account:" + user.Id.Hex(), limit: h.config.LoginThrottle.AccountLimit}
+}
+
+// unknownLoginThrottleKey counts failures against a login that matches no
+// user, so guessing at accounts that do not exist locks out the same way.
+func (h *Handler) unknownLoginThrottleKey(login string) throttleKey {
+	return throttleKey{key: "login:" + strings.ToLower(login), limit: h.config.LoginThrottle.AccountLimit}
+}
+
+// loginLockedUntil returns the latest lock still running on any of keys, or
+// the zero time when none is.
+func (h *Handler) loginLockedUntil(r *http.Request, keys ...throttleKey) (time.Time, error) {
+	var until time.Time
+	for _, k := range keys {
+		attempt, err := h.store.LoginAttempts.Get(r.Context(), k.key)
+		if errors.Is(err, repository.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return time.Time{}, err
+		}
+		if attempt.LockedUntil.After(h.now()) && attempt.LockedUntil.After(until) {
+			until = attempt.LockedUntil
+		}
+	}
+	return until, nil
+}
+
+// recordLoginFailure counts a failed attempt against each key and locks the
+// ones that reached their limit. actorId names the user the attempt was
+// made against, if known.
+func (h *Handler) recordLoginFailure(r *http.Request, actorId string, keys ...throttleKey) {
+	policy := h.config.LoginThrottle
+	now := h.now()
+	for _, k := range keys {
+		attempt, err := h.store.LoginAttempts.RecordFailure(r.Context(), k.key, now, policy.Window)
+		if err != nil {
+			log.Printf("login throttle: unable to record failure for %s: %v", k.key, err)
+			continue
+		}
+		if attempt.Failures < k.limit {
+			continue
+		}
+
+		lockout := policy.Lockout
+		for i := k.limit; i < attempt.Failures && lockout < policy.MaxLockout; i++ {
+			lockout *= 2
+		}
+		if lockout > policy.MaxLockout {
+			lockout = policy.MaxLockout
+		}
+		until := now.Add(lockout)
+		if err := h.store.LoginAttempts.Lock(r.Context(), k.key, until); err != nil {
+			log.Printf("login throttle: unable to lock %s: %v", k.key, err)
+			continue
+		}
+		h.audit(r, models.AuditEvent{
+			Action:     models.AuditLoginLockout,
+			ActorId:    actorId,
+			TargetType: "login",
+			TargetId:   k.key,
+			Details: map[string]interface{}{
+				"failures":    attempt.Failures,
+				"lockedUntil": until,
+			},
+		})
+	}
+}
+
+// resetLoginFailures clears the account counter after a successful login.
+// The IP counter is left to expire so one valid account cannot be used to
+// keep resetting it.
+func (h *Handler) resetLoginFailures(r *http.Request, user *models.User) {
+	if err := h.store.LoginAttempts.Reset(r.Context(), h.accountThrottleKey(user).key); err != nil {
+		log.Printf("login throttle: unable to reset %s: %v", user.Id.Hex(), err)
+	}
+}
+
+func (h *Handler) sendLoginLocked(w http.ResponseWriter, until time.Time) {
+	seconds := int(until.Sub(h.now()).Seconds()) + 1
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	helpers.SendResponse(w, http.StatusTooManyRequests, "Too many failed login attempts, please try again later", nil, nil)
+}
+
+var (
+	dummyHashOnce sync.Once
+	dummyHash     string
+)
+
+// burnPasswordCheck spends as long as a real password check so response
+// times do not reveal whether an account exists.
+func burnPasswordCheck(password string) {
+	dummyHashOnce.Do(func() {
+		dummyHash, _ = HashPassword("expenso-dummy-password")
+	})
+	CheckPasswordHash(password, dummyHash)
+}
@@ -0,0 +1,66 @@
+package handlers_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/amrohan/expenso-go/internal/handlers"
+)
+
+func TestLoginLockout(t *testing.T) {
+	t.Parallel()
+	now := &clock{now: time.Now()}
+	s := newTestServer(t, handlers.Config{
+		Now:           now.Now,
+		LoginThrottle: handlers.LoginThrottle{AccountLimit: 2, IPLimit: 100, Lockout: time.Minute},
+	})
+	s.register("sam")
+
+	login := func(username, password string) apiResponse {
+		return s.call("", http.MethodPost, "/login", map[string]string{"username": username, "password": password})
+	}
+	// Unknown accounts and wrong passwords look the same.
+	unknown := login("nobody", "wrong")
+	wrong := login("sam", "wrong")
+	if unknown.Status != http.StatusUnauthorized || wrong.Status != http.StatusUnauthorized || unknown.Message != wrong.Message {
+		t.Errorf("failed logins = %d %q and %d %q, want the same 401", unknown.Status, unknown.Message, wrong.Status, wrong.Message)
+	}
+
+	// The second failure locks the account, even against the right password.
+	login("sam", "wrong")
+	if resp := login("sam", "correct horse sam"); resp.Status != http.StatusTooManyRequests {
+		t.Fatalf("login while locked = %d %q, want 429", resp.Status, resp.Message)
+	}
+	now.Add(61 * time.Second)
+
+	// Failing again straight after doubles the lockout.
+	login("sam", "wrong")
+	now.Add(61 * time.Second)
+	if resp := login("sam", "correct horse sam"); resp.Status != http.StatusTooManyRequests {
+		t.Errorf("login during the doubled lockout = %d %q, want 429", resp.Status, resp.Message)
+	}
+	now.Add(time.Minute)
+	s.login("sam")
+
+	// A successful login starts the count again.
+	login("sam", "wrong")
+	s.login("sam")
+}
+
+func TestLoginIPThrottle(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t, handlers.Config{
+		LoginThrottle: handlers.LoginThrottle{AccountLimit: 10, IPLimit: 3},
+	})
+	s.register("tess")
+
+	for _, username := range []string{"a", "b", "c"} {
+		s.expect(http.StatusUnauthorized, "", http.MethodPost, "/login", map[string]string{"username": username, "password": "guess"})
+	}
+	// Spraying logins locks out the address, whichever account it tries.
+	s.expect(http.StatusTooManyRequests, "", http.MethodPost, "/login", map[string]string{
+		"username": "tess",
+		"password": "correct horse tess",
+	})
+}
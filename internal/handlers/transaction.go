@@ -3,178 +3,158 @@ package handlers
 import (
 	"context"
 	"encoding/json"
-	"log"
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 	"time"
 
-	"github.com/amrohan/expenso-go/internal/db"
 	"github.com/amrohan/expenso-go/internal/helpers"
 	"github.com/amrohan/expenso-go/internal/models"
+	"github.com/amrohan/expenso-go/internal/money"
+	"github.com/amrohan/expenso-go/internal/repository"
 	"github.com/go-chi/chi/v5"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-func CreateTransaction(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) CreateTransaction(w http.ResponseWriter, r *http.Request) {
 	transaction := models.Transaction{
 		Id:        primitive.NewObjectID(),
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
+		CreatedAt: h.now(),
+		UpdatedAt: h.now(),
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&transaction); err != nil {
 		helpers.SendResponse(w, http.StatusBadRequest, "Couldnt decode request", nil, err)
+		return
 	}
 	transaction.Id = primitive.NewObjectID()
-
-	client, err := db.GetMongoClient()
-	if err != nil {
-		helpers.SendResponse(w, http.StatusInternalServerError, "Couldnt connect to db", nil, err)
+	transaction.UserId = currentUserId(r)
+	transaction.RecurringRuleId = ""
+	transaction.IsDeleted = false
+	transaction.DeletedAt = time.Time{}
+	if !rejectTransferType(w, &transaction) || !checkStatus(w, &transaction, models.StatusPending) {
+		return
+	}
+	if !h.checkTransactionReferences(w, r, &transaction) || !h.normalizeAmount(w, r, &transaction) || !normalizeSplits(w, &transaction) {
+		return
 	}
 
-	collection := client.Database(db.Database).Collection(string(db.TransactionCollection))
-
-	data, err := collection.InsertOne(context.TODO(), transaction)
-	if err != nil {
+	if err := h.store.Transactions.Create(r.Context(), &transaction); err != nil {
 		helpers.SendResponse(w, http.StatusInternalServerError, "Couldnt insert transaction", nil, err)
+		return
 	}
-	helpers.SendResponse(w, http.StatusOK, "Transaction created", data, nil)
+	h.recordRevision(r, "transaction", transaction.Id, transaction.UserId, models.RevisionCreate, nil, &transaction)
+	h.auditChange(r, models.AuditTransactionCreate, "transaction", transaction.Id, nil, &transaction)
+	helpers.SendResponse(w, http.StatusOK, "Transaction created", transaction, nil)
 }
 
-func GetAllTransaction(w http.ResponseWriter, r *http.Request) {
-	var transactions []*models.Transaction
-
-	client, err := db.GetMongoClient()
-	if err != nil {
-		helpers.SendResponse(w, http.StatusInternalServerError, "Couldnt connect to db", nil, err)
-	}
-	collection := client.Database(db.Database).Collection(string(db.TransactionCollection))
-
-	cur, err := collection.Find(context.TODO(), bson.D{
-		primitive.E{},
-	})
-
+func (h *Handler) GetAllTransaction(w http.ResponseWriter, r *http.Request) {
+	transactions, err := h.store.Transactions.Find(r.Context(), repository.TransactionFilter{})
 	if err != nil {
 		helpers.SendResponse(w, http.StatusInternalServerError, "Couldnt find transactions", nil, err)
-	}
-
-	for cur.Next(context.TODO()) {
-		var transaction models.Transaction
-		err := cur.Decode(&transaction)
-		if err != nil {
-			helpers.SendResponse(w, http.StatusInternalServerError, "Couldnt decode transactions", nil, err)
-		}
-		transactions = append(transactions, &transaction)
+		return
 	}
 	helpers.SendResponse(w, http.StatusOK, "Transactions fetched successfully", transactions, nil)
 }
 
-func GetTransactionById(w http.ResponseWriter, r *http.Request) {
-	var transaction models.Transaction
-
+func (h *Handler) GetTransactionById(w http.ResponseWriter, r *http.Request) {
 	id, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
 	if err != nil {
 		helpers.SendResponse(w, http.StatusBadRequest, "Invalid id", nil, err)
+		return
 	}
 
-	client, err := db.GetMongoClient()
-	if err != nil {
-		helpers.SendResponse(w, http.StatusInternalServerError, "Couldnt connect to db", nil, err)
-	}
-	collection := client.Database(db.Database).Collection(string(db.TransactionCollection))
-
-	err = collection.FindOne(context.TODO(), bson.M{"_id": id}).Decode(&transaction)
-	if err != nil {
-		helpers.SendResponse(w, http.StatusInternalServerError, "Couldnt find transaction", nil, err)
+	transaction, ok := h.ownedTransaction(w, r, id)
+	if !ok {
+		return
 	}
 	helpers.SendResponse(w, http.StatusOK, "Transaction found", transaction, nil)
 }
 
-func GetTransactionByUserId(w http.ResponseWriter, r *http.Request) {
-	var transactions []*models.Transaction
-	userId := chi.URLParam(r, "id")
+// ownedTransaction loads a transaction belonging to the caller. When it does
+// not exist, is in the trash or belongs to someone else the 404 is written
+// here and ok is false.
+func (h *Handler) ownedTransaction(w http.ResponseWriter, r *http.Request, id primitive.ObjectID) (*models.Transaction, bool) {
+	return h.findTransaction(w, r, id, false)
+}
 
-	client, err := db.GetMongoClient()
-	if err != nil {
-		helpers.SendResponse(w, http.StatusInternalServerError, "Couldnt connect to db", nil, err)
-	}
-	collection := client.Database(db.Database).Collection(string(db.TransactionCollection))
+// trashedTransaction is ownedTransaction for transactions in the trash.
+func (h *Handler) trashedTransaction(w http.ResponseWriter, r *http.Request, id primitive.ObjectID) (*models.Transaction, bool) {
+	return h.findTransaction(w, r, id, true)
+}
 
-	cur, err := collection.Find(context.TODO(), bson.M{"userId": userId})
+func (h *Handler) findTransaction(w http.ResponseWriter, r *http.Request, id primitive.ObjectID, deleted bool) (*models.Transaction, bool) {
+	transaction, err := h.store.Transactions.FindById(r.Context(), id)
+	if errors.Is(err, repository.ErrNotFound) || (err == nil && (!owns(r, transaction.UserId) || transaction.IsDeleted != deleted)) {
+		helpers.SendResponse(w, http.StatusNotFound, "Transaction not found", nil, nil)
+		return nil, false
+	}
 	if err != nil {
-		helpers.SendResponse(w, http.StatusInternalServerError, "Couldnt find transactions", nil, err)
+		helpers.SendResponse(w, http.StatusInternalServerError, "Couldnt find transaction", nil, err)
+		return nil, false
 	}
+	return transaction, true
+}
 
-	for cur.Next(context.TODO()) {
-		var transaction models.Transaction
-		err := cur.Decode(&transaction)
-		if err != nil {
-			helpers.SendResponse(w, http.StatusInternalServerError, "Couldnt decode transactions", nil, err)
-		}
+func (h *Handler) GetTransactionByUserId(w http.ResponseWriter, r *http.Request) {
+	userId := chi.URLParam(r, "id")
+	if !owns(r, userId) {
+		helpers.SendResponse(w, http.StatusNotFound, "User not found", nil, nil)
+		return
+	}
+	h.findTransactions(w, r, repository.TransactionFilter{UserId: userId})
+}
 
-		transactions = append(transactions, &transaction)
+func (h *Handler) GetTransactionByAccountId(w http.ResponseWriter, r *http.Request) {
+	account, ok := h.ownedAccount(w, r, chi.URLParam(r, "id"))
+	if !ok {
+		return
 	}
+	h.findTransactions(w, r, repository.TransactionFilter{UserId: account.UserId, AccountId: account.Id.Hex()})
+}
 
-	helpers.SendResponse(w, http.StatusOK, "Transactions found", transactions, nil)
+// categoryTransaction is a transaction listed under one category, with the
+// part of its amount that falls in that category.
+type categoryTransaction struct {
+	models.Transaction
+	CategoryAmount money.Money `json:"categoryAmount"`
 }
 
-func GetTransactionByAccountId(w http.ResponseWriter, r *http.Request) {
-	var transactions []*models.Transaction
-	accountId := chi.URLParam(r, "id")
-	client, err := db.GetMongoClient()
-	if err != nil {
-		helpers.SendResponse(w, http.StatusInternalServerError, "Couldnt connect to db", nil, err)
+// GetTransactionByCategoryId lists the transactions in a category, split
+// transactions included when any of their splits is in it.
+func (h *Handler) GetTransactionByCategoryId(w http.ResponseWriter, r *http.Request) {
+	category, ok := h.ownedCategory(w, r, chi.URLParam(r, "id"))
+	if !ok {
+		return
 	}
-	collection := client.Database(db.Database).Collection(string(db.TransactionCollection))
-
-	cur, err := collection.Find(context.TODO(), bson.M{"accountId": accountId})
+	transactions, err := h.store.Transactions.Find(r.Context(), repository.TransactionFilter{UserId: category.UserId, CategoryId: category.Id.Hex()})
 	if err != nil {
 		helpers.SendResponse(w, http.StatusInternalServerError, "Couldnt find transactions", nil, err)
+		return
 	}
-
-	for cur.Next(context.TODO()) {
-		var transaction models.Transaction
-		err := cur.Decode(&transaction)
+	views := make([]categoryTransaction, 0, len(transactions))
+	for _, t := range transactions {
+		amount, err := t.CategoryAmount(category.Id.Hex())
 		if err != nil {
-			helpers.SendResponse(w, http.StatusInternalServerError, "Couldnt decode transactions", nil, err)
+			helpers.SendResponse(w, http.StatusInternalServerError, "Couldnt total transactions", nil, err)
+			return
 		}
-
-		transactions = append(transactions, &transaction)
+		views = append(views, categoryTransaction{Transaction: t, CategoryAmount: amount})
 	}
-	helpers.SendResponse(w, http.StatusOK, "Transactions found", transactions, nil)
+	helpers.SendResponse(w, http.StatusOK, "Transactions found", views, nil)
 }
 
-func GetTransactionByCategoryId(w http.ResponseWriter, r *http.Request) {
-	var transactions []*models.Transaction
-	categoryId := chi.URLParam(r, "id")
-
-	client, err := db.GetMongoClient()
-	if err != nil {
-		helpers.SendResponse(w, http.StatusInternalServerError, "Couldnt connect to db", nil, err)
-	}
-	collection := client.Database(db.Database).Collection(string(db.TransactionCollection))
-
-	cur, err := collection.Find(context.TODO(), bson.M{"categoryId": categoryId})
+func (h *Handler) findTransactions(w http.ResponseWriter, r *http.Request, filter repository.TransactionFilter) {
+	transactions, err := h.store.Transactions.Find(r.Context(), filter)
 	if err != nil {
 		helpers.SendResponse(w, http.StatusInternalServerError, "Couldnt find transactions", nil, err)
-	}
-
-	for cur.Next(context.TODO()) {
-		var transaction models.Transaction
-		err := cur.Decode(&transaction)
-		if err != nil {
-			helpers.SendResponse(w, http.StatusInternalServerError, "Couldnt decode transactions", nil, err)
-		}
-
-		transactions = append(transactions, &transaction)
+		return
 	}
 	helpers.SendResponse(w, http.StatusOK, "Transactions found", transactions, nil)
 }
 
-func GetTransactionByMonthAndYear(w http.ResponseWriter, r *http.Request) {
-	var transactions []*models.Transaction
-
+func (h *Handler) GetTransactionByMonthAndYear(w http.ResponseWriter, r *http.Request) {
 	monthStr := chi.URLParam(r, "month")
 	yearStr := chi.URLParam(r, "year")
 
@@ -190,18 +170,15 @@ func GetTransactionByMonthAndYear(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	cookies := r.Cookies()
 	var timezone string
-	for _, cookie := range cookies {
-		if cookie.Name == "timezone" {
-			timezone = cookie.Value
-			break
-		}
+	if cookie, err := r.Cookie("timezone"); err == nil {
+		timezone = cookie.Value
 	}
 
 	tz, err := time.LoadLocation(timezone)
 	if err != nil {
-		log.Fatal(err)
+		helpers.SendResponse(w, http.StatusBadRequest, "Please send valid timezone", nil, err)
+		return
 	}
 
 	startDate := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC)
@@ -209,49 +186,30 @@ func GetTransactionByMonthAndYear(w http.ResponseWriter, r *http.Request) {
 	startDate = startDate.In(tz)
 	endDate = endDate.In(tz)
 
-	client, err := db.GetMongoClient()
-	if err != nil {
-		helpers.SendResponse(w, http.StatusInternalServerError, "Couldnt connect to db", nil, err)
-		return
-	}
-	collection := client.Database(db.Database).Collection(string(db.TransactionCollection))
-
-	filter := bson.M{
-		"date": bson.M{
-			"$gte": startDate,
-			"$lt":  endDate,
-		},
-	}
-
-	cur, err := collection.Find(context.TODO(), filter)
+	transactions, err := h.store.Transactions.Find(r.Context(), repository.TransactionFilter{
+		UserId: currentUserId(r),
+		From:   startDate,
+		To:     endDate,
+	})
 	if err != nil {
 		helpers.SendResponse(w, http.StatusInternalServerError, "Couldnt find transactions", nil, err)
 		return
 	}
-
-	for cur.Next(context.TODO()) {
-		var transaction models.Transaction
-		err := cur.Decode(&transaction)
-		if err != nil {
-			helpers.SendResponse(w, http.StatusInternalServerError, "Couldnt decode transactions", nil, err)
-			return
-		}
-
-		transactions = append(transactions, &transaction)
-	}
-	if transactions == nil {
+	if len(transactions) == 0 {
 		helpers.SendResponse(w, http.StatusOK, "No transactions found", transactions, nil)
 		return
 	}
 	helpers.SendResponse(w, http.StatusOK, "Transactions found", transactions, nil)
 }
 
-func GetTransactionByMonthAndYearByUserId(w http.ResponseWriter, r *http.Request) {
-	var transactions []*models.Transaction
-
+func (h *Handler) GetTransactionByMonthAndYearByUserId(w http.ResponseWriter, r *http.Request) {
 	monthStr := chi.URLParam(r, "month")
 	yearStr := chi.URLParam(r, "year")
 	userId := chi.URLParam(r, "userId")
+	if !owns(r, userId) {
+		helpers.SendResponse(w, http.StatusNotFound, "User not found", nil, nil)
+		return
+	}
 
 	month, err := strconv.Atoi(monthStr)
 	if err != nil {
@@ -268,92 +226,343 @@ func GetTransactionByMonthAndYearByUserId(w http.ResponseWriter, r *http.Request
 	startDate := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC)
 	endDate := startDate.AddDate(0, 1, 0)
 
-	client, err := db.GetMongoClient()
+	transactions, err := h.store.Transactions.Find(r.Context(), repository.TransactionFilter{
+		UserId: userId,
+		From:   startDate,
+		To:     endDate,
+	})
 	if err != nil {
-		helpers.SendResponse(w, http.StatusInternalServerError, "Couldn't connect to the database", nil, err)
+		helpers.SendResponse(w, http.StatusInternalServerError, "Couldn't find transactions", nil, err)
 		return
 	}
-	collection := client.Database(db.Database).Collection(string(db.TransactionCollection))
-	filter := bson.M{
-		"date": bson.M{
-			"$gte": startDate,
-			"$lt":  endDate,
-		},
-		"userId": userId,
-	}
-	cur, err := collection.Find(context.TODO(), filter)
+
+	summary, err := h.summarize(r.Context(), userId, transactions)
 	if err != nil {
-		helpers.SendResponse(w, http.StatusInternalServerError, "Couldn't find transactions", nil, err)
+		helpers.SendResponse(w, http.StatusInternalServerError, "Couldn't total transactions", nil, err)
+		return
+	}
+
+	if len(transactions) == 0 {
+		helpers.SendResponse(w, http.StatusOK, "No transactions found", map[string]interface{}{"transaction": transactions, "summary": summary}, nil)
 		return
 	}
 
-	var totalIncome, totalExpense int
+	helpers.SendResponse(w, http.StatusOK, "Transactions found", map[string]interface{}{"transaction": transactions, "summary": summary}, nil)
+}
+
+// currencyTotals is the income and expense of a set of transactions in one
+// currency.
+type currencyTotals struct {
+	TotalIncome  money.Money `json:"totalIncome"`
+	TotalExpense money.Money `json:"totalExpense"`
+}
+
+func newCurrencyTotals(currency string) *currencyTotals {
+	return &currencyTotals{TotalIncome: money.New(0, currency), TotalExpense: money.New(0, currency)}
+}
+
+// add counts amount as income or expense according to transactionType.
+// Other types, transfers included, are ignored.
+func (c *currencyTotals) add(transactionType string, amount money.Money) error {
+	var err error
+	if transactionType == models.TransactionIncome {
+		c.TotalIncome, err = c.TotalIncome.Add(amount)
+	} else if transactionType == models.TransactionExpense {
+		c.TotalExpense, err = c.TotalExpense.Add(amount)
+	}
+	return err
+}
+
+// categoryTotals is the converted income and expense of one category,
+// counting split transactions by their split amounts. Uncategorized amounts
+// have an empty CategoryId.
+type categoryTotals struct {
+	CategoryId string `json:"categoryId"`
+	*currencyTotals
+}
 
-	for cur.Next(context.TODO()) {
-		var transaction models.Transaction
-		err := cur.Decode(&transaction)
+// missingRate is a transaction left out of the converted totals because no
+// exchange rate covered its date.
+type missingRate struct {
+	TransactionId string `json:"transactionId"`
+	From          string `json:"from"`
+	To            string `json:"to"`
+	Date          string `json:"date"`
+}
+
+// summarize totals income and expense in userId's base currency, converting
+// each amount at the rate of its transaction date. byCurrency has the
+// unconverted totals of every currency present and byCategory the converted
+// totals per category.
+func (h *Handler) summarize(ctx context.Context, userId string, transactions []models.Transaction) (map[string]interface{}, error) {
+	base := h.baseCurrency(ctx, userId)
+	converter := h.newRateConverter(ctx)
+	totals := newCurrencyTotals(base)
+	byCurrency := map[string]*currencyTotals{}
+	byCategory := []categoryTotals{}
+	categoryIndex := map[string]int{}
+	missing := []missingRate{}
+
+	for _, transaction := range transactions {
+		amount, err := transaction.Amount.WithDefaultCurrency(h.config.DefaultCurrency)
 		if err != nil {
-			helpers.SendResponse(w, http.StatusInternalServerError, "Couldn't decode transactions", nil, err)
-			return
+			return nil, err
+		}
+		if byCurrency[amount.Currency] == nil {
+			byCurrency[amount.Currency] = newCurrencyTotals(amount.Currency)
+		}
+		if err := byCurrency[amount.Currency].add(transaction.Type, amount); err != nil {
+			return nil, err
+		}
+		// Transfers only move money between accounts, so they need no
+		// rate and never count as missing one.
+		if transaction.Type != models.TransactionIncome && transaction.Type != models.TransactionExpense {
+			continue
 		}
 
-		transactions = append(transactions, &transaction)
-
-		if transaction.Type == "Income" {
-			totalIncome += transaction.Amount
-		} else if transaction.Type == "Expense" {
-			totalExpense += transaction.Amount
+		converted, err := converter.convert(amount, base, transaction.Date)
+		if errors.Is(err, errNoRate) {
+			missing = append(missing, missingRate{
+				TransactionId: transaction.Id.Hex(),
+				From:          amount.Currency,
+				To:            base,
+				Date:          rateDay(transaction.Date).Format(rateDateLayout),
+			})
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := totals.add(transaction.Type, converted); err != nil {
+			return nil, err
+		}
+		for _, split := range transaction.CategoryAmounts() {
+			amount, err := split.Amount.WithDefaultCurrency(h.config.DefaultCurrency)
+			if err == nil {
+				amount, err = converter.convert(amount, base, transaction.Date)
+			}
+			if err != nil {
+				return nil, err
+			}
+			i, ok := categoryIndex[split.CategoryId]
+			if !ok {
+				i = len(byCategory)
+				categoryIndex[split.CategoryId] = i
+				byCategory = append(byCategory, categoryTotals{CategoryId: split.CategoryId, currencyTotals: newCurrencyTotals(base)})
+			}
+			if err := byCategory[i].add(transaction.Type, amount); err != nil {
+				return nil, err
+			}
 		}
 	}
 
-	summary := map[string]int{
-		"totalIncome":  totalIncome,
-		"totalExpense": totalExpense,
+	return map[string]interface{}{
+		"currency":     base,
+		"totalIncome":  totals.TotalIncome,
+		"totalExpense": totals.TotalExpense,
+		"byCurrency":   byCurrency,
+		"byCategory":   byCategory,
+		"missingRates": missing,
+	}, nil
+}
+
+// normalizeAmount gives an amount sent without a currency the currency of
+// its account, or the caller's base currency when it has none, and rejects
+// amounts in a currency other than their account's.
+func (h *Handler) normalizeAmount(w http.ResponseWriter, r *http.Request, transaction *models.Transaction) bool {
+	var accountCurrency string
+	if transaction.AccountId != "" {
+		account, err := h.lookupAccount(r, transaction.AccountId)
+		if err != nil && !errors.Is(err, repository.ErrNotFound) {
+			helpers.SendResponse(w, http.StatusInternalServerError, "Couldnt check Account", nil, err)
+			return false
+		}
+		if err == nil {
+			accountCurrency = h.accountCurrency(account)
+		}
+	}
+	currency := accountCurrency
+	if currency == "" {
+		currency = h.baseCurrency(r.Context(), transaction.UserId)
 	}
 
-	if transactions == nil {
-		helpers.SendResponse(w, http.StatusOK, "No transactions found", map[string]interface{}{"transaction": transactions, "summary": summary}, nil)
-		return
+	amount, err := transaction.Amount.WithDefaultCurrency(currency)
+	if err == nil {
+		amount.Currency, err = money.NormalizeCurrency(amount.Currency)
+	}
+	if err != nil {
+		helpers.SendResponse(w, http.StatusBadRequest, "Please send a valid amount", nil, err)
+		return false
 	}
+	if accountCurrency != "" && amount.Currency != accountCurrency {
+		helpers.SendResponse(w, http.StatusBadRequest, "Amount must be in the account currency, "+accountCurrency, nil, nil)
+		return false
+	}
+	transaction.Amount = amount
+	return true
+}
 
-	helpers.SendResponse(w, http.StatusOK, "Transactions found", map[string]interface{}{"transaction": transactions, "summary": summary}, nil)
+// normalizeSplits checks the splits of a transaction whose amount is already
+// normalized. Split amounts default to the transaction currency, must not be
+// zero and must add up to the transaction amount.
+func normalizeSplits(w http.ResponseWriter, transaction *models.Transaction) bool {
+	if len(transaction.Splits) == 0 {
+		transaction.Splits = nil
+		return true
+	}
+	currency := transaction.Amount.Currency
+	total := money.New(0, currency)
+	for i := range transaction.Splits {
+		amount, err := transaction.Splits[i].Amount.WithDefaultCurrency(currency)
+		if err != nil || amount.Currency != currency {
+			helpers.SendResponse(w, http.StatusBadRequest, "Split amounts must be in the transaction currency, "+currency, nil, err)
+			return false
+		}
+		if amount.IsZero() {
+			helpers.SendResponse(w, http.StatusBadRequest, "Split amounts cannot be zero", nil, nil)
+			return false
+		}
+		transaction.Splits[i].Amount = amount
+		if total, err = total.Add(amount); err != nil {
+			helpers.SendResponse(w, http.StatusBadRequest, "Please send valid split amounts", nil, err)
+			return false
+		}
+	}
+	if total.Amount != transaction.Amount.Amount {
+		helpers.SendResponse(w, http.StatusBadRequest,
+			fmt.Sprintf("Splits add up to %s but the transaction amount is %s", total, transaction.Amount), nil, nil)
+		return false
+	}
+	transaction.CategoryId = ""
+	return true
 }
 
-func UpdateTransaction(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) UpdateTransaction(w http.ResponseWriter, r *http.Request) {
 	var transaction models.Transaction
 
 	if err := json.NewDecoder(r.Body).Decode(&transaction); err != nil {
 		helpers.SendResponse(w, http.StatusBadRequest, "Couldnt decode request", nil, err)
+		return
 	}
 
-	client, err := db.GetMongoClient()
-	if err != nil {
-		helpers.SendResponse(w, http.StatusInternalServerError, "Couldnt connect to db", nil, err)
+	existing, ok := h.ownedTransaction(w, r, transaction.Id)
+	if !ok {
+		return
+	}
+	if existing.IsLocked() {
+		sendLocked(w)
+		return
+	}
+	if existing.IsTransfer() {
+		h.updateTransferLeg(w, r, existing, &transaction)
+		return
+	}
+	transaction.UserId = currentUserId(r)
+	transaction.CreatedAt = existing.CreatedAt
+	transaction.UpdatedAt = h.now()
+	// Only the scheduler ties transactions to recurring rules.
+	transaction.RecurringRuleId = existing.RecurringRuleId
+	transaction.IsDeleted = false
+	transaction.DeletedAt = time.Time{}
+	if !rejectTransferType(w, &transaction) || !checkStatus(w, &transaction, existing.Status) {
+		return
+	}
+	if !h.checkTransactionReferences(w, r, &transaction) || !h.normalizeAmount(w, r, &transaction) || !normalizeSplits(w, &transaction) {
+		return
 	}
-	collection := client.Database(db.Database).Collection(string(db.TransactionCollection))
 
-	data, err := collection.UpdateOne(context.TODO(), bson.M{"_id": transaction.Id}, bson.M{"$set": transaction})
-	if err != nil {
+	if err := h.store.Transactions.Update(r.Context(), &transaction); err != nil {
 		helpers.SendResponse(w, http.StatusInternalServerError, "Couldnt update transaction", nil, err)
+		return
 	}
-	helpers.SendResponse(w, http.StatusOK, "Transaction updated", data, nil)
+	h.recordRevision(r, "transaction", transaction.Id, transaction.UserId, models.RevisionUpdate, existing, &transaction)
+	h.auditChange(r, models.AuditTransactionUpdate, "transaction", transaction.Id, existing, &transaction)
+	helpers.SendResponse(w, http.StatusOK, "Transaction updated", transaction, nil)
 }
 
-func DeleteTransaction(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) DeleteTransaction(w http.ResponseWriter, r *http.Request) {
 	id, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
 	if err != nil {
 		helpers.SendResponse(w, http.StatusBadRequest, "Invalid id", nil, err)
+		return
 	}
 
-	client, err := db.GetMongoClient()
-	if err != nil {
-		helpers.SendResponse(w, http.StatusInternalServerError, "Couldnt connect to db", nil, err)
+	existing, ok := h.ownedTransaction(w, r, id)
+	if !ok {
+		return
 	}
-	collection := client.Database(db.Database).Collection(string(db.TransactionCollection))
 
-	data, err := collection.DeleteOne(context.TODO(), bson.M{"_id": id})
+	_, err = h.removeTransaction(r, existing)
+	if errors.Is(err, errLocked) {
+		sendLocked(w)
+		return
+	}
 	if err != nil {
 		helpers.SendResponse(w, http.StatusInternalServerError, "Couldnt delete transaction", nil, err)
+		return
+	}
+	helpers.SendResponse(w, http.StatusOK, "Transaction deleted", nil, nil)
+}
+
+// GetTransactionTrash lists the caller's deleted transactions that have not
+// been purged yet.
+func (h *Handler) GetTransactionTrash(w http.ResponseWriter, r *http.Request) {
+	h.findTransactions(w, r, repository.TransactionFilter{UserId: currentUserId(r), DeletedOnly: true})
+}
+
+func (h *Handler) RestoreTransaction(w http.ResponseWriter, r *http.Request) {
+	id, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.SendResponse(w, http.StatusBadRequest, "Invalid id", nil, err)
+		return
+	}
+
+	existing, ok := h.trashedTransaction(w, r, id)
+	if !ok {
+		return
+	}
+
+	// Both legs of a transfer come back together.
+	legs := []*models.Transaction{existing}
+	if existing.IsTransfer() {
+		partner, err := h.transferPartner(r, existing)
+		if err == nil {
+			legs = append(legs, partner)
+			err = h.store.Transactions.RestorePair(r.Context(), existing.Id, partner.Id)
+		}
+		if errors.Is(err, repository.ErrNotFound) {
+			helpers.SendResponse(w, http.StatusConflict, "The other side of the transfer cannot be restored", nil, nil)
+			return
+		}
+		if err != nil {
+			helpers.SendResponse(w, http.StatusInternalServerError, "Couldnt restore transaction", nil, err)
+			return
+		}
+	} else if err := h.store.Transactions.Restore(r.Context(), id); err != nil {
+		helpers.SendResponse(w, http.StatusInternalServerError, "Couldnt restore transaction", nil, err)
+		return
+	}
+
+	restored := make([]models.Transaction, len(legs))
+	for i, leg := range legs {
+		restored[i] = *leg
+		restored[i].IsDeleted = false
+		restored[i].DeletedAt = time.Time{}
+		h.recordRevision(r, "transaction", leg.Id, leg.UserId, models.RevisionRestore, nil, &restored[i])
+		h.auditChange(r, models.AuditTransactionRestore, "transaction", leg.Id, leg, &restored[i])
 	}
-	helpers.SendResponse(w, http.StatusOK, "Transaction deleted", data, nil)
+	helpers.SendResponse(w, http.StatusOK, "Transaction restored", restored[0], nil)
+}
+
+// rejectTransferType keeps plain transaction writes from creating transfer
+// legs, which must go through CreateTransfer so both sides exist.
+func rejectTransferType(w http.ResponseWriter, transaction *models.Transaction) bool {
+	if transaction.Type == models.TransactionTransfer {
+		helpers.SendResponse(w, http.StatusBadRequest, "Use /api/transaction/transfer to record transfers", nil, nil)
+		return false
+	}
+	transaction.TransferId = ""
+	transaction.TransferAccountId = ""
+	transaction.TransferDirection = ""
+	return true
 }
@@ -0,0 +1,54 @@
+package handlers_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/amrohan/expenso-go/internal/handlers"
+)
+
+// Transfers move money between accounts, so a month summary neither
+// converts them nor reports them as missing a rate.
+func TestSummarySkipsTransfers(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t, handlers.Config{})
+	omar := s.register("omar")
+	checking := s.createdId(omar.Token, "/api/account/", map[string]interface{}{"title": "Checking", "currency": "USD"})
+	savings := s.createdId(omar.Token, "/api/account/", map[string]interface{}{"title": "Savings", "currency": "USD"})
+
+	s.createdId(omar.Token, "/api/transaction/", map[string]interface{}{
+		"title": "Rent", "amount": 100, "date": "2026-10-01T10:00:00Z", "type": "Expense",
+	})
+	coffee := s.createdId(omar.Token, "/api/transaction/", map[string]interface{}{
+		"title": "Coffee", "amount": 10, "date": "2026-10-02T10:00:00Z", "type": "Expense", "accountId": checking,
+	})
+	s.expect(http.StatusOK, omar.Token, http.MethodPost, "/api/transaction/transfer", map[string]interface{}{
+		"title": "Save", "date": "2026-10-03T10:00:00Z", "amount": 50,
+		"fromAccountId": checking, "toAccountId": savings,
+	})
+
+	var month struct {
+		Summary struct {
+			Currency     string `json:"currency"`
+			TotalExpense amount `json:"totalExpense"`
+			ByCurrency   map[string]struct {
+				TotalExpense amount `json:"totalExpense"`
+			} `json:"byCurrency"`
+			MissingRates []struct {
+				TransactionId string `json:"transactionId"`
+				From          string `json:"from"`
+			} `json:"missingRates"`
+		} `json:"summary"`
+	}
+	s.decode(s.expect(http.StatusOK, omar.Token, http.MethodGet, "/api/transaction/u/10-2026-"+s.subject(omar.Token), nil), &month)
+	summary := month.Summary
+	if summary.Currency != "INR" || summary.TotalExpense.Amount != "100.00" {
+		t.Errorf("converted expense = %s %s, want INR 100.00", summary.Currency, summary.TotalExpense.Amount)
+	}
+	if got := summary.ByCurrency["USD"].TotalExpense.Amount; got != "10.00" {
+		t.Errorf("USD expense = %s, want 10.00", got)
+	}
+	if len(summary.MissingRates) != 1 || summary.MissingRates[0].TransactionId != coffee || summary.MissingRates[0].From != "USD" {
+		t.Errorf("missing rates = %+v, want only the coffee", summary.MissingRates)
+	}
+}
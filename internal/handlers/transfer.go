@@ -0,0 +1,369 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/amrohan/expenso-go/internal/helpers"
+	"github.com/amrohan/expenso-go/internal/models"
+	"github.com/amrohan/expenso-go/internal/money"
+	"github.com/amrohan/expenso-go/internal/repository"
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// transferRequest creates or edits a transfer. Amount leaves the source
+// account and ToAmount arrives in the destination. Only one of them is
+// needed: the other is the same amount when both accounts use one currency
+// and is converted at the rate of Date otherwise.
+type transferRequest struct {
+	Title         string       `json:"title"`
+	Date          time.Time    `json:"date"`
+	FromAccountId string       `json:"fromAccountId"`
+	ToAccountId   string       `json:"toAccountId"`
+	Amount        *money.Money `json:"amount"`
+	ToAmount      *money.Money `json:"toAmount"`
+	CategoryId    string       `json:"categoryId"`
+	ImageUrl      string       `json:"imageUrl"`
+}
+
+func transferView(out, in *models.Transaction) map[string]interface{} {
+	return map[string]interface{}{"from": out, "to": in}
+}
+
+func (h *Handler) CreateTransfer(w http.ResponseWriter, r *http.Request) {
+	var req transferRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		helpers.SendResponse(w, http.StatusBadRequest, "Couldnt decode request", nil, err)
+		return
+	}
+
+	out, in, ok := h.buildTransfer(w, r, req, nil, nil)
+	if !ok {
+		return
+	}
+	if err := h.store.Transactions.CreatePair(r.Context(), out, in); err != nil {
+		helpers.SendResponse(w, http.StatusInternalServerError, "Couldnt create transfer", nil, err)
+		return
+	}
+	for _, leg := range []*models.Transaction{out, in} {
+		h.recordRevision(r, "transaction", leg.Id, leg.UserId, models.RevisionCreate, nil, leg)
+		h.auditChange(r, models.AuditTransactionCreate, "transaction", leg.Id, nil, leg)
+	}
+	helpers.SendResponse(w, http.StatusOK, "Transfer created", transferView(out, in), nil)
+}
+
+// GetTransfer returns both legs of the transfer that id is a leg of.
+func (h *Handler) GetTransfer(w http.ResponseWriter, r *http.Request) {
+	leg, ok := h.transferLeg(w, r)
+	if !ok {
+		return
+	}
+	out, in, ok := h.transferLegs(w, r, leg)
+	if !ok {
+		return
+	}
+	helpers.SendResponse(w, http.StatusOK, "Transfer found", transferView(out, in), nil)
+}
+
+// UpdateTransfer replaces the transfer that id is a leg of.
+func (h *Handler) UpdateTransfer(w http.ResponseWriter, r *http.Request) {
+	leg, ok := h.transferLeg(w, r)
+	if !ok {
+		return
+	}
+	var req transferRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		helpers.SendResponse(w, http.StatusBadRequest, "Couldnt decode request", nil, err)
+		return
+	}
+	out, in, ok := h.transferLegs(w, r, leg)
+	if !ok {
+		return
+	}
+	h.saveTransfer(w, r, out, in, req)
+}
+
+// updateTransferLeg applies a PUT /api/transaction/ on one leg to the whole
+// transfer. The other leg keeps its account, and keeps its amount unless
+// the edit changed the amount or account of this one.
+func (h *Handler) updateTransferLeg(w http.ResponseWriter, r *http.Request, existing, edited *models.Transaction) {
+	out, in, ok := h.transferLegs(w, r, existing)
+	if !ok {
+		return
+	}
+	if edited.AccountId == "" {
+		edited.AccountId = existing.AccountId
+	}
+	amount := edited.Amount
+	unchanged := edited.AccountId == existing.AccountId && sameAmount(amount, existing.Amount)
+
+	req := transferRequest{
+		Title:         edited.Title,
+		Date:          edited.Date,
+		FromAccountId: out.AccountId,
+		ToAccountId:   in.AccountId,
+		CategoryId:    edited.CategoryId,
+		ImageUrl:      edited.ImageUrl,
+	}
+	if existing.TransferDirection == models.TransferOut {
+		req.FromAccountId, req.Amount = edited.AccountId, &amount
+		if unchanged {
+			req.ToAmount = &in.Amount
+		}
+	} else {
+		req.ToAccountId, req.ToAmount = edited.AccountId, &amount
+		if unchanged {
+			req.Amount = &out.Amount
+		}
+	}
+	h.saveTransfer(w, r, out, in, req)
+}
+
+func sameAmount(edited, existing money.Money) bool {
+	amount, err := edited.WithDefaultCurrency(existing.Currency)
+	return err == nil && amount.Currency == existing.Currency && amount.Amount == existing.Amount
+}
+
+func (h *Handler) saveTransfer(w http.ResponseWriter, r *http.Request, oldOut, oldIn *models.Transaction, req transferRequest) {
+	if oldOut.IsLocked() || oldIn.IsLocked() {
+		sendLocked(w)
+		return
+	}
+	out, in, ok := h.buildTransfer(w, r, req, oldOut, oldIn)
+	if !ok {
+		return
+	}
+	if err := h.store.Transactions.UpdatePair(r.Context(), out, in); err != nil {
+		helpers.SendResponse(w, http.StatusInternalServerError, "Couldnt update transfer", nil, err)
+		return
+	}
+	h.recordTransferUpdate(r, oldOut, oldIn, out, in)
+	helpers.SendResponse(w, http.StatusOK, "Transfer updated", transferView(out, in), nil)
+}
+
+func (h *Handler) recordTransferUpdate(r *http.Request, oldOut, oldIn, out, in *models.Transaction) {
+	for _, legs := range [][2]*models.Transaction{{oldOut, out}, {oldIn, in}} {
+		before, after := legs[0], legs[1]
+		h.recordRevision(r, "transaction", after.Id, after.UserId, models.RevisionUpdate, before, after)
+		h.auditChange(r, models.AuditTransactionUpdate, "transaction", after.Id, before, after)
+	}
+}
+
+// buildTransfer validates req and returns the out and in legs it describes.
+// When editing, existingOut and existingIn keep their ids and creation
+// times and statuses. On failure the response is written here and ok is
+// false.
+func (h *Handler) buildTransfer(w http.ResponseWriter, r *http.Request, req transferRequest, existingOut, existingIn *models.Transaction) (*models.Transaction, *models.Transaction, bool) {
+	if req.FromAccountId == "" || req.ToAccountId == "" {
+		helpers.SendResponse(w, http.StatusBadRequest, "Please send fromAccountId and toAccountId", nil, nil)
+		return nil, nil, false
+	}
+	if req.FromAccountId == req.ToAccountId {
+		helpers.SendResponse(w, http.StatusBadRequest, "Cannot transfer to the same account", nil, nil)
+		return nil, nil, false
+	}
+	from, err := h.lookupAccount(r, req.FromAccountId)
+	if err != nil {
+		sendReferenceError(w, "Source account", err)
+		return nil, nil, false
+	}
+	to, err := h.lookupAccount(r, req.ToAccountId)
+	if err != nil {
+		sendReferenceError(w, "Destination account", err)
+		return nil, nil, false
+	}
+	if req.CategoryId != "" {
+		if _, err := h.lookupCategory(r, req.CategoryId); err != nil {
+			sendReferenceError(w, "Category", err)
+			return nil, nil, false
+		}
+	}
+
+	date := req.Date
+	if date.IsZero() {
+		date = h.now()
+	}
+	fromCurrency, toCurrency := h.accountCurrency(from), h.accountCurrency(to)
+	amount, ok := transferAmount(w, req.Amount, fromCurrency, "amount")
+	if !ok {
+		return nil, nil, false
+	}
+	toAmount, ok := transferAmount(w, req.ToAmount, toCurrency, "toAmount")
+	if !ok {
+		return nil, nil, false
+	}
+	if amount == nil && toAmount == nil {
+		helpers.SendResponse(w, http.StatusBadRequest, "Please send an amount", nil, nil)
+		return nil, nil, false
+	}
+
+	converter := h.newRateConverter(r.Context())
+	if amount == nil {
+		converted, err := converter.convert(*toAmount, fromCurrency, date)
+		if !sendConversionError(w, err, "amount") {
+			return nil, nil, false
+		}
+		amount = &converted
+	}
+	if toAmount == nil {
+		converted, err := converter.convert(*amount, toCurrency, date)
+		if !sendConversionError(w, err, "toAmount") {
+			return nil, nil, false
+		}
+		toAmount = &converted
+	}
+
+	now := h.now()
+	out := models.Transaction{Id: primitive.NewObjectID(), CreatedAt: now, IsActive: true, Status: models.StatusPending}
+	in := models.Transaction{Id: primitive.NewObjectID(), CreatedAt: now, IsActive: true, Status: models.StatusPending}
+	if existingOut != nil && existingIn != nil {
+		out.Id, out.CreatedAt, out.IsActive, out.Status = existingOut.Id, existingOut.CreatedAt, existingOut.IsActive, existingOut.Status
+		in.Id, in.CreatedAt, in.IsActive, in.Status = existingIn.Id, existingIn.CreatedAt, existingIn.IsActive, existingIn.Status
+	}
+	for _, leg := range []struct {
+		t              *models.Transaction
+		amount         money.Money
+		account, other *models.Account
+		partner        primitive.ObjectID
+		direction      string
+	}{
+		{&out, *amount, from, to, in.Id, models.TransferOut},
+		{&in, *toAmount, to, from, out.Id, models.TransferIn},
+	} {
+		leg.t.Title = req.Title
+		leg.t.Amount = leg.amount
+		leg.t.Date = date
+		leg.t.CategoryId = req.CategoryId
+		leg.t.Type = models.TransactionTransfer
+		leg.t.ImageUrl = req.ImageUrl
+		leg.t.AccountId = leg.account.Id.Hex()
+		leg.t.UserId = currentUserId(r)
+		leg.t.UpdatedAt = now
+		leg.t.TransferId = leg.partner.Hex()
+		leg.t.TransferAccountId = leg.other.Id.Hex()
+		leg.t.TransferDirection = leg.direction
+	}
+	return &out, &in, true
+}
+
+// transferAmount checks an optional transfer amount against the currency of
+// its account. A nil amount stays nil.
+func transferAmount(w http.ResponseWriter, amount *money.Money, currency, name string) (*money.Money, bool) {
+	if amount == nil {
+		return nil, true
+	}
+	normalized, err := amount.WithDefaultCurrency(currency)
+	if err == nil {
+		normalized.Currency, err = money.NormalizeCurrency(normalized.Currency)
+	}
+	if err != nil {
+		helpers.SendResponse(w, http.StatusBadRequest, "Please send a valid "+name, nil, err)
+		return nil, false
+	}
+	if normalized.Currency != currency {
+		helpers.SendResponse(w, http.StatusBadRequest, "The "+name+" must be in the account currency, "+currency, nil, nil)
+		return nil, false
+	}
+	if normalized.Amount <= 0 {
+		helpers.SendResponse(w, http.StatusBadRequest, "Transfer amounts must be positive", nil, nil)
+		return nil, false
+	}
+	return &normalized, true
+}
+
+func sendConversionError(w http.ResponseWriter, err error, missing string) bool {
+	if errors.Is(err, errNoRate) {
+		helpers.SendResponse(w, http.StatusBadRequest, "No exchange rate found, please send "+missing, nil, err)
+		return false
+	}
+	if err != nil {
+		helpers.SendResponse(w, http.StatusInternalServerError, "Couldnt convert amount", nil, err)
+		return false
+	}
+	return true
+}
+
+// transferLeg loads the caller's transfer leg named by the id URL parameter.
+func (h *Handler) transferLeg(w http.ResponseWriter, r *http.Request) (*models.Transaction, bool) {
+	id, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.SendResponse(w, http.StatusBadRequest, "Invalid id", nil, err)
+		return nil, false
+	}
+	leg, ok := h.ownedTransaction(w, r, id)
+	if !ok {
+		return nil, false
+	}
+	if !leg.IsTransfer() {
+		helpers.SendResponse(w, http.StatusBadRequest, "Transaction is not a transfer", nil, nil)
+		return nil, false
+	}
+	return leg, true
+}
+
+// transferLegs returns the out and in legs of the transfer leg belongs to.
+func (h *Handler) transferLegs(w http.ResponseWriter, r *http.Request, leg *models.Transaction) (*models.Transaction, *models.Transaction, bool) {
+	partner, err := h.transferPartner(r, leg)
+	if err != nil {
+		helpers.SendResponse(w, http.StatusInternalServerError, "Couldnt find the other side of the transfer", nil, err)
+		return nil, nil, false
+	}
+	if leg.TransferDirection == models.TransferOut {
+		return leg, partner, true
+	}
+	return partner, leg, true
+}
+
+func (h *Handler) transferPartner(r *http.Request, leg *models.Transaction) (*models.Transaction, error) {
+	id, err := primitive.ObjectIDFromHex(leg.TransferId)
+	if err != nil {
+		return nil, repository.ErrNotFound
+	}
+	return h.store.Transactions.FindById(r.Context(), id)
+}
+
+// removeTransaction moves a transaction to the trash, together with the
+// other leg when it is part of a transfer, and records the change. It
+// returns the ids of every transaction deleted, or errLocked when either
+// leg is reconciled.
+func (h *Handler) removeTransaction(r *http.Request, existing *models.Transaction) ([]primitive.ObjectID, error) {
+	if existing.IsLocked() {
+		return nil, errLocked
+	}
+	legs := []*models.Transaction{existing}
+	at := h.now()
+	if existing.IsTransfer() {
+		partner, err := h.transferPartner(r, existing)
+		if err != nil {
+			return nil, err
+		}
+		if partner.IsLocked() {
+			return nil, errLocked
+		}
+		legs = append(legs, partner)
+		err = h.store.Transactions.DeletePair(r.Context(), existing.Id, partner.Id, at)
+		if err != nil {
+			return nil, err
+		}
+	} else if err := h.store.Transactions.Delete(r.Context(), existing.Id, at); err != nil {
+		return nil, err
+	}
+
+	ids := make([]primitive.ObjectID, 0, len(legs))
+	for _, leg := range legs {
+		deleted := *leg
+		deleted.IsDeleted = true
+		deleted.DeletedAt = at
+		h.recordRevision(r, "transaction", leg.Id, leg.UserId, models.RevisionDelete, leg, &deleted)
+		h.auditChange(r, models.AuditTransactionDelete, "transaction", leg.Id, leg, &deleted)
+		ids = append(ids, leg.Id)
+	}
+	return ids, nil
+}
+
+// errSelfTransfer is returned when a reassignment would leave both legs of a
+// transfer in the same account.
+var errSelfTransfer = errors.New("transfer would have the same source and destination account")
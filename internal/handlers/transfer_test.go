@@ -0,0 +1,86 @@
+package handlers_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/amrohan/expenso-go/internal/handlers"
+)
+
+type transferLeg struct {
+	Id                string `json:"id"`
+	Type              string `json:"type"`
+	Amount            amount `json:"amount"`
+	AccountId         string `json:"accountId"`
+	TransferId        string `json:"transferId"`
+	TransferAccountId string `json:"transferAccountId"`
+	TransferDirection string `json:"transferDirection"`
+}
+
+type transferView struct {
+	From transferLeg `json:"from"`
+	To   transferLeg `json:"to"`
+}
+
+func TestTransfers(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t, handlers.Config{})
+	dan := s.register("dan")
+	bank := s.createdId(dan.Token, "/api/account/", map[string]interface{}{"title": "Bank"})
+	wallet := s.createdId(dan.Token, "/api/account/", map[string]interface{}{"title": "Wallet"})
+	travel := s.createdId(dan.Token, "/api/account/", map[string]interface{}{"title": "Travel card", "currency": "EUR"})
+
+	transfer := func(from, to string, body map[string]interface{}) map[string]interface{} {
+		body["title"], body["date"], body["fromAccountId"], body["toAccountId"] = "Cash", "2026-10-04T10:00:00Z", from, to
+		return body
+	}
+	s.expect(http.StatusBadRequest, dan.Token, http.MethodPost, "/api/transaction/transfer", transfer(bank, bank, map[string]interface{}{"amount": 50}))
+	s.expect(http.StatusBadRequest, dan.Token, http.MethodPost, "/api/transaction/transfer", transfer(bank, wallet, map[string]interface{}{}))
+	// Without a rate, a transfer between currencies needs both amounts.
+	s.expect(http.StatusBadRequest, dan.Token, http.MethodPost, "/api/transaction/transfer", transfer(bank, travel, map[string]interface{}{"amount": 900}))
+	s.expect(http.StatusOK, dan.Token, http.MethodPost, "/api/transaction/transfer",
+		transfer(bank, travel, map[string]interface{}{"amount": 900, "toAmount": map[string]string{"amount": "10", "currency": "EUR"}}))
+	// Plain transaction writes cannot make half a transfer.
+	s.expect(http.StatusBadRequest, dan.Token, http.MethodPost, "/api/transaction/", map[string]interface{}{
+		"title": "Cash", "amount": 50, "date": "2026-10-04T10:00:00Z", "type": "Transfer", "accountId": bank,
+	})
+
+	var created transferView
+	s.decode(s.expect(http.StatusOK, dan.Token, http.MethodPost, "/api/transaction/transfer",
+		transfer(bank, wallet, map[string]interface{}{"amount": 50})), &created)
+	out, in := created.From, created.To
+	if out.Type != "Transfer" || out.AccountId != bank || out.TransferAccountId != wallet || out.TransferId != in.Id || out.TransferDirection != "out" {
+		t.Errorf("out leg = %+v, want a transfer from bank pointing at the in leg", out)
+	}
+	if in.AccountId != wallet || in.TransferAccountId != bank || in.TransferId != out.Id || in.Amount.Amount != "50.00" {
+		t.Errorf("in leg = %+v, want 50.00 into wallet pointing at the out leg", in)
+	}
+
+	// Transfers are not income or expense.
+	var month struct {
+		Summary struct {
+			TotalIncome  amount `json:"totalIncome"`
+			TotalExpense amount `json:"totalExpense"`
+		} `json:"summary"`
+	}
+	s.decode(s.expect(http.StatusOK, dan.Token, http.MethodGet, "/api/transaction/u/10-2026-"+s.subject(dan.Token), nil), &month)
+	if month.Summary.TotalIncome.Amount != "0.00" || month.Summary.TotalExpense.Amount != "0.00" {
+		t.Errorf("month totals = %+v, want transfers left out", month.Summary)
+	}
+
+	// Editing one leg as a plain transaction keeps the other in step.
+	s.expect(http.StatusOK, dan.Token, http.MethodPut, "/api/transaction/", map[string]interface{}{
+		"id": in.Id, "title": "More cash", "amount": 60, "date": "2026-10-04T10:00:00Z", "type": "Transfer", "accountId": wallet,
+	})
+	var edited transferView
+	s.decode(s.expect(http.StatusOK, dan.Token, http.MethodGet, "/api/transaction/transfer/"+out.Id, nil), &edited)
+	if edited.From.Amount.Amount != "60.00" || edited.To.Amount.Amount != "60.00" || edited.From.AccountId != bank {
+		t.Errorf("transfer after editing the in leg = %+v, want 60.00 on both legs", edited)
+	}
+
+	// Deleting either leg deletes both.
+	s.expect(http.StatusOK, dan.Token, http.MethodDelete, "/api/transaction/"+in.Id, nil)
+	s.expect(http.StatusNotFound, dan.Token, http.MethodGet, "/api/transaction/"+out.Id, nil)
+	s.expect(http.StatusOK, dan.Token, http.MethodPost, "/api/transaction/"+out.Id+"/restore", nil)
+	s.expect(http.StatusOK, dan.Token, http.MethodGet, "/api/transaction/"+in.Id, nil)
+}
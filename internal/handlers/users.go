@@ -3,239 +3,314 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"log"
 	"net/http"
 	"time"
 
-	"github.com/amrohan/expenso-go/internal/db"
 	"github.com/amrohan/expenso-go/internal/helpers"
 	"github.com/amrohan/expenso-go/internal/models"
+	"github.com/amrohan/expenso-go/internal/money"
+	"github.com/amrohan/expenso-go/internal/repository"
 	"github.com/go-chi/chi/v5"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-func CreateUser(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) CreateUser(w http.ResponseWriter, r *http.Request) {
 	user := models.User{
 		Id:        primitive.NewObjectID(),
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
+		CreatedAt: h.now(),
+		UpdatedAt: h.now(),
 	}
 	if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
 		helpers.SendResponse(w, http.StatusBadRequest, "Please valid body", nil, err)
 		return
 	}
-
-	client, err := db.GetMongoClient()
-	if err != nil {
-		helpers.SendResponse(w, http.StatusInternalServerError, "Error connecting to mongo", nil, err)
+	user.Id = primitive.NewObjectID()
+	if user.Password != "" {
+		hash, err := HashPassword(user.Password)
+		if err != nil {
+			helpers.SendResponse(w, http.StatusBadRequest, "Please valid body", nil, err)
+			return
+		}
+		user.Password = hash
+	}
+	if h.loginTaken(w, r, &user) {
 		return
 	}
 
-	collection := client.Database(db.Database).Collection(string(db.UserCollection))
-	if _, err = collection.InsertOne(context.TODO(), user); err != nil {
+	if err := h.store.Users.Create(r.Context(), &user); err != nil {
+		if errors.Is(err, repository.ErrDuplicate) {
+			helpers.SendResponse(w, http.StatusBadRequest, "Username or email already exists", nil, err)
+			return
+		}
 		helpers.SendResponse(w, http.StatusInternalServerError, "Error creating user", nil, err)
 		return
 	}
+	user.Password = ""
 	helpers.SendResponse(w, http.StatusOK, "User created successfully", user, nil)
 }
 
-func GetAllUsers(w http.ResponseWriter, r *http.Request) {
-	client, err := db.GetMongoClient()
-	if err != nil {
-		helpers.SendResponse(w, http.StatusInternalServerError, "Error connecting to mongo", nil, err)
-		return
+// loginTaken answers 400 and returns true when a user other than user
+// already has its username or email. The unique indexes still catch a
+// concurrent request that gets past this check.
+func (h *Handler) loginTaken(w http.ResponseWriter, r *http.Request, user *models.User) bool {
+	for _, check := range []struct {
+		value   string
+		find    func(context.Context, string) (*models.User, error)
+		message string
+	}{
+		{user.Username, h.store.Users.FindByUsername, "Username already exists"},
+		{user.Email, h.store.Users.FindByEmail, "Email already exists"},
+	} {
+		if check.value == "" {
+			continue
+		}
+		other, err := check.find(r.Context(), check.value)
+		if errors.Is(err, repository.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			helpers.SendResponse(w, http.StatusInternalServerError, "Unable to check for existing user", nil, err)
+			return true
+		}
+		if other.Id != user.Id {
+			helpers.SendResponse(w, http.StatusBadRequest, check.message, nil, nil)
+			return true
+		}
 	}
+	return false
+}
 
-	collection := client.Database(db.Database).Collection(string(db.UserCollection))
-	cursor, err := collection.Find(context.TODO(), bson.D{
-		primitive.E{},
-	})
+func (h *Handler) GetAllUsers(w http.ResponseWriter, r *http.Request) {
+	users, err := h.store.Users.Find(r.Context(), repository.UserFilter{})
 	if err != nil {
 		helpers.SendResponse(w, http.StatusInternalServerError, "Error getting users", nil, err)
 		return
 	}
-
-	var users []models.User
-	if err = cursor.All(context.TODO(), &users); err != nil {
-		helpers.SendResponse(w, http.StatusInternalServerError, "Error getting users", nil, err)
-		return
-	}
+	users = withoutPasswords(users)
 	helpers.SendResponse(w, http.StatusOK, "Users fetched successfully", users, nil)
 }
 
-func GetUserById(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) GetUserById(w http.ResponseWriter, r *http.Request) {
 	id, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
 	if err != nil {
 		helpers.SendResponse(w, http.StatusBadRequest, "Invalid user id", nil, err)
 		return
 	}
 
-	client, err := db.GetMongoClient()
-	if err != nil {
-		helpers.SendResponse(w, http.StatusInternalServerError, "Error connecting to mongo", nil, err)
-		return
-	}
-
-	collection := client.Database(db.Database).Collection(string(db.UserCollection))
-	var user models.User
-	if err = collection.FindOne(context.TODO(), bson.M{"_id": id}).Decode(&user); err != nil {
-		helpers.SendResponse(w, http.StatusInternalServerError, "Error getting user", nil, err)
+	user, ok := h.ownedUser(w, r, id)
+	if !ok {
 		return
 	}
 	user.Password = ""
 	helpers.SendResponse(w, http.StatusOK, "User fetched successfully", user, nil)
 }
 
-func UpdateUser(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 	user := models.User{
-		UpdatedAt: time.Now(),
+		UpdatedAt: h.now(),
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
 		helpers.SendResponse(w, http.StatusBadRequest, "Please valid body", nil, err)
 		return
 	}
-	client, err := db.GetMongoClient()
-	if err != nil {
-		helpers.SendResponse(w, http.StatusInternalServerError, "Error connecting to mongo", nil, err)
-		return
-	}
 	// Retrieve the existing user from the database
-	existingUser := models.User{}
-	collection := client.Database(db.Database).Collection(string(db.UserCollection))
-	if err := collection.FindOne(context.TODO(), bson.M{"_id": user.Id}).Decode(&existingUser); err != nil {
-		helpers.SendResponse(w, http.StatusInternalServerError, "Error retrieving user", nil, err)
+	existingUser, ok := h.ownedUser(w, r, user.Id)
+	if !ok {
 		return
 	}
-	// Keep the existing password
+	// Keep the existing password, roles and lifecycle fields
 
 	user.Password = existingUser.Password
-	_, err = collection.UpdateOne(context.TODO(), bson.M{"_id": user.Id}, bson.M{"$set": user})
-	if err != nil {
+	user.CreatedAt = existingUser.CreatedAt
+	user.IsActive = existingUser.IsActive
+	user.IsDeleted = existingUser.IsDeleted
+	user.DeletedAt = existingUser.DeletedAt
+	user.Roles = existingUser.Roles
+	user.TOTPSecret = existingUser.TOTPSecret
+	user.TOTPEnabled = existingUser.TOTPEnabled
+	user.TOTPLastStep = existingUser.TOTPLastStep
+	user.RecoveryCodeHashes = existingUser.RecoveryCodeHashes
+	user.Identities = existingUser.Identities
+	if user.BaseCurrency != "" {
+		currency, err := money.NormalizeCurrency(user.BaseCurrency)
+		if err != nil {
+			helpers.SendResponse(w, http.StatusBadRequest, "Please send a valid base currency", nil, err)
+			return
+		}
+		user.BaseCurrency = currency
+	}
+	if h.loginTaken(w, r, &user) {
+		return
+	}
+	// A changed email has to be verified again
+	emailChanged := user.Email != existingUser.Email
+	user.IsVerified = existingUser.IsVerified && !emailChanged
+	if err := h.store.Users.Update(r.Context(), &user); err != nil {
+		if errors.Is(err, repository.ErrDuplicate) {
+			helpers.SendResponse(w, http.StatusBadRequest, "Username or email already exists", nil, err)
+			return
+		}
 		helpers.SendResponse(w, http.StatusInternalServerError, "Error updating user", nil, err)
 		return
 	}
+	if emailChanged {
+		h.sendVerificationEmail(r, &user)
+	}
 	user.Password = ""
 	helpers.SendResponse(w, http.StatusOK, "User updated successfully", user, nil)
 }
 
-func DeleteUser(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) DeleteUser(w http.ResponseWriter, r *http.Request) {
 	id, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
 	if err != nil {
 		helpers.SendResponse(w, http.StatusBadRequest, "Invalid user id", nil, err)
 		return
 	}
 
-	client, err := db.GetMongoClient()
-	if err != nil {
-		helpers.SendResponse(w, http.StatusInternalServerError, "Error connecting to mongo", nil, err)
+	existing, ok := h.ownedUser(w, r, id)
+	if !ok {
 		return
 	}
 
-	collection := client.Database(db.Database).Collection(string(db.UserCollection))
-	if _, err = collection.DeleteOne(context.TODO(), bson.M{"_id": id}); err != nil {
+	deleted := *existing
+	deleted.IsDeleted = true
+	deleted.DeletedAt = h.now()
+	if err := h.store.Users.Delete(r.Context(), id, deleted.DeletedAt); errors.Is(err, repository.ErrNotFound) {
+		sendUserLookupError(w, err)
+		return
+	} else if err != nil {
 		helpers.SendResponse(w, http.StatusInternalServerError, "Error deleting user", nil, err)
 		return
 	}
+	// A deleted user keeps no way in.
+	if err := h.store.Sessions.RevokeAll(r.Context(), id.Hex(), deleted.DeletedAt); err != nil {
+		log.Printf("Error revoking sessions of deleted user: %v", err)
+	}
+	h.auditChange(r, models.AuditUserDelete, "user", id, existing, &deleted)
 	helpers.SendResponse(w, http.StatusOK, "User deleted successfully", nil, nil)
 }
 
-func GetUserByEmail(w http.ResponseWriter, r *http.Request) {
-	email := chi.URLParam(r, "email")
-
-	client, err := db.GetMongoClient()
-	if err != nil {
-		helpers.SendResponse(w, http.StatusInternalServerError, "Error connecting to mongo", nil, err)
-		return
+func (h *Handler) GetUserByEmail(w http.ResponseWriter, r *http.Request) {
+	user, err := h.store.Users.FindByEmail(r.Context(), chi.URLParam(r, "email"))
+	if err == nil && !owns(r, user.Id.Hex()) {
+		err = repository.ErrNotFound
 	}
-
-	collection := client.Database(db.Database).Collection(string(db.UserCollection))
-	var user models.User
-	if err = collection.FindOne(context.TODO(), bson.M{"email": email}).Decode(&user); err != nil {
-		helpers.SendResponse(w, http.StatusInternalServerError, "Error getting user", nil, err)
+	if err != nil {
+		sendUserLookupError(w, err)
 		return
 	}
+	user.Password = ""
 	helpers.SendResponse(w, http.StatusOK, "User fetched successfully", user, nil)
 }
 
-func GetUserByUsername(w http.ResponseWriter, r *http.Request) {
-	username := chi.URLParam(r, "username")
-
-	client, err := db.GetMongoClient()
-	if err != nil {
-		helpers.SendResponse(w, http.StatusInternalServerError, "Error connecting to mongo", nil, err)
-		return
+func (h *Handler) GetUserByUsername(w http.ResponseWriter, r *http.Request) {
+	user, err := h.store.Users.FindByUsername(r.Context(), chi.URLParam(r, "username"))
+	if err == nil && !owns(r, user.Id.Hex()) {
+		err = repository.ErrNotFound
 	}
-
-	collection := client.Database(db.Database).Collection(string(db.UserCollection))
-	var user models.User
-	if err = collection.FindOne(context.TODO(), bson.M{"username": username}).Decode(&user); err != nil {
-		helpers.SendResponse(w, http.StatusInternalServerError, "Error getting user", nil, err)
+	if err != nil {
+		sendUserLookupError(w, err)
 		return
 	}
+	user.Password = ""
 	helpers.SendResponse(w, http.StatusOK, "User fetched successfully", user, nil)
 }
 
 // check if username exists or not
-func CheckUsername(w http.ResponseWriter, r *http.Request) {
-	username := chi.URLParam(r, "username")
-
-	client, err := db.GetMongoClient()
-	if err != nil {
-		helpers.SendResponse(w, http.StatusInternalServerError, "Error connecting to mongo", nil, err)
-		return
-	}
-
-	collection := client.Database(db.Database).Collection(string(db.UserCollection))
-	var user models.User
-	if err = collection.FindOne(context.TODO(), bson.M{"username": username}).Decode(&user); err != nil {
+func (h *Handler) CheckUsername(w http.ResponseWriter, r *http.Request) {
+	if _, err := h.store.Users.FindByUsername(r.Context(), chi.URLParam(r, "username")); err != nil {
 		helpers.SendResponse(w, http.StatusOK, "Username available", nil, nil)
 		return
 	}
 	helpers.SendResponse(w, http.StatusOK, "Username already exists", nil, nil)
 }
 
-func GetAllDeletedUser(w http.ResponseWriter, r *http.Request) {
-	client, err := db.GetMongoClient()
+func (h *Handler) GetAllDeletedUser(w http.ResponseWriter, r *http.Request) {
+	users, err := h.store.Users.Find(r.Context(), repository.UserFilter{DeletedOnly: true})
 	if err != nil {
-		helpers.SendResponse(w, http.StatusInternalServerError, "Error connecting to mongo", nil, err)
-		return
-	}
-
-	collection := client.Database(db.Database).Collection(string(db.UserCollection))
-	cursor, err := collection.Find(context.TODO(), bson.D{
-		primitive.E{Key: "isDeleted", Value: true},
-	})
-	if err != nil {
-		helpers.SendResponse(w, http.StatusInternalServerError, "Error getting users", nil, err)
-		return
-	}
-
-	var users []models.User
-	if err = cursor.All(context.TODO(), &users); err != nil {
 		helpers.SendResponse(w, http.StatusInternalServerError, "Error getting users", nil, err)
 		return
 	}
+	users = withoutPasswords(users)
 	helpers.SendResponse(w, http.StatusOK, "Users fetched successfully", users, nil)
 }
 
-func RestoreUser(w http.ResponseWriter, r *http.Request) {
+func (h *Handler) RestoreUser(w http.ResponseWriter, r *http.Request) {
 	id, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
 	if err != nil {
 		helpers.SendResponse(w, http.StatusBadRequest, "Invalid user id", nil, err)
 		return
 	}
 
-	client, err := db.GetMongoClient()
+	user, err := h.store.Users.FindById(r.Context(), id)
 	if err != nil {
-		helpers.SendResponse(w, http.StatusInternalServerError, "Error connecting to mongo", nil, err)
+		sendUserLookupError(w, err)
 		return
 	}
-
-	collection := client.Database(db.Database).Collection(string(db.UserCollection))
-	if _, err = collection.UpdateOne(context.TODO(), bson.M{"_id": id}, bson.M{"$set": bson.M{"isDeleted": false}}); err != nil {
-		helpers.SendResponse(w, http.StatusInternalServerError, "Error restoring user", nil, err)
+	if err := h.store.Users.Restore(r.Context(), id); err != nil {
+		sendUserLookupError(w, err)
 		return
 	}
+	restored := *user
+	restored.IsDeleted = false
+	restored.DeletedAt = time.Time{}
+	h.auditChange(r, models.AuditUserRestore, "user", id, user, &restored)
 	helpers.SendResponse(w, http.StatusOK, "User restored successfully", nil, nil)
 }
+
+// ownedUser loads the user with the given id if it is the caller. Any other
+// user is reported as not found.
+func (h *Handler) ownedUser(w http.ResponseWriter, r *http.Request, id primitive.ObjectID) (*models.User, bool) {
+	user, err := h.store.Users.FindById(r.Context(), id)
+	if err == nil && !owns(r, user.Id.Hex()) {
+		err = repository.ErrNotFound
+	}
+	if err != nil {
+		sendUserLookupError(w, err)
+		return nil, false
+	}
+	return user, true
+}
+
+func withoutPasswords(users []models.User) []models.User {
+	for i := range users {
+		users[i].Password = ""
+	}
+	return users
+}
+
+func sendUserLookupError(w http.ResponseWriter, err error) {
+	if errors.Is(err, repository.ErrNotFound) {
+		helpers.SendResponse(w, http.StatusNotFound, "User not found", nil, nil)
+		return
+	}
+	helpers.SendResponse(w, http.StatusInternalServerError, "Error getting user", nil, err)
+}
+
+// BootstrapAdmins grants the admin role to existing users whose email is
+// listed in Config.AdminEmails and verified. It is run once at startup so the
+// first admin can be created without touching the database by hand.
+func (h *Handler) BootstrapAdmins(ctx context.Context) error {
+	for _, email := range h.config.AdminEmails {
+		user, err := h.store.Users.FindByEmail(ctx, email)
+		if errors.Is(err, repository.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if !user.IsVerified || user.HasRole(models.RoleAdmin) {
+			continue
+		}
+		user.Roles = append(user.Roles, models.RoleAdmin)
+		user.UpdatedAt = h.now()
+		if err := h.store.Users.Update(ctx, user); err != nil {
+			return err
+		}
+		log.Printf("Granted admin role to %s", email)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/amrohan/expenso-go/internal/handlers"
+)
+
+func TestUniqueLogins(t *testing.T) {
+	t.Parallel()
+	s := newTestServer(t, handlers.Config{AdminEmails: []string{"root@example.com"}})
+	root := s.register("root")
+	mia := s.register("mia")
+	s.register("noah")
+
+	// The admin role comes with a verified email, and admin routes stay
+	// closed to everyone else.
+	s.expect(http.StatusForbidden, root.Token, http.MethodGet, "/api/user/", nil)
+	s.expect(http.StatusOK, "", http.MethodGet, s.link("root@example.com", "/verify?token="), nil)
+	var admin tokens
+	s.decode(s.login("root"), &admin)
+	s.expect(http.StatusOK, admin.Token, http.MethodGet, "/api/user/", nil)
+	s.expect(http.StatusForbidden, mia.Token, http.MethodGet, "/api/user/", nil)
+	s.expect(http.StatusForbidden, mia.Token, http.MethodPost, "/api/user/", map[string]string{"username": "mallory"})
+
+	create := func(username, email string) apiResponse {
+		return s.call(admin.Token, http.MethodPost, "/api/user/", map[string]string{
+			"username": username,
+			"email":    email,
+			"password": "correct horse " + username,
+		})
+	}
+	for _, tt := range []struct{ username, email, message string }{
+		{"mia", "other@example.com", "Username already exists"},
+		{"olga", "mia@example.com", "Email already exists"},
+	} {
+		if resp := create(tt.username, tt.email); resp.Status != http.StatusBadRequest || resp.Message != tt.message {
+			t.Errorf("create %s %s = %d %q, want 400 %q", tt.username, tt.email, resp.Status, resp.Message, tt.message)
+		}
+	}
+	if resp := create("olga", "olga@example.com"); resp.Status != http.StatusOK {
+		t.Fatalf("create olga = %d %q, want 200", resp.Status, resp.Message)
+	}
+
+	var user map[string]json.RawMessage
+	s.decode(s.expect(http.StatusOK, mia.Token, http.MethodGet, "/api/user/"+s.subject(mia.Token), nil), &user)
+	update := func(field, value string) apiResponse {
+		changed := map[string]json.RawMessage{}
+		for k, v := range user {
+			changed[k] = v
+		}
+		changed[field], _ = json.Marshal(value)
+		return s.call(mia.Token, http.MethodPut, "/api/user/", changed)
+	}
+	for _, tt := range []struct{ field, value, message string }{
+		{"username", "noah", "Username already exists"},
+		{"email", "noah@example.com", "Email already exists"},
+		{"username", "olga", "Username already exists"},
+	} {
+		if resp := update(tt.field, tt.value); resp.Status != http.StatusBadRequest || resp.Message != tt.message {
+			t.Errorf("update %s to %s = %d %q, want 400 %q", tt.field, tt.value, resp.Status, resp.Message, tt.message)
+		}
+	}
+	// Saving unchanged values is not a clash with oneself.
+	if resp := update("name", "Mia"); resp.Status != http.StatusOK {
+		t.Errorf("update name = %d %q, want 200", resp.Status, resp.Message)
+	}
+	if resp := update("username", "mia2"); resp.Status != http.StatusOK {
+		t.Errorf("update username = %d %q, want 200", resp.Status, resp.Message)
+	}
+}
@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/amrohan/expenso-go/internal/helpers"
+	"github.com/amrohan/expenso-go/internal/mailer"
+	"github.com/amrohan/expenso-go/internal/models"
+	"github.com/amrohan/expenso-go/internal/repository"
+	"github.com/golang-jwt/jwt/v5"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const purposeVerifyEmail = "verify-email"
+
+// sendVerificationEmail mails user a signed link to GET /verify. The token is
+// bound to the address it was sent to, so changing the email invalidates it.
+// Failures are logged; the user can always ask for a new link.
+func (h *Handler) sendVerificationEmail(r *http.Request, user *models.User) {
+	token, err := h.signPurposeToken(purposeVerifyEmail, user.Id.Hex(), h.config.VerifyEmailTTL, jwt.MapClaims{
+		"email": user.Email,
+	})
+	if err != nil {
+		log.Printf("Error signing verification token: %v", err)
+		return
+	}
+
+	link := h.publicURL(r) + "/verify?token=" + url.QueryEscape(token)
+	err = h.config.Mailer.Send(r.Context(), mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your Expenso email",
+		Body: fmt.Sprintf("Hi %s,\n\nPlease confirm your email address by opening the link below. It expires in %s.\n\n%s\n",
+			user.Username, h.config.VerifyEmailTTL, link),
+	})
+	if err != nil {
+		log.Printf("Error sending verification email: %v", err)
+	}
+}
+
+func (h *Handler) publicURL(r *http.Request) string {
+	if h.config.PublicURL != "" {
+		return h.config.PublicURL
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+func (h *Handler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	id, claims, err := h.parsePurposeToken(r.URL.Query().Get("token"), purposeVerifyEmail)
+	if err != nil {
+		helpers.SendResponse(w, http.StatusBadRequest, "Verification link is invalid or has expired", nil, err)
+		return
+	}
+	email, _ := claims["email"].(string)
+
+	user, err := h.store.Users.FindById(r.Context(), id)
+	if errors.Is(err, repository.ErrNotFound) || (err == nil && user.Email != email) {
+		helpers.SendResponse(w, http.StatusBadRequest, "Verification link is invalid or has expired", nil, nil)
+		return
+	}
+	if err != nil {
+		helpers.SendResponse(w, http.StatusInternalServerError, "Error getting user", nil, err)
+		return
+	}
+
+	if !user.IsVerified {
+		user.IsVerified = true
+		// Admin emails only count once their owner has proven them.
+		if h.isAdminEmail(user.Email) && !user.HasRole(models.RoleAdmin) {
+			user.Roles = append(user.Roles, models.RoleAdmin)
+		}
+		user.UpdatedAt = h.now()
+		if err := h.store.Users.Update(r.Context(), user); err != nil {
+			helpers.SendResponse(w, http.StatusInternalServerError, "Error verifying email", nil, err)
+			return
+		}
+	}
+	helpers.SendResponse(w, http.StatusOK, "Email verified", nil, nil)
+}
+
+// ResendVerification sends a fresh verification link. It is public so users
+// locked out by the login grace period can still verify, and answers the same
+// way for unknown addresses.
+func (h *Handler) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Email string `json:"email"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Email == "" {
+		helpers.SendResponse(w, http.StatusBadRequest, "Please send valid body", nil, err)
+		return
+	}
+
+	user, err := h.store.Users.FindByEmail(r.Context(), body.Email)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		helpers.SendResponse(w, http.StatusInternalServerError, "Unable to check for existing user", nil, err)
+		return
+	}
+	if err == nil && !user.IsVerified && !user.IsDeleted {
+		h.sendVerificationEmail(r, user)
+	}
+	helpers.SendResponse(w, http.StatusOK, "If the email needs verifying, a new link has been sent", nil, nil)
+}
+
+// verificationOverdue reports whether the login grace period for an
+// unverified account has run out.
+func (h *Handler) verificationOverdue(user *models.User) bool {
+	days := h.config.Unverified.LoginGraceDays
+	return !user.IsVerified && days > 0 && h.now().Sub(user.CreatedAt) > time.Duration(days)*24*time.Hour
+}
+
+// EnforceVerification applies the unverified policy: writes are rejected
+// under ReadOnly, and every request once the login grace period has run
+// out, so an access token issued earlier stops working too. It must run
+// after AuthMiddleware. The flag in the token is as old as the token, so it
+// is read from the user record instead.
+func (h *Handler) EnforceVerification(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := PrincipalFromContext(r.Context())
+		safe := r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions
+		readOnly := h.config.Unverified.ReadOnly && !safe
+		if ok && (readOnly || h.config.Unverified.LoginGraceDays > 0) {
+			id, err := primitive.ObjectIDFromHex(principal.UserId)
+			if err != nil {
+				helpers.SendResponse(w, http.StatusUnauthorized, "Unauthorized", nil, err)
+				return
+			}
+			user, err := h.store.Users.FindById(r.Context(), id)
+			if err != nil {
+				sendUserLookupError(w, err)
+				return
+			}
+			if h.verificationOverdue(user) {
+				helpers.SendResponse(w, http.StatusForbidden, "Please verify your email to continue", nil, nil)
+				return
+			}
+			if readOnly && !user.IsVerified {
+				helpers.SendResponse(w, http.StatusForbidden, "Please verify your email to make changes", nil, nil)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,58 @@
+package handlers_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/amrohan/expenso-go/internal/handlers"
+)
+
+func TestUnverifiedPolicy(t *testing.T) {
+	t.Parallel()
+	now := &clock{now: time.Now()}
+	// Access tokens outlive the grace period so the checks below are the
+	// verification policy's, not token expiry.
+	s := newTestServer(t, handlers.Config{
+		Now:            now.Now,
+		Unverified:     handlers.UnverifiedPolicy{ReadOnly: true, LoginGraceDays: 3},
+		AccessTokenTTL: 7 * 24 * time.Hour,
+	})
+	kim := s.register("kim")
+	lee := s.register("lee")
+
+	// Unverified users can read but not write.
+	s.expect(http.StatusOK, kim.Token, http.MethodGet, "/api/category/", nil)
+	s.expect(http.StatusForbidden, kim.Token, http.MethodPost, "/api/category/", map[string]string{"title": "Travel"})
+
+	// Verifying lifts the restriction for the token already held.
+	s.expect(http.StatusBadRequest, "", http.MethodGet, "/verify?token=forged", nil)
+	s.expect(http.StatusOK, "", http.MethodGet, s.link("kim@example.com", "/verify?token="), nil)
+	s.createdId(kim.Token, "/api/category/", map[string]string{"title": "Travel"})
+
+	// Past the grace period lee is locked out everywhere, not just at the
+	// password login.
+	now.Add(4 * 24 * time.Hour)
+	s.expect(http.StatusForbidden, "", http.MethodPost, "/login", map[string]string{
+		"username": "lee",
+		"password": "correct horse lee",
+	})
+	s.expect(http.StatusForbidden, "", http.MethodPost, "/refresh", map[string]string{"refreshToken": lee.RefreshToken})
+	s.expect(http.StatusForbidden, lee.Token, http.MethodGet, "/api/category/", nil)
+	s.expect(http.StatusForbidden, lee.Token, http.MethodGet, "/api/transaction/u/10-2026-"+s.subject(lee.Token), nil)
+
+	// The first link has expired by now, but a new one lets lee back in.
+	expired := s.link("lee@example.com", "/verify?token=")
+	s.expect(http.StatusBadRequest, "", http.MethodGet, expired, nil)
+	s.expect(http.StatusOK, "", http.MethodPost, "/verify/resend", map[string]string{"email": "lee@example.com"})
+	fresh := s.link("lee@example.com", "/verify?token=")
+	if fresh == expired {
+		t.Fatal("resend did not mail a new link")
+	}
+	s.expect(http.StatusOK, "", http.MethodGet, fresh, nil)
+	s.expect(http.StatusOK, lee.Token, http.MethodGet, "/api/category/", nil)
+	s.login("lee")
+
+	s.expect(http.StatusOK, kim.Token, http.MethodGet, "/api/category/", nil)
+	s.expect(http.StatusOK, "", http.MethodPost, "/refresh", map[string]string{"refreshToken": kim.RefreshToken})
+}
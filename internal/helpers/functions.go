@@ -2,6 +2,7 @@ package helpers
 
 import (
 	"encoding/json"
+	"net"
 	"net/http"
 
 	"github.com/amrohan/expenso-go/internal/models"
@@ -18,3 +19,14 @@ func SendResponse(w http.ResponseWriter, statusCode int, message string, data in
 	}
 	json.NewEncoder(w).Encode(res)
 }
+
+// ClientIP returns the address of the caller without the port. When the
+// server runs behind a trusted proxy, middleware.RealIP rewrites RemoteAddr
+// before this is called.
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -3,6 +3,8 @@ package models
 import (
 	"time"
 
+	"github.com/amrohan/expenso-go/internal/money"
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
@@ -15,7 +17,7 @@ type Response struct {
 type Transaction struct {
 	Id         primitive.ObjectID `json:"id" bson:"_id"`
 	Title      string             `json:"title" bson:"title"`
-	Amount     int                `json:"amount" bson:"amount"`
+	Amount     money.Money        `json:"amount" bson:"amount"`
 	Date       time.Time          `json:"date" bson:"date"`
 	CategoryId string             `json:"categoryId" bson:"categoryId"`
 	Type       string             `json:"type" bson:"type"`
@@ -27,6 +29,80 @@ type Transaction struct {
 	DeletedAt  time.Time          `json:"deletedAt" bson:"deletedAt"`
 	IsDeleted  bool               `json:"isDeleted" bson:"isDeleted"`
 	IsActive   bool               `json:"isActive" bson:"isActive"`
+	// A transfer is stored as two legs that point at each other: the out leg
+	// in the source account and the in leg in the destination account.
+	TransferId        string `json:"transferId,omitempty" bson:"transferId,omitempty"`
+	TransferAccountId string `json:"transferAccountId,omitempty" bson:"transferAccountId,omitempty"`
+	TransferDirection string `json:"transferDirection,omitempty" bson:"transferDirection,omitempty"`
+	// Status is pending, cleared or reconciled. Reconciled transactions
+	// belong to ReconciliationId and are locked against edits.
+	Status           string `json:"status" bson:"status"`
+	ReconciliationId string `json:"reconciliationId,omitempty" bson:"reconciliationId,omitempty"`
+	// Splits divide Amount across categories. A split transaction has no
+	// CategoryId of its own and its split amounts add up to Amount.
+	Splits []Split `json:"splits,omitempty" bson:"splits,omitempty"`
+	// RecurringRuleId is the rule that posted the transaction, if any.
+	RecurringRuleId string `json:"recurringRuleId,omitempty" bson:"recurringRuleId,omitempty"`
+}
+
+// Split is the part of a transaction that belongs to one category.
+type Split struct {
+	CategoryId string      `json:"categoryId" bson:"categoryId"`
+	Amount     money.Money `json:"amount" bson:"amount"`
+	Memo       string      `json:"memo" bson:"memo"`
+}
+
+// Transaction types. Only income and expense count towards totals.
+const (
+	TransactionIncome   = "Income"
+	TransactionExpense  = "Expense"
+	TransactionTransfer = "Transfer"
+)
+
+// Transfer leg directions.
+const (
+	TransferOut = "out"
+	TransferIn  = "in"
+)
+
+// Transaction statuses.
+const (
+	StatusPending    = "pending"
+	StatusCleared    = "cleared"
+	StatusReconciled = "reconciled"
+)
+
+// IsLocked reports whether t has been reconciled and must not change.
+func (t Transaction) IsLocked() bool {
+	return t.Status == StatusReconciled
+}
+
+// CategoryAmounts returns how t's amount is divided across categories: its
+// splits, or the whole amount under CategoryId when it is not split.
+func (t Transaction) CategoryAmounts() []Split {
+	if len(t.Splits) > 0 {
+		return t.Splits
+	}
+	return []Split{{CategoryId: t.CategoryId, Amount: t.Amount}}
+}
+
+// CategoryAmount is the part of t's amount that belongs to categoryId.
+func (t Transaction) CategoryAmount(categoryId string) (money.Money, error) {
+	total := money.New(0, t.Amount.Currency)
+	var err error
+	for _, split := range t.CategoryAmounts() {
+		if split.CategoryId == categoryId {
+			if total, err = total.Add(split.Amount); err != nil {
+				return total, err
+			}
+		}
+	}
+	return total, nil
+}
+
+// IsTransfer reports whether t is one leg of a transfer.
+func (t Transaction) IsTransfer() bool {
+	return t.TransferId != ""
 }
 
 type Category struct {
@@ -47,12 +123,18 @@ type Account struct {
 	Title     string             `json:"title" bson:"title"`
 	Icon      string             `json:"imageUrl" bson:"imageUrl"`
 	UserId    string             `json:"userId" bson:"userId"`
+	Currency  string             `json:"currency" bson:"currency"`
 	IsDefault bool               `json:"isDefault" bson:"isDefault"`
 	CreatedAt time.Time          `json:"createdAt" bson:"createdAt"`
 	UpdatedAt time.Time          `json:"updatedAt" bson:"updatedAt"`
 	DeletedAt time.Time          `json:"deletedAt" bson:"deletedAt"`
 	IsDeleted bool               `json:"isDeleted" bson:"isDeleted"`
 	IsActive  bool               `json:"isActive" bson:"isActive"`
+	// OpeningBalance is what the account held at the start of OpeningDate;
+	// only transactions from then on change its balance. A zero
+	// OpeningDate counts every transaction.
+	OpeningBalance money.Money `json:"openingBalance" bson:"openingBalance"`
+	OpeningDate    time.Time   `json:"openingDate" bson:"openingDate"`
 }
 
 type User struct {
@@ -68,4 +150,324 @@ type User struct {
 	IsDeleted  bool               `json:"isDeleted" bson:"isDeleted"`
 	IsActive   bool               `json:"isActive" bson:"isActive"`
 	IsVerified bool               `json:"isVerified" bson:"isVerified"`
+	Roles      []string           `json:"roles" bson:"roles"`
+	// TOTP second factor. The secret is stored while enrollment is pending
+	// and only takes effect once TOTPEnabled is set.
+	TOTPSecret         string   `json:"-" bson:"totpSecret"`
+	TOTPEnabled        bool     `json:"totpEnabled" bson:"totpEnabled"`
+	TOTPLastStep       int64    `json:"-" bson:"totpLastStep"`
+	RecoveryCodeHashes []string `json:"-" bson:"recoveryCodeHashes"`
+	// Identities are the external OpenID Connect accounts linked to this
+	// user.
+	Identities []UserIdentity `json:"identities" bson:"identities,omitempty"`
+	// BaseCurrency is the ISO 4217 code summaries are converted into. Empty
+	// means the server default.
+	BaseCurrency string `json:"baseCurrency" bson:"baseCurrency"`
+}
+
+type UserIdentity struct {
+	Provider string    `json:"provider" bson:"provider"`
+	Subject  string    `json:"subject" bson:"subject"`
+	Email    string    `json:"email" bson:"email"`
+	LinkedAt time.Time `json:"linkedAt" bson:"linkedAt"`
+}
+
+const RoleAdmin = "admin"
+
+type Session struct {
+	Id                primitive.ObjectID `json:"id" bson:"_id"`
+	UserId            string             `json:"userId" bson:"userId"`
+	RefreshTokenHash  string             `json:"-" bson:"refreshTokenHash"`
+	PreviousTokenHash string             `json:"-" bson:"previousTokenHash"`
+	UserAgent         string             `json:"userAgent" bson:"userAgent"`
+	Ip                string             `json:"ip" bson:"ip"`
+	CreatedAt         time.Time          `json:"createdAt" bson:"createdAt"`
+	LastSeenAt        time.Time          `json:"lastSeenAt" bson:"lastSeenAt"`
+	ExpiresAt         time.Time          `json:"expiresAt" bson:"expiresAt"`
+	RevokedAt         time.Time          `json:"revokedAt" bson:"revokedAt"`
+	IsRevoked         bool               `json:"isRevoked" bson:"isRevoked"`
+}
+
+// IsActive reports whether the session can still be refreshed at now.
+func (s Session) IsActive(now time.Time) bool {
+	return !s.IsRevoked && now.Before(s.ExpiresAt)
+}
+
+func (u User) HasRole(role string) bool {
+	for _, r := range u.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+type PasswordReset struct {
+	Id        primitive.ObjectID `json:"id" bson:"_id"`
+	UserId    string             `json:"userId" bson:"userId"`
+	TokenHash string             `json:"-" bson:"tokenHash"`
+	CreatedAt time.Time          `json:"createdAt" bson:"createdAt"`
+	ExpiresAt time.Time          `json:"expiresAt" bson:"expiresAt"`
+	UsedAt    time.Time          `json:"usedAt" bson:"usedAt"`
+	IsUsed    bool               `json:"isUsed" bson:"isUsed"`
+}
+
+// LoginAttempt counts recent failed logins for a throttle key such as an
+// account or a client IP.
+type LoginAttempt struct {
+	Key           string    `json:"key" bson:"_id"`
+	Failures      int       `json:"failures" bson:"failures"`
+	LastFailureAt time.Time `json:"lastFailureAt" bson:"lastFailureAt"`
+	LockedUntil   time.Time `json:"lockedUntil" bson:"lockedUntil"`
+}
+
+// Revision is one stored version of a transaction, account or category.
+// Snapshot holds the document as it was stored after Operation.
+type Revision struct {
+	Id         primitive.ObjectID `json:"id" bson:"_id"`
+	EntityType string             `json:"entityType" bson:"entityType"`
+	EntityId   string             `json:"entityId" bson:"entityId"`
+	UserId     string             `json:"userId" bson:"userId"`
+	Version    int                `json:"version" bson:"version"`
+	Operation  string             `json:"operation" bson:"operation"`
+	Snapshot   bson.M             `json:"snapshot" bson:"snapshot"`
+	ActorId    string             `json:"actorId" bson:"actorId"`
+	CreatedAt  time.Time          `json:"createdAt" bson:"createdAt"`
+}
+
+// Revision operations. RevisionInitial records the state a document was in
+// before history was kept for it.
+const (
+	RevisionInitial = "initial"
+	RevisionCreate  = "create"
+	RevisionUpdate  = "update"
+	RevisionDelete  = "delete"
+	RevisionRevert  = "revert"
+	RevisionRestore = "restore"
+)
+
+type AuditEvent struct {
+	Id         primitive.ObjectID     `json:"id" bson:"_id"`
+	Action     string                 `json:"action" bson:"action"`
+	ActorId    string                 `json:"actorId" bson:"actorId"`
+	TargetType string                 `json:"targetType" bson:"targetType"`
+	TargetId   string                 `json:"targetId" bson:"targetId"`
+	Ip         string                 `json:"ip" bson:"ip"`
+	UserAgent  string                 `json:"userAgent" bson:"userAgent"`
+	Details    map[string]interface{} `json:"details,omitempty" bson:"details,omitempty"`
+	// Changes holds the fields that differ between the target's state
+	// before and after the event.
+	Changes   map[string]AuditChange `json:"changes,omitempty" bson:"changes,omitempty"`
+	CreatedAt time.Time              `json:"createdAt" bson:"createdAt"`
+}
+
+type AuditChange struct {
+	Before interface{} `json:"before" bson:"before"`
+	After  interface{} `json:"after" bson:"after"`
+}
+
+// Audit actions.
+const (
+	AuditLoginSuccess   = "login.success"
+	AuditLoginFailure   = "login.failure"
+	AuditLoginLockout   = "login.lockout"
+	AuditLogout         = "logout"
+	AuditPasswordChange = "password.change"
+
+	AuditUserDelete  = "user.delete"
+	AuditUserRestore = "user.restore"
+
+	AuditTransactionCreate  = "transaction.create"
+	AuditTransactionUpdate  = "transaction.update"
+	AuditTransactionDelete  = "transaction.delete"
+	AuditTransactionRevert  = "transaction.revert"
+	AuditTransactionRestore = "transaction.restore"
+	AuditAccountCreate      = "account.create"
+	AuditAccountUpdate      = "account.update"
+	AuditAccountDelete      = "account.delete"
+	AuditAccountRestore     = "account.restore"
+	AuditCategoryCreate     = "category.create"
+	AuditCategoryUpdate     = "category.update"
+	AuditCategoryDelete     = "category.delete"
+	AuditCategoryRestore    = "category.restore"
+
+	AuditTransactionUnlock    = "transaction.unlock"
+	AuditReconciliationStart  = "reconciliation.start"
+	AuditReconciliationFinish = "reconciliation.finish"
+	AuditReconciliationCancel = "reconciliation.cancel"
+)
+
+// Scopes an API key can be granted. Session logins implicitly hold all of them.
+const (
+	ScopeTransactionsRead  = "transactions:read"
+	ScopeTransactionsWrite = "transactions:write"
+	ScopeCategoriesRead    = "categories:read"
+	ScopeCategoriesWrite   = "categories:write"
+	ScopeAccountsRead      = "accounts:read"
+	ScopeAccountsWrite     = "accounts:write"
+	ScopeReportsRead       = "reports:read"
+	ScopeBudgetsRead       = "budgets:read"
+	ScopeBudgetsWrite      = "budgets:write"
+	ScopeProfileRead       = "profile:read"
+	ScopeProfileWrite      = "profile:write"
+)
+
+var Scopes = []string{
+	ScopeTransactionsRead,
+	ScopeTransactionsWrite,
+	ScopeCategoriesRead,
+	ScopeCategoriesWrite,
+	ScopeAccountsRead,
+	ScopeAccountsWrite,
+	ScopeReportsRead,
+	ScopeBudgetsRead,
+	ScopeBudgetsWrite,
+	ScopeProfileRead,
+	ScopeProfileWrite,
+}
+
+func IsValidScope(scope string) bool {
+	for _, s := range Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+type APIKey struct {
+	Id         primitive.ObjectID `json:"id" bson:"_id"`
+	UserId     string             `json:"userId" bson:"userId"`
+	Name       string             `json:"name" bson:"name"`
+	Prefix     string             `json:"prefix" bson:"prefix"`
+	KeyHash    string             `json:"-" bson:"keyHash"`
+	Scopes     []string           `json:"scopes" bson:"scopes"`
+	ExpiresAt  time.Time          `json:"expiresAt" bson:"expiresAt"`
+	LastUsedAt time.Time          `json:"lastUsedAt" bson:"lastUsedAt"`
+	CreatedAt  time.Time          `json:"createdAt" bson:"createdAt"`
+	RevokedAt  time.Time          `json:"revokedAt" bson:"revokedAt"`
+	IsRevoked  bool               `json:"isRevoked" bson:"isRevoked"`
+}
+
+// IsActive reports whether the key can be used at now. A zero ExpiresAt
+// means the key never expires.
+func (k APIKey) IsActive(now time.Time) bool {
+	return !k.IsRevoked && (k.ExpiresAt.IsZero() || now.Before(k.ExpiresAt))
+}
+
+// ExchangeRate is the price of one unit of Base in Quote on Date. Rate is an
+// exact decimal string; Date is midnight UTC.
+type ExchangeRate struct {
+	Id        primitive.ObjectID `json:"id" bson:"_id"`
+	Base      string             `json:"base" bson:"base"`
+	Quote     string             `json:"quote" bson:"quote"`
+	Rate      string             `json:"rate" bson:"rate"`
+	Date      time.Time          `json:"date" bson:"date"`
+	Source    string             `json:"source" bson:"source"`
+	UpdatedAt time.Time          `json:"updatedAt" bson:"updatedAt"`
+}
+
+// Exchange rate sources.
+const (
+	RateSourceManual = "manual"
+	RateSourceImport = "import"
+)
+
+// Reconciliation checks an account against a bank statement. While open,
+// TransactionIds are the transactions ticked off so far; finishing marks
+// them reconciled and records the cleared balance.
+type Reconciliation struct {
+	Id               primitive.ObjectID `json:"id" bson:"_id"`
+	AccountId        string             `json:"accountId" bson:"accountId"`
+	UserId           string             `json:"userId" bson:"userId"`
+	StatementDate    time.Time          `json:"statementDate" bson:"statementDate"`
+	StatementBalance money.Money        `json:"statementBalance" bson:"statementBalance"`
+	TransactionIds   []string           `json:"transactionIds" bson:"transactionIds"`
+	ClearedBalance   money.Money        `json:"clearedBalance" bson:"clearedBalance"`
+	Status           string             `json:"status" bson:"status"`
+	CreatedAt        time.Time          `json:"createdAt" bson:"createdAt"`
+	FinishedAt       time.Time          `json:"finishedAt" bson:"finishedAt"`
+}
+
+// Reconciliation states.
+const (
+	ReconciliationOpen     = "open"
+	ReconciliationFinished = "finished"
+)
+
+// RecurringRule posts a transaction built from Template on every day
+// Schedule, an RRULE such as "FREQ=MONTHLY;BYMONTHDAY=1", fires from
+// StartDate on. PostedThrough is the last day already posted, so days missed
+// while the server was down are caught up.
+type RecurringRule struct {
+	Id            primitive.ObjectID   `json:"id" bson:"_id"`
+	UserId        string               `json:"userId" bson:"userId"`
+	Name          string               `json:"name" bson:"name"`
+	Schedule      string               `json:"schedule" bson:"schedule"`
+	StartDate     time.Time            `json:"startDate" bson:"startDate"`
+	Template      RecurringTemplate    `json:"template" bson:"template"`
+	Exceptions    []RecurringException `json:"exceptions" bson:"exceptions"`
+	PostedThrough time.Time            `json:"postedThrough" bson:"postedThrough"`
+	LastError     string               `json:"lastError,omitempty" bson:"lastError,omitempty"`
+	IsActive      bool                 `json:"isActive" bson:"isActive"`
+	CreatedAt     time.Time            `json:"createdAt" bson:"createdAt"`
+	UpdatedAt     time.Time            `json:"updatedAt" bson:"updatedAt"`
+}
+
+// RecurringTemplate is the transaction a recurring rule posts.
+type RecurringTemplate struct {
+	Title      string      `json:"title" bson:"title"`
+	Amount     money.Money `json:"amount" bson:"amount"`
+	CategoryId string      `json:"categoryId" bson:"categoryId"`
+	Type       string      `json:"type" bson:"type"`
+	ImageUrl   string      `json:"imageUrl" bson:"imageUrl"`
+	AccountId  string      `json:"accountId" bson:"accountId"`
+	Splits     []Split     `json:"splits,omitempty" bson:"splits,omitempty"`
+}
+
+// RecurringException changes a single occurrence of a rule. Skip drops it;
+// otherwise Template is posted instead of the rule's template.
+type RecurringException struct {
+	Date     time.Time          `json:"date" bson:"date"`
+	Skip     bool               `json:"skip" bson:"skip"`
+	Template *RecurringTemplate `json:"template,omitempty" bson:"template,omitempty"`
+}
+
+// Exception returns the exception for the occurrence on day, if any.
+func (r RecurringRule) Exception(day time.Time) *RecurringException {
+	for i := range r.Exceptions {
+		if r.Exceptions[i].Date.Equal(day) {
+			return &r.Exceptions[i]
+		}
+	}
+	return nil
+}
+
+// Budget plans spending in a category for one month, written "2006-01".
+// Amount is in the user's base currency. With Rollover set, whatever was
+// left of the previous month's budget, or overspent, carries into this one.
+type Budget struct {
+	Id         primitive.ObjectID `json:"id" bson:"_id"`
+	UserId     string             `json:"userId" bson:"userId"`
+	CategoryId string             `json:"categoryId" bson:"categoryId"`
+	Month      string             `json:"month" bson:"month"`
+	Amount     money.Money        `json:"amount" bson:"amount"`
+	Rollover   bool               `json:"rollover" bson:"rollover"`
+	CreatedAt  time.Time          `json:"createdAt" bson:"createdAt"`
+	UpdatedAt  time.Time          `json:"updatedAt" bson:"updatedAt"`
+}
+
+// EnvelopeMove moves Amount, in the user's base currency, between budget
+// envelopes in Month, written "2006-01". An empty category is the ready to
+// assign pool, so assigning money is a move out of the pool and returning
+// it is a move into it.
+type EnvelopeMove struct {
+	Id             primitive.ObjectID `json:"id" bson:"_id"`
+	UserId         string             `json:"userId" bson:"userId"`
+	Month          string             `json:"month" bson:"month"`
+	FromCategoryId string             `json:"fromCategoryId" bson:"fromCategoryId"`
+	ToCategoryId   string             `json:"toCategoryId" bson:"toCategoryId"`
+	Amount         money.Money        `json:"amount" bson:"amount"`
+	Note           string             `json:"note" bson:"note"`
+	CreatedAt      time.Time          `json:"createdAt" bson:"createdAt"`
 }
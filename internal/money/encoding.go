@@ -0,0 +1,127 @@
+package money
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+)
+
+// jsonMoney is the wire form: the amount as an exact decimal string.
+type jsonMoney struct {
+	Amount   string `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+// MarshalJSON encodes m as {"amount":"10.50","currency":"INR"}.
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonMoney{Amount: m.Decimal(), Currency: m.Currency})
+}
+
+// UnmarshalJSON accepts {"amount":"10.50","currency":"INR"}, with the amount
+// as a string or a number. A bare number or string, as older clients send,
+// is kept until WithDefaultCurrency supplies the currency; so is an object
+// without a currency. Numbers are read from their literal text, never
+// through a float.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		*m = Money{}
+		return nil
+	}
+
+	var raw struct {
+		Amount   json.RawMessage `json:"amount"`
+		Currency string          `json:"currency"`
+	}
+	amount := data
+	if len(data) > 0 && data[0] == '{' {
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return err
+		}
+		amount = raw.Amount
+	}
+
+	decimal, err := decimalText(amount)
+	if err != nil {
+		return err
+	}
+	if raw.Currency == "" {
+		if _, err := parseMinor(decimal, 0); err != nil && !errors.Is(err, ErrOverflow) {
+			return err
+		}
+		*m = Money{pending: decimal}
+		return nil
+	}
+	parsed, err := Parse(decimal, raw.Currency)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+func decimalText(data []byte) (string, error) {
+	if len(data) == 0 {
+		return "", ErrInvalidAmount
+	}
+	if data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return "", err
+		}
+		return s, nil
+	}
+	var n json.Number
+	if err := json.Unmarshal(data, &n); err != nil {
+		return "", ErrInvalidAmount
+	}
+	// Exponent notation is not worth supporting for money.
+	if strings.ContainsAny(n.String(), "eE") {
+		return "", ErrInvalidAmount
+	}
+	return n.String(), nil
+}
+
+// bsonMoney is the stored form: minor units and the currency.
+type bsonMoney struct {
+	Amount   int64  `bson:"amount"`
+	Currency string `bson:"currency"`
+}
+
+// MarshalBSONValue stores m as an embedded {amount, currency} document with
+// the amount in minor units.
+func (m Money) MarshalBSONValue() (bsontype.Type, []byte, error) {
+	if m.Currency == "" && m.pending != "" {
+		return 0, nil, fmt.Errorf("money: amount %q has no currency", m.pending)
+	}
+	data, err := bson.Marshal(bsonMoney{Amount: m.Amount, Currency: m.Currency})
+	return bson.TypeEmbeddedDocument, data, err
+}
+
+// UnmarshalBSONValue reads the {amount, currency} document. Plain numbers
+// written before amounts had a currency are read as a pending amount; run
+// cmd/migrate to convert them.
+func (m *Money) UnmarshalBSONValue(t bsontype.Type, data []byte) error {
+	raw := bson.RawValue{Type: t, Value: data}
+	switch t {
+	case bson.TypeEmbeddedDocument:
+		var stored bsonMoney
+		if err := raw.Unmarshal(&stored); err != nil {
+			return err
+		}
+		*m = New(stored.Amount, stored.Currency)
+	case bson.TypeInt32, bson.TypeInt64:
+		*m = Money{pending: strconv.FormatInt(raw.AsInt64(), 10)}
+	case bson.TypeNull, bson.TypeUndefined:
+		*m = Money{}
+	default:
+		return fmt.Errorf("money: cannot decode BSON %s", t)
+	}
+	return nil
+}
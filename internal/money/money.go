@@ -0,0 +1,225 @@
+// Package money represents amounts exactly as integer minor units (paise,
+// cents) together with an ISO 4217 currency code.
+package money
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrCurrencyMismatch = errors.New("money: currencies do not match")
+	ErrInvalidCurrency  = errors.New("money: invalid currency code")
+	ErrInvalidAmount    = errors.New("money: invalid amount")
+	ErrOverflow         = errors.New("money: amount out of range")
+)
+
+// exponents lists the ISO 4217 currencies whose minor unit is not 1/100.
+var exponents = map[string]int{
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0,
+	"KRW": 0, "PYG": 0, "RWF": 0, "UGX": 0, "UYI": 0, "VND": 0, "VUV": 0,
+	"XAF": 0, "XOF": 0, "XPF": 0,
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+	"CLF": 4, "UYW": 4,
+}
+
+// Exponent returns the number of decimal places of a currency's minor unit.
+func Exponent(currency string) int {
+	if e, ok := exponents[currency]; ok {
+		return e
+	}
+	return 2
+}
+
+// ValidCurrency reports whether code looks like an ISO 4217 code: three
+// upper case letters.
+func ValidCurrency(code string) bool {
+	if len(code) != 3 {
+		return false
+	}
+	for _, c := range code {
+		if c < 'A' || c > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+// NormalizeCurrency upper cases and validates a currency code.
+func NormalizeCurrency(code string) (string, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if !ValidCurrency(code) {
+		return "", ErrInvalidCurrency
+	}
+	return code, nil
+}
+
+// Money is an exact amount in a currency's minor units.
+type Money struct {
+	Amount   int64
+	Currency string
+
+	// pending holds a decimal decoded without a currency. It is rounded
+	// once WithDefaultCurrency supplies one.
+	pending string
+}
+
+// New returns minor units of currency.
+func New(minor int64, currency string) Money {
+	return Money{Amount: minor, Currency: currency}
+}
+
+// FromMajor returns whole units of currency, e.g. FromMajor(10, "INR") is
+// ₹10.00.
+func FromMajor(major int64, currency string) (Money, error) {
+	minor, ok := mulPow10(major, Exponent(currency))
+	if !ok {
+		return Money{}, ErrOverflow
+	}
+	return New(minor, currency), nil
+}
+
+// Parse reads a decimal string such as "10.50" or "-3" as an amount of
+// currency. Digits beyond the currency's exponent are rounded half away from
+// zero, so "10.505" INR is 10.51.
+func Parse(s, currency string) (Money, error) {
+	currency, err := NormalizeCurrency(currency)
+	if err != nil {
+		return Money{}, err
+	}
+	minor, err := parseMinor(strings.TrimSpace(s), Exponent(currency))
+	if err != nil {
+		return Money{}, err
+	}
+	return New(minor, currency), nil
+}
+
+func parseMinor(s string, exponent int) (int64, error) {
+	negative := false
+	switch {
+	case strings.HasPrefix(s, "-"):
+		negative, s = true, s[1:]
+	case strings.HasPrefix(s, "+"):
+		s = s[1:]
+	}
+	whole, frac, _ := strings.Cut(s, ".")
+	if whole == "" && frac == "" || !digits(whole) || !digits(frac) {
+		return 0, ErrInvalidAmount
+	}
+
+	// Keep exponent fraction digits and round on the rest.
+	roundUp := false
+	if len(frac) > exponent {
+		rest := frac[exponent:]
+		roundUp = rest[0] >= '5'
+		frac = frac[:exponent]
+	}
+	frac += strings.Repeat("0", exponent-len(frac))
+
+	var minor int64
+	for _, c := range whole + frac {
+		d := int64(c - '0')
+		if minor > (math.MaxInt64-d)/10 {
+			return 0, ErrOverflow
+		}
+		minor = minor*10 + d
+	}
+	if roundUp {
+		if minor == math.MaxInt64 {
+			return 0, ErrOverflow
+		}
+		minor++
+	}
+	if negative {
+		minor = -minor
+	}
+	return minor, nil
+}
+
+func digits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func mulPow10(n int64, exponent int) (int64, bool) {
+	for i := 0; i < exponent; i++ {
+		if n > math.MaxInt64/10 || n < math.MinInt64/10 {
+			return 0, false
+		}
+		n *= 10
+	}
+	return n, true
+}
+
+// WithDefaultCurrency fills in currency for an amount that was decoded
+// without one, rounding it to the currency's exponent. Amounts that already
+// have a currency are returned unchanged.
+func (m Money) WithDefaultCurrency(currency string) (Money, error) {
+	if m.Currency != "" {
+		return m, nil
+	}
+	if m.pending == "" {
+		return Money{Amount: m.Amount, Currency: currency}, nil
+	}
+	return Parse(m.pending, currency)
+}
+
+// Decimal formats the amount without its currency, e.g. "10.50".
+func (m Money) Decimal() string {
+	if m.Currency == "" && m.pending != "" {
+		return m.pending
+	}
+	exponent := Exponent(m.Currency)
+
+	// Work on the magnitude so math.MinInt64 formats correctly.
+	sign, magnitude := "", uint64(m.Amount)
+	if m.Amount < 0 {
+		sign, magnitude = "-", uint64(-(m.Amount+1))+1
+	}
+	s := strconv.FormatUint(magnitude, 10)
+	if exponent == 0 {
+		return sign + s
+	}
+	if len(s) <= exponent {
+		s = strings.Repeat("0", exponent-len(s)+1) + s
+	}
+	return sign + s[:len(s)-exponent] + "." + s[len(s)-exponent:]
+}
+
+func (m Money) String() string {
+	return m.Decimal() + " " + m.Currency
+}
+
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+// Add returns m + o. Both must be in the same currency.
+func (m Money) Add(o Money) (Money, error) {
+	if m.Currency != o.Currency {
+		return Money{}, ErrCurrencyMismatch
+	}
+	sum := m.Amount + o.Amount
+	if (o.Amount > 0 && sum < m.Amount) || (o.Amount < 0 && sum > m.Amount) {
+		return Money{}, ErrOverflow
+	}
+	return New(sum, m.Currency), nil
+}
+
+// Sub returns m - o. Both must be in the same currency.
+func (m Money) Sub(o Money) (Money, error) {
+	if o.Amount == math.MinInt64 {
+		return Money{}, ErrOverflow
+	}
+	return m.Add(o.Neg())
+}
+
+func (m Money) Neg() Money {
+	return New(-m.Amount, m.Currency)
+}
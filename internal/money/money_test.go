@@ -0,0 +1,144 @@
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in, currency string
+		want         Money
+		err          error
+	}{
+		{"10.50", "INR", New(1050, "INR"), nil},
+		{"10", "inr", New(1000, "INR"), nil},
+		{".5", "USD", New(50, "USD"), nil},
+		{"+3", "USD", New(300, "USD"), nil},
+		{"10.504", "INR", New(1050, "INR"), nil},
+		{"10.505", "INR", New(1051, "INR"), nil},
+		{"-10.505", "INR", New(-1051, "INR"), nil},
+		{"1.5", "JPY", New(2, "JPY"), nil},
+		{"1.0005", "BHD", New(1001, "BHD"), nil},
+		{"", "INR", Money{}, ErrInvalidAmount},
+		{"1.2.3", "INR", Money{}, ErrInvalidAmount},
+		{"1e3", "INR", Money{}, ErrInvalidAmount},
+		{"10", "US", Money{}, ErrInvalidCurrency},
+		{"92233720368547758.08", "INR", Money{}, ErrOverflow},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.in, tt.currency)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("Parse(%q, %q) error = %v, want %v", tt.in, tt.currency, err, tt.err)
+			continue
+		}
+		if err == nil && got != tt.want {
+			t.Errorf("Parse(%q, %q) = %v, want %v", tt.in, tt.currency, got, tt.want)
+		}
+	}
+}
+
+func TestDecimal(t *testing.T) {
+	tests := []struct {
+		in   Money
+		want string
+	}{
+		{New(5, "INR"), "0.05"},
+		{New(-1050, "INR"), "-10.50"},
+		{New(7, "JPY"), "7"},
+		{New(1001, "BHD"), "1.001"},
+		{New(math.MinInt64, "USD"), "-92233720368547758.08"},
+	}
+	for _, tt := range tests {
+		if got := tt.in.Decimal(); got != tt.want {
+			t.Errorf("%#v.Decimal() = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestArithmetic(t *testing.T) {
+	sum, err := New(1050, "INR").Add(New(-50, "INR"))
+	if err != nil || sum != New(1000, "INR") {
+		t.Errorf("Add = %v, %v, want 10.00 INR", sum, err)
+	}
+	if _, err := New(1, "INR").Add(New(1, "USD")); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Errorf("Add across currencies error = %v, want %v", err, ErrCurrencyMismatch)
+	}
+	if _, err := New(math.MaxInt64, "INR").Add(New(1, "INR")); !errors.Is(err, ErrOverflow) {
+		t.Errorf("Add past MaxInt64 error = %v, want %v", err, ErrOverflow)
+	}
+	if _, err := New(0, "INR").Sub(New(math.MinInt64, "INR")); !errors.Is(err, ErrOverflow) {
+		t.Errorf("Sub of MinInt64 error = %v, want %v", err, ErrOverflow)
+	}
+}
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		in       Money
+		currency string
+		rate     string
+		want     Money
+	}{
+		{New(100000, "INR"), "USD", "0.012", New(1200, "USD")},
+		{New(125, "USD"), "EUR", "0.1", New(13, "EUR")},
+		{New(-125, "USD"), "EUR", "0.1", New(-13, "EUR")},
+		{New(124, "USD"), "EUR", "0.1", New(12, "EUR")},
+		{New(150, "USD"), "JPY", "1.5", New(2, "JPY")},
+		{New(3, "JPY"), "BHD", "0.0025", New(8, "BHD")},
+		{New(500, "INR"), "INR", "2", New(500, "INR")},
+	}
+	for _, tt := range tests {
+		rate, err := ParseRate(tt.rate)
+		if err != nil {
+			t.Fatalf("ParseRate(%q): %v", tt.rate, err)
+		}
+		got, err := tt.in.Convert(tt.currency, rate)
+		if err != nil || got != tt.want {
+			t.Errorf("%v.Convert(%s, %s) = %v, %v, want %v", tt.in, tt.currency, tt.rate, got, err, tt.want)
+		}
+	}
+
+	for _, bad := range []string{"0", "-1", "1e3", "1/3", "abc"} {
+		if _, err := ParseRate(bad); !errors.Is(err, ErrInvalidRate) {
+			t.Errorf("ParseRate(%q) error = %v, want %v", bad, err, ErrInvalidRate)
+		}
+	}
+}
+
+func TestUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Money
+	}{
+		{`{"amount":"10.505","currency":"INR"}`, New(1051, "INR")},
+		{`{"amount":1.5,"currency":"JPY"}`, New(2, "JPY")},
+		{`10.505`, New(1051, "INR")},
+		{`"7.25"`, New(725, "INR")},
+		{`{"amount":"3"}`, New(300, "INR")},
+	}
+	for _, tt := range tests {
+		var m Money
+		if err := json.Unmarshal([]byte(tt.in), &m); err != nil {
+			t.Errorf("Unmarshal(%s): %v", tt.in, err)
+			continue
+		}
+		got, err := m.WithDefaultCurrency("INR")
+		if err != nil || got != tt.want {
+			t.Errorf("Unmarshal(%s) = %v, %v, want %v", tt.in, got, err, tt.want)
+		}
+	}
+
+	for _, bad := range []string{`"abc"`, `1e3`, `{"amount":"1","currency":"X"}`} {
+		var m Money
+		if err := json.Unmarshal([]byte(bad), &m); err == nil {
+			t.Errorf("Unmarshal(%s) succeeded, want an error", bad)
+		}
+	}
+
+	data, err := json.Marshal(New(-1050, "INR"))
+	if err != nil || string(data) != `{"amount":"-10.50","currency":"INR"}` {
+		t.Errorf("Marshal = %s, %v", data, err)
+	}
+}
@@ -0,0 +1,69 @@
+package money
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+)
+
+// ErrInvalidRate is returned for exchange rates that are not positive
+// decimals.
+var ErrInvalidRate = errors.New("money: invalid exchange rate")
+
+// ParseRate reads an exchange rate such as "1.0876". Rates are kept as exact
+// fractions so converting never goes through a float.
+func ParseRate(s string) (*big.Rat, error) {
+	s = strings.TrimSpace(s)
+	if strings.ContainsAny(s, "eE/") {
+		return nil, ErrInvalidRate
+	}
+	rate, ok := new(big.Rat).SetString(s)
+	if !ok || rate.Sign() <= 0 {
+		return nil, ErrInvalidRate
+	}
+	return rate, nil
+}
+
+// Convert returns m in currency, where rate is the price of one unit of m's
+// currency in currency. The result is rounded half away from zero to the
+// target currency's exponent.
+func (m Money) Convert(currency string, rate *big.Rat) (Money, error) {
+	if m.Currency == currency {
+		return m, nil
+	}
+	// minor units in currency = m.Amount * rate * 10^(to - from)
+	value := new(big.Rat).Mul(new(big.Rat).SetInt64(m.Amount), rate)
+	shift := Exponent(currency) - Exponent(m.Currency)
+	scale := new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(abs(shift))), nil))
+	if shift >= 0 {
+		value.Mul(value, scale)
+	} else {
+		value.Quo(value, scale)
+	}
+
+	minor := roundHalfAway(value)
+	if !minor.IsInt64() {
+		return Money{}, ErrOverflow
+	}
+	return New(minor.Int64(), currency), nil
+}
+
+func roundHalfAway(r *big.Rat) *big.Int {
+	num := new(big.Int).Abs(r.Num())
+	den := r.Denom()
+	q, rem := new(big.Int).QuoRem(num, den, new(big.Int))
+	if rem.Mul(rem, big.NewInt(2)).Cmp(den) >= 0 {
+		q.Add(q, big.NewInt(1))
+	}
+	if r.Sign() < 0 {
+		q.Neg(q)
+	}
+	return q
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
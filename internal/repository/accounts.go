@@ -0,0 +1,145 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/amrohan/expenso-go/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// AccountFilter narrows Find results. Zero fields are ignored.
+type AccountFilter struct {
+	UserId string
+	// DeletedOnly lists the trash instead; deleted documents are otherwise
+	// left out.
+	DeletedOnly bool
+}
+
+type AccountRepository interface {
+	Create(ctx context.Context, account *models.Account) error
+	FindById(ctx context.Context, id primitive.ObjectID) (*models.Account, error)
+	Find(ctx context.Context, filter AccountFilter) ([]models.Account, error)
+	Update(ctx context.Context, account *models.Account) error
+	// Delete moves a document to the trash; Restore takes it back out.
+	Delete(ctx context.Context, id primitive.ObjectID, at time.Time) error
+	Restore(ctx context.Context, id primitive.ObjectID) error
+	// Purge permanently removes documents deleted before cutoff.
+	Purge(ctx context.Context, cutoff time.Time) (int64, error)
+}
+
+func (f AccountFilter) bson() bson.M {
+	filter := bson.M{}
+	if f.UserId != "" {
+		filter["userId"] = f.UserId
+	}
+	return deletedFilter(filter, f.DeletedOnly)
+}
+
+func (f AccountFilter) match(c models.Account) bool {
+	return (f.UserId == "" || c.UserId == f.UserId) && c.IsDeleted == f.DeletedOnly
+}
+
+type mongoAccountRepository struct {
+	collection *mongo.Collection
+}
+
+func (r *mongoAccountRepository) Create(ctx context.Context, account *models.Account) error {
+	_, err := r.collection.InsertOne(ctx, account)
+	return mongoError(err)
+}
+
+func (r *mongoAccountRepository) FindById(ctx context.Context, id primitive.ObjectID) (*models.Account, error) {
+	var account models.Account
+	if err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&account); err != nil {
+		return nil, mongoError(err)
+	}
+	return &account, nil
+}
+
+func (r *mongoAccountRepository) Find(ctx context.Context, filter AccountFilter) ([]models.Account, error) {
+	cur, err := r.collection.Find(ctx, filter.bson())
+	if err != nil {
+		return nil, err
+	}
+	return decodeAll[models.Account](ctx, cur)
+}
+
+func (r *mongoAccountRepository) Update(ctx context.Context, account *models.Account) error {
+	res, err := r.collection.UpdateOne(ctx, bson.M{"_id": account.Id}, bson.M{"$set": account})
+	if err != nil {
+		return mongoError(err)
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *mongoAccountRepository) Delete(ctx context.Context, id primitive.ObjectID, at time.Time) error {
+	return softDelete(ctx, r.collection, id, at)
+}
+
+func (r *mongoAccountRepository) Restore(ctx context.Context, id primitive.ObjectID) error {
+	return restoreDeleted(ctx, r.collection, id)
+}
+
+func (r *mongoAccountRepository) Purge(ctx context.Context, cutoff time.Time) (int64, error) {
+	return purgeDeleted(ctx, r.collection, cutoff)
+}
+
+type memoryAccountRepository struct {
+	items *memoryCollection[models.Account]
+}
+
+func accountId(c models.Account) primitive.ObjectID { return c.Id }
+
+func (r *memoryAccountRepository) Create(ctx context.Context, account *models.Account) error {
+	return r.items.insert(*account)
+}
+
+func (r *memoryAccountRepository) FindById(ctx context.Context, id primitive.ObjectID) (*models.Account, error) {
+	account, err := r.items.get(id)
+	if err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+func (r *memoryAccountRepository) Find(ctx context.Context, filter AccountFilter) ([]models.Account, error) {
+	return r.items.find(filter.match), nil
+}
+
+func (r *memoryAccountRepository) Update(ctx context.Context, account *models.Account) error {
+	return r.items.replace(*account)
+}
+
+func (r *memoryAccountRepository) Delete(ctx context.Context, id primitive.ObjectID, at time.Time) error {
+	return r.items.modify(id, func(account *models.Account) error {
+		if account.IsDeleted {
+			return ErrNotFound
+		}
+		account.IsDeleted = true
+		account.DeletedAt = at
+		return nil
+	})
+}
+
+func (r *memoryAccountRepository) Restore(ctx context.Context, id primitive.ObjectID) error {
+	return r.items.modify(id, func(account *models.Account) error {
+		if !account.IsDeleted {
+			return ErrNotFound
+		}
+		account.IsDeleted = false
+		account.DeletedAt = time.Time{}
+		return nil
+	})
+}
+
+func (r *memoryAccountRepository) Purge(ctx context.Context, cutoff time.Time) (int64, error) {
+	return r.items.deleteWhere(func(account models.Account) bool {
+		return account.IsDeleted && account.DeletedAt.Before(cutoff)
+	}), nil
+}
@@ -0,0 +1,151 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/amrohan/expenso-go/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// APIKeyFilter narrows Find results. Zero fields are ignored.
+type APIKeyFilter struct {
+	UserId string
+}
+
+type APIKeyRepository interface {
+	Create(ctx context.Context, key *models.APIKey) error
+	FindById(ctx context.Context, id primitive.ObjectID) (*models.APIKey, error)
+	FindByHash(ctx context.Context, hash string) (*models.APIKey, error)
+	Find(ctx context.Context, filter APIKeyFilter) ([]models.APIKey, error)
+	// Touch records that a key was used at at. Revoked keys are left alone
+	// and give ErrNotFound.
+	Touch(ctx context.Context, id primitive.ObjectID, at time.Time) error
+	// Revoke revokes a key. A key that is already revoked keeps its original
+	// revokedAt.
+	Revoke(ctx context.Context, id primitive.ObjectID, at time.Time) error
+}
+
+func (f APIKeyFilter) bson() bson.M {
+	filter := bson.M{}
+	if f.UserId != "" {
+		filter["userId"] = f.UserId
+	}
+	return filter
+}
+
+func (f APIKeyFilter) match(k models.APIKey) bool {
+	return f.UserId == "" || k.UserId == f.UserId
+}
+
+type mongoAPIKeyRepository struct {
+	collection *mongo.Collection
+}
+
+func (r *mongoAPIKeyRepository) Create(ctx context.Context, key *models.APIKey) error {
+	_, err := r.collection.InsertOne(ctx, key)
+	return mongoError(err)
+}
+
+func (r *mongoAPIKeyRepository) findOne(ctx context.Context, filter interface{}) (*models.APIKey, error) {
+	var key models.APIKey
+	if err := r.collection.FindOne(ctx, filter).Decode(&key); err != nil {
+		return nil, mongoError(err)
+	}
+	return &key, nil
+}
+
+func (r *mongoAPIKeyRepository) FindById(ctx context.Context, id primitive.ObjectID) (*models.APIKey, error) {
+	return r.findOne(ctx, bson.M{"_id": id})
+}
+
+func (r *mongoAPIKeyRepository) FindByHash(ctx context.Context, hash string) (*models.APIKey, error) {
+	return r.findOne(ctx, bson.M{"keyHash": hash})
+}
+
+func (r *mongoAPIKeyRepository) Find(ctx context.Context, filter APIKeyFilter) ([]models.APIKey, error) {
+	cur, err := r.collection.Find(ctx, filter.bson())
+	if err != nil {
+		return nil, err
+	}
+	return decodeAll[models.APIKey](ctx, cur)
+}
+
+func (r *mongoAPIKeyRepository) Touch(ctx context.Context, id primitive.ObjectID, at time.Time) error {
+	res, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": id, "isRevoked": false},
+		bson.M{"$set": bson.M{"lastUsedAt": at}},
+	)
+	if err != nil {
+		return mongoError(err)
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *mongoAPIKeyRepository) Revoke(ctx context.Context, id primitive.ObjectID, at time.Time) error {
+	_, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": id, "isRevoked": false},
+		bson.M{"$set": bson.M{"isRevoked": true, "revokedAt": at}},
+	)
+	return mongoError(err)
+}
+
+type memoryAPIKeyRepository struct {
+	items *memoryCollection[models.APIKey]
+}
+
+func apiKeyId(k models.APIKey) primitive.ObjectID { return k.Id }
+
+func (r *memoryAPIKeyRepository) Create(ctx context.Context, key *models.APIKey) error {
+	return r.items.insert(*key)
+}
+
+func (r *memoryAPIKeyRepository) FindById(ctx context.Context, id primitive.ObjectID) (*models.APIKey, error) {
+	key, err := r.items.get(id)
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (r *memoryAPIKeyRepository) FindByHash(ctx context.Context, hash string) (*models.APIKey, error) {
+	key, err := r.items.findOne(func(k models.APIKey) bool { return k.KeyHash == hash })
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (r *memoryAPIKeyRepository) Find(ctx context.Context, filter APIKeyFilter) ([]models.APIKey, error) {
+	return r.items.find(filter.match), nil
+}
+
+func (r *memoryAPIKeyRepository) Touch(ctx context.Context, id primitive.ObjectID, at time.Time) error {
+	return r.items.modify(id, func(k *models.APIKey) error {
+		if k.IsRevoked {
+			return ErrNotFound
+		}
+		k.LastUsedAt = at
+		return nil
+	})
+}
+
+func (r *memoryAPIKeyRepository) Revoke(ctx context.Context, id primitive.ObjectID, at time.Time) error {
+	err := r.items.modify(id, func(k *models.APIKey) error {
+		if !k.IsRevoked {
+			k.IsRevoked = true
+			k.RevokedAt = at
+		}
+		return nil
+	})
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,44 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/amrohan/expenso-go/internal/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Recording use of a key loaded before it was revoked must not bring it
+// back.
+func TestAPIKeyTouchDoesNotReviveRevoked(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	start := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	key := &models.APIKey{Id: primitive.NewObjectID(), UserId: "user", KeyHash: "hash", CreatedAt: start}
+	if err := store.APIKeys.Create(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.APIKeys.Touch(ctx, key.Id, start.Add(time.Minute)); err != nil {
+		t.Fatalf("Touch: %v", err)
+	}
+	if err := store.APIKeys.Revoke(ctx, key.Id, start.Add(2*time.Minute)); err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+	if err := store.APIKeys.Touch(ctx, key.Id, start.Add(3*time.Minute)); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Touch after revoke = %v, want %v", err, ErrNotFound)
+	}
+	if err := store.APIKeys.Revoke(ctx, key.Id, start.Add(4*time.Minute)); err != nil {
+		t.Errorf("second Revoke: %v", err)
+	}
+
+	got, err := store.APIKeys.FindById(ctx, key.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.IsRevoked || !got.RevokedAt.Equal(start.Add(2*time.Minute)) || !got.LastUsedAt.Equal(start.Add(time.Minute)) {
+		t.Errorf("key = %+v, want revoked at the first revoke and last used before it", got)
+	}
+}
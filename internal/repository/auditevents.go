@@ -0,0 +1,124 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/amrohan/expenso-go/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// AuditEventFilter narrows an audit query. Empty fields match everything.
+type AuditEventFilter struct {
+	ActorId    string
+	Action     string
+	TargetType string
+	TargetId   string
+	From       time.Time
+	To         time.Time
+}
+
+// Page selects a slice of a result set. Pages are numbered from 1.
+type Page struct {
+	Number int
+	Size   int
+}
+
+func (p Page) skip() int {
+	return (p.Number - 1) * p.Size
+}
+
+// AuditEventRepository is append-only: events are never updated or removed
+// through it.
+type AuditEventRepository interface {
+	Create(ctx context.Context, event *models.AuditEvent) error
+	// Find returns one page of matching events, newest first, and the total
+	// number of matches.
+	Find(ctx context.Context, filter AuditEventFilter, page Page) ([]models.AuditEvent, int64, error)
+}
+
+func (f AuditEventFilter) bson() bson.M {
+	filter := bson.M{}
+	if f.ActorId != "" {
+		filter["actorId"] = f.ActorId
+	}
+	if f.Action != "" {
+		filter["action"] = f.Action
+	}
+	if f.TargetType != "" {
+		filter["targetType"] = f.TargetType
+	}
+	if f.TargetId != "" {
+		filter["targetId"] = f.TargetId
+	}
+	createdAt := bson.M{}
+	if !f.From.IsZero() {
+		createdAt["$gte"] = f.From
+	}
+	if !f.To.IsZero() {
+		createdAt["$lt"] = f.To
+	}
+	if len(createdAt) > 0 {
+		filter["createdAt"] = createdAt
+	}
+	return filter
+}
+
+func (f AuditEventFilter) match(e models.AuditEvent) bool {
+	return (f.ActorId == "" || e.ActorId == f.ActorId) &&
+		(f.Action == "" || e.Action == f.Action) &&
+		(f.TargetType == "" || e.TargetType == f.TargetType) &&
+		(f.TargetId == "" || e.TargetId == f.TargetId) &&
+		(f.From.IsZero() || !e.CreatedAt.Before(f.From)) &&
+		(f.To.IsZero() || e.CreatedAt.Before(f.To))
+}
+
+type mongoAuditEventRepository struct {
+	collection *mongo.Collection
+}
+
+func (r *mongoAuditEventRepository) Create(ctx context.Context, event *models.AuditEvent) error {
+	_, err := r.collection.InsertOne(ctx, event)
+	return mongoError(err)
+}
+
+func (r *mongoAuditEventRepository) Find(ctx context.Context, filter AuditEventFilter, page Page) ([]models.AuditEvent, int64, error) {
+	total, err := r.collection.CountDocuments(ctx, filter.bson())
+	if err != nil {
+		return nil, 0, err
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "_id", Value: -1}}).
+		SetSkip(int64(page.skip())).
+		SetLimit(int64(page.Size))
+	cur, err := r.collection.Find(ctx, filter.bson(), opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	events, err := decodeAll[models.AuditEvent](ctx, cur)
+	return events, total, err
+}
+
+type memoryAuditEventRepository struct {
+	items *memoryCollection[models.AuditEvent]
+}
+
+func auditEventId(e models.AuditEvent) primitive.ObjectID { return e.Id }
+
+func (r *memoryAuditEventRepository) Create(ctx context.Context, event *models.AuditEvent) error {
+	return r.items.insert(*event)
+}
+
+func (r *memoryAuditEventRepository) Find(ctx context.Context, filter AuditEventFilter, page Page) ([]models.AuditEvent, int64, error) {
+	matches := r.items.find(filter.match)
+	total := len(matches)
+
+	events := []models.AuditEvent{}
+	for i := total - 1 - page.skip(); i >= 0 && len(events) < page.Size; i-- {
+		events = append(events, matches[i])
+	}
+	return events, int64(total), nil
+}
@@ -0,0 +1,155 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/amrohan/expenso-go/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// BudgetFilter narrows Find results. Zero fields are ignored; months are
+// written "2006-01".
+type BudgetFilter struct {
+	UserId     string
+	CategoryId string
+	Month      string
+	Before     string // exclusive
+}
+
+// BudgetRepository keeps at most one budget per user, category and month.
+type BudgetRepository interface {
+	// Create returns ErrDuplicate when the category already has a budget
+	// for the month.
+	Create(ctx context.Context, budget *models.Budget) error
+	FindById(ctx context.Context, id primitive.ObjectID) (*models.Budget, error)
+	// Find returns matching budgets ordered by month.
+	Find(ctx context.Context, filter BudgetFilter) ([]models.Budget, error)
+	Update(ctx context.Context, budget *models.Budget) error
+	Delete(ctx context.Context, id primitive.ObjectID) error
+}
+
+func (f BudgetFilter) bson() bson.M {
+	filter := bson.M{}
+	if f.UserId != "" {
+		filter["userId"] = f.UserId
+	}
+	if f.CategoryId != "" {
+		filter["categoryId"] = f.CategoryId
+	}
+	month := bson.M{}
+	if f.Month != "" {
+		month["$eq"] = f.Month
+	}
+	if f.Before != "" {
+		month["$lt"] = f.Before
+	}
+	if len(month) > 0 {
+		filter["month"] = month
+	}
+	return filter
+}
+
+func (f BudgetFilter) match(b models.Budget) bool {
+	return (f.UserId == "" || b.UserId == f.UserId) &&
+		(f.CategoryId == "" || b.CategoryId == f.CategoryId) &&
+		(f.Month == "" || b.Month == f.Month) &&
+		(f.Before == "" || b.Month < f.Before)
+}
+
+type mongoBudgetRepository struct {
+	collection *mongo.Collection
+	indexOnce  sync.Once
+}
+
+// ensureIndex keeps one budget per category and month.
+func (r *mongoBudgetRepository) ensureIndex(ctx context.Context) {
+	r.indexOnce.Do(func() {
+		r.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+			Keys:    bson.D{{Key: "userId", Value: 1}, {Key: "categoryId", Value: 1}, {Key: "month", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		})
+	})
+}
+
+func (r *mongoBudgetRepository) Create(ctx context.Context, budget *models.Budget) error {
+	r.ensureIndex(ctx)
+	_, err := r.collection.InsertOne(ctx, budget)
+	return mongoError(err)
+}
+
+func (r *mongoBudgetRepository) FindById(ctx context.Context, id primitive.ObjectID) (*models.Budget, error) {
+	var budget models.Budget
+	if err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&budget); err != nil {
+		return nil, mongoError(err)
+	}
+	return &budget, nil
+}
+
+func (r *mongoBudgetRepository) Find(ctx context.Context, filter BudgetFilter) ([]models.Budget, error) {
+	cur, err := r.collection.Find(ctx, filter.bson(), options.Find().SetSort(bson.D{{Key: "month", Value: 1}, {Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	return decodeAll[models.Budget](ctx, cur)
+}
+
+func (r *mongoBudgetRepository) Update(ctx context.Context, budget *models.Budget) error {
+	res, err := r.collection.ReplaceOne(ctx, bson.M{"_id": budget.Id}, budget)
+	if err != nil {
+		return mongoError(err)
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *mongoBudgetRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	res, err := r.collection.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+type memoryBudgetRepository struct {
+	items *memoryCollection[models.Budget]
+}
+
+func budgetId(b models.Budget) primitive.ObjectID { return b.Id }
+
+func (r *memoryBudgetRepository) Create(ctx context.Context, budget *models.Budget) error {
+	return r.items.insertUnique(*budget, func(b models.Budget) bool {
+		return b.UserId == budget.UserId && b.CategoryId == budget.CategoryId && b.Month == budget.Month
+	})
+}
+
+func (r *memoryBudgetRepository) FindById(ctx context.Context, id primitive.ObjectID) (*models.Budget, error) {
+	budget, err := r.items.get(id)
+	if err != nil {
+		return nil, err
+	}
+	return &budget, nil
+}
+
+func (r *memoryBudgetRepository) Find(ctx context.Context, filter BudgetFilter) ([]models.Budget, error) {
+	budgets := r.items.find(filter.match)
+	sort.SliceStable(budgets, func(i, j int) bool { return budgets[i].Month < budgets[j].Month })
+	return budgets, nil
+}
+
+func (r *memoryBudgetRepository) Update(ctx context.Context, budget *models.Budget) error {
+	return r.items.replace(*budget)
+}
+
+func (r *memoryBudgetRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	return r.items.delete(id)
+}
@@ -0,0 +1,145 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/amrohan/expenso-go/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// CategoryFilter narrows Find results. Zero fields are ignored.
+type CategoryFilter struct {
+	UserId string
+	// DeletedOnly lists the trash instead; deleted documents are otherwise
+	// left out.
+	DeletedOnly bool
+}
+
+type CategoryRepository interface {
+	Create(ctx context.Context, category *models.Category) error
+	FindById(ctx context.Context, id primitive.ObjectID) (*models.Category, error)
+	Find(ctx context.Context, filter CategoryFilter) ([]models.Category, error)
+	Update(ctx context.Context, category *models.Category) error
+	// Delete moves a document to the trash; Restore takes it back out.
+	Delete(ctx context.Context, id primitive.ObjectID, at time.Time) error
+	Restore(ctx context.Context, id primitive.ObjectID) error
+	// Purge permanently removes documents deleted before cutoff.
+	Purge(ctx context.Context, cutoff time.Time) (int64, error)
+}
+
+func (f CategoryFilter) bson() bson.M {
+	filter := bson.M{}
+	if f.UserId != "" {
+		filter["userId"] = f.UserId
+	}
+	return deletedFilter(filter, f.DeletedOnly)
+}
+
+func (f CategoryFilter) match(c models.Category) bool {
+	return (f.UserId == "" || c.UserId == f.UserId) && c.IsDeleted == f.DeletedOnly
+}
+
+type mongoCategoryRepository struct {
+	collection *mongo.Collection
+}
+
+func (r *mongoCategoryRepository) Create(ctx context.Context, category *models.Category) error {
+	_, err := r.collection.InsertOne(ctx, category)
+	return mongoError(err)
+}
+
+func (r *mongoCategoryRepository) FindById(ctx context.Context, id primitive.ObjectID) (*models.Category, error) {
+	var category models.Category
+	if err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&category); err != nil {
+		return nil, mongoError(err)
+	}
+	return &category, nil
+}
+
+func (r *mongoCategoryRepository) Find(ctx context.Context, filter CategoryFilter) ([]models.Category, error) {
+	cur, err := r.collection.Find(ctx, filter.bson())
+	if err != nil {
+		return nil, err
+	}
+	return decodeAll[models.Category](ctx, cur)
+}
+
+func (r *mongoCategoryRepository) Update(ctx context.Context, category *models.Category) error {
+	res, err := r.collection.UpdateOne(ctx, bson.M{"_id": category.Id}, bson.M{"$set": category})
+	if err != nil {
+		return mongoError(err)
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *mongoCategoryRepository) Delete(ctx context.Context, id primitive.ObjectID, at time.Time) error {
+	return softDelete(ctx, r.collection, id, at)
+}
+
+func (r *mongoCategoryRepository) Restore(ctx context.Context, id primitive.ObjectID) error {
+	return restoreDeleted(ctx, r.collection, id)
+}
+
+func (r *mongoCategoryRepository) Purge(ctx context.Context, cutoff time.Time) (int64, error) {
+	return purgeDeleted(ctx, r.collection, cutoff)
+}
+
+type memoryCategoryRepository struct {
+	items *memoryCollection[models.Category]
+}
+
+func categoryId(c models.Category) primitive.ObjectID { return c.Id }
+
+func (r *memoryCategoryRepository) Create(ctx context.Context, category *models.Category) error {
+	return r.items.insert(*category)
+}
+
+func (r *memoryCategoryRepository) FindById(ctx context.Context, id primitive.ObjectID) (*models.Category, error) {
+	category, err := r.items.get(id)
+	if err != nil {
+		return nil, err
+	}
+	return &category, nil
+}
+
+func (r *memoryCategoryRepository) Find(ctx context.Context, filter CategoryFilter) ([]models.Category, error) {
+	return r.items.find(filter.match), nil
+}
+
+func (r *memoryCategoryRepository) Update(ctx context.Context, category *models.Category) error {
+	return r.items.replace(*category)
+}
+
+func (r *memoryCategoryRepository) Delete(ctx context.Context, id primitive.ObjectID, at time.Time) error {
+	return r.items.modify(id, func(category *models.Category) error {
+		if category.IsDeleted {
+			return ErrNotFound
+		}
+		category.IsDeleted = true
+		category.DeletedAt = at
+		return nil
+	})
+}
+
+func (r *memoryCategoryRepository) Restore(ctx context.Context, id primitive.ObjectID) error {
+	return r.items.modify(id, func(category *models.Category) error {
+		if !category.IsDeleted {
+			return ErrNotFound
+		}
+		category.IsDeleted = false
+		category.DeletedAt = time.Time{}
+		return nil
+	})
+}
+
+func (r *memoryCategoryRepository) Purge(ctx context.Context, cutoff time.Time) (int64, error) {
+	return r.items.deleteWhere(func(category models.Category) bool {
+		return category.IsDeleted && category.DeletedAt.Before(cutoff)
+	}), nil
+}
@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"context"
+	"sort"
+
+	"github.com/amrohan/expenso-go/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// EnvelopeMoveFilter narrows Find results. Zero fields are ignored; months
+// are written "2006-01".
+type EnvelopeMoveFilter struct {
+	UserId  string
+	Month   string
+	Through string // inclusive
+}
+
+type EnvelopeMoveRepository interface {
+	Create(ctx context.Context, move *models.EnvelopeMove) error
+	FindById(ctx context.Context, id primitive.ObjectID) (*models.EnvelopeMove, error)
+	// Find returns matching moves ordered by month, oldest first.
+	Find(ctx context.Context, filter EnvelopeMoveFilter) ([]models.EnvelopeMove, error)
+	Delete(ctx context.Context, id primitive.ObjectID) error
+}
+
+func (f EnvelopeMoveFilter) bson() bson.M {
+	filter := bson.M{}
+	if f.UserId != "" {
+		filter["userId"] = f.UserId
+	}
+	month := bson.M{}
+	if f.Month != "" {
+		month["$eq"] = f.Month
+	}
+	if f.Through != "" {
+		month["$lte"] = f.Through
+	}
+	if len(month) > 0 {
+		filter["month"] = month
+	}
+	return filter
+}
+
+func (f EnvelopeMoveFilter) match(m models.EnvelopeMove) bool {
+	return (f.UserId == "" || m.UserId == f.UserId) &&
+		(f.Month == "" || m.Month == f.Month) &&
+		(f.Through == "" || m.Month <= f.Through)
+}
+
+type mongoEnvelopeMoveRepository struct {
+	collection *mongo.Collection
+}
+
+func (r *mongoEnvelopeMoveRepository) Create(ctx context.Context, move *models.EnvelopeMove) error {
+	_, err := r.collection.InsertOne(ctx, move)
+	return err
+}
+
+func (r *mongoEnvelopeMoveRepository) FindById(ctx context.Context, id primitive.ObjectID) (*models.EnvelopeMove, error) {
+	var move models.EnvelopeMove
+	if err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&move); err != nil {
+		return nil, mongoError(err)
+	}
+	return &move, nil
+}
+
+func (r *mongoEnvelopeMoveRepository) Find(ctx context.Context, filter EnvelopeMoveFilter) ([]models.EnvelopeMove, error) {
+	cur, err := r.collection.Find(ctx, filter.bson(), options.Find().SetSort(bson.D{{Key: "month", Value: 1}, {Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	return decodeAll[models.EnvelopeMove](ctx, cur)
+}
+
+func (r *mongoEnvelopeMoveRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	res, err := r.collection.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+type memoryEnvelopeMoveRepository struct {
+	items *memoryCollection[models.EnvelopeMove]
+}
+
+func envelopeMoveId(m models.EnvelopeMove) primitive.ObjectID { return m.Id }
+
+func (r *memoryEnvelopeMoveRepository) Create(ctx context.Context, move *models.EnvelopeMove) error {
+	return r.items.insert(*move)
+}
+
+func (r *memoryEnvelopeMoveRepository) FindById(ctx context.Context, id primitive.ObjectID) (*models.EnvelopeMove, error) {
+	move, err := r.items.get(id)
+	if err != nil {
+		return nil, err
+	}
+	return &move, nil
+}
+
+func (r *memoryEnvelopeMoveRepository) Find(ctx context.Context, filter EnvelopeMoveFilter) ([]models.EnvelopeMove, error) {
+	moves := r.items.find(filter.match)
+	sort.SliceStable(moves, func(i, j int) bool {
+		if moves[i].Month != moves[j].Month {
+			return moves[i].Month < moves[j].Month
+		}
+		return moves[i].Id.Hex() < moves[j].Id.Hex()
+	})
+	return moves, nil
+}
+
+func (r *memoryEnvelopeMoveRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	return r.items.delete(id)
+}
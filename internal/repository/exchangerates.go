@@ -0,0 +1,207 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/amrohan/expenso-go/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ExchangeRateFilter narrows a rate query. Empty fields match everything.
+type ExchangeRateFilter struct {
+	Base  string
+	Quote string
+	From  time.Time
+	To    time.Time
+}
+
+// ExchangeRateRepository keeps at most one rate per currency pair and day.
+type ExchangeRateRepository interface {
+	// Put stores rates, replacing any existing rate for the same pair and
+	// day.
+	Put(ctx context.Context, rates []models.ExchangeRate) error
+	// Latest returns the most recent rate for the pair dated on or before
+	// on.
+	Latest(ctx context.Context, base, quote string, on time.Time) (*models.ExchangeRate, error)
+	// Find returns one page of matching rates, newest first, and the total
+	// number of matches.
+	Find(ctx context.Context, filter ExchangeRateFilter, page Page) ([]models.ExchangeRate, int64, error)
+	Delete(ctx context.Context, id primitive.ObjectID) error
+}
+
+func (f ExchangeRateFilter) bson() bson.M {
+	filter := bson.M{}
+	if f.Base != "" {
+		filter["base"] = f.Base
+	}
+	if f.Quote != "" {
+		filter["quote"] = f.Quote
+	}
+	date := bson.M{}
+	if !f.From.IsZero() {
+		date["$gte"] = f.From
+	}
+	if !f.To.IsZero() {
+		date["$lt"] = f.To
+	}
+	if len(date) > 0 {
+		filter["date"] = date
+	}
+	return filter
+}
+
+func (f ExchangeRateFilter) match(e models.ExchangeRate) bool {
+	return (f.Base == "" || e.Base == f.Base) &&
+		(f.Quote == "" || e.Quote == f.Quote) &&
+		(f.From.IsZero() || !e.Date.Before(f.From)) &&
+		(f.To.IsZero() || e.Date.Before(f.To))
+}
+
+type mongoExchangeRateRepository struct {
+	collection *mongo.Collection
+	indexOnce  sync.Once
+}
+
+// ensureIndex keeps one rate per pair and day and serves Latest.
+func (r *mongoExchangeRateRepository) ensureIndex(ctx context.Context) {
+	r.indexOnce.Do(func() {
+		r.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+			Keys:    bson.D{{Key: "base", Value: 1}, {Key: "quote", Value: 1}, {Key: "date", Value: -1}},
+			Options: options.Index().SetUnique(true),
+		})
+	})
+}
+
+func (r *mongoExchangeRateRepository) Put(ctx context.Context, rates []models.ExchangeRate) error {
+	if len(rates) == 0 {
+		return nil
+	}
+	r.ensureIndex(ctx)
+	writes := make([]mongo.WriteModel, 0, len(rates))
+	for _, rate := range rates {
+		writes = append(writes, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"base": rate.Base, "quote": rate.Quote, "date": rate.Date}).
+			SetUpdate(bson.M{
+				"$set":         bson.M{"rate": rate.Rate, "source": rate.Source, "updatedAt": rate.UpdatedAt},
+				"$setOnInsert": bson.M{"_id": rate.Id},
+			}).
+			SetUpsert(true))
+	}
+	_, err := r.collection.BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false))
+	return mongoError(err)
+}
+
+func (r *mongoExchangeRateRepository) Latest(ctx context.Context, base, quote string, on time.Time) (*models.ExchangeRate, error) {
+	var rate models.ExchangeRate
+	err := r.collection.FindOne(ctx,
+		bson.M{"base": base, "quote": quote, "date": bson.M{"$lte": on}},
+		options.FindOne().SetSort(bson.D{{Key: "date", Value: -1}}),
+	).Decode(&rate)
+	if err != nil {
+		return nil, mongoError(err)
+	}
+	return &rate, nil
+}
+
+func (r *mongoExchangeRateRepository) Find(ctx context.Context, filter ExchangeRateFilter, page Page) ([]models.ExchangeRate, int64, error) {
+	total, err := r.collection.CountDocuments(ctx, filter.bson())
+	if err != nil {
+		return nil, 0, err
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "date", Value: -1}, {Key: "base", Value: 1}, {Key: "quote", Value: 1}}).
+		SetSkip(int64(page.skip())).
+		SetLimit(int64(page.Size))
+	cur, err := r.collection.Find(ctx, filter.bson(), opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	rates, err := decodeAll[models.ExchangeRate](ctx, cur)
+	return rates, total, err
+}
+
+func (r *mongoExchangeRateRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	res, err := r.collection.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+type memoryExchangeRateRepository struct {
+	// mu makes Put's check-then-write atomic.
+	mu    sync.Mutex
+	items *memoryCollection[models.ExchangeRate]
+}
+
+func exchangeRateId(e models.ExchangeRate) primitive.ObjectID { return e.Id }
+
+func (r *memoryExchangeRateRepository) Put(ctx context.Context, rates []models.ExchangeRate) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, rate := range rates {
+		existing, err := r.items.findOne(func(e models.ExchangeRate) bool {
+			return e.Base == rate.Base && e.Quote == rate.Quote && e.Date.Equal(rate.Date)
+		})
+		if err == nil {
+			rate.Id = existing.Id
+			err = r.items.replace(rate)
+		} else {
+			err = r.items.insert(rate)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *memoryExchangeRateRepository) Latest(ctx context.Context, base, quote string, on time.Time) (*models.ExchangeRate, error) {
+	var latest *models.ExchangeRate
+	for _, rate := range r.items.find(func(e models.ExchangeRate) bool {
+		return e.Base == base && e.Quote == quote && !e.Date.After(on)
+	}) {
+		if latest == nil || rate.Date.After(latest.Date) {
+			rate := rate
+			latest = &rate
+		}
+	}
+	if latest == nil {
+		return nil, ErrNotFound
+	}
+	return latest, nil
+}
+
+func (r *memoryExchangeRateRepository) Find(ctx context.Context, filter ExchangeRateFilter, page Page) ([]models.ExchangeRate, int64, error) {
+	matches := r.items.find(filter.match)
+	sort.SliceStable(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		if !a.Date.Equal(b.Date) {
+			return a.Date.After(b.Date)
+		}
+		if a.Base != b.Base {
+			return a.Base < b.Base
+		}
+		return a.Quote < b.Quote
+	})
+
+	rates := []models.ExchangeRate{}
+	for i := page.skip(); i < len(matches) && len(rates) < page.Size; i++ {
+		rates = append(rates, matches[i])
+	}
+	return rates, int64(len(matches)), nil
+}
+
+func (r *memoryExchangeRateRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	return r.items.delete(id)
+}
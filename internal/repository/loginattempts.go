@@ -0,0 +1,128 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/amrohan/expenso-go/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// LoginAttemptRepository keeps failed login counters. The memory
+// implementation is enough for a single instance; use Mongo when several
+// instances share the load so they see the same counters.
+type LoginAttemptRepository interface {
+	Get(ctx context.Context, key string) (*models.LoginAttempt, error)
+	// RecordFailure counts a failure at the given time and returns the
+	// updated counter. Failures older than window are forgotten first.
+	RecordFailure(ctx context.Context, key string, at time.Time, window time.Duration) (*models.LoginAttempt, error)
+	Lock(ctx context.Context, key string, until time.Time) error
+	Reset(ctx context.Context, key string) error
+}
+
+type mongoLoginAttemptRepository struct {
+	collection *mongo.Collection
+}
+
+func (r *mongoLoginAttemptRepository) Get(ctx context.Context, key string) (*models.LoginAttempt, error) {
+	var attempt models.LoginAttempt
+	if err := r.collection.FindOne(ctx, bson.M{"_id": key}).Decode(&attempt); err != nil {
+		return nil, mongoError(err)
+	}
+	return &attempt, nil
+}
+
+func (r *mongoLoginAttemptRepository) RecordFailure(ctx context.Context, key string, at time.Time, window time.Duration) (*models.LoginAttempt, error) {
+	after := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	// Bump a counter that is still inside the window...
+	var attempt models.LoginAttempt
+	err := r.collection.FindOneAndUpdate(ctx,
+		bson.M{"_id": key, "lastFailureAt": bson.M{"$gt": at.Add(-window)}},
+		bson.M{"$inc": bson.M{"failures": 1}, "$set": bson.M{"lastFailureAt": at}},
+		after,
+	).Decode(&attempt)
+	if err == nil {
+		return &attempt, nil
+	}
+	if err != mongo.ErrNoDocuments {
+		return nil, err
+	}
+
+	// ...or start a fresh one, keeping any lock that is still running.
+	err = r.collection.FindOneAndUpdate(ctx,
+		bson.M{"_id": key},
+		bson.M{"$set": bson.M{"failures": 1, "lastFailureAt": at}},
+		after.SetUpsert(true),
+	).Decode(&attempt)
+	if err != nil {
+		return nil, mongoError(err)
+	}
+	return &attempt, nil
+}
+
+func (r *mongoLoginAttemptRepository) Lock(ctx context.Context, key string, until time.Time) error {
+	_, err := r.collection.UpdateOne(ctx, bson.M{"_id": key},
+		bson.M{"$set": bson.M{"lockedUntil": until}},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+func (r *mongoLoginAttemptRepository) Reset(ctx context.Context, key string) error {
+	_, err := r.collection.DeleteOne(ctx, bson.M{"_id": key})
+	return err
+}
+
+type memoryLoginAttemptRepository struct {
+	mu    sync.Mutex
+	items map[string]models.LoginAttempt
+}
+
+func (r *memoryLoginAttemptRepository) Get(ctx context.Context, key string) (*models.LoginAttempt, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	attempt, ok := r.items[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &attempt, nil
+}
+
+func (r *memoryLoginAttemptRepository) RecordFailure(ctx context.Context, key string, at time.Time, window time.Duration) (*models.LoginAttempt, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	attempt := r.items[key]
+	attempt.Key = key
+	if !attempt.LastFailureAt.After(at.Add(-window)) {
+		attempt.Failures = 0
+	}
+	attempt.Failures++
+	attempt.LastFailureAt = at
+	r.items[key] = attempt
+	return &attempt, nil
+}
+
+func (r *memoryLoginAttemptRepository) Lock(ctx context.Context, key string, until time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	attempt := r.items[key]
+	attempt.Key = key
+	attempt.LockedUntil = until
+	r.items[key] = attempt
+	return nil
+}
+
+func (r *memoryLoginAttemptRepository) Reset(ctx context.Context, key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.items, key)
+	return nil
+}
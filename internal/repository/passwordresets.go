@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/amrohan/expenso-go/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type PasswordResetRepository interface {
+	Create(ctx context.Context, reset *models.PasswordReset) error
+	FindByHash(ctx context.Context, hash string) (*models.PasswordReset, error)
+	// Consume marks the unused reset with hash as used at, provided it has
+	// not expired by then, and returns it. Only one caller can consume a
+	// reset; the rest get ErrNotFound.
+	Consume(ctx context.Context, hash string, at time.Time) (*models.PasswordReset, error)
+	// InvalidateAll marks every outstanding reset of a user as used.
+	InvalidateAll(ctx context.Context, userId string, at time.Time) error
+}
+
+type mongoPasswordResetRepository struct {
+	collection *mongo.Collection
+}
+
+func (r *mongoPasswordResetRepository) Create(ctx context.Context, reset *models.PasswordReset) error {
+	_, err := r.collection.InsertOne(ctx, reset)
+	return mongoError(err)
+}
+
+func (r *mongoPasswordResetRepository) FindByHash(ctx context.Context, hash string) (*models.PasswordReset, error) {
+	var reset models.PasswordReset
+	if err := r.collection.FindOne(ctx, bson.M{"tokenHash": hash}).Decode(&reset); err != nil {
+		return nil, mongoError(err)
+	}
+	return &reset, nil
+}
+
+func (r *mongoPasswordResetRepository) Consume(ctx context.Context, hash string, at time.Time) (*models.PasswordReset, error) {
+	var reset models.PasswordReset
+	err := r.collection.FindOneAndUpdate(ctx,
+		bson.M{"tokenHash": hash, "isUsed": false, "expiresAt": bson.M{"$gt": at}},
+		bson.M{"$set": bson.M{"isUsed": true, "usedAt": at}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&reset)
+	if err != nil {
+		return nil, mongoError(err)
+	}
+	return &reset, nil
+}
+
+func (r *mongoPasswordResetRepository) InvalidateAll(ctx context.Context, userId string, at time.Time) error {
+	_, err := r.collection.UpdateMany(ctx,
+		bson.M{"userId": userId, "isUsed": false},
+		bson.M{"$set": bson.M{"isUsed": true, "usedAt": at}},
+	)
+	return err
+}
+
+type memoryPasswordResetRepository struct {
+	items *memoryCollection[models.PasswordReset]
+}
+
+func passwordResetId(p models.PasswordReset) primitive.ObjectID { return p.Id }
+
+func (r *memoryPasswordResetRepository) Create(ctx context.Context, reset *models.PasswordReset) error {
+	return r.items.insert(*reset)
+}
+
+func (r *memoryPasswordResetRepository) FindByHash(ctx context.Context, hash string) (*models.PasswordReset, error) {
+	reset, err := r.items.findOne(func(p models.PasswordReset) bool { return p.TokenHash == hash })
+	if err != nil {
+		return nil, err
+	}
+	return &reset, nil
+}
+
+func (r *memoryPasswordResetRepository) Consume(ctx context.Context, hash string, at time.Time) (*models.PasswordReset, error) {
+	reset, err := r.items.findOne(func(p models.PasswordReset) bool { return p.TokenHash == hash })
+	if err != nil {
+		return nil, err
+	}
+	err = r.items.modify(reset.Id, func(p *models.PasswordReset) error {
+		if p.IsUsed || !at.Before(p.ExpiresAt) {
+			return ErrNotFound
+		}
+		p.IsUsed = true
+		p.UsedAt = at
+		reset = *p
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &reset, nil
+}
+
+func (r *memoryPasswordResetRepository) InvalidateAll(ctx context.Context, userId string, at time.Time) error {
+	r.items.update(func(p *models.PasswordReset) {
+		if p.UserId == userId && !p.IsUsed {
+			p.IsUsed = true
+			p.UsedAt = at
+		}
+	})
+	return nil
+}
@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/amrohan/expenso-go/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type ReconciliationRepository interface {
+	Create(ctx context.Context, reconciliation *models.Reconciliation) error
+	FindById(ctx context.Context, id primitive.ObjectID) (*models.Reconciliation, error)
+	// FindByAccount returns an account's reconciliations, newest first.
+	FindByAccount(ctx context.Context, accountId string) ([]models.Reconciliation, error)
+	Update(ctx context.Context, reconciliation *models.Reconciliation) error
+	Delete(ctx context.Context, id primitive.ObjectID) error
+}
+
+type mongoReconciliationRepository struct {
+	collection *mongo.Collection
+}
+
+func (r *mongoReconciliationRepository) Create(ctx context.Context, reconciliation *models.Reconciliation) error {
+	_, err := r.collection.InsertOne(ctx, reconciliation)
+	return mongoError(err)
+}
+
+func (r *mongoReconciliationRepository) FindById(ctx context.Context, id primitive.ObjectID) (*models.Reconciliation, error) {
+	var reconciliation models.Reconciliation
+	if err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&reconciliation); err != nil {
+		return nil, mongoError(err)
+	}
+	return &reconciliation, nil
+}
+
+func (r *mongoReconciliationRepository) FindByAccount(ctx context.Context, accountId string) ([]models.Reconciliation, error) {
+	cur, err := r.collection.Find(ctx, bson.M{"accountId": accountId}, options.Find().SetSort(bson.D{{Key: "_id", Value: -1}}))
+	if err != nil {
+		return nil, err
+	}
+	return decodeAll[models.Reconciliation](ctx, cur)
+}
+
+func (r *mongoReconciliationRepository) Update(ctx context.Context, reconciliation *models.Reconciliation) error {
+	res, err := r.collection.ReplaceOne(ctx, bson.M{"_id": reconciliation.Id}, reconciliation)
+	if err != nil {
+		return mongoError(err)
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *mongoReconciliationRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	res, err := r.collection.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+type memoryReconciliationRepository struct {
+	items *memoryCollection[models.Reconciliation]
+}
+
+func reconciliationId(r models.Reconciliation) primitive.ObjectID { return r.Id }
+
+func (r *memoryReconciliationRepository) Create(ctx context.Context, reconciliation *models.Reconciliation) error {
+	return r.items.insert(*reconciliation)
+}
+
+func (r *memoryReconciliationRepository) FindById(ctx context.Context, id primitive.ObjectID) (*models.Reconciliation, error) {
+	reconciliation, err := r.items.get(id)
+	if err != nil {
+		return nil, err
+	}
+	return &reconciliation, nil
+}
+
+func (r *memoryReconciliationRepository) FindByAccount(ctx context.Context, accountId string) ([]models.Reconciliation, error) {
+	matches := r.items.find(func(rec models.Reconciliation) bool { return rec.AccountId == accountId })
+	for i, j := 0, len(matches)-1; i < j; i, j = i+1, j-1 {
+		matches[i], matches[j] = matches[j], matches[i]
+	}
+	return matches, nil
+}
+
+func (r *memoryReconciliationRepository) Update(ctx context.Context, reconciliation *models.Reconciliation) error {
+	return r.items.replace(*reconciliation)
+}
+
+func (r *memoryReconciliationRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	return r.items.delete(id)
+}
@@ -0,0 +1,141 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/amrohan/expenso-go/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type RecurringRuleRepository interface {
+	Create(ctx context.Context, rule *models.RecurringRule) error
+	FindById(ctx context.Context, id primitive.ObjectID) (*models.RecurringRule, error)
+	FindByUser(ctx context.Context, userId string) ([]models.RecurringRule, error)
+	// FindDue returns the active rules not yet posted through day.
+	FindDue(ctx context.Context, day time.Time) ([]models.RecurringRule, error)
+	Update(ctx context.Context, rule *models.RecurringRule) error
+	// MarkPosted moves a rule's PostedThrough from from to through and
+	// records lastError. It returns ErrNotFound when PostedThrough is no
+	// longer from, because another run or an edit got there first.
+	MarkPosted(ctx context.Context, id primitive.ObjectID, from, through time.Time, lastError string) error
+	Delete(ctx context.Context, id primitive.ObjectID) error
+}
+
+type mongoRecurringRuleRepository struct {
+	collection *mongo.Collection
+}
+
+func (r *mongoRecurringRuleRepository) Create(ctx context.Context, rule *models.RecurringRule) error {
+	_, err := r.collection.InsertOne(ctx, rule)
+	return mongoError(err)
+}
+
+func (r *mongoRecurringRuleRepository) FindById(ctx context.Context, id primitive.ObjectID) (*models.RecurringRule, error) {
+	var rule models.RecurringRule
+	if err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&rule); err != nil {
+		return nil, mongoError(err)
+	}
+	return &rule, nil
+}
+
+func (r *mongoRecurringRuleRepository) FindByUser(ctx context.Context, userId string) ([]models.RecurringRule, error) {
+	cur, err := r.collection.Find(ctx, bson.M{"userId": userId})
+	if err != nil {
+		return nil, err
+	}
+	return decodeAll[models.RecurringRule](ctx, cur)
+}
+
+func (r *mongoRecurringRuleRepository) FindDue(ctx context.Context, day time.Time) ([]models.RecurringRule, error) {
+	cur, err := r.collection.Find(ctx, bson.M{"isActive": true, "postedThrough": bson.M{"$lt": day}})
+	if err != nil {
+		return nil, err
+	}
+	return decodeAll[models.RecurringRule](ctx, cur)
+}
+
+func (r *mongoRecurringRuleRepository) Update(ctx context.Context, rule *models.RecurringRule) error {
+	res, err := r.collection.ReplaceOne(ctx, bson.M{"_id": rule.Id}, rule)
+	if err != nil {
+		return mongoError(err)
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *mongoRecurringRuleRepository) MarkPosted(ctx context.Context, id primitive.ObjectID, from, through time.Time, lastError string) error {
+	res, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": id, "postedThrough": from},
+		bson.M{"$set": bson.M{"postedThrough": through, "lastError": lastError}},
+	)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *mongoRecurringRuleRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	res, err := r.collection.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+type memoryRecurringRuleRepository struct {
+	items *memoryCollection[models.RecurringRule]
+}
+
+func recurringRuleId(r models.RecurringRule) primitive.ObjectID { return r.Id }
+
+func (r *memoryRecurringRuleRepository) Create(ctx context.Context, rule *models.RecurringRule) error {
+	return r.items.insert(*rule)
+}
+
+func (r *memoryRecurringRuleRepository) FindById(ctx context.Context, id primitive.ObjectID) (*models.RecurringRule, error) {
+	rule, err := r.items.get(id)
+	if err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+func (r *memoryRecurringRuleRepository) FindByUser(ctx context.Context, userId string) ([]models.RecurringRule, error) {
+	return r.items.find(func(rule models.RecurringRule) bool { return rule.UserId == userId }), nil
+}
+
+func (r *memoryRecurringRuleRepository) FindDue(ctx context.Context, day time.Time) ([]models.RecurringRule, error) {
+	return r.items.find(func(rule models.RecurringRule) bool {
+		return rule.IsActive && rule.PostedThrough.Before(day)
+	}), nil
+}
+
+func (r *memoryRecurringRuleRepository) Update(ctx context.Context, rule *models.RecurringRule) error {
+	return r.items.replace(*rule)
+}
+
+func (r *memoryRecurringRuleRepository) MarkPosted(ctx context.Context, id primitive.ObjectID, from, through time.Time, lastError string) error {
+	return r.items.modify(id, func(rule *models.RecurringRule) error {
+		if !rule.PostedThrough.Equal(from) {
+			return ErrNotFound
+		}
+		rule.PostedThrough = through
+		rule.LastError = lastError
+		return nil
+	})
+}
+
+func (r *memoryRecurringRuleRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	return r.items.delete(id)
+}
@@ -0,0 +1,421 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/amrohan/expenso-go/internal/db"
+	"github.com/amrohan/expenso-go/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ErrNotFound is returned when no document matches the lookup.
+var ErrNotFound = errors.New("document not found")
+
+// ErrDuplicate is returned when a document with the same id, or the same
+// value for a unique field, already exists.
+var ErrDuplicate = errors.New("document already exists")
+
+// ErrConflict is returned when a document changed after it was read and a
+// write conditional on the read copy was not made.
+var ErrConflict = errors.New("document changed since it was read")
+
+// Store bundles the repositories the handlers depend on.
+type Store struct {
+	Transactions    TransactionRepository
+	Categories      CategoryRepository
+	Accounts        AccountRepository
+	Users           UserRepository
+	Sessions        SessionRepository
+	APIKeys         APIKeyRepository
+	PasswordResets  PasswordResetRepository
+	LoginAttempts   LoginAttemptRepository
+	AuditEvents     AuditEventRepository
+	Revisions       RevisionRepository
+	ExchangeRates   ExchangeRateRepository
+	Reconciliations ReconciliationRepository
+	RecurringRules  RecurringRuleRepository
+	Budgets         BudgetRepository
+	EnvelopeMoves   EnvelopeMoveRepository
+
+	// client is nil for the memory store.
+	client *mongo.Client
+}
+
+// Atomically runs fn in a multi-document transaction that repository calls
+// made with the context fn is given take part in, including their own
+// atomic writes. The memory store has no transactions and runs fn as is, so
+// only each call on it is atomic.
+func (s *Store) Atomically(ctx context.Context, fn func(ctx context.Context) error) error {
+	if s.client == nil {
+		return fn(ctx)
+	}
+	return inTransaction(ctx, s.client, fn)
+}
+
+// NewMongoStore returns a Store backed by the given MongoDB client.
+func NewMongoStore(client *mongo.Client) *Store {
+	database := client.Database(db.Database)
+	return &Store{
+		Transactions:    &mongoTransactionRepository{collection: database.Collection(string(db.TransactionCollection))},
+		Categories:      &mongoCategoryRepository{collection: database.Collection(string(db.CategoryCollection))},
+		Accounts:        &mongoAccountRepository{collection: database.Collection(string(db.AccountCollection))},
+		Users:           &mongoUserRepository{collection: database.Collection(string(db.UserCollection))},
+		Sessions:        &mongoSessionRepository{collection: database.Collection(string(db.SessionCollection))},
+		APIKeys:         &mongoAPIKeyRepository{collection: database.Collection(string(db.APIKeyCollection))},
+		PasswordResets:  &mongoPasswordResetRepository{collection: database.Collection(string(db.PasswordResetCollection))},
+		LoginAttempts:   &mongoLoginAttemptRepository{collection: database.Collection(string(db.LoginAttemptCollection))},
+		AuditEvents:     &mongoAuditEventRepository{collection: database.Collection(string(db.AuditEventCollection))},
+		Revisions:       &mongoRevisionRepository{collection: database.Collection(string(db.RevisionCollection))},
+		ExchangeRates:   &mongoExchangeRateRepository{collection: database.Collection(string(db.ExchangeRateCollection))},
+		Reconciliations: &mongoReconciliationRepository{collection: database.Collection(string(db.ReconciliationCollection))},
+		RecurringRules:  &mongoRecurringRuleRepository{collection: database.Collection(string(db.RecurringRuleCollection))},
+		Budgets:         &mongoBudgetRepository{collection: database.Collection(string(db.BudgetCollection))},
+		EnvelopeMoves:   &mongoEnvelopeMoveRepository{collection: database.Collection(string(db.EnvelopeMoveCollection))},
+		client:          client,
+	}
+}
+
+// NewMemoryStore returns a Store that keeps everything in process memory.
+// It is meant for tests and local demos; nothing survives a restart.
+func NewMemoryStore() *Store {
+	return &Store{
+		Transactions:    &memoryTransactionRepository{items: newMemoryCollection(transactionId)},
+		Categories:      &memoryCategoryRepository{items: newMemoryCollection(categoryId)},
+		Accounts:        &memoryAccountRepository{items: newMemoryCollection(accountId)},
+		Users:           &memoryUserRepository{items: newMemoryCollection(userId)},
+		Sessions:        &memorySessionRepository{items: newMemoryCollection(sessionId)},
+		APIKeys:         &memoryAPIKeyRepository{items: newMemoryCollection(apiKeyId)},
+		PasswordResets:  &memoryPasswordResetRepository{items: newMemoryCollection(passwordResetId)},
+		LoginAttempts:   &memoryLoginAttemptRepository{items: map[string]models.LoginAttempt{}},
+		AuditEvents:     &memoryAuditEventRepository{items: newMemoryCollection(auditEventId)},
+		Revisions:       &memoryRevisionRepository{items: newMemoryCollection(revisionId)},
+		ExchangeRates:   &memoryExchangeRateRepository{items: newMemoryCollection(exchangeRateId)},
+		Reconciliations: &memoryReconciliationRepository{items: newMemoryCollection(reconciliationId)},
+		RecurringRules:  &memoryRecurringRuleRepository{items: newMemoryCollection(recurringRuleId)},
+		Budgets:         &memoryBudgetRepository{items: newMemoryCollection(budgetId)},
+		EnvelopeMoves:   &memoryEnvelopeMoveRepository{items: newMemoryCollection(envelopeMoveId)},
+	}
+}
+
+// memoryCollection is a concurrency safe map of documents keyed by ObjectID.
+// Documents are stored and returned by value so callers never share state
+// with the collection.
+type memoryCollection[T any] struct {
+	mu    sync.RWMutex
+	items map[primitive.ObjectID]T
+	id    func(T) primitive.ObjectID
+}
+
+func newMemoryCollection[T any](id func(T) primitive.ObjectID) *memoryCollection[T] {
+	return &memoryCollection[T]{items: map[primitive.ObjectID]T{}, id: id}
+}
+
+func (c *memoryCollection[T]) insert(item T) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	id := c.id(item)
+	if _, ok := c.items[id]; ok {
+		return ErrDuplicate
+	}
+	c.items[id] = item
+	return nil
+}
+
+// insertUnique is insert that also fails with ErrDuplicate when any stored
+// document is accepted by conflict, standing in for a unique index.
+func (c *memoryCollection[T]) insertUnique(item T, conflict func(T) bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	id := c.id(item)
+	if _, ok := c.items[id]; ok {
+		return ErrDuplicate
+	}
+	for _, existing := range c.items {
+		if conflict(existing) {
+			return ErrDuplicate
+		}
+	}
+	c.items[id] = item
+	return nil
+}
+
+func (c *memoryCollection[T]) get(id primitive.ObjectID) (T, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	item, ok := c.items[id]
+	if !ok {
+		return item, ErrNotFound
+	}
+	return item, nil
+}
+
+// find returns every document accepted by match in insertion order, which
+// matches MongoDB's natural order for ObjectID keyed documents.
+func (c *memoryCollection[T]) find(match func(T) bool) []T {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	ids := make([]primitive.ObjectID, 0, len(c.items))
+	for id, item := range c.items {
+		if match(item) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return bytes.Compare(ids[i][:], ids[j][:]) < 0
+	})
+
+	items := make([]T, 0, len(ids))
+	for _, id := range ids {
+		items = append(items, c.items[id])
+	}
+	return items
+}
+
+func (c *memoryCollection[T]) findOne(match func(T) bool) (T, error) {
+	items := c.find(match)
+	if len(items) == 0 {
+		var zero T
+		return zero, ErrNotFound
+	}
+	return items[0], nil
+}
+
+func (c *memoryCollection[T]) replace(item T) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	id := c.id(item)
+	if _, ok := c.items[id]; !ok {
+		return ErrNotFound
+	}
+	c.items[id] = item
+	return nil
+}
+
+// replaceUnique is replace that also fails with ErrDuplicate when any other
+// stored document is accepted by conflict.
+func (c *memoryCollection[T]) replaceUnique(item T, conflict func(T) bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	id := c.id(item)
+	if _, ok := c.items[id]; !ok {
+		return ErrNotFound
+	}
+	for otherId, existing := range c.items {
+		if otherId != id && conflict(existing) {
+			return ErrDuplicate
+		}
+	}
+	c.items[id] = item
+	return nil
+}
+
+// update applies fn to every document in place.
+func (c *memoryCollection[T]) update(fn func(*T)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for id, item := range c.items {
+		fn(&item)
+		c.items[id] = item
+	}
+}
+
+// modify applies fn to a single document, storing the result unless fn
+// returns an error.
+func (c *memoryCollection[T]) modify(id primitive.ObjectID, fn func(*T) error) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	item, ok := c.items[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if err := fn(&item); err != nil {
+		return err
+	}
+	c.items[id] = item
+	return nil
+}
+
+// deleteWhere removes every document accepted by match.
+func (c *memoryCollection[T]) deleteWhere(match func(T) bool) int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var n int64
+	for id, item := range c.items {
+		if match(item) {
+			delete(c.items, id)
+			n++
+		}
+	}
+	return n
+}
+
+// insertAll adds every item or, when any id is taken, none of them.
+func (c *memoryCollection[T]) insertAll(items ...T) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, item := range items {
+		if _, ok := c.items[c.id(item)]; ok {
+			return ErrDuplicate
+		}
+	}
+	for _, item := range items {
+		c.items[c.id(item)] = item
+	}
+	return nil
+}
+
+// modifyAll is modify for several documents at once: fn is applied to each
+// and nothing is stored unless every call succeeds.
+func (c *memoryCollection[T]) modifyAll(ids []primitive.ObjectID, fn func(*T) error) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	updated := make([]T, 0, len(ids))
+	for _, id := range ids {
+		item, ok := c.items[id]
+		if !ok {
+			return ErrNotFound
+		}
+		if err := fn(&item); err != nil {
+			return err
+		}
+		updated = append(updated, item)
+	}
+	for i, id := range ids {
+		c.items[id] = updated[i]
+	}
+	return nil
+}
+
+func (c *memoryCollection[T]) delete(id primitive.ObjectID) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.items[id]; !ok {
+		return ErrNotFound
+	}
+	delete(c.items, id)
+	return nil
+}
+
+// decodeAll drains a cursor into a slice, returning an empty slice rather
+// than nil when nothing matched.
+func decodeAll[T any](ctx context.Context, cur *mongo.Cursor) ([]T, error) {
+	items := []T{}
+	if err := cur.All(ctx, &items); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// deletedFilter limits filter to soft-deleted documents when deletedOnly is
+// set and excludes them otherwise.
+func deletedFilter(filter bson.M, deletedOnly bool) bson.M {
+	if deletedOnly {
+		filter["isDeleted"] = true
+	} else {
+		filter["isDeleted"] = bson.M{"$ne": true}
+	}
+	return filter
+}
+
+// softDelete marks a live document as deleted.
+func softDelete(ctx context.Context, collection *mongo.Collection, id primitive.ObjectID, at time.Time) error {
+	res, err := collection.UpdateOne(ctx,
+		bson.M{"_id": id, "isDeleted": bson.M{"$ne": true}},
+		bson.M{"$set": bson.M{"isDeleted": true, "deletedAt": at}},
+	)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// restoreDeleted brings a soft-deleted document back.
+func restoreDeleted(ctx context.Context, collection *mongo.Collection, id primitive.ObjectID) error {
+	res, err := collection.UpdateOne(ctx,
+		bson.M{"_id": id, "isDeleted": true},
+		bson.M{"$set": bson.M{"isDeleted": false, "deletedAt": time.Time{}}},
+	)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// purgeDeleted permanently removes documents soft-deleted before cutoff.
+func purgeDeleted(ctx context.Context, collection *mongo.Collection, cutoff time.Time) (int64, error) {
+	res, err := collection.DeleteMany(ctx, bson.M{"isDeleted": true, "deletedAt": bson.M{"$lt": cutoff}})
+	if err != nil {
+		return 0, err
+	}
+	return res.DeletedCount, nil
+}
+
+// atomically runs fn in a multi-document transaction on the client of
+// collection.
+func atomically(ctx context.Context, collection *mongo.Collection, fn func(ctx context.Context) error) error {
+	return inTransaction(ctx, collection.Database().Client(), fn)
+}
+
+// inTransaction runs fn in a multi-document transaction, or as part of the
+// one ctx already carries. MongoDB only supports these on replica sets and
+// sharded clusters; against a standalone server, which rejects the first
+// write, fn is run again without one.
+func inTransaction(ctx context.Context, client *mongo.Client, fn func(ctx context.Context) error) error {
+	if mongo.SessionFromContext(ctx) != nil {
+		return fn(ctx)
+	}
+	session, err := client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sc)
+	})
+	var serverErr mongo.ServerError
+	if errors.As(err, &serverErr) && serverErr.HasErrorCode(illegalOperation) {
+		return fn(ctx)
+	}
+	return err
+}
+
+// illegalOperation is the server error code for transactions on a
+// standalone server.
+const illegalOperation = 20
+
+// mongoError maps driver errors onto the repository sentinel errors.
+func mongoError(err error) error {
+	switch {
+	case errors.Is(err, mongo.ErrNoDocuments):
+		return ErrNotFound
+	case mongo.IsDuplicateKeyError(err):
+		return ErrDuplicate
+	}
+	return err
+}
@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/amrohan/expenso-go/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// appendAttempts bounds retries when two writers race for the same version.
+const appendAttempts = 5
+
+// RevisionRepository keeps the version history of documents.
+type RevisionRepository interface {
+	// Append stores revision as the entity's next version and sets
+	// revision.Version accordingly.
+	Append(ctx context.Context, revision *models.Revision) error
+	// Find returns every version of an entity, oldest first.
+	Find(ctx context.Context, entityType, entityId string) ([]models.Revision, error)
+	FindVersion(ctx context.Context, entityType, entityId string, version int) (*models.Revision, error)
+}
+
+type mongoRevisionRepository struct {
+	collection *mongo.Collection
+	indexOnce  sync.Once
+}
+
+// ensureIndex makes version numbers unique per entity so concurrent appends
+// cannot both claim the same one.
+func (r *mongoRevisionRepository) ensureIndex(ctx context.Context) {
+	r.indexOnce.Do(func() {
+		r.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+			Keys:    bson.D{{Key: "entityType", Value: 1}, {Key: "entityId", Value: 1}, {Key: "version", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		})
+	})
+}
+
+func (r *mongoRevisionRepository) Append(ctx context.Context, revision *models.Revision) error {
+	r.ensureIndex(ctx)
+	for i := 0; i < appendAttempts; i++ {
+		var last models.Revision
+		err := r.collection.FindOne(ctx,
+			bson.M{"entityType": revision.EntityType, "entityId": revision.EntityId},
+			options.FindOne().SetSort(bson.D{{Key: "version", Value: -1}}),
+		).Decode(&last)
+		if err != nil && err != mongo.ErrNoDocuments {
+			return err
+		}
+
+		revision.Version = last.Version + 1
+		_, err = r.collection.InsertOne(ctx, revision)
+		if err = mongoError(err); !errors.Is(err, ErrDuplicate) {
+			return err
+		}
+	}
+	return ErrDuplicate
+}
+
+func (r *mongoRevisionRepository) Find(ctx context.Context, entityType, entityId string) ([]models.Revision, error) {
+	cur, err := r.collection.Find(ctx,
+		bson.M{"entityType": entityType, "entityId": entityId},
+		options.Find().SetSort(bson.D{{Key: "version", Value: 1}}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return decodeAll[models.Revision](ctx, cur)
+}
+
+func (r *mongoRevisionRepository) FindVersion(ctx context.Context, entityType, entityId string, version int) (*models.Revision, error) {
+	var revision models.Revision
+	err := r.collection.FindOne(ctx, bson.M{"entityType": entityType, "entityId": entityId, "version": version}).Decode(&revision)
+	if err != nil {
+		return nil, mongoError(err)
+	}
+	return &revision, nil
+}
+
+type memoryRevisionRepository struct {
+	// appendMu serialises appends so version numbers are assigned once.
+	appendMu sync.Mutex
+	items    *memoryCollection[models.Revision]
+}
+
+func revisionId(r models.Revision) primitive.ObjectID { return r.Id }
+
+func (r *memoryRevisionRepository) Append(ctx context.Context, revision *models.Revision) error {
+	r.appendMu.Lock()
+	defer r.appendMu.Unlock()
+
+	existing, _ := r.Find(ctx, revision.EntityType, revision.EntityId)
+	revision.Version = len(existing) + 1
+	return r.items.insert(*revision)
+}
+
+func (r *memoryRevisionRepository) Find(ctx context.Context, entityType, entityId string) ([]models.Revision, error) {
+	return r.items.find(func(rev models.Revision) bool {
+		return rev.EntityType == entityType && rev.EntityId == entityId
+	}), nil
+}
+
+func (r *memoryRevisionRepository) FindVersion(ctx context.Context, entityType, entityId string, version int) (*models.Revision, error) {
+	revision, err := r.items.findOne(func(rev models.Revision) bool {
+		return rev.EntityType == entityType && rev.EntityId == entityId && rev.Version == version
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &revision, nil
+}
@@ -0,0 +1,225 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/amrohan/expenso-go/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// SessionFilter narrows Find results. Zero fields are ignored; ActiveAt
+// keeps only sessions that are neither revoked nor expired at that time.
+type SessionFilter struct {
+	UserId   string
+	ActiveAt time.Time
+}
+
+type SessionRepository interface {
+	Create(ctx context.Context, session *models.Session) error
+	FindById(ctx context.Context, id primitive.ObjectID) (*models.Session, error)
+	// FindByTokenHash matches either the current or the previous refresh
+	// token hash so callers can detect reuse of a rotated token.
+	FindByTokenHash(ctx context.Context, hash string) (*models.Session, error)
+	Find(ctx context.Context, filter SessionFilter) ([]models.Session, error)
+	// Touch records that a session was used at at from ip. Revoked sessions
+	// are left alone and give ErrNotFound.
+	Touch(ctx context.Context, id primitive.ObjectID, at time.Time, ip string) error
+	// Revoke revokes a session. A session that is already revoked keeps its
+	// original revokedAt.
+	Revoke(ctx context.Context, id primitive.ObjectID, at time.Time) error
+	// Rotate stores session only while its refresh token is still
+	// session.PreviousTokenHash and it is not revoked, and otherwise returns
+	// ErrNotFound, so of two refreshes with one token only the first wins.
+	Rotate(ctx context.Context, session *models.Session) error
+	// RevokeAll revokes every active session of a user.
+	RevokeAll(ctx context.Context, userId string, at time.Time) error
+}
+
+func (f SessionFilter) bson() bson.M {
+	filter := bson.M{}
+	if f.UserId != "" {
+		filter["userId"] = f.UserId
+	}
+	if !f.ActiveAt.IsZero() {
+		filter["isRevoked"] = false
+		filter["expiresAt"] = bson.M{"$gt": f.ActiveAt}
+	}
+	return filter
+}
+
+func (f SessionFilter) match(s models.Session) bool {
+	if f.UserId != "" && s.UserId != f.UserId {
+		return false
+	}
+	if !f.ActiveAt.IsZero() && !s.IsActive(f.ActiveAt) {
+		return false
+	}
+	return true
+}
+
+type mongoSessionRepository struct {
+	collection *mongo.Collection
+}
+
+func (r *mongoSessionRepository) Create(ctx context.Context, session *models.Session) error {
+	_, err := r.collection.InsertOne(ctx, session)
+	return mongoError(err)
+}
+
+func (r *mongoSessionRepository) findOne(ctx context.Context, filter interface{}) (*models.Session, error) {
+	var session models.Session
+	if err := r.collection.FindOne(ctx, filter).Decode(&session); err != nil {
+		return nil, mongoError(err)
+	}
+	return &session, nil
+}
+
+func (r *mongoSessionRepository) FindById(ctx context.Context, id primitive.ObjectID) (*models.Session, error) {
+	return r.findOne(ctx, bson.M{"_id": id})
+}
+
+func (r *mongoSessionRepository) FindByTokenHash(ctx context.Context, hash string) (*models.Session, error) {
+	if hash == "" {
+		return nil, ErrNotFound
+	}
+	return r.findOne(ctx, bson.M{"$or": []bson.M{
+		{"refreshTokenHash": hash},
+		{"previousTokenHash": hash},
+	}})
+}
+
+func (r *mongoSessionRepository) Find(ctx context.Context, filter SessionFilter) ([]models.Session, error) {
+	cur, err := r.collection.Find(ctx, filter.bson())
+	if err != nil {
+		return nil, err
+	}
+	return decodeAll[models.Session](ctx, cur)
+}
+
+func (r *mongoSessionRepository) Touch(ctx context.Context, id primitive.ObjectID, at time.Time, ip string) error {
+	res, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": id, "isRevoked": false},
+		bson.M{"$set": bson.M{"lastSeenAt": at, "ip": ip}},
+	)
+	if err != nil {
+		return mongoError(err)
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *mongoSessionRepository) Revoke(ctx context.Context, id primitive.ObjectID, at time.Time) error {
+	_, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": id, "isRevoked": false},
+		bson.M{"$set": bson.M{"isRevoked": true, "revokedAt": at}},
+	)
+	return mongoError(err)
+}
+
+func (r *mongoSessionRepository) Rotate(ctx context.Context, session *models.Session) error {
+	res, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": session.Id, "refreshTokenHash": session.PreviousTokenHash, "isRevoked": false},
+		bson.M{"$set": session},
+	)
+	if err != nil {
+		return mongoError(err)
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *mongoSessionRepository) RevokeAll(ctx context.Context, userId string, at time.Time) error {
+	_, err := r.collection.UpdateMany(ctx,
+		bson.M{"userId": userId, "isRevoked": false},
+		bson.M{"$set": bson.M{"isRevoked": true, "revokedAt": at}},
+	)
+	return err
+}
+
+type memorySessionRepository struct {
+	items *memoryCollection[models.Session]
+}
+
+func sessionId(s models.Session) primitive.ObjectID { return s.Id }
+
+func (r *memorySessionRepository) Create(ctx context.Context, session *models.Session) error {
+	return r.items.insert(*session)
+}
+
+func (r *memorySessionRepository) FindById(ctx context.Context, id primitive.ObjectID) (*models.Session, error) {
+	session, err := r.items.get(id)
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (r *memorySessionRepository) FindByTokenHash(ctx context.Context, hash string) (*models.Session, error) {
+	if hash == "" {
+		return nil, ErrNotFound
+	}
+	session, err := r.items.findOne(func(s models.Session) bool {
+		return s.RefreshTokenHash == hash || s.PreviousTokenHash == hash
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (r *memorySessionRepository) Find(ctx context.Context, filter SessionFilter) ([]models.Session, error) {
+	return r.items.find(filter.match), nil
+}
+
+func (r *memorySessionRepository) Touch(ctx context.Context, id primitive.ObjectID, at time.Time, ip string) error {
+	return r.items.modify(id, func(s *models.Session) error {
+		if s.IsRevoked {
+			return ErrNotFound
+		}
+		s.LastSeenAt = at
+		s.Ip = ip
+		return nil
+	})
+}
+
+func (r *memorySessionRepository) Revoke(ctx context.Context, id primitive.ObjectID, at time.Time) error {
+	err := r.items.modify(id, func(s *models.Session) error {
+		if !s.IsRevoked {
+			s.IsRevoked = true
+			s.RevokedAt = at
+		}
+		return nil
+	})
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+func (r *memorySessionRepository) Rotate(ctx context.Context, session *models.Session) error {
+	return r.items.modify(session.Id, func(s *models.Session) error {
+		if s.RefreshTokenHash != session.PreviousTokenHash || s.IsRevoked {
+			return ErrNotFound
+		}
+		*s = *session
+		return nil
+	})
+}
+
+func (r *memorySessionRepository) RevokeAll(ctx context.Context, userId string, at time.Time) error {
+	r.items.update(func(s *models.Session) {
+		if s.UserId == userId && !s.IsRevoked {
+			s.IsRevoked = true
+			s.RevokedAt = at
+		}
+	})
+	return nil
+}
@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/amrohan/expenso-go/internal/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// A request that loaded a session before it was revoked must not bring it
+// back, whichever write it makes afterwards.
+func TestSessionWritesDoNotReviveRevoked(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	start := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	session := &models.Session{
+		Id:               primitive.NewObjectID(),
+		UserId:           "user",
+		RefreshTokenHash: "first",
+		CreatedAt:        start,
+		LastSeenAt:       start,
+		ExpiresAt:        start.Add(time.Hour),
+	}
+	if err := store.Sessions.Create(ctx, session); err != nil {
+		t.Fatal(err)
+	}
+
+	stale, err := store.Sessions.FindById(ctx, session.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Sessions.Touch(ctx, session.Id, start.Add(time.Minute), "10.0.0.1"); err != nil {
+		t.Fatalf("Touch: %v", err)
+	}
+	if err := store.Sessions.Revoke(ctx, session.Id, start.Add(2*time.Minute)); err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+
+	if err := store.Sessions.Touch(ctx, session.Id, start.Add(3*time.Minute), "10.0.0.2"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Touch after revoke = %v, want %v", err, ErrNotFound)
+	}
+	stale.PreviousTokenHash = stale.RefreshTokenHash
+	stale.RefreshTokenHash = "second"
+	if err := store.Sessions.Rotate(ctx, stale); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Rotate after revoke = %v, want %v", err, ErrNotFound)
+	}
+	if err := store.Sessions.Revoke(ctx, session.Id, start.Add(4*time.Minute)); err != nil {
+		t.Errorf("second Revoke: %v", err)
+	}
+
+	got, err := store.Sessions.FindById(ctx, session.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.IsRevoked || !got.RevokedAt.Equal(start.Add(2*time.Minute)) {
+		t.Errorf("session revoked = %v at %v, want revoked at the first revoke", got.IsRevoked, got.RevokedAt)
+	}
+	if got.RefreshTokenHash != "first" || got.Ip != "10.0.0.1" {
+		t.Errorf("session = %+v, want the state from before the revoke", got)
+	}
+}
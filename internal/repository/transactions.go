@@ -0,0 +1,327 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/amrohan/expenso-go/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// TransactionFilter narrows Find results. Zero fields are ignored.
+type TransactionFilter struct {
+	UserId     string
+	AccountId  string
+	CategoryId string    // also matches splits
+	From       time.Time // inclusive
+	To         time.Time // exclusive
+	// DeletedOnly lists the trash instead; deleted transactions are
+	// otherwise left out.
+	DeletedOnly bool
+}
+
+type TransactionRepository interface {
+	Create(ctx context.Context, transaction *models.Transaction) error
+	FindById(ctx context.Context, id primitive.ObjectID) (*models.Transaction, error)
+	Find(ctx context.Context, filter TransactionFilter) ([]models.Transaction, error)
+	Update(ctx context.Context, transaction *models.Transaction) error
+	// Delete moves a document to the trash; Restore takes it back out.
+	Delete(ctx context.Context, id primitive.ObjectID, at time.Time) error
+	Restore(ctx context.Context, id primitive.ObjectID) error
+	// Purge permanently removes documents deleted before cutoff.
+	Purge(ctx context.Context, cutoff time.Time) (int64, error)
+
+	// The pair methods write both legs of a transfer, or neither.
+	CreatePair(ctx context.Context, a, b *models.Transaction) error
+	UpdatePair(ctx context.Context, a, b *models.Transaction) error
+	DeletePair(ctx context.Context, a, b primitive.ObjectID, at time.Time) error
+	RestorePair(ctx context.Context, a, b primitive.ObjectID) error
+	// UpdateAll writes every update given, or none of them. It fails with
+	// ErrConflict when a transaction no longer matches the copy its update
+	// was made from.
+	UpdateAll(ctx context.Context, updates []TransactionUpdate) error
+}
+
+// TransactionUpdate is one write of UpdateAll. When Loaded is set, the copy
+// Transaction was made from, the write only goes ahead while the stored
+// transaction still has Loaded's UpdatedAt, Status and IsDeleted.
+type TransactionUpdate struct {
+	Transaction *models.Transaction
+	Loaded      *models.Transaction
+}
+
+func (u TransactionUpdate) bson() bson.M {
+	filter := bson.M{"_id": u.Transaction.Id}
+	if u.Loaded == nil {
+		return filter
+	}
+	filter["updatedAt"] = u.Loaded.UpdatedAt
+	// Documents written before statuses and soft deletes may lack the
+	// fields altogether.
+	if u.Loaded.Status == "" {
+		filter["status"] = bson.M{"$in": bson.A{"", nil}}
+	} else {
+		filter["status"] = u.Loaded.Status
+	}
+	return deletedFilter(filter, u.Loaded.IsDeleted)
+}
+
+func (u TransactionUpdate) unchanged(t models.Transaction) bool {
+	return u.Loaded == nil || (t.UpdatedAt.Equal(u.Loaded.UpdatedAt) && t.Status == u.Loaded.Status && t.IsDeleted == u.Loaded.IsDeleted)
+}
+
+func (f TransactionFilter) bson() bson.M {
+	filter := bson.M{}
+	if f.UserId != "" {
+		filter["userId"] = f.UserId
+	}
+	if f.AccountId != "" {
+		filter["accountId"] = f.AccountId
+	}
+	if f.CategoryId != "" {
+		filter["$or"] = bson.A{bson.M{"categoryId": f.CategoryId}, bson.M{"splits.categoryId": f.CategoryId}}
+	}
+	if !f.From.IsZero() || !f.To.IsZero() {
+		date := bson.M{}
+		if !f.From.IsZero() {
+			date["$gte"] = f.From
+		}
+		if !f.To.IsZero() {
+			date["$lt"] = f.To
+		}
+		filter["date"] = date
+	}
+	return deletedFilter(filter, f.DeletedOnly)
+}
+
+func (f TransactionFilter) match(t models.Transaction) bool {
+	if t.IsDeleted != f.DeletedOnly {
+		return false
+	}
+	if f.UserId != "" && t.UserId != f.UserId {
+		return false
+	}
+	if f.AccountId != "" && t.AccountId != f.AccountId {
+		return false
+	}
+	if f.CategoryId != "" && !inCategory(t, f.CategoryId) {
+		return false
+	}
+	if !f.From.IsZero() && t.Date.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && !t.Date.Before(f.To) {
+		return false
+	}
+	return true
+}
+
+func inCategory(t models.Transaction, categoryId string) bool {
+	for _, split := range t.CategoryAmounts() {
+		if split.CategoryId == categoryId {
+			return true
+		}
+	}
+	return false
+}
+
+type mongoTransactionRepository struct {
+	collection *mongo.Collection
+}
+
+func (r *mongoTransactionRepository) Create(ctx context.Context, transaction *models.Transaction) error {
+	_, err := r.collection.InsertOne(ctx, transaction)
+	return mongoError(err)
+}
+
+func (r *mongoTransactionRepository) FindById(ctx context.Context, id primitive.ObjectID) (*models.Transaction, error) {
+	var transaction models.Transaction
+	if err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&transaction); err != nil {
+		return nil, mongoError(err)
+	}
+	return &transaction, nil
+}
+
+func (r *mongoTransactionRepository) Find(ctx context.Context, filter TransactionFilter) ([]models.Transaction, error) {
+	cur, err := r.collection.Find(ctx, filter.bson())
+	if err != nil {
+		return nil, err
+	}
+	return decodeAll[models.Transaction](ctx, cur)
+}
+
+// Update replaces the whole document so optional fields such as splits or
+// the reconciliation id are removed when they are cleared.
+func (r *mongoTransactionRepository) Update(ctx context.Context, transaction *models.Transaction) error {
+	res, err := r.collection.ReplaceOne(ctx, bson.M{"_id": transaction.Id}, transaction)
+	if err != nil {
+		return mongoError(err)
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *mongoTransactionRepository) Delete(ctx context.Context, id primitive.ObjectID, at time.Time) error {
+	return softDelete(ctx, r.collection, id, at)
+}
+
+func (r *mongoTransactionRepository) Restore(ctx context.Context, id primitive.ObjectID) error {
+	return restoreDeleted(ctx, r.collection, id)
+}
+
+func (r *mongoTransactionRepository) Purge(ctx context.Context, cutoff time.Time) (int64, error) {
+	return purgeDeleted(ctx, r.collection, cutoff)
+}
+
+func (r *mongoTransactionRepository) CreatePair(ctx context.Context, a, b *models.Transaction) error {
+	return atomically(ctx, r.collection, func(ctx context.Context) error {
+		_, err := r.collection.InsertMany(ctx, []interface{}{a, b})
+		return mongoError(err)
+	})
+}
+
+func (r *mongoTransactionRepository) UpdatePair(ctx context.Context, a, b *models.Transaction) error {
+	return r.UpdateAll(ctx, []TransactionUpdate{{Transaction: a}, {Transaction: b}})
+}
+
+func (r *mongoTransactionRepository) UpdateAll(ctx context.Context, updates []TransactionUpdate) error {
+	return atomically(ctx, r.collection, func(ctx context.Context) error {
+		return r.updateAll(ctx, updates)
+	})
+}
+
+// updateAll makes the writes of UpdateAll in the caller's transaction.
+func (r *mongoTransactionRepository) updateAll(ctx context.Context, updates []TransactionUpdate) error {
+	for _, update := range updates {
+		res, err := r.collection.ReplaceOne(ctx, update.bson(), update.Transaction)
+		if err != nil {
+			return mongoError(err)
+		}
+		if res.MatchedCount == 0 && update.Loaded != nil {
+			return ErrConflict
+		}
+		if res.MatchedCount == 0 {
+			return ErrNotFound
+		}
+	}
+	return nil
+}
+
+func (r *mongoTransactionRepository) DeletePair(ctx context.Context, a, b primitive.ObjectID, at time.Time) error {
+	return atomically(ctx, r.collection, func(ctx context.Context) error {
+		for _, id := range []primitive.ObjectID{a, b} {
+			if err := softDelete(ctx, r.collection, id, at); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *mongoTransactionRepository) RestorePair(ctx context.Context, a, b primitive.ObjectID) error {
+	return atomically(ctx, r.collection, func(ctx context.Context) error {
+		for _, id := range []primitive.ObjectID{a, b} {
+			if err := restoreDeleted(ctx, r.collection, id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+type memoryTransactionRepository struct {
+	items *memoryCollection[models.Transaction]
+}
+
+func transactionId(t models.Transaction) primitive.ObjectID { return t.Id }
+
+func (r *memoryTransactionRepository) Create(ctx context.Context, transaction *models.Transaction) error {
+	return r.items.insert(*transaction)
+}
+
+func (r *memoryTransactionRepository) FindById(ctx context.Context, id primitive.ObjectID) (*models.Transaction, error) {
+	transaction, err := r.items.get(id)
+	if err != nil {
+		return nil, err
+	}
+	return &transaction, nil
+}
+
+func (r *memoryTransactionRepository) Find(ctx context.Context, filter TransactionFilter) ([]models.Transaction, error) {
+	return r.items.find(filter.match), nil
+}
+
+func (r *memoryTransactionRepository) Update(ctx context.Context, transaction *models.Transaction) error {
+	return r.items.replace(*transaction)
+}
+
+func (r *memoryTransactionRepository) Delete(ctx context.Context, id primitive.ObjectID, at time.Time) error {
+	return r.items.modify(id, softDeleteTransaction(at))
+}
+
+func (r *memoryTransactionRepository) Restore(ctx context.Context, id primitive.ObjectID) error {
+	return r.items.modify(id, restoreTransaction)
+}
+
+func softDeleteTransaction(at time.Time) func(*models.Transaction) error {
+	return func(transaction *models.Transaction) error {
+		if transaction.IsDeleted {
+			return ErrNotFound
+		}
+		transaction.IsDeleted = true
+		transaction.DeletedAt = at
+		return nil
+	}
+}
+
+func restoreTransaction(transaction *models.Transaction) error {
+	if !transaction.IsDeleted {
+		return ErrNotFound
+	}
+	transaction.IsDeleted = false
+	transaction.DeletedAt = time.Time{}
+	return nil
+}
+
+func (r *memoryTransactionRepository) CreatePair(ctx context.Context, a, b *models.Transaction) error {
+	return r.items.insertAll(*a, *b)
+}
+
+func (r *memoryTransactionRepository) UpdatePair(ctx context.Context, a, b *models.Transaction) error {
+	return r.UpdateAll(ctx, []TransactionUpdate{{Transaction: a}, {Transaction: b}})
+}
+
+func (r *memoryTransactionRepository) UpdateAll(ctx context.Context, updates []TransactionUpdate) error {
+	ids := make([]primitive.ObjectID, 0, len(updates))
+	byId := map[primitive.ObjectID]TransactionUpdate{}
+	for _, update := range updates {
+		ids = append(ids, update.Transaction.Id)
+		byId[update.Transaction.Id] = update
+	}
+	return r.items.modifyAll(ids, func(transaction *models.Transaction) error {
+		update := byId[transaction.Id]
+		if !update.unchanged(*transaction) {
+			return ErrConflict
+		}
+		*transaction = *update.Transaction
+		return nil
+	})
+}
+
+func (r *memoryTransactionRepository) DeletePair(ctx context.Context, a, b primitive.ObjectID, at time.Time) error {
+	return r.items.modifyAll([]primitive.ObjectID{a, b}, softDeleteTransaction(at))
+}
+
+func (r *memoryTransactionRepository) RestorePair(ctx context.Context, a, b primitive.ObjectID) error {
+	return r.items.modifyAll([]primitive.ObjectID{a, b}, restoreTransaction)
+}
+
+func (r *memoryTransactionRepository) Purge(ctx context.Context, cutoff time.Time) (int64, error) {
+	return r.items.deleteWhere(func(transaction models.Transaction) bool {
+		return transaction.IsDeleted && transaction.DeletedAt.Before(cutoff)
+	}), nil
+}
@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/amrohan/expenso-go/internal/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// UpdateAll made from copies read earlier writes nothing when any of them
+// has changed since.
+func TestUpdateAllConflict(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	start := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	var loaded []*models.Transaction
+	for _, title := range []string{"first", "second"} {
+		transaction := &models.Transaction{
+			Id:        primitive.NewObjectID(),
+			Title:     title,
+			UserId:    "user",
+			Status:    models.StatusCleared,
+			UpdatedAt: start,
+		}
+		if err := store.Transactions.Create(ctx, transaction); err != nil {
+			t.Fatal(err)
+		}
+		loaded = append(loaded, transaction)
+	}
+
+	lock := func() error {
+		var updates []TransactionUpdate
+		for _, transaction := range loaded {
+			updated := *transaction
+			updated.Status = models.StatusReconciled
+			updated.UpdatedAt = start.Add(time.Hour)
+			updates = append(updates, TransactionUpdate{Transaction: &updated, Loaded: transaction})
+		}
+		return store.Transactions.UpdateAll(ctx, updates)
+	}
+
+	edited := *loaded[1]
+	edited.Title = "edited"
+	edited.UpdatedAt = start.Add(time.Minute)
+	if err := store.Transactions.Update(ctx, &edited); err != nil {
+		t.Fatal(err)
+	}
+	if err := lock(); !errors.Is(err, ErrConflict) {
+		t.Fatalf("UpdateAll after an edit = %v, want %v", err, ErrConflict)
+	}
+	for i, want := range []string{"first", "edited"} {
+		got, err := store.Transactions.FindById(ctx, loaded[i].Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Title != want || got.Status != models.StatusCleared {
+			t.Errorf("transaction %d = %q %s, want %q untouched", i, got.Title, got.Status, want)
+		}
+	}
+
+	loaded[1] = &edited
+	if err := store.Transactions.Delete(ctx, loaded[0].Id, start.Add(2*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if err := lock(); !errors.Is(err, ErrConflict) {
+		t.Fatalf("UpdateAll after a delete = %v, want %v", err, ErrConflict)
+	}
+
+	if err := store.Transactions.Restore(ctx, loaded[0].Id); err != nil {
+		t.Fatal(err)
+	}
+	if err := lock(); err != nil {
+		t.Fatalf("UpdateAll of unchanged copies: %v", err)
+	}
+}
@@ -0,0 +1,300 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/amrohan/expenso-go/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// UserFilter narrows Find results. Zero fields are ignored.
+type UserFilter struct {
+	// DeletedOnly lists the trash instead; deleted users are otherwise
+	// left out.
+	DeletedOnly bool
+}
+
+type UserRepository interface {
+	Create(ctx context.Context, user *models.User) error
+	FindById(ctx context.Context, id primitive.ObjectID) (*models.User, error)
+	FindByEmail(ctx context.Context, email string) (*models.User, error)
+	FindByUsername(ctx context.Context, username string) (*models.User, error)
+	// FindByLogin returns the first user whose username or email matches.
+	// Empty values never match.
+	FindByLogin(ctx context.Context, username, email string) (*models.User, error)
+	// FindByIdentity returns the user linked to an external identity.
+	FindByIdentity(ctx context.Context, provider, subject string) (*models.User, error)
+	Find(ctx context.Context, filter UserFilter) ([]models.User, error)
+	Update(ctx context.Context, user *models.User) error
+	// UseTOTPStep records that the TOTP code for step was used, unless a
+	// code for step or a later one already was, in which case it returns
+	// ErrNotFound. UseRecoveryCode removes one recovery code hash, returning
+	// ErrNotFound when it is already gone. Either way only one of several
+	// concurrent uses of a code succeeds.
+	UseTOTPStep(ctx context.Context, id primitive.ObjectID, step int64) error
+	UseRecoveryCode(ctx context.Context, id primitive.ObjectID, hash string) error
+	// Delete moves a document to the trash; Restore takes it back out.
+	Delete(ctx context.Context, id primitive.ObjectID, at time.Time) error
+	Restore(ctx context.Context, id primitive.ObjectID) error
+	// Purge permanently removes documents deleted before cutoff.
+	Purge(ctx context.Context, cutoff time.Time) (int64, error)
+	// EnsureIndexes creates the unique indexes on username and email. Create
+	// and Update return ErrDuplicate when another user already has either.
+	EnsureIndexes(ctx context.Context) error
+}
+
+func (f UserFilter) bson() bson.M {
+	return deletedFilter(bson.M{}, f.DeletedOnly)
+}
+
+func (f UserFilter) match(u models.User) bool {
+	return u.IsDeleted == f.DeletedOnly
+}
+
+type mongoUserRepository struct {
+	collection *mongo.Collection
+}
+
+// Users without a username or email, such as some created through OIDC, are
+// left out of the unique indexes so they do not clash with each other.
+func (r *mongoUserRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "username", Value: 1}},
+			Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{"username": bson.M{"$gt": ""}}),
+		},
+		{
+			Keys:    bson.D{{Key: "email", Value: 1}},
+			Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{"email": bson.M{"$gt": ""}}),
+		},
+	})
+	return err
+}
+
+func (r *mongoUserRepository) Create(ctx context.Context, user *models.User) error {
+	_, err := r.collection.InsertOne(ctx, user)
+	return mongoError(err)
+}
+
+func (r *mongoUserRepository) findOne(ctx context.Context, filter interface{}) (*models.User, error) {
+	var user models.User
+	if err := r.collection.FindOne(ctx, filter).Decode(&user); err != nil {
+		return nil, mongoError(err)
+	}
+	return &user, nil
+}
+
+func (r *mongoUserRepository) FindById(ctx context.Context, id primitive.ObjectID) (*models.User, error) {
+	return r.findOne(ctx, bson.M{"_id": id})
+}
+
+func (r *mongoUserRepository) FindByEmail(ctx context.Context, email string) (*models.User, error) {
+	return r.findOne(ctx, bson.M{"email": email})
+}
+
+func (r *mongoUserRepository) FindByUsername(ctx context.Context, username string) (*models.User, error) {
+	return r.findOne(ctx, bson.M{"username": username})
+}
+
+func (r *mongoUserRepository) FindByLogin(ctx context.Context, username, email string) (*models.User, error) {
+	or := []bson.M{}
+	if username != "" {
+		or = append(or, bson.M{"username": username})
+	}
+	if email != "" {
+		or = append(or, bson.M{"email": email})
+	}
+	if len(or) == 0 {
+		return nil, ErrNotFound
+	}
+	return r.findOne(ctx, bson.M{"$or": or})
+}
+
+func (r *mongoUserRepository) FindByIdentity(ctx context.Context, provider, subject string) (*models.User, error) {
+	return r.findOne(ctx, bson.M{"identities": bson.M{"$elemMatch": bson.M{"provider": provider, "subject": subject}}})
+}
+
+func (r *mongoUserRepository) Find(ctx context.Context, filter UserFilter) ([]models.User, error) {
+	cur, err := r.collection.Find(ctx, filter.bson())
+	if err != nil {
+		return nil, err
+	}
+	return decodeAll[models.User](ctx, cur)
+}
+
+func (r *mongoUserRepository) Update(ctx context.Context, user *models.User) error {
+	res, err := r.collection.UpdateOne(ctx, bson.M{"_id": user.Id}, bson.M{"$set": user})
+	if err != nil {
+		return mongoError(err)
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *mongoUserRepository) UseTOTPStep(ctx context.Context, id primitive.ObjectID, step int64) error {
+	res, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": id, "totpLastStep": bson.M{"$lt": step}},
+		bson.M{"$set": bson.M{"totpLastStep": step}},
+	)
+	if err != nil {
+		return mongoError(err)
+	}
+	if res.ModifiedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *mongoUserRepository) UseRecoveryCode(ctx context.Context, id primitive.ObjectID, hash string) error {
+	res, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": id, "recoveryCodeHashes": hash},
+		bson.M{"$pull": bson.M{"recoveryCodeHashes": hash}},
+	)
+	if err != nil {
+		return mongoError(err)
+	}
+	if res.ModifiedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *mongoUserRepository) Delete(ctx context.Context, id primitive.ObjectID, at time.Time) error {
+	return softDelete(ctx, r.collection, id, at)
+}
+
+func (r *mongoUserRepository) Restore(ctx context.Context, id primitive.ObjectID) error {
+	return restoreDeleted(ctx, r.collection, id)
+}
+
+func (r *mongoUserRepository) Purge(ctx context.Context, cutoff time.Time) (int64, error) {
+	return purgeDeleted(ctx, r.collection, cutoff)
+}
+
+type memoryUserRepository struct {
+	items *memoryCollection[models.User]
+}
+
+func userId(u models.User) primitive.ObjectID { return u.Id }
+
+// sameLogin stands in for the unique indexes: it accepts users sharing
+// user's username or email, where empty values never clash.
+func sameLogin(user models.User) func(models.User) bool {
+	return func(other models.User) bool {
+		return (user.Username != "" && other.Username == user.Username) ||
+			(user.Email != "" && other.Email == user.Email)
+	}
+}
+
+func (r *memoryUserRepository) EnsureIndexes(ctx context.Context) error {
+	return nil
+}
+
+func (r *memoryUserRepository) Create(ctx context.Context, user *models.User) error {
+	return r.items.insertUnique(*user, sameLogin(*user))
+}
+
+func (r *memoryUserRepository) findOne(match func(models.User) bool) (*models.User, error) {
+	user, err := r.items.findOne(match)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *memoryUserRepository) FindById(ctx context.Context, id primitive.ObjectID) (*models.User, error) {
+	user, err := r.items.get(id)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *memoryUserRepository) FindByEmail(ctx context.Context, email string) (*models.User, error) {
+	return r.findOne(func(u models.User) bool { return u.Email == email })
+}
+
+func (r *memoryUserRepository) FindByUsername(ctx context.Context, username string) (*models.User, error) {
+	return r.findOne(func(u models.User) bool { return u.Username == username })
+}
+
+func (r *memoryUserRepository) FindByLogin(ctx context.Context, username, email string) (*models.User, error) {
+	return r.findOne(func(u models.User) bool {
+		return (username != "" && u.Username == username) || (email != "" && u.Email == email)
+	})
+}
+
+func (r *memoryUserRepository) FindByIdentity(ctx context.Context, provider, subject string) (*models.User, error) {
+	return r.findOne(func(u models.User) bool {
+		for _, identity := range u.Identities {
+			if identity.Provider == provider && identity.Subject == subject {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+func (r *memoryUserRepository) Find(ctx context.Context, filter UserFilter) ([]models.User, error) {
+	return r.items.find(filter.match), nil
+}
+
+func (r *memoryUserRepository) Update(ctx context.Context, user *models.User) error {
+	return r.items.replaceUnique(*user, sameLogin(*user))
+}
+
+func (r *memoryUserRepository) UseTOTPStep(ctx context.Context, id primitive.ObjectID, step int64) error {
+	return r.items.modify(id, func(user *models.User) error {
+		if user.TOTPLastStep >= step {
+			return ErrNotFound
+		}
+		user.TOTPLastStep = step
+		return nil
+	})
+}
+
+func (r *memoryUserRepository) UseRecoveryCode(ctx context.Context, id primitive.ObjectID, hash string) error {
+	return r.items.modify(id, func(user *models.User) error {
+		for i, h := range user.RecoveryCodeHashes {
+			if h == hash {
+				user.RecoveryCodeHashes = append(user.RecoveryCodeHashes[:i:i], user.RecoveryCodeHashes[i+1:]...)
+				return nil
+			}
+		}
+		return ErrNotFound
+	})
+}
+
+func (r *memoryUserRepository) Delete(ctx context.Context, id primitive.ObjectID, at time.Time) error {
+	return r.items.modify(id, func(user *models.User) error {
+		if user.IsDeleted {
+			return ErrNotFound
+		}
+		user.IsDeleted = true
+		user.DeletedAt = at
+		return nil
+	})
+}
+
+func (r *memoryUserRepository) Restore(ctx context.Context, id primitive.ObjectID) error {
+	return r.items.modify(id, func(user *models.User) error {
+		if !user.IsDeleted {
+			return ErrNotFound
+		}
+		user.IsDeleted = false
+		user.DeletedAt = time.Time{}
+		return nil
+	})
+}
+
+func (r *memoryUserRepository) Purge(ctx context.Context, cutoff time.Time) (int64, error) {
+	return r.items.deleteWhere(func(user models.User) bool {
+		return user.IsDeleted && user.DeletedAt.Before(cutoff)
+	}), nil
+}
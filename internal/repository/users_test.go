@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/amrohan/expenso-go/internal/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Usernames and emails are unique, except that users without one do not
+// clash with each other.
+func TestUserLoginsUnique(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	create := func(username, email string) (*models.User, error) {
+		user := &models.User{Id: primitive.NewObjectID(), Username: username, Email: email}
+		return user, store.Users.Create(ctx, user)
+	}
+
+	ana, err := create("ana", "ana@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := create("ana", "other@example.com"); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("Create with a taken username = %v, want %v", err, ErrDuplicate)
+	}
+	if _, err := create("other", "ana@example.com"); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("Create with a taken email = %v, want %v", err, ErrDuplicate)
+	}
+	for _, username := range []string{"ben", "cal"} {
+		if _, err := create(username, ""); err != nil {
+			t.Errorf("Create %s without an email: %v", username, err)
+		}
+	}
+
+	ben, err := store.Users.FindByUsername(ctx, "ben")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ben.Email = ana.Email
+	if err := store.Users.Update(ctx, ben); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("Update to a taken email = %v, want %v", err, ErrDuplicate)
+	}
+	ana.Name = "Ana"
+	if err := store.Users.Update(ctx, ana); err != nil {
+		t.Errorf("Update keeping its own email: %v", err)
+	}
+}
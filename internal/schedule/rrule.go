@@ -0,0 +1,400 @@
+// Package schedule expands the subset of RFC 5545 recurrence rules used by
+// recurring transactions. Rules work on whole days in UTC:
+//
+//	FREQ=DAILY;INTERVAL=10                      every 10 days
+//	FREQ=WEEKLY;BYDAY=MO,TH                     Mondays and Thursdays
+//	FREQ=MONTHLY;BYMONTHDAY=1                   the 1st of every month
+//	FREQ=MONTHLY;BYMONTHDAY=-1                  the last day of every month
+//	FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1  the last business day
+//	FREQ=MONTHLY;BYDAY=2FR                      the second Friday
+//	FREQ=YEARLY;BYMONTH=4;BYMONTHDAY=15         every 15 April
+//
+// COUNT and UNTIL end a rule. Unlike RFC 5545, a BYMONTHDAY past the end of
+// a short month falls on its last day, so a rule for the 31st still fires
+// in February.
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrRule is returned for rules that cannot be parsed or are not supported.
+var ErrRule = errors.New("invalid schedule rule")
+
+const (
+	Daily   = "DAILY"
+	Weekly  = "WEEKLY"
+	Monthly = "MONTHLY"
+	Yearly  = "YEARLY"
+)
+
+// maxPeriods bounds how many periods an expansion walks, so a rule that
+// can never match does not loop forever.
+const maxPeriods = 100000
+
+// Weekday is a BYDAY entry. Nth is zero for every such weekday in the
+// period, or selects one of them counting from the start (1) or end (-1).
+type Weekday struct {
+	Nth int
+	Day time.Weekday
+}
+
+// Rule is a parsed recurrence rule.
+type Rule struct {
+	Freq       string
+	Interval   int
+	ByDay      []Weekday
+	ByMonthDay []int
+	ByMonth    []time.Month
+	BySetPos   []int
+	Count      int
+	Until      time.Time // inclusive, zero for none
+}
+
+var weekdays = map[string]time.Weekday{
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+	"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+}
+
+// Parse reads a rule such as "FREQ=MONTHLY;BYMONTHDAY=1". A leading
+// "RRULE:" is accepted.
+func Parse(s string) (*Rule, error) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "RRULE:")
+	rule := &Rule{Interval: 1}
+	seen := map[string]bool{}
+	for _, part := range strings.Split(s, ";") {
+		name, value, ok := strings.Cut(part, "=")
+		name = strings.ToUpper(strings.TrimSpace(name))
+		value = strings.ToUpper(strings.TrimSpace(value))
+		if !ok || value == "" {
+			return nil, fmt.Errorf("%w: %q", ErrRule, part)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("%w: %s given twice", ErrRule, name)
+		}
+		seen[name] = true
+
+		var err error
+		switch name {
+		case "FREQ":
+			rule.Freq = value
+		case "INTERVAL":
+			rule.Interval, err = positive(value)
+		case "COUNT":
+			rule.Count, err = positive(value)
+		case "UNTIL":
+			rule.Until, err = parseUntil(value)
+		case "BYDAY":
+			rule.ByDay, err = parseByDay(value)
+		case "BYMONTHDAY":
+			rule.ByMonthDay, err = parseInts(value, 31)
+		case "BYMONTH":
+			var months []int
+			months, err = parseInts(value, 12)
+			for _, m := range months {
+				if m < 0 {
+					err = fmt.Errorf("%w: BYMONTH %d", ErrRule, m)
+				}
+				rule.ByMonth = append(rule.ByMonth, time.Month(m))
+			}
+		case "BYSETPOS":
+			rule.BySetPos, err = parseInts(value, 366)
+		case "WKST":
+			if value != "MO" {
+				err = fmt.Errorf("%w: only WKST=MO is supported", ErrRule)
+			}
+		default:
+			err = fmt.Errorf("%w: %s is not supported", ErrRule, name)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return rule, rule.validate()
+}
+
+func (r *Rule) validate() error {
+	switch r.Freq {
+	case Daily:
+		if len(r.ByDay) > 0 || len(r.ByMonthDay) > 0 || len(r.ByMonth) > 0 || len(r.BySetPos) > 0 {
+			return fmt.Errorf("%w: DAILY rules take only INTERVAL, COUNT and UNTIL", ErrRule)
+		}
+	case Weekly:
+		if len(r.ByMonthDay) > 0 || len(r.ByMonth) > 0 {
+			return fmt.Errorf("%w: WEEKLY rules cannot use BYMONTHDAY or BYMONTH", ErrRule)
+		}
+	case Monthly, Yearly:
+		if r.Freq == Monthly && len(r.ByMonth) > 0 {
+			return fmt.Errorf("%w: MONTHLY rules cannot use BYMONTH", ErrRule)
+		}
+		if len(r.ByDay) > 0 && len(r.ByMonthDay) > 0 {
+			return fmt.Errorf("%w: use BYDAY or BYMONTHDAY, not both", ErrRule)
+		}
+	case "":
+		return fmt.Errorf("%w: FREQ is required", ErrRule)
+	default:
+		return fmt.Errorf("%w: FREQ=%s is not supported", ErrRule, r.Freq)
+	}
+	for _, d := range r.ByDay {
+		if d.Nth != 0 && r.Freq == Weekly {
+			return fmt.Errorf("%w: WEEKLY rules cannot number BYDAY entries", ErrRule)
+		}
+	}
+	if r.Count > 0 && !r.Until.IsZero() {
+		return fmt.Errorf("%w: use COUNT or UNTIL, not both", ErrRule)
+	}
+	return nil
+}
+
+func positive(value string) (int, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("%w: %q must be a positive number", ErrRule, value)
+	}
+	return n, nil
+}
+
+func parseUntil(value string) (time.Time, error) {
+	for _, layout := range []string{"20060102", "20060102T150405Z", "2006-01-02"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return Day(t), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%w: UNTIL %q", ErrRule, value)
+}
+
+// parseInts reads a list of non-zero numbers between -limit and limit.
+func parseInts(value string, limit int) ([]int, error) {
+	var ns []int
+	for _, item := range strings.Split(value, ",") {
+		n, err := strconv.Atoi(item)
+		if err != nil || n == 0 || n < -limit || n > limit {
+			return nil, fmt.Errorf("%w: %q", ErrRule, item)
+		}
+		ns = append(ns, n)
+	}
+	return ns, nil
+}
+
+func parseByDay(value string) ([]Weekday, error) {
+	var days []Weekday
+	for _, item := range strings.Split(value, ",") {
+		if len(item) < 2 {
+			return nil, fmt.Errorf("%w: BYDAY %q", ErrRule, item)
+		}
+		day, ok := weekdays[item[len(item)-2:]]
+		if !ok {
+			return nil, fmt.Errorf("%w: BYDAY %q", ErrRule, item)
+		}
+		weekday := Weekday{Day: day}
+		if prefix := item[:len(item)-2]; prefix != "" {
+			n, err := strconv.Atoi(prefix)
+			if err != nil || n == 0 || n < -53 || n > 53 {
+				return nil, fmt.Errorf("%w: BYDAY %q", ErrRule, item)
+			}
+			weekday.Nth = n
+		}
+		days = append(days, weekday)
+	}
+	return days, nil
+}
+
+// Day truncates t to midnight UTC.
+func Day(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// Between returns the days in [from, to) on which a rule that starts on
+// start fires. start counts as the first day of the series for COUNT and
+// INTERVAL, as DTSTART does in RFC 5545.
+func (r *Rule) Between(start, from, to time.Time) []time.Time {
+	start, from, to = Day(start), Day(from), Day(to)
+	var days []time.Time
+	count := 0
+	first := r.firstPeriod(start, from)
+	for period := first; period < first+maxPeriods; period++ {
+		periodStart, candidates := r.period(start, period)
+		if !to.After(periodStart) || (!r.Until.IsZero() && r.Until.Before(periodStart)) {
+			break
+		}
+		for _, day := range candidates {
+			if day.Before(start) {
+				continue
+			}
+			if !r.Until.IsZero() && day.After(r.Until) {
+				return days
+			}
+			count++
+			if r.Count > 0 && count > r.Count {
+				return days
+			}
+			if !day.Before(from) && day.Before(to) {
+				days = append(days, day)
+			}
+		}
+	}
+	return days
+}
+
+// firstPeriod is the period Between can start from. Periods before from
+// are skipped unless COUNT needs them counted; one period of slack covers
+// a series whose periods do not line up with calendar boundaries.
+func (r *Rule) firstPeriod(start, from time.Time) int {
+	if r.Count > 0 || !from.After(start) {
+		return 0
+	}
+	var periods int
+	switch r.Freq {
+	case Daily:
+		periods = int(from.Sub(start).Hours()/24) / r.Interval
+	case Weekly:
+		periods = int(from.Sub(start).Hours()/24/7) / r.Interval
+	case Monthly:
+		periods = ((from.Year()-start.Year())*12 + int(from.Month()-start.Month())) / r.Interval
+	default: // Yearly
+		periods = (from.Year() - start.Year()) / r.Interval
+	}
+	if periods > 0 {
+		periods--
+	}
+	return periods
+}
+
+// Next returns up to n days on or after from on which the rule fires.
+func (r *Rule) Next(start, from time.Time, n int) []time.Time {
+	var days []time.Time
+	// Look ahead a year at a time; far enough for any supported rule to
+	// fire at least once unless it has ended.
+	for window := 1; len(days) < n && window <= 50; window++ {
+		days = r.Between(start, from, Day(from).AddDate(window, 0, 0))
+	}
+	if len(days) > n {
+		days = days[:n]
+	}
+	return days
+}
+
+// period returns the first day of the period'th period after start and
+// the days in it the rule selects, in order.
+func (r *Rule) period(start time.Time, period int) (time.Time, []time.Time) {
+	switch r.Freq {
+	case Daily:
+		day := start.AddDate(0, 0, period*r.Interval)
+		return day, []time.Time{day}
+
+	case Weekly:
+		monday := start.AddDate(0, 0, -((int(start.Weekday())+6)%7)+7*period*r.Interval)
+		byDay := r.ByDay
+		if len(byDay) == 0 {
+			byDay = []Weekday{{Day: start.Weekday()}}
+		}
+		var days []time.Time
+		for i := 0; i < 7; i++ {
+			day := monday.AddDate(0, 0, i)
+			for _, d := range byDay {
+				if d.Day == day.Weekday() {
+					days = append(days, day)
+					break
+				}
+			}
+		}
+		return monday, r.setPos(days)
+
+	case Monthly:
+		first := time.Date(start.Year(), start.Month()+time.Month(period*r.Interval), 1, 0, 0, 0, 0, time.UTC)
+		return first, r.setPos(r.monthDays(start, first))
+
+	default: // Yearly
+		first := time.Date(start.Year()+period*r.Interval, 1, 1, 0, 0, 0, 0, time.UTC)
+		months := r.ByMonth
+		if len(months) == 0 {
+			months = []time.Month{start.Month()}
+		}
+		var days []time.Time
+		for _, month := range months {
+			days = append(days, r.monthDays(start, time.Date(first.Year(), month, 1, 0, 0, 0, 0, time.UTC))...)
+		}
+		sortDays(days)
+		return first, r.setPos(days)
+	}
+}
+
+// monthDays returns the days of the month starting on first selected by
+// BYMONTHDAY or BYDAY, defaulting to start's day of the month.
+func (r *Rule) monthDays(start, first time.Time) []time.Time {
+	length := first.AddDate(0, 1, -1).Day()
+	var days []time.Time
+	switch {
+	case len(r.ByDay) > 0:
+		for _, d := range r.ByDay {
+			var matches []time.Time
+			for i := 0; i < length; i++ {
+				if day := first.AddDate(0, 0, i); day.Weekday() == d.Day {
+					matches = append(matches, day)
+				}
+			}
+			switch {
+			case d.Nth == 0:
+				days = append(days, matches...)
+			case d.Nth > 0 && d.Nth <= len(matches):
+				days = append(days, matches[d.Nth-1])
+			case d.Nth < 0 && -d.Nth <= len(matches):
+				days = append(days, matches[len(matches)+d.Nth])
+			}
+		}
+	default:
+		monthDays := r.ByMonthDay
+		if len(monthDays) == 0 {
+			monthDays = []int{start.Day()}
+		}
+		for _, n := range monthDays {
+			if n < 0 {
+				n = length + 1 + n
+			}
+			if n < 1 {
+				continue
+			}
+			if n > length {
+				n = length
+			}
+			days = append(days, first.AddDate(0, 0, n-1))
+		}
+	}
+	sortDays(days)
+	return days
+}
+
+// setPos sorts and dedupes days and keeps the BYSETPOS positions, if any.
+func (r *Rule) setPos(days []time.Time) []time.Time {
+	sortDays(days)
+	unique := days[:0]
+	for i, day := range days {
+		if i == 0 || !day.Equal(days[i-1]) {
+			unique = append(unique, day)
+		}
+	}
+	if len(r.BySetPos) == 0 {
+		return unique
+	}
+	var picked []time.Time
+	for _, pos := range r.BySetPos {
+		switch {
+		case pos > 0 && pos <= len(unique):
+			picked = append(picked, unique[pos-1])
+		case pos < 0 && -pos <= len(unique):
+			picked = append(picked, unique[len(unique)+pos])
+		}
+	}
+	sortDays(picked)
+	return picked
+}
+
+func sortDays(days []time.Time) {
+	sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+}
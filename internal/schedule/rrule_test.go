@@ -0,0 +1,177 @@
+package schedule
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func day(s string) time.Time {
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func days(ts []time.Time) []string {
+	out := make([]string, len(ts))
+	for i, t := range ts {
+		out[i] = t.Format("2006-01-02")
+	}
+	return out
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestBetween(t *testing.T) {
+	tests := []struct {
+		name     string
+		rule     string
+		start    string
+		from, to string
+		want     []string
+	}{
+		{
+			name: "every other day", rule: "FREQ=DAILY;INTERVAL=2",
+			start: "2026-01-01", from: "2026-01-01", to: "2026-01-10",
+			want: []string{"2026-01-01", "2026-01-03", "2026-01-05", "2026-01-07", "2026-01-09"},
+		},
+		{
+			name: "weekdays of the week", rule: "FREQ=WEEKLY;BYDAY=MO,FR",
+			start: "2026-01-01", from: "2026-01-01", to: "2026-01-13",
+			want: []string{"2026-01-02", "2026-01-05", "2026-01-09", "2026-01-12"},
+		},
+		{
+			name: "fortnightly on the start weekday", rule: "FREQ=WEEKLY;INTERVAL=2",
+			start: "2026-01-01", from: "2026-01-01", to: "2026-02-01",
+			want: []string{"2026-01-01", "2026-01-15", "2026-01-29"},
+		},
+		{
+			name: "31st falls back to the end of short months", rule: "FREQ=MONTHLY;BYMONTHDAY=31",
+			start: "2026-01-31", from: "2026-01-01", to: "2026-05-01",
+			want: []string{"2026-01-31", "2026-02-28", "2026-03-31", "2026-04-30"},
+		},
+		{
+			name: "last day of the month", rule: "FREQ=MONTHLY;BYMONTHDAY=-1",
+			start: "2028-01-15", from: "2028-01-01", to: "2028-04-01",
+			want: []string{"2028-01-31", "2028-02-29", "2028-03-31"},
+		},
+		{
+			name: "last business day", rule: "FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1",
+			start: "2026-01-01", from: "2026-01-01", to: "2026-06-01",
+			want: []string{"2026-01-30", "2026-02-27", "2026-03-31", "2026-04-30", "2026-05-29"},
+		},
+		{
+			name: "second Friday", rule: "FREQ=MONTHLY;BYDAY=2FR",
+			start: "2026-01-01", from: "2026-01-01", to: "2026-04-01",
+			want: []string{"2026-01-09", "2026-02-13", "2026-03-13"},
+		},
+		{
+			name: "yearly in April", rule: "FREQ=YEARLY;BYMONTH=4;BYMONTHDAY=15",
+			start: "2026-05-01", from: "2026-01-01", to: "2029-01-01",
+			want: []string{"2027-04-15", "2028-04-15"},
+		},
+		{
+			name: "count includes occurrences before from", rule: "FREQ=DAILY;COUNT=3",
+			start: "2026-01-01", from: "2026-01-02", to: "2026-02-01",
+			want: []string{"2026-01-02", "2026-01-03"},
+		},
+		{
+			name: "until is inclusive", rule: "FREQ=WEEKLY;UNTIL=20260115",
+			start: "2026-01-01", from: "2026-01-01", to: "2026-03-01",
+			want: []string{"2026-01-01", "2026-01-08", "2026-01-15"},
+		},
+		{
+			name: "from far into the series", rule: "FREQ=MONTHLY;INTERVAL=5;BYDAY=-1FR",
+			start: "2020-03-10", from: "2026-01-01", to: "2027-01-01",
+			want: []string{"2026-01-30", "2026-06-26", "2026-11-27"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := Parse(tt.rule)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.rule, err)
+			}
+			got := days(rule.Between(day(tt.start), day(tt.from), day(tt.to)))
+			if !equal(got, tt.want) {
+				t.Errorf("Between = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// Starting part way into a series skips the periods before from; it must
+// find the same days as walking the series from its start.
+func TestBetweenFromMatchesFullWalk(t *testing.T) {
+	rules := []string{
+		"FREQ=DAILY;INTERVAL=3",
+		"FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,FR",
+		"FREQ=MONTHLY;BYMONTHDAY=31",
+		"FREQ=MONTHLY;INTERVAL=5;BYDAY=-1FR",
+		"FREQ=YEARLY;INTERVAL=2;BYMONTH=2;BYMONTHDAY=29",
+	}
+	start := day("2020-02-29")
+	to := day("2027-01-01")
+	for _, s := range rules {
+		rule, err := Parse(s)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", s, err)
+		}
+		all := rule.Between(start, start, to)
+		for from := start; from.Before(to); from = from.AddDate(0, 0, 17) {
+			var want []string
+			for _, d := range all {
+				if !d.Before(from) {
+					want = append(want, d.Format("2006-01-02"))
+				}
+			}
+			if got := days(rule.Between(start, from, to)); !equal(got, want) {
+				t.Fatalf("%s from %s = %v, want %v", s, from.Format("2006-01-02"), got, want)
+			}
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	rule, err := Parse("RRULE:FREQ=YEARLY")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := days(rule.Next(day("2024-02-29"), day("2025-01-01"), 2))
+	want := []string{"2025-02-28", "2026-02-28"}
+	if !equal(got, want) {
+		t.Errorf("Next = %v, want %v", got, want)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, s := range []string{
+		"",
+		"FREQ=HOURLY",
+		"FREQ=DAILY;INTERVAL=0",
+		"FREQ=DAILY;BYDAY=MO",
+		"FREQ=WEEKLY;BYDAY=XX",
+		"FREQ=WEEKLY;BYDAY=2MO",
+		"FREQ=MONTHLY;BYMONTH=2",
+		"FREQ=MONTHLY;BYDAY=MO;BYMONTHDAY=1",
+		"FREQ=DAILY;COUNT=2;UNTIL=20260101",
+		"FREQ=DAILY;FREQ=WEEKLY",
+		"FREQ=DAILY;WKST=SU",
+	} {
+		if _, err := Parse(s); !errors.Is(err, ErrRule) {
+			t.Errorf("Parse(%q) error = %v, want %v", s, err, ErrRule)
+		}
+	}
+}
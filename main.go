@@ -1,17 +1,26 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"log"
 	"net/http"
 	"os"
+	"time"
 
 	"github.com/amrohan/expenso-go/api/routes"
+	"github.com/amrohan/expenso-go/internal/db"
+	"github.com/amrohan/expenso-go/internal/handlers"
+	"github.com/amrohan/expenso-go/internal/repository"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
+	"github.com/joho/godotenv"
 )
 
 func main() {
+	godotenv.Load()
+
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "3000"
@@ -19,6 +28,12 @@ func main() {
 
 	r := chi.NewRouter()
 
+	// Only trust X-Forwarded-For and friends when a proxy we control sets them;
+	// the client IP feeds session listings and login throttling.
+	if os.Getenv("TRUST_PROXY_HEADERS") == "true" {
+		r.Use(middleware.RealIP)
+	}
+
 	r.Use(
 		middleware.Logger,
 		middleware.CleanPath,
@@ -32,8 +47,59 @@ func main() {
 		}),
 	)
 
-	routes.LoadRoutes(r)
+	store, err := newStore()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := store.Users.EnsureIndexes(context.Background()); err != nil {
+		log.Fatal(err)
+	}
+
+	config, err := loadConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	h := handlers.New(store, config)
+	if err := h.BootstrapAdmins(context.Background()); err != nil {
+		log.Fatal(err)
+	}
+
+	purgeInterval, err := durationEnv("TRASH_PURGE_INTERVAL")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if purgeInterval <= 0 {
+		purgeInterval = time.Hour
+	}
+	go h.RunTrashPurge(context.Background(), purgeInterval)
+
+	recurringInterval, err := durationEnv("RECURRING_INTERVAL")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if recurringInterval <= 0 {
+		recurringInterval = 15 * time.Minute
+	}
+	go h.RunRecurring(context.Background(), recurringInterval)
+
+	routes.LoadRoutes(r, h)
 
 	fmt.Println("Server is running on port " + port)
 	http.ListenAndServe(":"+port, r)
 }
+
+// newStore picks the storage backend. Setting STORE=memory runs the whole API
+// without MongoDB, which is handy for local demos.
+func newStore() (*repository.Store, error) {
+	if os.Getenv("STORE") == "memory" {
+		log.Println("Using in-memory store, data will not be persisted")
+		return repository.NewMemoryStore(), nil
+	}
+
+	client, err := db.GetMongoClient()
+	if err != nil {
+		return nil, err
+	}
+	return repository.NewMongoStore(client), nil
+}